@@ -15,7 +15,7 @@ export function playTrack(track: UserLibraryTrack) {
 	currentTrack.set({
 		id: track.id,
 		title: track.title,
-		artist: track.artist.Valid ? track.artist.String : 'Artista Desconocido'
+		artist: track.artist ?? 'Artista Desconocido'
 	});
 	isPlaying.set(true);
 }
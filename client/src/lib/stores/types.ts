@@ -22,8 +22,17 @@ export interface Track {
 export interface UserLibraryTrack {
 	id: number;
 	title: string;
-	duration: { Int64: number; Valid: boolean };
-	artist: { String: string; Valid: boolean };
-	album: { String: string; Valid: boolean };
-	album_art_path: { String: string; Valid: boolean };
+	duration?: number;
+	artist?: string;
+	album?: string;
+	album_art_path?: string;
+	added_at: string;
+}
+
+export interface TrackPage {
+	tracks: UserLibraryTrack[];
+	sort: string;
+	order: 'asc' | 'desc';
+	limit: number;
+	next_cursor?: string;
 }
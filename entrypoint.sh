@@ -31,6 +31,25 @@ else
     echo "Advertencia: QOBUZ_APP_ID no está configurado"
 fi
 
+# Credenciales opcionales de las demás fuentes (tidal, deezer, soundcloud).
+# set_key <clave> <variable>: pone el valor de la variable en la clave, si está definida
+set_key() {
+    value=$(eval echo "\$$2")
+    if [ -n "$value" ]; then
+        echo "Configurando $1 desde $2..."
+        sed -i "s|^$1 = \".*\"|$1 = \"$value\"|" "$CONFIG_FILE"
+    fi
+}
+
+set_key arl DEEZER_ARL
+set_key user_id TIDAL_USER_ID
+set_key country_code TIDAL_COUNTRY_CODE
+set_key access_token TIDAL_ACCESS_TOKEN
+set_key refresh_token TIDAL_REFRESH_TOKEN
+set_key token_expiry TIDAL_TOKEN_EXPIRY
+set_key client_id SOUNDCLOUD_CLIENT_ID
+set_key app_version SOUNDCLOUD_APP_VERSION
+
 echo "Configuración completada. Iniciando sancho..."
 
 # Ejecutar el comando principal (sancho) con todos los argumentos pasados
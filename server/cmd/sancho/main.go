@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
@@ -36,7 +38,7 @@ func main() {
 	// The goroutines implementation handle concurrency
 	// for the connection behind the scenes
 
-	conn, err := sql.Open("sqlite3", dbPath)
+	conn, err := sql.Open("sqlite3", "file:"+dbPath+"?_fk=1")
 	if err != nil {
 		log.Fatalf("Error abriendo la base de datos: %v", err)
 	}
@@ -47,16 +49,35 @@ func main() {
 	queries := db.New(conn)
 
 	// Inicializar servicios
-	fileMangerService := service.NewFileManager(conn, queries)
-	indexerService := service.NewIndexer(queries, fileMangerService)
+	webhookDispatcher := service.NewWebhookDispatcher(queries)
+	fileMangerService := service.NewFileManager(conn, queries, webhookDispatcher)
+	indexerService := service.NewIndexer(conn, queries, fileMangerService, webhookDispatcher)
 	proxyHandler := controller.NewProxyCORSHandler()
-	streamripService := service.NewStreamrip(indexerService, fileMangerService, queries)
-	thumbnailService := service.NewThumbnailService(queries)
+	streamripService := service.NewStreamrip(indexerService, fileMangerService, service.NewRipDownloader(), queries, webhookDispatcher)
+	thumbnailService := service.NewThumbnailService(queries, webhookDispatcher)
+	playlistImporter := service.NewPlaylistImporter(queries, streamripService)
+	releaseWatcher := service.NewReleaseWatcher(queries, streamripService)
+	librarySearch := service.NewLibrarySearch(conn, queries)
+
+	// Pick up the downloads that were running when the server stopped
+	if err := streamripService.ResumeInterrupted(context.Background()); err != nil {
+		log.Printf("Error resuming interrupted downloads: %v", err)
+	}
+	releaseWatcher.Start()
+	if err := librarySearch.Setup(context.Background()); err != nil {
+		log.Printf("Library search disabled: %v", err)
+	}
+	if err := webhookDispatcher.ResumePending(context.Background()); err != nil {
+		log.Printf("Error resuming webhook deliveries: %v", err)
+	}
 
 	// Inicializar handlers
 	downloadHandler := controller.NewMusicHandler(streamripService, indexerService, fileMangerService)
-	libraryHandler := controller.NewLibraryHandler(queries, indexerService, fileMangerService, thumbnailService)
+	libraryHandler := controller.NewLibraryHandler(queries, indexerService, fileMangerService, thumbnailService, librarySearch)
 	userHandler := controller.NewUserHandler(queries)
+	playlistHandler := controller.NewPlaylistHandler(playlistImporter)
+	followHandler := controller.NewFollowHandler(releaseWatcher)
+	webhookHandler := controller.NewWebhookHandler(webhookDispatcher)
 
 	// Configurar router
 	router := gin.Default()
@@ -64,7 +85,7 @@ func main() {
 	// Serve library files for album art
 	router.Static("/library", config.LibraryPath)
 
-	api.RegisterRoutes(router, proxyHandler, downloadHandler, libraryHandler, userHandler)
+	api.RegisterRoutes(router, proxyHandler, downloadHandler, libraryHandler, userHandler, playlistHandler, followHandler, webhookHandler)
 	// ------------------------------------------
 
 	// ------------- FRONTEND -------------------
@@ -116,5 +137,42 @@ func runMigrations(dbPath string) error {
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		return err
 	}
+	return runSearchMigrations(conn)
+}
+
+// runSearchMigrations crea el índice de la búsqueda en la librería. Usa
+// FTS5, que solo está si se compila con la etiqueta sqlite_fts5, por eso
+// sus migraciones van aparte, con su propia tabla de versiones.
+func runSearchMigrations(conn *sql.DB) error {
+	available, err := service.LibrarySearchAvailable(context.Background(), conn)
+	if err != nil {
+		return err
+	}
+	if !available {
+		// Los triggers del índice harían fallar cualquier cambio de la librería
+		var triggers int
+		err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'track_search_%'`).Scan(&triggers)
+		if err != nil {
+			return err
+		}
+		if triggers > 0 {
+			return errors.New("la base de datos tiene el índice de búsqueda, que necesita FTS5: compila con -tags sqlite_fts5")
+		}
+		return nil
+	}
+
+	driver, err := sqlite.WithInstance(conn, &sqlite.Config{MigrationsTable: "search_schema_migrations"})
+	if err != nil {
+		return err
+	}
+	m, err := migrate.NewWithDatabaseInstance(
+		"file://migrations/search",
+		"sqlite3", driver)
+	if err != nil {
+		return err
+	}
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
 	return nil
 }
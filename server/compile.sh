@@ -8,7 +8,9 @@ export SANCHO_PATH="$REPO_ROOT/test/test_env/"
 export CGO_ENABLED=1
 export FRONTEND_PATH="../client/build"
 
-if go build -o ./bin ./cmd/sancho; then
+# sqlite_fts5 incluye FTS5 en SQLite, lo usa la búsqueda en la librería.
+# Sin él el servidor no arranca con una base que ya tiene el índice.
+if go build -tags sqlite_fts5 -o ./bin ./cmd/sancho; then
   echo "Build exitoso."
 else
   echo "Error al compilar."
@@ -0,0 +1,58 @@
+-- name: UpsertArtistFollow :one
+INSERT INTO artist_follow (
+  user_id, deezer_artist_id, artist_name, release_types, auto_download, quality
+) VALUES (
+  sqlc.arg('user_id'), sqlc.arg('deezer_artist_id'), sqlc.arg('artist_name'),
+  sqlc.arg('release_types'), sqlc.arg('auto_download'), sqlc.arg('quality')
+)
+ON CONFLICT (user_id, deezer_artist_id) DO UPDATE SET
+  artist_name = excluded.artist_name, release_types = excluded.release_types,
+  auto_download = excluded.auto_download, quality = excluded.quality
+RETURNING *;
+
+-- name: DeleteArtistFollow :execrows
+DELETE FROM artist_follow
+WHERE user_id = sqlc.arg('user_id') AND deezer_artist_id = sqlc.arg('deezer_artist_id');
+
+-- name: ListUserArtistFollows :many
+SELECT * FROM artist_follow
+WHERE user_id = sqlc.arg('user_id')
+ORDER BY artist_name, id;
+
+-- name: ListArtistFollows :many
+SELECT sqlc.embed(af), u.username FROM artist_follow AS af
+JOIN user AS u ON af.user_id = u.id
+ORDER BY af.deezer_artist_id, af.id;
+
+-- name: UpdateArtistFollowChecked :exec
+UPDATE artist_follow
+SET last_checked_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id');
+
+-- name: InsertReleaseNotification :one
+INSERT INTO release_notification (
+  follow_id, deezer_album_id, title, release_type, release_date, cover_url
+) VALUES (
+  sqlc.arg('follow_id'), sqlc.arg('deezer_album_id'), sqlc.arg('title'),
+  sqlc.arg('release_type'), sqlc.arg('release_date'), sqlc.arg('cover_url')
+)
+ON CONFLICT (follow_id, deezer_album_id) DO NOTHING
+RETURNING *;
+
+-- name: SetReleaseNotificationDownload :exec
+UPDATE release_notification
+SET download_id = sqlc.arg('download_id')
+WHERE id = sqlc.arg('id');
+
+-- name: ListReleaseNotifications :many
+SELECT sqlc.embed(rn), af.deezer_artist_id, af.artist_name FROM release_notification AS rn
+JOIN artist_follow AS af ON rn.follow_id = af.id
+WHERE af.user_id = sqlc.arg('user_id') AND (NOT sqlc.arg('unread_only') OR rn.read_at IS NULL)
+ORDER BY rn.created_at DESC, rn.id DESC;
+
+-- name: MarkReleaseNotificationsRead :execrows
+UPDATE release_notification
+SET read_at = CURRENT_TIMESTAMP
+WHERE read_at IS NULL
+  AND follow_id IN (SELECT id FROM artist_follow WHERE user_id = sqlc.arg('user_id'))
+  AND (sqlc.narg('id') IS NULL OR id = sqlc.narg('id'));
@@ -0,0 +1,31 @@
+-- name: InsertDownloadBatch :one
+INSERT INTO download_batch (
+  id, user_id, source, fallback, quality
+) VALUES (
+  sqlc.arg('id'), sqlc.arg('user_id'), sqlc.arg('source'), sqlc.narg('fallback'), sqlc.arg('quality')
+)
+RETURNING *;
+
+-- name: GetDownloadBatch :one
+SELECT sqlc.embed(db), u.username FROM download_batch AS db
+JOIN user AS u ON db.user_id = u.id
+WHERE db.id = sqlc.arg('id');
+
+-- name: InsertDownloadBatchItem :one
+INSERT INTO download_batch_item (
+  batch_id, position, source_id, isrc, action, download_id, request_id, error_message
+) VALUES (
+  sqlc.arg('batch_id'), sqlc.arg('position'), sqlc.narg('source_id'), sqlc.arg('isrc'),
+  sqlc.narg('action'), sqlc.narg('download_id'), sqlc.narg('request_id'), sqlc.narg('error_message')
+)
+RETURNING *;
+
+-- name: ListDownloadBatchItems :many
+SELECT sqlc.embed(i),
+  COALESCE(i.download_id, dr.download_id) AS job_id, dh.status AS job_status,
+  dh.error_message AS job_error, dh.track_id, dr.status AS request_status, dr.reason AS request_reason
+FROM download_batch_item AS i
+LEFT JOIN download_request AS dr ON dr.id = i.request_id
+LEFT JOIN download_history AS dh ON dh.id = COALESCE(i.download_id, dr.download_id)
+WHERE i.batch_id = sqlc.arg('batch_id')
+ORDER BY i.position;
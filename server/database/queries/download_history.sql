@@ -1,15 +1,112 @@
 -- name: InsertDownloadHistory :one
 INSERT INTO download_history (
-  id, user_id, track_id, quality, 
-  status, service, completed_at, error_message
+  id, user_id, track_id, quality,
+  status, service, completed_at, error_message,
+  source_track_id, isrc, media_type, parent_id,
+  fallback_service, requested_quality, upgrade, linked
 ) VALUES (
   sqlc.arg('id'), sqlc.arg('user_id'), sqlc.arg('track_id'),
-  sqlc.arg('quality'), sqlc.arg('status'), sqlc.arg('service'), 
-  sqlc.arg('completed_at'), sqlc.arg('error_message')
+  sqlc.arg('quality'), sqlc.arg('status'), sqlc.arg('service'),
+  sqlc.arg('completed_at'), sqlc.arg('error_message'),
+  sqlc.arg('source_track_id'), sqlc.arg('isrc'),
+  sqlc.arg('media_type'), sqlc.arg('parent_id'),
+  sqlc.arg('fallback_service'), sqlc.arg('requested_quality'),
+  sqlc.arg('upgrade'), sqlc.arg('linked')
 )
 RETURNING *;
 
 -- name: UpdateDownloadCompletion :exec
 UPDATE download_history
-SET completed_at = CURRENT_TIMESTAMP, status = sqlc.arg('status'), error_message = sqlc.arg('error_message')
+SET completed_at = CURRENT_TIMESTAMP, status = sqlc.arg('status'), error_message = sqlc.arg('error_message'),
+  track_id = COALESCE(sqlc.narg('track_id'), track_id), quality = COALESCE(sqlc.narg('quality'), quality),
+  next_attempt_at = NULL
 WHERE id = sqlc.arg('id');
+
+-- name: UpdateDownloadRetry :exec
+UPDATE download_history
+SET status = 'queued', attempts = sqlc.arg('attempts'), error_message = sqlc.arg('error_message'),
+  next_attempt_at = sqlc.arg('next_attempt_at')
+WHERE id = sqlc.arg('id');
+
+-- name: RequeueFailedDownload :execrows
+UPDATE download_history
+SET status = 'queued', attempts = 1, error_message = NULL, completed_at = NULL, next_attempt_at = NULL
+WHERE id = sqlc.arg('id') AND status = 'failed';
+
+-- name: DeleteAlbumDownloadTracks :exec
+DELETE FROM download_history
+WHERE parent_id = sqlc.arg('parent_id');
+
+-- name: UpdateDownloadStatus :exec
+UPDATE download_history
+SET status = sqlc.arg('status')
+WHERE id = sqlc.arg('id');
+
+-- name: UpdateDownloadSource :exec
+UPDATE download_history
+SET service = sqlc.arg('service'), source_track_id = sqlc.arg('source_track_id')
+WHERE id = sqlc.arg('id');
+
+-- name: GetDownloadJobByID :one
+SELECT sqlc.embed(dh), u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.id = sqlc.arg('id')
+LIMIT 1;
+
+-- name: ListActiveDownloads :many
+SELECT sqlc.embed(dh), u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.status IN ('queued', 'downloading', 'indexing')
+  AND (sqlc.narg('username') IS NULL OR u.username = sqlc.narg('username'))
+ORDER BY dh.started_at;
+
+-- name: ListAlbumDownloadTracks :many
+SELECT sqlc.embed(dh), u.username, t.title AS track_title FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+LEFT JOIN track AS t ON dh.track_id = t.id
+WHERE dh.parent_id = sqlc.arg('parent_id')
+ORDER BY t.disc_number, t.track_number, dh.started_at;
+
+-- name: DetachDownloadsFromTrack :exec
+UPDATE download_history
+SET track_id = NULL
+WHERE track_id = sqlc.arg('track_id');
+
+-- name: ListFailedDownloads :many
+SELECT sqlc.embed(dh), u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.status = 'failed'
+  AND (sqlc.narg('username') IS NULL OR u.username = sqlc.narg('username'))
+ORDER BY dh.started_at;
+
+-- name: ListDownloadHistory :many
+SELECT sqlc.embed(dh), u.username, t.title AS track_title, al.title AS album_title, ar.name AS artist_name FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+LEFT JOIN track AS t ON dh.track_id = t.id
+LEFT JOIN album AS al ON t.album_id = al.id
+LEFT JOIN artist AS ar ON t.artist_id = ar.id
+WHERE (sqlc.narg('username') IS NULL OR u.username = sqlc.narg('username'))
+  AND (sqlc.narg('status') IS NULL OR dh.status = sqlc.narg('status'))
+  AND (sqlc.narg('service') IS NULL OR dh.service = sqlc.narg('service'))
+  AND (sqlc.narg('quality') IS NULL OR dh.quality = sqlc.narg('quality'))
+  AND (sqlc.narg('started_after') IS NULL OR datetime(dh.started_at) >= datetime(sqlc.narg('started_after')))
+  AND (sqlc.narg('started_before') IS NULL OR datetime(dh.started_at) < datetime(sqlc.narg('started_before')))
+ORDER BY dh.started_at DESC, dh.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountDownloadHistory :one
+SELECT COUNT(*) FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE (sqlc.narg('username') IS NULL OR u.username = sqlc.narg('username'))
+  AND (sqlc.narg('status') IS NULL OR dh.status = sqlc.narg('status'))
+  AND (sqlc.narg('service') IS NULL OR dh.service = sqlc.narg('service'))
+  AND (sqlc.narg('quality') IS NULL OR dh.quality = sqlc.narg('quality'))
+  AND (sqlc.narg('started_after') IS NULL OR datetime(dh.started_at) >= datetime(sqlc.narg('started_after')))
+  AND (sqlc.narg('started_before') IS NULL OR datetime(dh.started_at) < datetime(sqlc.narg('started_before')));
+
+-- name: HasUpgradeToQuality :one
+SELECT EXISTS (
+  SELECT 1 FROM download_history
+  WHERE upgrade AND isrc = sqlc.arg('isrc') AND requested_quality >= sqlc.arg('requested_quality')
+    AND status IN ('queued', 'downloading', 'indexing', 'success', 'skipped')
+);
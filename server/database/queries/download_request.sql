@@ -0,0 +1,42 @@
+-- name: InsertDownloadRequest :one
+INSERT INTO download_request (
+  id, user_id, media_type, source, fallback, source_id, isrc, quality
+) VALUES (
+  sqlc.arg('id'), sqlc.arg('user_id'), sqlc.arg('media_type'), sqlc.arg('source'),
+  sqlc.arg('fallback'), sqlc.arg('source_id'), sqlc.arg('isrc'), sqlc.arg('quality')
+)
+RETURNING *;
+
+-- name: GetPendingDownloadRequest :one
+SELECT * FROM download_request
+WHERE user_id = sqlc.arg('user_id') AND media_type = sqlc.arg('media_type')
+  AND source = sqlc.arg('source') AND source_id = sqlc.arg('source_id')
+  AND status = 'pending';
+
+-- name: GetDownloadRequest :one
+SELECT sqlc.embed(dr), u.username FROM download_request AS dr
+JOIN user AS u ON dr.user_id = u.id
+WHERE dr.id = sqlc.arg('id');
+
+-- name: ListDownloadRequests :many
+SELECT sqlc.embed(dr), u.username FROM download_request AS dr
+JOIN user AS u ON dr.user_id = u.id
+WHERE (sqlc.narg('username') IS NULL OR u.username = sqlc.narg('username'))
+  AND (sqlc.narg('status') IS NULL OR dr.status = sqlc.narg('status'))
+ORDER BY dr.created_at, dr.id;
+
+-- name: ReviewDownloadRequest :execrows
+UPDATE download_request
+SET status = sqlc.arg('status'), reason = sqlc.narg('reason'),
+  reviewed_by = sqlc.narg('reviewed_by'), reviewed_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg('id') AND status = 'pending';
+
+-- name: ReopenDownloadRequest :exec
+UPDATE download_request
+SET status = 'pending', reason = NULL, reviewed_by = NULL, reviewed_at = NULL
+WHERE id = sqlc.arg('id');
+
+-- name: SetDownloadRequestDownload :exec
+UPDATE download_request
+SET download_id = sqlc.arg('download_id')
+WHERE id = sqlc.arg('id');
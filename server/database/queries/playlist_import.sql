@@ -0,0 +1,51 @@
+-- name: InsertPlaylistImport :one
+INSERT INTO playlist_import (id, user_id, name, format, quality, status)
+VALUES (
+  sqlc.arg('id'), sqlc.arg('user_id'), sqlc.arg('name'),
+  sqlc.arg('format'), sqlc.arg('quality'), sqlc.arg('status')
+)
+RETURNING *;
+
+-- name: GetPlaylistImportByID :one
+SELECT sqlc.embed(pi), u.username FROM playlist_import AS pi
+JOIN user AS u ON pi.user_id = u.id
+WHERE pi.id = sqlc.arg('id')
+LIMIT 1;
+
+-- name: ListPlaylistImportsByUsername :many
+SELECT pi.* FROM playlist_import AS pi
+JOIN user AS u ON pi.user_id = u.id
+WHERE u.username = sqlc.arg('username')
+ORDER BY pi.created_at DESC;
+
+-- name: UpdatePlaylistImportStatus :exec
+UPDATE playlist_import
+SET status = sqlc.arg('status')
+WHERE id = sqlc.arg('id');
+
+-- name: InsertPlaylistImportEntry :one
+INSERT INTO playlist_import_entry (
+  import_id, position, title, artist, album, isrc,
+  result, track_id, download_id, candidates, error_message
+) VALUES (
+  sqlc.arg('import_id'), sqlc.arg('position'), sqlc.arg('title'),
+  sqlc.arg('artist'), sqlc.arg('album'), sqlc.arg('isrc'),
+  sqlc.arg('result'), sqlc.arg('track_id'), sqlc.arg('download_id'),
+  sqlc.arg('candidates'), sqlc.arg('error_message')
+)
+RETURNING *;
+
+-- name: ListPlaylistImportEntries :many
+SELECT * FROM playlist_import_entry
+WHERE import_id = sqlc.arg('import_id')
+ORDER BY position;
+
+-- name: GetPlaylistImportEntry :one
+SELECT * FROM playlist_import_entry
+WHERE id = sqlc.arg('id') AND import_id = sqlc.arg('import_id')
+LIMIT 1;
+
+-- name: UpdatePlaylistImportEntryResult :exec
+UPDATE playlist_import_entry
+SET result = sqlc.arg('result'), track_id = sqlc.arg('track_id'), download_id = sqlc.arg('download_id'), error_message = sqlc.arg('error_message')
+WHERE id = sqlc.arg('id');
@@ -0,0 +1,33 @@
+-- name: GetUserQuota :one
+SELECT * FROM user_quota
+WHERE user_id = sqlc.arg('user_id');
+
+-- name: UpsertUserQuota :one
+INSERT INTO user_quota (
+  user_id, daily_downloads, weekly_downloads, storage_bytes
+) VALUES (
+  sqlc.arg('user_id'), sqlc.narg('daily_downloads'), sqlc.narg('weekly_downloads'), sqlc.narg('storage_bytes')
+)
+ON CONFLICT (user_id) DO UPDATE SET
+  daily_downloads = excluded.daily_downloads, weekly_downloads = excluded.weekly_downloads,
+  storage_bytes = excluded.storage_bytes, updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: CountUserDownloadsSince :one
+SELECT COUNT(*) FROM download_history
+WHERE user_id = sqlc.arg('user_id') AND started_at >= sqlc.arg('since')
+  AND parent_id IS NULL AND linked = 0
+  AND status IN ('queued', 'downloading', 'indexing', 'success', 'skipped');
+
+-- name: GetUserStorageUsed :one
+SELECT CAST(COALESCE(SUM(t.file_size), 0) AS INTEGER) AS used_bytes
+FROM download_history AS dh
+JOIN track AS t ON dh.track_id = t.id
+WHERE dh.user_id = sqlc.arg('user_id') AND dh.status IN ('success', 'transfered')
+  AND dh.linked = 0 AND dh.upgrade = 0
+  AND NOT EXISTS (
+    SELECT 1 FROM download_history AS earlier
+    WHERE earlier.track_id = dh.track_id AND earlier.status IN ('success', 'transfered')
+      AND (earlier.started_at < dh.started_at
+        OR (earlier.started_at = dh.started_at AND earlier.rowid < dh.rowid))
+  );
@@ -0,0 +1,22 @@
+-- name: UpsertSavedQuery :one
+INSERT INTO saved_query (
+  user_id, name, query
+) VALUES (
+  sqlc.arg('user_id'), sqlc.arg('name'), sqlc.arg('query')
+)
+ON CONFLICT (user_id, name) DO UPDATE SET
+  query = excluded.query, updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetSavedQuery :one
+SELECT * FROM saved_query
+WHERE user_id = sqlc.arg('user_id') AND name = sqlc.arg('name');
+
+-- name: ListSavedQueries :many
+SELECT * FROM saved_query
+WHERE user_id = sqlc.arg('user_id')
+ORDER BY name, id;
+
+-- name: DeleteSavedQuery :execrows
+DELETE FROM saved_query
+WHERE user_id = sqlc.arg('user_id') AND name = sqlc.arg('name');
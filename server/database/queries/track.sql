@@ -56,4 +56,32 @@ WHERE id = sqlc.arg('id');
 -- name: GetFirstTrackByAlbumID :one
 SELECT * FROM track
 WHERE album_id = sqlc.arg('album_id')
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: FindTracksByNormalizedTitleAndArtist :many
+SELECT track.* FROM track
+JOIN artist ON track.artist_id = artist.id
+WHERE track.normalized_title = sqlc.arg('normalized_title')
+  AND artist.normalized_name = sqlc.arg('normalized_name')
+ORDER BY track.created_at;
+
+-- name: ListTracksUnderPath :many
+SELECT * FROM track
+WHERE substr(file_path, 1, length(sqlc.arg('prefix'))) = sqlc.arg('prefix')
+ORDER BY id;
+
+-- name: UpdateTrackFile :exec
+UPDATE track
+SET file_path = sqlc.arg('file_path'), duration = sqlc.arg('duration'), sample_rate = sqlc.arg('sample_rate'), bitrate = sqlc.arg('bitrate'),
+  channels = sqlc.arg('channels'), file_size = sqlc.arg('file_size')
+WHERE id = sqlc.arg('track_id');
+
+-- name: ListTracksByISRCs :many
+SELECT sqlc.embed(t),
+  EXISTS (
+    SELECT 1 FROM user_track AS ut
+    JOIN user AS u ON ut.user_id = u.id
+    WHERE ut.track_id = t.id AND u.username = sqlc.arg('username')
+  ) AS owned
+FROM track AS t
+WHERE t.isrc IN (SELECT value FROM json_each(sqlc.arg('isrcs')));
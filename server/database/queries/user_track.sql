@@ -37,4 +37,15 @@ JOIN "user" AS u ON ut.user_id = u.id
 LEFT JOIN artist AS art ON t.artist_id = art.id
 LEFT JOIN album AS alb ON t.album_id = alb.id
 WHERE u.username = ?
-ORDER BY art.name, alb.title, t.track_number;
\ No newline at end of file
+ORDER BY art.name, alb.title, t.track_number;
+
+-- name: ListTrackUsers :many
+SELECT sqlc.embed(ut), u.username FROM user_track AS ut
+JOIN user AS u ON ut.user_id = u.id
+WHERE ut.track_id = sqlc.arg('track_id')
+ORDER BY u.username;
+
+-- name: UpdateUserTrackSymlink :exec
+UPDATE user_track
+SET symlink_path = sqlc.arg('symlink_path')
+WHERE user_id = sqlc.arg('user_id') AND track_id = sqlc.arg('track_id');
@@ -0,0 +1,51 @@
+-- name: InsertWebhook :one
+INSERT INTO webhook (
+  url, secret, events
+) VALUES (
+  sqlc.arg('url'), sqlc.arg('secret'), sqlc.arg('events')
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhook
+WHERE id = sqlc.arg('id');
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_delivery
+WHERE id = sqlc.arg('id');
+
+-- name: ListWebhooks :many
+SELECT * FROM webhook
+ORDER BY id;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhook
+WHERE id = sqlc.arg('id');
+
+-- name: InsertWebhookDelivery :one
+INSERT INTO webhook_delivery (
+  webhook_id, event_id, event_type, payload
+) VALUES (
+  sqlc.arg('webhook_id'), sqlc.arg('event_id'), sqlc.arg('event_type'), sqlc.arg('payload')
+)
+RETURNING *;
+
+-- name: UpdateWebhookDelivery :one
+UPDATE webhook_delivery
+SET status = sqlc.arg('status'), attempts = sqlc.arg('attempts'),
+  response_status = sqlc.narg('response_status'), error_message = sqlc.narg('error_message'),
+  completed_at = CASE WHEN sqlc.arg('status') = 'pending' THEN NULL ELSE CURRENT_TIMESTAMP END
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_delivery
+WHERE webhook_id = sqlc.arg('webhook_id')
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListPendingWebhookDeliveries :many
+SELECT sqlc.embed(wd), sqlc.embed(w) FROM webhook_delivery AS wd
+JOIN webhook AS w ON wd.webhook_id = w.id
+WHERE wd.status = 'pending'
+ORDER BY wd.id;
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	mdw "github.com/alejandro-bustamante/sancho/server/internal/api/middleware"
+	"github.com/alejandro-bustamante/sancho/server/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+type FollowArtistRequest struct {
+	DeezerID string `json:"deezer_id" binding:"required"`
+	// album, ep, single, live or compilation. Empty means album, ep and single
+	ReleaseTypes []string `json:"release_types"`
+	AutoDownload bool     `json:"auto_download"`
+	// Quality of the downloads of new releases
+	Quality int64 `json:"quality"`
+}
+
+type MarkNotificationsReadRequest struct {
+	// Notification to mark, all of them when empty
+	ID int64 `json:"id"`
+}
+
+type FollowHandler struct {
+	watcher ReleaseWatcher
+}
+
+func NewFollowHandler(w ReleaseWatcher) *FollowHandler {
+	return &FollowHandler{
+		watcher: w,
+	}
+}
+
+// Follows an artist, or changes the options of one already followed
+func (h *FollowHandler) FollowArtist(c *gin.Context) {
+	var req FollowArtistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	// Auto downloads go through the approval of the follower
+	if !mdw.RequireUser(c, c.Param("username")) {
+		return
+	}
+	opts := model.FollowOptions{
+		AutoDownload: req.AutoDownload,
+		Quality:      model.Quality(req.Quality),
+	}
+	for _, t := range req.ReleaseTypes {
+		opts.ReleaseTypes = append(opts.ReleaseTypes, model.ReleaseType(t))
+	}
+
+	follow, err := h.watcher.Follow(c.Request.Context(), c.Param("username"), req.DeezerID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidReleaseType):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid release type", "details": err.Error()})
+		case errors.Is(err, model.ErrInvalidQuality):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quality", "details": err.Error()})
+		case errors.Is(err, model.ErrArtistNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Artist not found", "details": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow the artist", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, follow)
+}
+
+func (h *FollowHandler) UnfollowArtist(c *gin.Context) {
+	err := h.watcher.Unfollow(c.Request.Context(), c.Param("username"), c.Param("deezerId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrFollowNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "The user doesn't follow the artist"})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow the artist", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Artist unfollowed"})
+}
+
+func (h *FollowHandler) ListFollowedArtists(c *gin.Context) {
+	follows, err := h.watcher.ListFollows(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the followed artists", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"follows": follows})
+}
+
+// Lists the new releases of the followed artists, only the unread ones
+// with ?unread=true
+func (h *FollowHandler) ListReleaseNotifications(c *gin.Context) {
+	unread, _ := strconv.ParseBool(c.Query("unread"))
+	notifications, err := h.watcher.ListNotifications(c.Request.Context(), c.Param("username"), unread)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the notifications", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+func (h *FollowHandler) MarkReleaseNotificationsRead(c *gin.Context) {
+	var req MarkNotificationsReadRequest
+	// The body is optional
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+	}
+	marked, err := h.watcher.MarkNotificationsRead(c.Request.Context(), c.Param("username"), req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark the notifications as read", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
+
+// Checks for new releases now instead of waiting for the next scheduled check
+func (h *FollowHandler) CheckReleases(c *gin.Context) {
+	added, err := h.watcher.CheckReleases(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for new releases", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"new_releases": added})
+}
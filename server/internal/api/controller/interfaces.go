@@ -2,18 +2,60 @@ package controller
 
 import (
 	"context"
+	"io"
 	"os"
 
 	"github.com/alejandro-bustamante/sancho/server/internal/model"
 )
 
 type Streamrip interface {
-	EnsureTrackForUser(ctx context.Context, songID, user, isrc string, quality int64) (*model.DownloadResult, error)
+	EnsureTrackForUser(ctx context.Context, source, fallback model.Source, songID, user, isrc string, quality model.Quality) (*model.DownloadResult, error)
+	EnsureAlbumForUser(ctx context.Context, source model.Source, albumID, user string, quality model.Quality) (*model.DownloadResult, error)
 	SearchSong(source, mediaType, query string) ([]model.StreamripSearchResult, error)
-	GetDownloadStatus(downloadID string) (model.DownloadStatus, string)
+	AnnotateTrackPreviews(ctx context.Context, user string, previews []model.TrackPreview) error
+	GetDownloadStatus(downloadID string) (model.DownloadJob, error)
+	ListDownloads(user string) ([]model.DownloadJob, error)
+	CancelDownload(downloadID, user string) error
+	RepairDownloads(ctx context.Context, user string) (model.RepairResult, error)
+	CreateDownloadBatch(ctx context.Context, user string, source, fallback model.Source, quality model.Quality, tracks []model.DownloadBatchTrack) (model.DownloadBatch, error)
+	GetDownloadBatch(ctx context.Context, id string) (model.DownloadBatch, error)
+	PreviewDiscography(ctx context.Context, user, artistID string, source model.Source, filters model.DiscographyFilters) (model.Discography, error)
+	DownloadDiscography(ctx context.Context, user, artistID string, source model.Source, quality model.Quality, filters model.DiscographyFilters, albumIDs []string) (model.DiscographyDownload, error)
+	DownloadHistory(ctx context.Context, filter model.DownloadHistoryFilter) (model.DownloadHistoryPage, error)
+	ListDownloadRequests(ctx context.Context, filter model.DownloadRequestFilter) ([]model.DownloadRequest, error)
+	ApproveDownloadRequest(ctx context.Context, id, admin string) (model.DownloadRequest, error)
+	RejectDownloadRequest(ctx context.Context, id, admin, reason string) (model.DownloadRequest, error)
+	CancelDownloadRequest(ctx context.Context, id, user string) (model.DownloadRequest, error)
+	UserQuota(ctx context.Context, user string) (model.UserQuota, error)
+	SetUserQuota(ctx context.Context, user string, update model.QuotaUpdate) (model.UserQuota, error)
+	SubscribeDownloadEvents(user string) (events <-chan model.DownloadEvent, unsubscribe func())
 	GetDeezerTrackSample(isrc string) (sampleUrl string, err error)
 }
 
+type PlaylistImporter interface {
+	Import(ctx context.Context, user, name, filename string, quality int64, r io.Reader) (model.PlaylistImport, error)
+	GetImport(ctx context.Context, id string) (model.PlaylistImport, error)
+	ListImports(ctx context.Context, user string) ([]model.PlaylistImport, error)
+	ResolveEntry(ctx context.Context, importID string, entryID int64, trackID string) (model.PlaylistImportEntry, error)
+}
+
+type ReleaseWatcher interface {
+	Follow(ctx context.Context, user, deezerID string, opts model.FollowOptions) (model.ArtistFollow, error)
+	Unfollow(ctx context.Context, user, deezerID string) error
+	ListFollows(ctx context.Context, user string) ([]model.ArtistFollow, error)
+	ListNotifications(ctx context.Context, user string, unreadOnly bool) ([]model.ReleaseNotification, error)
+	MarkNotificationsRead(ctx context.Context, user string, id int64) (int64, error)
+	CheckReleases(ctx context.Context) (newReleases int, err error)
+}
+
+type WebhookDispatcher interface {
+	CreateWebhook(ctx context.Context, url string, events []model.WebhookEventType, secret string) (model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, id int64) ([]model.WebhookDelivery, error)
+	SendTest(ctx context.Context, id int64) (model.WebhookDelivery, error)
+}
+
 type Indexer interface {
 	// IndexFolder(ctx context.Context, rootDir, user string) error
 	IndexFolder(ctx context.Context, rootDir, user, service string, quality int) error
@@ -25,6 +67,15 @@ type FileManager interface {
 	DeleteTrackForUser(ctx context.Context, username string, trackID int64) error
 }
 
+type LibrarySearch interface {
+	Search(ctx context.Context, query model.LibrarySearchQuery) (model.LibrarySearchPage, error)
+	ListTracks(ctx context.Context, query model.TrackListQuery) (model.TrackPage, error)
+	SaveQuery(ctx context.Context, user, name, query string) (model.SavedQuery, error)
+	SavedQuery(ctx context.Context, user, name string) (model.SavedQuery, error)
+	ListSavedQueries(ctx context.Context, user string) ([]model.SavedQuery, error)
+	DeleteSavedQuery(ctx context.Context, user, name string) error
+}
+
 type ThumbnailService interface {
 	GenerateAlbumThumbnails()
 	GetStatus() (isRunning bool, processed int, total int, err string)
@@ -3,12 +3,15 @@ package controller
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/alejandro-bustamante/sancho/server/internal/model"
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
 	"github.com/gin-gonic/gin"
 )
@@ -21,19 +24,29 @@ type LibraryIndexRequest struct {
 	Quality int    `json:"quality" binding:"required"`
 }
 
+// Full-text search of the library, read from the query string
+type LibrarySearchRequest struct {
+	Query  string `form:"q" binding:"required"`
+	User   string `form:"user"`
+	Limit  int64  `form:"limit"`
+	Offset int64  `form:"offset"`
+}
+
 type LibraryHandler struct {
 	queries          *db.Queries
 	indexerService   Indexer
 	fileManager      FileManager
 	thumbnailService ThumbnailService
+	librarySearch    LibrarySearch
 }
 
-func NewLibraryHandler(q *db.Queries, s Indexer, f FileManager, t ThumbnailService) *LibraryHandler {
+func NewLibraryHandler(q *db.Queries, s Indexer, f FileManager, t ThumbnailService, ls LibrarySearch) *LibraryHandler {
 	return &LibraryHandler{
 		queries:          q,
 		indexerService:   s,
 		fileManager:      f,
 		thumbnailService: t,
+		librarySearch:    ls,
 	}
 }
 
@@ -73,29 +86,146 @@ func (h *LibraryHandler) IndexFolder(c *gin.Context) {
 	}()
 }
 
+// A page of a library listing, read from the query string. The order is
+// asc or desc, by default desc for the date added and asc for the rest.
+// q is a query of the library query language.
+type TrackListRequest struct {
+	Sort   string `form:"sort"`
+	Order  string `form:"order"`
+	Cursor string `form:"cursor"`
+	Limit  int64  `form:"limit"`
+	Query  string `form:"q"`
+	model.TrackFilter
+}
+
+type SaveQueryRequest struct {
+	Query string `json:"query" binding:"required"`
+}
+
+// Lists the songs of the library, newest first by default. Pages follow
+// each other with ?cursor= set to the next_cursor of the previous one
 func (h *LibraryHandler) GetTracks(c *gin.Context) {
-	tracks, err := h.queries.ListTracksByDate(c.Request.Context())
+	h.listTracks(c, "", nil)
+}
+
+// Lists the songs of the user's library like GetTracks, by the date they
+// came to it
+func (h *LibraryHandler) GetUserTracks(c *gin.Context) {
+	h.listTracks(c, c.Param("username"), nil)
+}
+
+// listTracks runs the saved query in place of ?q= when given
+func (h *LibraryHandler) listTracks(c *gin.Context, user string, saved *model.SavedQuery) {
+	var req TrackListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	sort, err := model.ParseTrackSort(req.Sort)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while getting the tracks"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, tracks)
+	query := model.TrackListQuery{
+		User:   user,
+		Sort:   sort,
+		Desc:   sort == model.SortAdded,
+		Cursor: req.Cursor,
+		Limit:  req.Limit,
+		Filter: req.TrackFilter,
+		Query:  req.Query,
+	}
+	if saved != nil {
+		query.Query = saved.Query
+	}
+	switch strings.ToLower(req.Order) {
+	case "":
+	case "asc":
+		query.Desc = false
+	case "desc":
+		query.Desc = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order", "details": "the order is asc or desc"})
+		return
+	}
+
+	page, err := h.librarySearch.ListTracks(c.Request.Context(), query)
+	if err != nil {
+		var syntaxErr *model.LibraryQueryError
+		switch {
+		case errors.As(err, &syntaxErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "details": syntaxErr.Message, "position": syntaxErr.Position})
+		case errors.Is(err, model.ErrInvalidFilter):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while getting the tracks", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
-func (h *LibraryHandler) GetUserTracks(c *gin.Context) {
-	username := c.Param("username")
+func (h *LibraryHandler) ListSavedQueries(c *gin.Context) {
+	queries, err := h.librarySearch.ListSavedQueries(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		savedQueryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, queries)
+}
 
-	tracks, err := h.queries.ListTracksByUsername(c.Request.Context(), username)
+// Saves the query of the body with the name of the path, replacing the one
+// saved with it. Syntax errors come with their position.
+func (h *LibraryHandler) SaveQuery(c *gin.Context) {
+	var req SaveQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	saved, err := h.librarySearch.SaveQuery(c.Request.Context(), c.Param("username"), c.Param("name"), req.Query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting user tracks"})
+		savedQueryError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, saved)
+}
 
-	if tracks == nil {
-		tracks = []db.ListTracksByUsernameRow{}
+func (h *LibraryHandler) DeleteSavedQuery(c *gin.Context) {
+	if err := h.librarySearch.DeleteSavedQuery(c.Request.Context(), c.Param("username"), c.Param("name")); err != nil {
+		savedQueryError(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Saved query deleted"})
+}
 
-	c.JSON(http.StatusOK, tracks)
+// Lists the songs of the user's library the saved query matches, with the
+// sort, pagination and filters of GetUserTracks
+func (h *LibraryHandler) GetSavedQueryTracks(c *gin.Context) {
+	user := c.Param("username")
+	saved, err := h.librarySearch.SavedQuery(c.Request.Context(), user, c.Param("name"))
+	if err != nil {
+		savedQueryError(c, err)
+		return
+	}
+	h.listTracks(c, user, &saved)
+}
+
+func savedQueryError(c *gin.Context, err error) {
+	var syntaxErr *model.LibraryQueryError
+	switch {
+	case errors.As(err, &syntaxErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "details": syntaxErr.Message, "position": syntaxErr.Position})
+	case errors.Is(err, model.ErrInvalidLibraryQuery), errors.Is(err, model.ErrInvalidQueryName):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+	case errors.Is(err, model.ErrSavedQueryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved query not found"})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while handling the saved queries", "details": err.Error()})
+	}
 }
 
 func (h *LibraryHandler) StreamTrack(c *gin.Context) {
@@ -142,6 +272,38 @@ func (h *LibraryHandler) FindTrackInLibrary(c *gin.Context) {
 	c.JSON(http.StatusOK, results)
 }
 
+// Searches the title, artist, album and composer of the songs of the
+// library, or of the user's with ?user=. The results are ranked, with
+// the matched words highlighted, and paginated with ?limit= and ?offset=
+func (h *LibraryHandler) SearchLibrary(c *gin.Context) {
+	var req LibrarySearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	page, err := h.librarySearch.Search(c.Request.Context(), model.LibrarySearchQuery{
+		Query:  req.Query,
+		User:   req.User,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidLibrarySearch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		case errors.Is(err, model.ErrLibrarySearchUnavailable):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Library search is not available", "details": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while searching the library", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
 func (h *LibraryHandler) DeleteTrackFromLibrary(c *gin.Context) {
 	username := c.Param("username")
 	trackIDStr := c.Param("trackId")
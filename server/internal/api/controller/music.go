@@ -1,9 +1,15 @@
 package controller
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
+	mdw "github.com/alejandro-bustamante/sancho/server/internal/api/middleware"
 	"github.com/alejandro-bustamante/sancho/server/internal/model"
 	"github.com/gin-gonic/gin"
 )
@@ -23,11 +29,77 @@ func NewMusicHandler(s Streamrip, x Indexer, f FileManager) *MusicHandler {
 }
 
 type DownloadRequest struct {
-	// Qobuz song's ids in their json are strings
+	// Song's ids in the sources' json are strings
+	ID   string `json:"id" binding:"required"`
+	ISRC string `json:"isrc" binding:"required"`
+	User string `json:"user" binding:"required"`
+	// A pointer so the quality 0, valid in most sources, isn't taken as missing
+	Quality *int64 `json:"quality" binding:"required"`
+	// qobuz when empty
+	Source string `json:"source"`
+	// Optional, source tried when Source can't download the song
+	Fallback string `json:"fallback"`
+}
+
+type AlbumDownloadRequest struct {
+	// Album's ids are strings too
 	ID      string `json:"id" binding:"required"`
-	ISRC    string `json:"isrc" binding:"required"`
 	User    string `json:"user" binding:"required"`
-	Quality int64  `json:"quality" binding:"required"`
+	Quality *int64 `json:"quality" binding:"required"`
+	// qobuz when empty
+	Source string `json:"source"`
+}
+
+// Many songs downloaded at once. Each one needs at least its ISRC,
+// without the ID it is looked up by ISRC in the source. ISRCs is a
+// shorthand for tracks with only the ISRC.
+type BatchDownloadRequest struct {
+	Tracks  []model.DownloadBatchTrack `json:"tracks"`
+	ISRCs   []string                   `json:"isrcs"`
+	User    string                     `json:"user" binding:"required"`
+	Quality *int64                     `json:"quality" binding:"required"`
+	// qobuz when empty
+	Source   string `json:"source"`
+	Fallback string `json:"fallback"`
+}
+
+// Download of the releases of an artist that pass the filters. Albums
+// narrows them to the given Deezer IDs, as listed by the preview.
+type DiscographyDownloadRequest struct {
+	User    string                   `json:"user" binding:"required"`
+	Quality *int64                   `json:"quality" binding:"required"`
+	Filters model.DiscographyFilters `json:"filters"`
+	Albums  []string                 `json:"albums"`
+	// qobuz when empty
+	Source string `json:"source"`
+}
+
+type RepairRequest struct {
+	User string `json:"user" binding:"required"`
+}
+
+// Filters of the download history, read from the query string. Dates are
+// RFC 3339 timestamps or plain days, a day given as "to" is included.
+type DownloadHistoryQuery struct {
+	Status  string `form:"status"`
+	Service string `form:"service"`
+	Quality *int64 `form:"quality"`
+	From    string `form:"from"`
+	To      string `form:"to"`
+	Limit   int64  `form:"limit"`
+	Offset  int64  `form:"offset"`
+}
+
+type RejectDownloadRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Limits of a user, null ones go back to the server's defaults and 0 means
+// no limit
+type UpdateQuotaRequest struct {
+	DailyDownloads  *int64 `json:"daily_downloads"`
+	WeeklyDownloads *int64 `json:"weekly_downloads"`
+	StorageBytes    *int64 `json:"storage_bytes"`
 }
 
 type SearchRequest struct {
@@ -46,10 +118,34 @@ func (h *MusicHandler) DownloadSingleTrack(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
 		return
 	}
+	if !mdw.RequireUser(c, req.User) {
+		return
+	}
+
+	source, err := model.ParseSource(req.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+	var fallback model.Source
+	if req.Fallback != "" {
+		if fallback, err = model.ParseSource(req.Fallback); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallback source", "details": err.Error()})
+			return
+		}
+	}
 
-	log.Printf("Download started for song with Qobuz ID: %s, ISRC: %s", req.ID, req.ISRC)
-	result, err := h.streamripService.EnsureTrackForUser(c.Request.Context(), req.ID, req.User, req.ISRC, req.Quality)
+	log.Printf("Download started for song with %s ID: %s, ISRC: %s", source, req.ID, req.ISRC)
+	result, err := h.streamripService.EnsureTrackForUser(c.Request.Context(), source, fallback, req.ID, req.User, req.ISRC, model.Quality(*req.Quality))
 	if err != nil {
+		if errors.Is(err, model.ErrInvalidQuality) || errors.Is(err, model.ErrUnknownSource) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, model.ErrQuotaExceeded) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Download quota exceeded", "details": err.Error()})
+			return
+		}
 		log.Printf("Error downloading and indexing song: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start download", "details": err.Error()})
 		return
@@ -73,6 +169,26 @@ func (h *MusicHandler) DownloadSingleTrack(c *gin.Context) {
 			"status":     "downloading",
 			"message":    "Download has started. You can track it with the download ID.",
 		})
+	case model.ActionQueued:
+		c.JSON(http.StatusAccepted, gin.H{
+			"downloadId": result.ID,
+			"status":     "queued",
+			"position":   result.Position,
+			"message":    "Download was queued. You can track it with the download ID.",
+		})
+	case model.ActionUpgrade:
+		c.JSON(http.StatusAccepted, gin.H{
+			"downloadId": result.ID,
+			"status":     "upgrading",
+			"position":   result.Position,
+			"message":    "The song is in your account in a lower quality. A better copy was queued to replace it.",
+		})
+	case model.ActionPending:
+		c.JSON(http.StatusAccepted, gin.H{
+			"requestId": result.ID,
+			"status":    "pending",
+			"message":   "The download was requested. It will start once an admin approves it.",
+		})
 	default:
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Unknown action returned by the server.",
@@ -80,7 +196,60 @@ func (h *MusicHandler) DownloadSingleTrack(c *gin.Context) {
 	}
 }
 
-func (h *MusicHandler) SearchTracksByTitle(c *gin.Context) {
+// Queues the download of every track of an album. The tracks the user
+// already has are skipped, the status of each one is reported in the
+// download's status.
+func (h *MusicHandler) DownloadAlbum(c *gin.Context) {
+	var req AlbumDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if !mdw.RequireUser(c, req.User) {
+		return
+	}
+
+	source, err := model.ParseSource(req.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+
+	log.Printf("Album download started for %s album ID: %s", source, req.ID)
+	result, err := h.streamripService.EnsureAlbumForUser(c.Request.Context(), source, req.ID, req.User, model.Quality(*req.Quality))
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidQuality) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, model.ErrQuotaExceeded) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Download quota exceeded", "details": err.Error()})
+			return
+		}
+		log.Printf("Error queuing album download: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start download", "details": err.Error()})
+		return
+	}
+	if result.Action == model.ActionPending {
+		c.JSON(http.StatusAccepted, gin.H{
+			"requestId": result.ID,
+			"status":    "pending",
+			"message":   "The album download was requested. It will start once an admin approves it.",
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"downloadId": result.ID,
+		"status":     "queued",
+		"position":   result.Position,
+		"message":    "Album download was queued. You can track it with the download ID.",
+	})
+}
+
+// Searches the catalog of a source. ?type= tells whether to look for
+// tracks (the default), albums, artists or playlists, each kind of result
+// has its own preview
+func (h *MusicHandler) SearchCatalog(c *gin.Context) {
 	// Obtener el query parameter
 	query := c.Query("q")
 
@@ -90,16 +259,62 @@ func (h *MusicHandler) SearchTracksByTitle(c *gin.Context) {
 		return
 	}
 
-	results, err := h.streamripService.SearchSong("qobuz", "track", query)
+	searchType, err := model.ParseSearchType(c.Query("type"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to search the track", "details": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search type", "details": err.Error()})
 		return
 	}
 
-	preview := model.MapToTrackPreviews(results)
+	// ?source= elige el servicio (qobuz por defecto) y ?fallback= el que se
+	// consulta cuando el primero no encuentra nada
+	source, err := model.ParseSource(c.Query("source"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+	var fallback model.Source
+	if c.Query("fallback") != "" {
+		if fallback, err = model.ParseSource(c.Query("fallback")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallback source", "details": err.Error()})
+			return
+		}
+	}
+
+	results, err := h.streamripService.SearchSong(string(source), string(searchType), query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to search the " + string(searchType), "details": err.Error()})
+		return
+	}
+	if len(results) == 0 && fallback != "" && fallback != source {
+		source = fallback
+		results, err = h.streamripService.SearchSong(string(source), string(searchType), query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to search the " + string(searchType), "details": err.Error()})
+			return
+		}
+	}
+
+	var previews any
+	switch searchType {
+	case model.SearchAlbum:
+		previews = model.MapToAlbumPreviews(results)
+	case model.SearchArtist:
+		previews = model.MapToArtistPreviews(results)
+	case model.SearchPlaylist:
+		previews = model.MapToPlaylistPreviews(results)
+	default:
+		tracks := model.MapToTrackPreviews(results)
+		// ?user= tells which songs the user has already
+		if err := h.streamripService.AnnotateTrackPreviews(c.Request.Context(), c.Query("user"), tracks); err != nil {
+			log.Printf("Error looking for the search results in the library: %v", err)
+		}
+		previews = tracks
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Búsqueda completada",
-		"results": preview,
+		"source":  source,
+		"type":    searchType,
+		"results": previews,
 	})
 }
 
@@ -150,13 +365,461 @@ func (h *MusicHandler) GetTrackSample(c *gin.Context) {
 // 	})
 // }
 
+// Asks for every song of the list in one batch. The response has the
+// outcome of each song, the batch can be followed with GetDownloadBatch
+func (h *MusicHandler) DownloadBatch(c *gin.Context) {
+	var req BatchDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if !mdw.RequireUser(c, req.User) {
+		return
+	}
+
+	source, err := model.ParseSource(req.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+	var fallback model.Source
+	if req.Fallback != "" {
+		if fallback, err = model.ParseSource(req.Fallback); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallback source", "details": err.Error()})
+			return
+		}
+	}
+	tracks := req.Tracks
+	for _, isrc := range req.ISRCs {
+		tracks = append(tracks, model.DownloadBatchTrack{ISRC: isrc})
+	}
+
+	batch, err := h.streamripService.CreateDownloadBatch(c.Request.Context(), req.User, source, fallback, model.Quality(*req.Quality), tracks)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidBatch), errors.Is(err, model.ErrInvalidQuality), errors.Is(err, model.ErrUnknownSource):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the batch", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// Returns how many songs of the batch are in each status, and the status
+// of each one
+func (h *MusicHandler) GetDownloadBatch(c *gin.Context) {
+	batch, err := h.streamripService.GetDownloadBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrBatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Download batch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get the batch", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// Lists the releases of the artist a discography download would fetch
+// with the filters given in the query string, and which of their tracks
+// the library and the user (?user=) already have. The answer waits for a
+// rip search per release outside Deezer, a Deezer call per release with
+// ?features=true and one more per release kept, made
+// SANCHO_DISCOGRAPHY_LOOKUPS at a time. Past SANCHO_DISCOGRAPHY_MAX_RELEASES
+// releases the rest are left out as over_limit
+func (h *MusicHandler) PreviewDiscography(c *gin.Context) {
+	var filters model.DiscographyFilters
+	if err := c.ShouldBindQuery(&filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filters", "details": err.Error()})
+		return
+	}
+	source, err := model.ParseSource(c.Query("source"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+
+	discography, err := h.streamripService.PreviewDiscography(c.Request.Context(), c.Query("user"), c.Param("deezerId"), source, filters)
+	if err != nil {
+		h.discographyError(c, err, "Failed to get the discography")
+		return
+	}
+	c.JSON(http.StatusOK, discography)
+}
+
+// Queues the download of the releases of the artist that pass the
+// filters. The response has what was done with each one. It waits for the
+// same lookups as the preview, so asking for the releases chosen there
+// (albums) doesn't make it any cheaper
+func (h *MusicHandler) DownloadDiscography(c *gin.Context) {
+	var req DiscographyDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if !mdw.RequireUser(c, req.User) {
+		return
+	}
+	source, err := model.ParseSource(req.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source", "details": err.Error()})
+		return
+	}
+
+	download, err := h.streamripService.DownloadDiscography(c.Request.Context(), req.User, c.Param("deezerId"), source, model.Quality(*req.Quality), req.Filters, req.Albums)
+	if err != nil {
+		h.discographyError(c, err, "Failed to download the discography")
+		return
+	}
+	c.JSON(http.StatusAccepted, download)
+}
+
+func (h *MusicHandler) discographyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, model.ErrInvalidQuality), errors.Is(err, model.ErrUnknownSource):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+	case errors.Is(err, model.ErrArtistNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Artist not found", "details": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
 func (h *MusicHandler) GetDownloadStatus(c *gin.Context) {
 	downloadID := c.Param("id")
-	status, errMsg := h.streamripService.GetDownloadStatus(downloadID)
+	job, err := h.streamripService.GetDownloadStatus(downloadID)
+	if err != nil {
+		if errors.Is(err, model.ErrDownloadNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Download not found", "downloadId": downloadID})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get the download status", "details": err.Error()})
+		return
+	}
 
-	resp := gin.H{"downloadId": downloadID, "status": status}
-	if status == model.StatusFailed {
-		resp["error"] = errMsg
+	resp := gin.H{"downloadId": downloadID, "status": job.Status}
+	if job.Status == model.StatusQueued {
+		resp["position"] = job.Position
+	}
+	// Waiting for a retry after a failed attempt
+	if job.Status == model.StatusQueued && job.NextAttemptAt != nil {
+		resp["attempts"] = job.Attempts
+		resp["next_attempt_at"] = job.NextAttemptAt
+		resp["error"] = job.Error
+	}
+	if job.Status == model.StatusFailed {
+		resp["error"] = job.Error
+	}
+	if job.Upgrade {
+		resp["upgrade"] = true
+		// Why the library's copy was kept
+		if job.Status == model.StatusSkipped {
+			resp["message"] = job.Error
+		}
+	}
+	if job.Quality != nil {
+		resp["quality"] = job.Quality
+		resp["requested_quality"] = job.RequestedQuality
+	}
+	if job.MediaType == model.MediaAlbum {
+		resp["media_type"] = job.MediaType
+		resp["tracks"] = job.Tracks
 	}
 	c.JSON(http.StatusOK, resp)
 }
+
+// Lists the queued and running downloads, optionally filtered with ?user=
+func (h *MusicHandler) ListDownloads(c *gin.Context) {
+	jobs, err := h.streamripService.ListDownloads(c.Query("user"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the downloads", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"downloads": jobs})
+}
+
+// Streams the download events as Server-Sent Events, optionally filtered
+// with ?user=. A ping is sent every 15 seconds to keep proxies from
+// closing an idle connection.
+func (h *MusicHandler) DownloadEvents(c *gin.Context) {
+	events, unsubscribe := h.streamripService.SubscribeDownloadEvents(c.Query("user"))
+	defer unsubscribe()
+
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("download", ev)
+			return true
+		case <-ping.C:
+			c.SSEvent("ping", time.Now().UTC().Format(time.RFC3339))
+			return true
+		}
+	})
+}
+
+// Cancels a download of the user in the X-Sancho-User header. Admins can
+// cancel anyone's, for the rest the downloads of others don't exist.
+func (h *MusicHandler) CancelDownload(c *gin.Context) {
+	downloadID := c.Param("id")
+	owner, admin, ok := mdw.RequireCaller(c)
+	if !ok {
+		return
+	}
+	if admin {
+		owner = ""
+	}
+	err := h.streamripService.CancelDownload(downloadID, owner)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrDownloadNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Download not found", "downloadId": downloadID})
+		case errors.Is(err, model.ErrDownloadFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": "The download already finished and can't be canceled", "downloadId": downloadID})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel the download", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"downloadId": downloadID,
+		"status":     model.StatusCanceled,
+		"message":    "The download was canceled.",
+	})
+}
+
+// Queues again the failed downloads of the user in the body
+func (h *MusicHandler) RepairDownloads(c *gin.Context) {
+	var req RepairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	if !mdw.RequireUser(c, req.User) {
+		return
+	}
+	h.repairDownloads(c, req.User)
+}
+
+// Queues again the failed downloads of every user, or of the one in ?user=
+func (h *MusicHandler) RepairAllDownloads(c *gin.Context) {
+	h.repairDownloads(c, c.Query("user"))
+}
+
+func (h *MusicHandler) repairDownloads(c *gin.Context, user string) {
+	result, err := h.streamripService.RepairDownloads(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to repair the downloads", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"requeued": result.Requeued,
+		"skipped":  result.Skipped,
+		"message":  fmt.Sprintf("%d failed downloads were queued again.", len(result.Requeued)),
+	})
+}
+
+// Lists the downloads and transfers of the user in the path
+func (h *MusicHandler) GetUserDownloadHistory(c *gin.Context) {
+	h.downloadHistory(c, c.Param("username"))
+}
+
+// Lists the downloads and transfers of every user, or of the one in ?user=
+func (h *MusicHandler) GetDownloadHistory(c *gin.Context) {
+	h.downloadHistory(c, c.Query("user"))
+}
+
+func (h *MusicHandler) downloadHistory(c *gin.Context, user string) {
+	var req DownloadHistoryQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	filter := model.DownloadHistoryFilter{
+		User:    user,
+		Status:  model.DownloadStatus(req.Status),
+		Service: req.Service,
+		Limit:   req.Limit,
+		Offset:  req.Offset,
+	}
+	if req.Quality != nil {
+		quality := model.Quality(*req.Quality)
+		filter.Quality = &quality
+	}
+	var err error
+	if filter.From, err = parseHistoryDate(req.From, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' date", "details": err.Error()})
+		return
+	}
+	if filter.To, err = parseHistoryDate(req.To, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' date", "details": err.Error()})
+		return
+	}
+
+	page, err := h.streamripService.DownloadHistory(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidFilter) || errors.Is(err, model.ErrInvalidQuality) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the download history", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// parseHistoryDate reads a date of the history filters, nil when empty.
+// A plain day given as the end of the range covers the whole day.
+func parseHistoryDate(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a day (2006-01-02) nor an RFC 3339 timestamp", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// Lists the download requests of the user in the path, ?status= filters
+// them
+func (h *MusicHandler) GetUserDownloadRequests(c *gin.Context) {
+	h.downloadRequests(c, c.Param("username"))
+}
+
+// Lists the download requests of every user, or of the one in ?user=.
+// ?status=pending gives the ones waiting for a review.
+func (h *MusicHandler) GetDownloadRequests(c *gin.Context) {
+	h.downloadRequests(c, c.Query("user"))
+}
+
+func (h *MusicHandler) downloadRequests(c *gin.Context, user string) {
+	filter := model.DownloadRequestFilter{
+		User:   user,
+		Status: model.RequestStatus(c.Query("status")),
+	}
+	requests, err := h.streamripService.ListDownloadRequests(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the download requests", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// Withdraws a pending request of the user in the path
+func (h *MusicHandler) CancelDownloadRequest(c *gin.Context) {
+	request, err := h.streamripService.CancelDownloadRequest(c.Request.Context(), c.Param("id"), c.Param("username"))
+	if err != nil {
+		h.downloadRequestError(c, err, "Failed to cancel the download request")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// Starts the requested download. The admin is the one in the X-Sancho-User
+// header.
+func (h *MusicHandler) ApproveDownloadRequest(c *gin.Context) {
+	admin := c.GetHeader(mdw.UserHeader)
+	request, err := h.streamripService.ApproveDownloadRequest(c.Request.Context(), c.Param("id"), admin)
+	if err != nil {
+		h.downloadRequestError(c, err, "Failed to approve the download request")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+func (h *MusicHandler) RejectDownloadRequest(c *gin.Context) {
+	var req RejectDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	admin := c.GetHeader(mdw.UserHeader)
+	request, err := h.streamripService.RejectDownloadRequest(c.Request.Context(), c.Param("id"), admin, req.Reason)
+	if err != nil {
+		h.downloadRequestError(c, err, "Failed to reject the download request")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+func (h *MusicHandler) downloadRequestError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, model.ErrRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Download request not found", "requestId": c.Param("id")})
+	case errors.Is(err, model.ErrRequestReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "The download request is no longer pending", "requestId": c.Param("id")})
+	case errors.Is(err, model.ErrReasonRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a download request"})
+	case errors.Is(err, model.ErrQuotaExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "The user is over their download quota", "details": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// Shows the download limits of the user in the path and how much of them
+// they used
+func (h *MusicHandler) GetUserQuota(c *gin.Context) {
+	quota, err := h.streamripService.UserQuota(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get the quota", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
+
+func (h *MusicHandler) UpdateUserQuota(c *gin.Context) {
+	var req UpdateQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	update := model.QuotaUpdate{
+		DailyDownloads:  req.DailyDownloads,
+		WeeklyDownloads: req.WeeklyDownloads,
+		StorageBytes:    req.StorageBytes,
+	}
+	quota, err := h.streamripService.SetUserQuota(c.Request.Context(), c.Param("username"), update)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidQuota):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quota", "details": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the quota", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, quota)
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	mdw "github.com/alejandro-bustamante/sancho/server/internal/api/middleware"
+	"github.com/alejandro-bustamante/sancho/server/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+type ResolveImportEntryRequest struct {
+	// Qobuz ID of the chosen candidate, empty to dismiss all of them
+	TrackID string `json:"track_id"`
+}
+
+type PlaylistHandler struct {
+	importer PlaylistImporter
+}
+
+func NewPlaylistHandler(i PlaylistImporter) *PlaylistHandler {
+	return &PlaylistHandler{
+		importer: i,
+	}
+}
+
+// Receives a playlist file as multipart form ("file", "user", "quality"
+// and optionally "name") and starts matching its songs.
+func (h *PlaylistHandler) ImportPlaylist(c *gin.Context) {
+	user := c.PostForm("user")
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Form field 'user' is required"})
+		return
+	}
+	if !mdw.RequireUser(c, user) {
+		return
+	}
+	quality, err := strconv.ParseInt(c.PostForm("quality"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Form field 'quality' must be a number", "details": err.Error()})
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Form field 'file' is required", "details": err.Error()})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded file", "details": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	imported, err := h.importer.Import(c.Request.Context(), user, c.PostForm("name"), fileHeader.Filename, quality, file)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidPlaylist) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, model.ErrInvalidQuality) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quality", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import the playlist", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "The songs of the playlist are being matched. Check the import to see the results.",
+		"import":  imported,
+	})
+}
+
+func (h *PlaylistHandler) ListPlaylistImports(c *gin.Context) {
+	user := c.Query("user")
+	if user == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'user' is required"})
+		return
+	}
+	imports, err := h.importer.ListImports(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the playlist imports", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"imports": imports})
+}
+
+func (h *PlaylistHandler) GetPlaylistImport(c *gin.Context) {
+	imported, err := h.importer.GetImport(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, model.ErrPlaylistImportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Playlist import not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get the playlist import", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, imported)
+}
+
+// Confirms which candidate of an ambiguous song is the right one, or retries
+// a song that could not be added
+func (h *PlaylistHandler) ResolvePlaylistImportEntry(c *gin.Context) {
+	entryID, err := strconv.ParseInt(c.Param("entryId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID", "details": err.Error()})
+		return
+	}
+	var req ResolveImportEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+
+	entry, err := h.importer.ResolveEntry(c.Request.Context(), c.Param("id"), entryID, req.TrackID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrPlaylistImportNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Playlist import not found"})
+		case errors.Is(err, model.ErrImportEntryNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Playlist import entry not found"})
+		case errors.Is(err, model.ErrImportEntryResolved):
+			c.JSON(http.StatusConflict, gin.H{"error": "The song is not waiting for a choice"})
+		case errors.Is(err, model.ErrUnknownCandidate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The track is not one of the candidates of the song"})
+		case errors.Is(err, model.ErrQuotaExceeded):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Download quota exceeded", "details": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve the song", "details": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Events sent to the webhook, every event when empty
+	Events []string `json:"events"`
+	// Key of the signatures, a random one is generated when empty
+	Secret string `json:"secret"`
+}
+
+type WebhookHandler struct {
+	dispatcher WebhookDispatcher
+}
+
+func NewWebhookHandler(d WebhookDispatcher) *WebhookHandler {
+	return &WebhookHandler{
+		dispatcher: d,
+	}
+}
+
+// Adds a webhook. The response has its secret, which isn't shown again
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request", "details": err.Error()})
+		return
+	}
+	events := make([]model.WebhookEventType, 0, len(req.Events))
+	for _, event := range req.Events {
+		events = append(events, model.WebhookEventType(event))
+	}
+
+	webhook, err := h.dispatcher.CreateWebhook(c.Request.Context(), req.URL, events, req.Secret)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidWebhook) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create the webhook", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.dispatcher.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list the webhooks", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks})
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	if err := h.dispatcher.DeleteWebhook(c.Request.Context(), id); err != nil {
+		webhookError(c, err, "Failed to delete the webhook")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// Lists the latest deliveries of the webhook, newest first
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	deliveries, err := h.dispatcher.ListDeliveries(c.Request.Context(), id)
+	if err != nil {
+		webhookError(c, err, "Failed to list the deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Sends a test event to the webhook and returns the delivery, whose status
+// tells whether the webhook accepted it
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	delivery, err := h.dispatcher.SendTest(c.Request.Context(), id)
+	if err != nil {
+		webhookError(c, err, "Failed to send the test event")
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+func webhookID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func webhookError(c *gin.Context, err error, msg string) {
+	if errors.Is(err, model.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": msg, "details": err.Error()})
+}
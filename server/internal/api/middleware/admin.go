@@ -0,0 +1,55 @@
+package util
+
+import (
+	"net/http"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Header with the username of whoever makes the request
+const UserHeader = "X-Sancho-User"
+
+// AdminMiddleware only lets through the requests made by one of the users
+// listed in SANCHO_ADMINS.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.GetHeader(UserHeader)
+		if user == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Header " + UserHeader + " is required"})
+			return
+		}
+		if !config.AdminUsers[user] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only admins can do this", "user": user})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUser lets the request through only when UserHeader names the
+// user it acts for. Whether a download waits for an admin's approval
+// depends on that user, so the one in the body can't be taken on trust.
+// It answers the request itself and returns false otherwise.
+func RequireUser(c *gin.Context, user string) bool {
+	caller, _, ok := RequireCaller(c)
+	if !ok {
+		return false
+	}
+	if caller != user {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Users can only act for themselves", "user": caller})
+		return false
+	}
+	return true
+}
+
+// RequireCaller returns the user in UserHeader and whether it is an admin.
+// Without the header it answers the request itself and ok is false.
+func RequireCaller(c *gin.Context) (user string, admin, ok bool) {
+	user = c.GetHeader(UserHeader)
+	if user == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Header " + UserHeader + " is required"})
+		return "", false, false
+	}
+	return user, config.AdminUsers[user], true
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// A user naming an admin in the body doesn't get the admin's downloads,
+// which skip the approval
+func TestRequireUserRejectsActingForOthers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	admins := config.AdminUsers
+	config.AdminUsers = map[string]bool{"admin": true}
+	t.Cleanup(func() { config.AdminUsers = admins })
+
+	router := gin.New()
+	router.POST("/downloads", func(c *gin.Context) {
+		var req struct {
+			User string `json:"user"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if !RequireUser(c, req.User) {
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+
+	for _, tc := range []struct {
+		caller, user string
+		want         int
+	}{
+		{"", "admin", http.StatusUnauthorized},
+		{"mallory", "admin", http.StatusForbidden},
+		{"mallory", "mallory", http.StatusAccepted},
+		{"admin", "admin", http.StatusAccepted},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/downloads", strings.NewReader(`{"user": "`+tc.user+`"}`))
+		if tc.caller != "" {
+			req.Header.Set(UserHeader, tc.caller)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%q downloading for %q: status %d, want %d", tc.caller, tc.user, rec.Code, tc.want)
+		}
+	}
+}
@@ -10,21 +10,69 @@ type ProxyHandler interface {
 }
 type MusicHandler interface {
 	DownloadSingleTrack(c *gin.Context)
-	SearchTracksByTitle(c *gin.Context)
+	DownloadAlbum(c *gin.Context)
+	DownloadBatch(c *gin.Context)
+	GetDownloadBatch(c *gin.Context)
+	PreviewDiscography(c *gin.Context)
+	DownloadDiscography(c *gin.Context)
+	SearchCatalog(c *gin.Context)
 	GetDownloadStatus(c *gin.Context)
+	ListDownloads(c *gin.Context)
+	CancelDownload(c *gin.Context)
+	RepairDownloads(c *gin.Context)
+	RepairAllDownloads(c *gin.Context)
+	GetUserDownloadHistory(c *gin.Context)
+	GetDownloadHistory(c *gin.Context)
+	GetUserDownloadRequests(c *gin.Context)
+	GetDownloadRequests(c *gin.Context)
+	CancelDownloadRequest(c *gin.Context)
+	ApproveDownloadRequest(c *gin.Context)
+	RejectDownloadRequest(c *gin.Context)
+	GetUserQuota(c *gin.Context)
+	UpdateUserQuota(c *gin.Context)
+	DownloadEvents(c *gin.Context)
 	GetTrackSample(c *gin.Context)
 }
 type LibraryHandler interface {
 	IndexFolder(c *gin.Context)
 	GetTracks(c *gin.Context)
 	FindTrackInLibrary(c *gin.Context)
+	SearchLibrary(c *gin.Context)
 	DeleteTrackFromLibrary(c *gin.Context)
 	GetUserTracks(c *gin.Context)
+	ListSavedQueries(c *gin.Context)
+	SaveQuery(c *gin.Context)
+	DeleteSavedQuery(c *gin.Context)
+	GetSavedQueryTracks(c *gin.Context)
 	StreamTrack(c *gin.Context)
 	GenerateAlbumThumbnails(c *gin.Context)
 	GetThumbnailGenerationStatus(c *gin.Context)
 }
 
+type PlaylistHandler interface {
+	ImportPlaylist(c *gin.Context)
+	ListPlaylistImports(c *gin.Context)
+	GetPlaylistImport(c *gin.Context)
+	ResolvePlaylistImportEntry(c *gin.Context)
+}
+
+type FollowHandler interface {
+	FollowArtist(c *gin.Context)
+	UnfollowArtist(c *gin.Context)
+	ListFollowedArtists(c *gin.Context)
+	ListReleaseNotifications(c *gin.Context)
+	MarkReleaseNotificationsRead(c *gin.Context)
+	CheckReleases(c *gin.Context)
+}
+
+type WebhookHandler interface {
+	CreateWebhook(c *gin.Context)
+	ListWebhooks(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	ListWebhookDeliveries(c *gin.Context)
+	TestWebhook(c *gin.Context)
+}
+
 type UserHandler interface {
 	RegisterUser(c *gin.Context)
 	DeleteUser(c *gin.Context)
@@ -32,7 +80,7 @@ type UserHandler interface {
 	UpdateUser(c *gin.Context)
 }
 
-func RegisterRoutes(router *gin.Engine, p ProxyHandler, m MusicHandler, l LibraryHandler, u UserHandler) {
+func RegisterRoutes(router *gin.Engine, p ProxyHandler, m MusicHandler, l LibraryHandler, u UserHandler, pl PlaylistHandler, f FollowHandler, w WebhookHandler) {
 	router.Use(mdw.CORSMiddleware())
 
 	api := router.Group("/api")
@@ -40,24 +88,71 @@ func RegisterRoutes(router *gin.Engine, p ProxyHandler, m MusicHandler, l Librar
 		api.GET("/proxy", p.ProxyCORSHandler)
 
 		api.POST("/downloads", m.DownloadSingleTrack)
-		api.GET("/search", m.SearchTracksByTitle)
+		api.POST("/downloads/albums", m.DownloadAlbum)
+		api.POST("/downloads/batch", m.DownloadBatch)
+		api.GET("/downloads/batch/:id", m.GetDownloadBatch)
+		api.GET("/downloads", m.ListDownloads)
+		api.GET("/downloads/events", m.DownloadEvents)
+		api.POST("/downloads/repair", m.RepairDownloads)
+		api.GET("/search", m.SearchCatalog)
 		api.GET("/downloads/:id/status", m.GetDownloadStatus)
+		api.DELETE("/downloads/:id", m.CancelDownload)
 		api.GET("/search/:isrc/sample", m.GetTrackSample)
+		api.GET("/artists/:deezerId/discography", m.PreviewDiscography)
+		api.POST("/artists/:deezerId/discography/download", m.DownloadDiscography)
 
 		api.POST("/index", l.IndexFolder)
 		api.GET("/tracks", l.GetTracks)
 		api.GET("/tracks/search", l.FindTrackInLibrary)
+		api.GET("/library/search", l.SearchLibrary)
 		api.DELETE("/users/:username/tracks/:trackId", l.DeleteTrackFromLibrary)
 
 		api.POST("/library/thumbnails", l.GenerateAlbumThumbnails)
 		api.GET("/library/thumbnails/status", l.GetThumbnailGenerationStatus)
 
 		api.GET("/users/:username/tracks", l.GetUserTracks)
+		api.GET("/users/:username/queries", l.ListSavedQueries)
+		api.PUT("/users/:username/queries/:name", l.SaveQuery)
+		api.DELETE("/users/:username/queries/:name", l.DeleteSavedQuery)
+		api.GET("/users/:username/queries/:name/tracks", l.GetSavedQueryTracks)
+		api.GET("/users/:username/downloads", m.GetUserDownloadHistory)
+		api.GET("/users/:username/requests", m.GetUserDownloadRequests)
+		api.DELETE("/users/:username/requests/:id", m.CancelDownloadRequest)
+		api.GET("/users/:username/quota", m.GetUserQuota)
 		api.GET("/tracks/:trackId/stream", l.StreamTrack)
 
+		api.POST("/playlists/imports", pl.ImportPlaylist)
+		api.GET("/playlists/imports", pl.ListPlaylistImports)
+		api.GET("/playlists/imports/:id", pl.GetPlaylistImport)
+		api.POST("/playlists/imports/:id/entries/:entryId/resolve", pl.ResolvePlaylistImportEntry)
+
+		api.POST("/users/:username/follows", f.FollowArtist)
+		api.GET("/users/:username/follows", f.ListFollowedArtists)
+		api.DELETE("/users/:username/follows/:deezerId", f.UnfollowArtist)
+		api.GET("/users/:username/notifications", f.ListReleaseNotifications)
+		api.POST("/users/:username/notifications/read", f.MarkReleaseNotificationsRead)
+
 		api.POST("/users", u.RegisterUser)
 		api.DELETE("/users", u.DeleteUser)
 		api.POST("/auth", u.AuthenticateUser)
 		api.PATCH("/users/:id", u.UpdateUser)
+
+		admin := api.Group("/admin", mdw.AdminMiddleware())
+		{
+			admin.GET("/downloads", m.GetDownloadHistory)
+			admin.POST("/downloads/repair", m.RepairAllDownloads)
+			admin.GET("/requests", m.GetDownloadRequests)
+			admin.POST("/requests/:id/approve", m.ApproveDownloadRequest)
+			admin.POST("/requests/:id/reject", m.RejectDownloadRequest)
+			admin.GET("/users/:username/quota", m.GetUserQuota)
+			admin.PUT("/users/:username/quota", m.UpdateUserQuota)
+			admin.POST("/releases/check", f.CheckReleases)
+
+			admin.POST("/webhooks", w.CreateWebhook)
+			admin.GET("/webhooks", w.ListWebhooks)
+			admin.DELETE("/webhooks/:id", w.DeleteWebhook)
+			admin.GET("/webhooks/:id/deliveries", w.ListWebhookDeliveries)
+			admin.POST("/webhooks/:id/test", w.TestWebhook)
+		}
 	}
 }
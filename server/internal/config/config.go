@@ -3,6 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func isDev() bool {
@@ -15,8 +18,70 @@ var (
 	HttpPort     string
 	FrontendPath string
 	LibraryPath  string
+	// Number of rip processes allowed to run at the same time
+	DownloadWorkers int
+	// Attempts made for a download that keeps failing with temporary
+	// errors, and the wait before the first retry, doubled on each one
+	DownloadMaxAttempts int
+	DownloadRetryDelay  time.Duration
+	// Base URL of the Deezer API, used to identify the indexed tracks
+	DeezerAPIURL string
+	// Users allowed to see and manage everyone's data
+	AdminUsers map[string]bool
+	// Downloads of users that aren't admins wait for an admin to approve
+	// them. Songs already in the library are linked right away.
+	DownloadApproval bool
+	// Default limits of every user: downloads in the last 24 hours and 7
+	// days, and bytes of the songs they brought to the library. 0 means no
+	// limit.
+	QuotaDailyDownloads  int64
+	QuotaWeeklyDownloads int64
+	QuotaStorageBytes    int64
+	// Time between two checks for new releases of the followed artists
+	ReleaseCheckInterval time.Duration
+	// Attempts made to deliver an event to a webhook, and the wait before
+	// the first retry, doubled on each one
+	WebhookMaxAttempts int
+	WebhookRetryDelay  time.Duration
+	// Time the results of a catalog search are reused for the same search
+	SearchCacheTTL time.Duration
+	// Lookups of the releases of a discography made at the same time, and
+	// the most releases looked up in the source for a single request
+	DiscographyLookups     int
+	DiscographyMaxReleases int
 )
 
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+func envString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envBool is false unless the variable is set to true, 1 or the like.
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// envList reads a comma separated list, ignoring blank items.
+func envList(key string) map[string]bool {
+	items := make(map[string]bool)
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items[item] = true
+		}
+	}
+	return items
+}
+
 func init() {
 	if isDev() {
 		DBPath = os.Getenv("DB_PATH")
@@ -31,4 +96,19 @@ func init() {
 		FrontendPath = "/app/build"
 		LibraryPath = "/sancho/library"
 	}
+	DownloadWorkers = envInt("SANCHO_DOWNLOAD_WORKERS", 2)
+	DownloadMaxAttempts = envInt("SANCHO_DOWNLOAD_ATTEMPTS", 4)
+	DownloadRetryDelay = time.Duration(envInt("SANCHO_DOWNLOAD_RETRY_DELAY", 30)) * time.Second
+	DeezerAPIURL = envString("SANCHO_DEEZER_API_URL", "https://api.deezer.com")
+	AdminUsers = envList("SANCHO_ADMINS")
+	DownloadApproval = envBool("SANCHO_DOWNLOAD_APPROVAL")
+	QuotaDailyDownloads = int64(envInt("SANCHO_QUOTA_DAILY_DOWNLOADS", 0))
+	QuotaWeeklyDownloads = int64(envInt("SANCHO_QUOTA_WEEKLY_DOWNLOADS", 0))
+	QuotaStorageBytes = int64(envInt("SANCHO_QUOTA_STORAGE_MB", 0)) * 1e6
+	ReleaseCheckInterval = time.Duration(envInt("SANCHO_RELEASE_CHECK_INTERVAL", 360)) * time.Minute
+	WebhookMaxAttempts = envInt("SANCHO_WEBHOOK_ATTEMPTS", 5)
+	WebhookRetryDelay = time.Duration(envInt("SANCHO_WEBHOOK_RETRY_DELAY", 10)) * time.Second
+	SearchCacheTTL = time.Duration(envInt("SANCHO_SEARCH_CACHE_TTL", 120)) * time.Second
+	DiscographyLookups = envInt("SANCHO_DISCOGRAPHY_LOOKUPS", 4)
+	DiscographyMaxReleases = envInt("SANCHO_DISCOGRAPHY_MAX_RELEASES", 100)
 }
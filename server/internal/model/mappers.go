@@ -2,6 +2,10 @@ package model
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
@@ -14,6 +18,14 @@ func toInt64Ptr(n sql.NullInt64) *int64 {
 	return nil
 }
 
+func toQualityPtr(n sql.NullInt64) *Quality {
+	if n.Valid {
+		q := Quality(n.Int64)
+		return &q
+	}
+	return nil
+}
+
 func toStringPtr(n sql.NullString) *string {
 	if n.Valid {
 		return &n.String
@@ -63,16 +75,101 @@ func ArtistFromDB(a db.Artist) Artist {
 
 func DownloadHistoryFromDB(d db.DownloadHistory) DownloadHistory {
 	return DownloadHistory{
-		ID:           d.ID,
-		UserID:       toInt64Ptr(d.UserID),
-		TrackID:      toInt64Ptr(d.TrackID),
-		Quality:      toInt64Ptr(d.Quality),
-		Status:       toStringPtr(d.Status),
-		Service:      toStringPtr(d.Service),
-		StartedAt:    d.StartedAt.Format(time.RFC3339),
-		CompletedAt:  toTimePtr(d.CompletedAt),
-		ErrorMessage: toStringPtr(d.ErrorMessage),
+		ID:               d.ID,
+		UserID:           toInt64Ptr(d.UserID),
+		TrackID:          toInt64Ptr(d.TrackID),
+		Quality:          toInt64Ptr(d.Quality),
+		Status:           toStringPtr(d.Status),
+		Service:          toStringPtr(d.Service),
+		StartedAt:        d.StartedAt.Format(time.RFC3339),
+		CompletedAt:      toTimePtr(d.CompletedAt),
+		ErrorMessage:     toStringPtr(d.ErrorMessage),
+		SourceTrackID:    toStringPtr(d.SourceTrackID),
+		ISRC:             toStringPtr(d.Isrc),
+		MediaType:        d.MediaType,
+		ParentID:         toStringPtr(d.ParentID),
+		FallbackService:  toStringPtr(d.FallbackService),
+		RequestedQuality: toInt64Ptr(d.RequestedQuality),
+		Attempts:         d.Attempts,
+		NextAttemptAt:    toTimePtr(d.NextAttemptAt),
+		Upgrade:          d.Upgrade,
+		Linked:           d.Linked,
+	}
+}
+
+func DownloadJobFromDB(d db.DownloadHistory, username string) DownloadJob {
+	return DownloadJob{
+		ID:               d.ID,
+		User:             username,
+		MediaType:        MediaType(d.MediaType),
+		ParentID:         d.ParentID.String,
+		Status:           DownloadStatus(d.Status.String),
+		Service:          d.Service.String,
+		Fallback:         d.FallbackService.String,
+		SourceTrackID:    d.SourceTrackID.String,
+		ISRC:             d.Isrc.String,
+		TrackID:          toInt64Ptr(d.TrackID),
+		Quality:          toQualityPtr(d.Quality),
+		RequestedQuality: toQualityPtr(d.RequestedQuality),
+		Error:            d.ErrorMessage.String,
+		Upgrade:          d.Upgrade,
+		Linked:           d.Linked,
+		Attempts:         d.Attempts,
+		NextAttemptAt:    toTimePtr(d.NextAttemptAt),
+		StartedAt:        d.StartedAt.Format(time.RFC3339),
+		CompletedAt:      toTimePtr(d.CompletedAt),
+	}
+}
+
+func DownloadRequestFromDB(r db.DownloadRequest, username string) DownloadRequest {
+	return DownloadRequest{
+		ID:         r.ID,
+		User:       username,
+		MediaType:  MediaType(r.MediaType),
+		Service:    r.Source,
+		Fallback:   r.Fallback.String,
+		SourceID:   r.SourceID,
+		ISRC:       r.Isrc.String,
+		Quality:    Quality(r.Quality),
+		Status:     RequestStatus(r.Status),
+		Reason:     toStringPtr(r.Reason),
+		ReviewedBy: toStringPtr(r.ReviewedBy),
+		ReviewedAt: toTimePtr(r.ReviewedAt),
+		DownloadID: toStringPtr(r.DownloadID),
+		CreatedAt:  r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func PlaylistImportFromDB(p db.PlaylistImport, username string) PlaylistImport {
+	return PlaylistImport{
+		ID:        p.ID,
+		User:      username,
+		Name:      p.Name,
+		Format:    p.Format,
+		Quality:   p.Quality,
+		Status:    p.Status,
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func PlaylistImportEntryFromDB(e db.PlaylistImportEntry) PlaylistImportEntry {
+	entry := PlaylistImportEntry{
+		ID:         e.ID,
+		Position:   e.Position,
+		Title:      e.Title,
+		Artist:     toStringPtr(e.Artist),
+		Album:      toStringPtr(e.Album),
+		ISRC:       toStringPtr(e.Isrc),
+		Result:     PlaylistImportResult(e.Result),
+		TrackID:    toInt64Ptr(e.TrackID),
+		DownloadID: toStringPtr(e.DownloadID),
+		Error:      toStringPtr(e.ErrorMessage),
+	}
+	// Candidates are stored as the JSON of the previews
+	if e.Candidates.Valid {
+		json.Unmarshal([]byte(e.Candidates.String), &entry.Candidates)
 	}
+	return entry
 }
 
 func TrackFromDB(t db.Track) Track {
@@ -122,22 +219,212 @@ func MapToTrackPreviews(results []StreamripSearchResult) []TrackPreview {
 	for _, r := range results {
 		preview := TrackPreview{
 			Title:    r.Data.Title,
-			Artist:   r.Data.Performer.Name,
+			Artist:   firstNonEmpty(r.Data.Performer.Name, r.Data.Artist.Name, r.Data.User.Username),
 			Album:    r.Data.Album.Title,
 			Duration: r.Data.Duration,
-			Image:    r.Data.Album.Image.Small,
+			Image:    firstNonEmpty(r.Data.Album.Image.Small, r.Data.Album.CoverSmall, tidalCoverURL(r.Data.Album.Cover), r.Data.ArtworkURL),
 			TrackID:  r.ID,
 			Source:   r.Source,
 			ISRC:     r.Data.ISRC,
 		}
+		// SoundCloud measures durations in milliseconds
+		if Source(r.Source) == SourceSoundCloud {
+			preview.Duration /= 1000
+		}
 		previews = append(previews, preview)
 	}
 	return previews
 }
 
+func MapToAlbumPreviews(results []StreamripSearchResult) []AlbumPreview {
+	previews := make([]AlbumPreview, 0, len(results))
+	for _, r := range results {
+		tracksCount := r.Data.TracksCount
+		if tracksCount == 0 {
+			tracksCount = max(r.Data.NumberOfTracks, r.Data.NbTracks)
+		}
+		preview := AlbumPreview{
+			Title:       r.Data.Title,
+			Artist:      firstNonEmpty(r.Data.Artist.Name, r.Data.User.Username),
+			TracksCount: tracksCount,
+			ReleaseDate: firstNonEmpty(r.Data.ReleaseDate, r.Data.TidalReleasedOn),
+			Image:       firstNonEmpty(r.Data.Image.Small, r.Data.CoverSmall, tidalCoverURL(r.Data.Cover), r.Data.ArtworkURL),
+			AlbumID:     r.ID,
+			Source:      r.Source,
+		}
+		// Dates start with the year in every source
+		if len(preview.ReleaseDate) >= 4 {
+			preview.Year, _ = strconv.Atoi(preview.ReleaseDate[:4])
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
+
+func MapToArtistPreviews(results []StreamripSearchResult) []ArtistPreview {
+	previews := make([]ArtistPreview, 0, len(results))
+	for _, r := range results {
+		picture := r.Data.Picture
+		if picture != "" && !strings.Contains(picture, "://") {
+			picture = tidalCoverURL(picture)
+		}
+		preview := ArtistPreview{
+			Name:        firstNonEmpty(r.Data.Name, r.Data.User.Username),
+			AlbumsCount: max(r.Data.AlbumsCount, r.Data.NbAlbum),
+			Image:       firstNonEmpty(r.Data.Image.Small, r.Data.PictureSmall, picture, r.Data.ArtworkURL),
+			ArtistID:    r.ID,
+			Source:      r.Source,
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
+
+func MapToPlaylistPreviews(results []StreamripSearchResult) []PlaylistPreview {
+	previews := make([]PlaylistPreview, 0, len(results))
+	for _, r := range results {
+		tracksCount := r.Data.TracksCount
+		if tracksCount == 0 {
+			tracksCount = max(r.Data.NumberOfTracks, r.Data.NbTracks, r.Data.TrackCount)
+		}
+		var image string
+		if len(r.Data.Images150) > 0 {
+			image = r.Data.Images150[0]
+		}
+		preview := PlaylistPreview{
+			Title:       firstNonEmpty(r.Data.Title, r.Data.Name),
+			Owner:       firstNonEmpty(r.Data.Owner.Name, r.Data.Creator.Name, r.Data.User.Name, r.Data.User.Username),
+			TracksCount: tracksCount,
+			Image:       firstNonEmpty(image, tidalCoverURL(firstNonEmpty(r.Data.SquareImage, r.Data.Image.ID)), r.Data.PictureSmall, r.Data.ArtworkURL),
+			PlaylistID:  r.ID,
+			Source:      r.Source,
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
+
+// Tidal only gives the ID of a cover, like "1a2b3c4d-...", the image is
+// served from its resources host with the dashes turned into slashes.
+func tidalCoverURL(id string) string {
+	if id == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://resources.tidal.com/images/%s/160x160.jpg", strings.ReplaceAll(id, "-", "/"))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func LimitResults[T any](items []T, max int) []T {
 	if len(items) > max {
 		return items[:max]
 	}
 	return items
 }
+
+func ArtistFollowFromDB(f db.ArtistFollow, username string) ArtistFollow {
+	follow := ArtistFollow{
+		ID:            f.ID,
+		User:          username,
+		DeezerID:      f.DeezerArtistID,
+		Name:          f.ArtistName,
+		ReleaseTypes:  []ReleaseType{},
+		AutoDownload:  f.AutoDownload,
+		Quality:       Quality(f.Quality),
+		LastCheckedAt: toTimePtr(f.LastCheckedAt),
+		CreatedAt:     f.CreatedAt.Format(time.RFC3339),
+	}
+	for _, t := range strings.Split(f.ReleaseTypes, ",") {
+		if t != "" {
+			follow.ReleaseTypes = append(follow.ReleaseTypes, ReleaseType(t))
+		}
+	}
+	return follow
+}
+
+func ReleaseNotificationFromDB(n db.ReleaseNotification, artistID, artist string) ReleaseNotification {
+	return ReleaseNotification{
+		ID:          n.ID,
+		ArtistID:    artistID,
+		Artist:      artist,
+		AlbumID:     n.DeezerAlbumID,
+		Title:       n.Title,
+		Type:        ReleaseType(n.ReleaseType),
+		ReleaseDate: toStringPtr(n.ReleaseDate),
+		CoverURL:    toStringPtr(n.CoverUrl),
+		DownloadID:  toStringPtr(n.DownloadID),
+		Read:        n.ReadAt.Valid,
+		CreatedAt:   n.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func DownloadBatchFromDB(b db.DownloadBatch, username string) DownloadBatch {
+	return DownloadBatch{
+		ID:        b.ID,
+		User:      username,
+		Service:   b.Source,
+		Fallback:  b.Fallback.String,
+		Quality:   Quality(b.Quality),
+		CreatedAt: b.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func DownloadBatchItemFromDB(i db.DownloadBatchItem) DownloadBatchItem {
+	return DownloadBatchItem{
+		Position:   i.Position,
+		SourceID:   toStringPtr(i.SourceID),
+		ISRC:       i.Isrc,
+		Action:     DownloadAction(i.Action.String),
+		DownloadID: toStringPtr(i.DownloadID),
+		RequestID:  toStringPtr(i.RequestID),
+		Error:      toStringPtr(i.ErrorMessage),
+	}
+}
+
+// WebhookFromDB leaves the secret out, it is only shown on creation
+func WebhookFromDB(w db.Webhook) Webhook {
+	webhook := Webhook{
+		ID:        w.ID,
+		URL:       w.Url,
+		Events:    []WebhookEventType{},
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+	}
+	for _, event := range strings.Split(w.Events, ",") {
+		if event != "" {
+			webhook.Events = append(webhook.Events, WebhookEventType(event))
+		}
+	}
+	return webhook
+}
+
+func WebhookDeliveryFromDB(d db.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.EventID,
+		EventType:      WebhookEventType(d.EventType),
+		Payload:        d.Payload,
+		Status:         WebhookDeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		ResponseStatus: toInt64Ptr(d.ResponseStatus),
+		Error:          toStringPtr(d.ErrorMessage),
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+		CompletedAt:    toTimePtr(d.CompletedAt),
+	}
+}
+
+func SavedQueryFromDB(q db.SavedQuery) SavedQuery {
+	return SavedQuery{
+		Name:      q.Name,
+		Query:     q.Query,
+		CreatedAt: q.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: q.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSearchPreviewsOfEveryType(t *testing.T) {
+	output := `[
+		{"source": "qobuz", "media_type": "album", "id": "a1", "data": {"title": "Parallel Lines", "artist": {"name": "Blondie"}, "tracks_count": 12, "release_date_original": "1978-09-23", "image": {"small": "https://qobuz.example/a1.jpg"}}},
+		{"source": "deezer", "media_type": "artist", "id": "1000", "data": {"name": "Blondie", "nb_album": 40, "picture_small": "https://deezer.example/1000.jpg"}},
+		{"source": "tidal", "media_type": "artist", "id": "2000", "data": {"name": "Blondie", "picture": "1a2b-3c4d"}},
+		{"source": "tidal", "media_type": "playlist", "id": "p1", "data": {"title": "New Wave", "numberOfTracks": 30, "image": "5e6f-7a8b", "creator": {"name": "TIDAL"}}}
+	]`
+	var results []StreamripSearchResult
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	album := MapToAlbumPreviews(results[:1])[0]
+	if album.Year != 1978 || album.TracksCount != 12 || album.Image != "https://qobuz.example/a1.jpg" {
+		t.Errorf("album = %+v", album)
+	}
+	artists := MapToArtistPreviews(results[1:3])
+	if artists[0].AlbumsCount != 40 || artists[0].Image != "https://deezer.example/1000.jpg" {
+		t.Errorf("deezer artist = %+v", artists[0])
+	}
+	if artists[1].Image != "https://resources.tidal.com/images/1a2b/3c4d/160x160.jpg" {
+		t.Errorf("tidal artist image = %q", artists[1].Image)
+	}
+	playlist := MapToPlaylistPreviews(results[3:])[0]
+	if playlist.TracksCount != 30 || playlist.Owner != "TIDAL" || playlist.Image != "https://resources.tidal.com/images/5e6f/7a8b/160x160.jpg" {
+		t.Errorf("playlist = %+v", playlist)
+	}
+}
@@ -1,5 +1,13 @@
 package model
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Album struct {
 	ID              int64   `json:"id"`
 	DeezerID        *string `json:"deezer_id"`
@@ -22,15 +30,25 @@ type Artist struct {
 }
 
 type DownloadHistory struct {
-	ID           string  `json:"id"`
-	UserID       *int64  `json:"user_id,omitempty"`
-	TrackID      *int64  `json:"track_id,omitempty"`
-	Quality      *int64  `json:"quality,omitempty"`
-	Status       *string `json:"status,omitempty"`
-	Service      *string `json:"service,omitempty"`
-	StartedAt    string  `json:"started_at"`
-	CompletedAt  *string `json:"completed_at,omitempty"`
-	ErrorMessage *string `json:"error_message,omitempty"`
+	ID               string  `json:"id"`
+	UserID           *int64  `json:"user_id,omitempty"`
+	TrackID          *int64  `json:"track_id,omitempty"`
+	Quality          *int64  `json:"quality,omitempty"`
+	Status           *string `json:"status,omitempty"`
+	Service          *string `json:"service,omitempty"`
+	StartedAt        string  `json:"started_at"`
+	CompletedAt      *string `json:"completed_at,omitempty"`
+	ErrorMessage     *string `json:"error_message,omitempty"`
+	SourceTrackID    *string `json:"source_track_id,omitempty"`
+	ISRC             *string `json:"isrc,omitempty"`
+	MediaType        string  `json:"media_type"`
+	ParentID         *string `json:"parent_id,omitempty"`
+	FallbackService  *string `json:"fallback_service,omitempty"`
+	RequestedQuality *int64  `json:"requested_quality,omitempty"`
+	Attempts         int64   `json:"attempts"`
+	NextAttemptAt    *string `json:"next_attempt_at,omitempty"`
+	Upgrade          bool    `json:"upgrade"`
+	Linked           bool    `json:"linked"`
 }
 
 type Track struct {
@@ -88,11 +106,98 @@ type StreamripSearchResult struct {
 				Thumbnail string `json:"thumbnail"`
 				Large     string `json:"large"`
 			} `json:"image"`
+			// Tidal gives the ID of the cover, Deezer its URL
+			Cover      string `json:"cover"`
+			CoverSmall string `json:"cover_small"`
 		} `json:"album"`
 		ISRC string `json:"isrc"`
+		// Only in Qobuz album results, and in every Tidal and Deezer result
+		Artist struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+		Image       SearchImage `json:"image"`
+		TracksCount int         `json:"tracks_count"`
+		ReleaseDate string      `json:"release_date_original"`
+
+		// Tidal albums
+		Cover           string `json:"cover"`
+		NumberOfTracks  int    `json:"numberOfTracks"`
+		TidalReleasedOn string `json:"releaseDate"`
+		// Qobuz albums, the sampling rate in kHz
+		MaximumBitDepth     int     `json:"maximum_bit_depth"`
+		MaximumSamplingRate float64 `json:"maximum_sampling_rate"`
+		// Tidal albums: LOW, HIGH, LOSSLESS, HI_RES or HI_RES_LOSSLESS
+		AudioQuality string `json:"audioQuality"`
+		// Deezer albums
+		CoverSmall string `json:"cover_small"`
+		NbTracks   int    `json:"nb_tracks"`
+		// Artists and Qobuz playlists have a name instead of a title
+		Name string `json:"name"`
+		// Qobuz and Deezer artists
+		AlbumsCount int `json:"albums_count"`
+		NbAlbum     int `json:"nb_album"`
+		// The URL of the artist's picture, only its ID in Tidal
+		Picture      string `json:"picture"`
+		PictureSmall string `json:"picture_small"`
+		// Qobuz playlists
+		Owner struct {
+			Name string `json:"name"`
+		} `json:"owner"`
+		Images150 []string `json:"images150"`
+		// Tidal playlists
+		Creator struct {
+			Name string `json:"name"`
+		} `json:"creator"`
+		SquareImage string `json:"squareImage"`
+		// SoundCloud has uploaders instead of artists, Deezer playlists
+		// have the name of their user
+		User struct {
+			Username string `json:"username"`
+			Name     string `json:"name"`
+		} `json:"user"`
+		ArtworkURL string `json:"artwork_url"`
+		TrackCount int    `json:"track_count"`
 	} `json:"data"`
 }
 
+// Image of a search result. Qobuz gives its URLs, a Tidal playlist only
+// the ID of the image, kept in ID.
+type SearchImage struct {
+	Small     string `json:"small"`
+	Thumbnail string `json:"thumbnail"`
+	Large     string `json:"large"`
+	ID        string `json:"-"`
+}
+
+func (i *SearchImage) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &i.ID); err == nil {
+		return nil
+	}
+	type urls SearchImage
+	return json.Unmarshal(data, (*urls)(i))
+}
+
+// What a catalog search looks for
+type SearchType string
+
+const (
+	SearchTrack    SearchType = "track"
+	SearchAlbum    SearchType = "album"
+	SearchArtist   SearchType = "artist"
+	SearchPlaylist SearchType = "playlist"
+)
+
+// ParseSearchType validates the type of a search, tracks when empty.
+func ParseSearchType(name string) (SearchType, error) {
+	switch t := SearchType(strings.ToLower(strings.TrimSpace(name))); t {
+	case "":
+		return SearchTrack, nil
+	case SearchTrack, SearchAlbum, SearchArtist, SearchPlaylist:
+		return t, nil
+	}
+	return "", fmt.Errorf("%w: %q, it must be track, album, artist or playlist", ErrInvalidSearchType, name)
+}
+
 type TrackPreview struct {
 	Title    string `json:"title"`
 	Artist   string `json:"artist"`
@@ -102,6 +207,40 @@ type TrackPreview struct {
 	TrackID  string `json:"track_id"`
 	Source   string `json:"source"`
 	ISRC     string `json:"isrc"`
+	// Whether the library has the song, in which quality, and whether the
+	// user who searched has it
+	InLibrary      bool     `json:"in_library"`
+	Owned          bool     `json:"owned_by_user"`
+	LibraryQuality *Quality `json:"library_quality,omitempty"`
+}
+
+type AlbumPreview struct {
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	TracksCount int    `json:"tracks_count"`
+	ReleaseDate string `json:"release_date,omitempty"`
+	Year        int    `json:"year,omitempty"`
+	Image       string `json:"image"`
+	AlbumID     string `json:"album_id"`
+	Source      string `json:"source"`
+}
+
+type ArtistPreview struct {
+	Name string `json:"name"`
+	// 0 when the source doesn't tell
+	AlbumsCount int    `json:"albums_count,omitempty"`
+	Image       string `json:"image"`
+	ArtistID    string `json:"artist_id"`
+	Source      string `json:"source"`
+}
+
+type PlaylistPreview struct {
+	Title       string `json:"title"`
+	Owner       string `json:"owner,omitempty"`
+	TracksCount int    `json:"tracks_count"`
+	Image       string `json:"image"`
+	PlaylistID  string `json:"playlist_id"`
+	Source      string `json:"source"`
 }
 
 type DeezerSearchResult struct {
@@ -130,10 +269,13 @@ type DownloadStatus string
 
 const (
 	StatusSuccess     DownloadStatus = "success"
+	StatusQueued      DownloadStatus = "queued"
 	StatusDownloading DownloadStatus = "downloading"
 	StatusIndexing    DownloadStatus = "indexing"
 	StatusFailed      DownloadStatus = "failed"
 	StatusCanceled    DownloadStatus = "canceled"
+	// A track of an album download that the user already had
+	StatusSkipped DownloadStatus = "skipped"
 	// Used to indicate a file was tranfered locally
 	// and not downloaded. Has no fail state
 	StatusTransfered DownloadStatus = "transfered"
@@ -149,9 +291,823 @@ const (
 	ActionNoop        DownloadAction = "noop"
 	ActionLinked      DownloadAction = "linked"
 	ActionDownloading DownloadAction = "downloading"
+	ActionQueued      DownloadAction = "queued"
+	// The library has the song in a lower quality than the one asked for,
+	// a download of a better copy was queued to replace it
+	ActionUpgrade DownloadAction = "upgrade"
+	// The download waits for an admin to approve it, the result's ID is
+	// the one of the request
+	ActionPending DownloadAction = "pending"
+)
+
+// A service rip can search and download from
+type Source string
+
+const (
+	SourceQobuz      Source = "qobuz"
+	SourceTidal      Source = "tidal"
+	SourceDeezer     Source = "deezer"
+	SourceSoundCloud Source = "soundcloud"
+)
+
+// Quality of a download on a scale shared by every source. It follows
+// streamrip's numbering, which means the same in every source, though
+// each one only offers part of the scale.
+type Quality int64
+
+const (
+	// Under 320 kbps: Deezer's 128 kbps MP3, Tidal's 256 kbps AAC, SoundCloud
+	QualityLossy Quality = iota
+	// 320 kbps MP3 or AAC
+	QualityLossyHigh
+	// Lossless 16 bit / 44.1 kHz
+	QualityCD
+	// Lossless 24 bit up to 96 kHz
+	QualityHiRes
+	// Lossless 24 bit up to 192 kHz
+	QualityHiResMax
+)
+
+func (q Quality) String() string {
+	switch q {
+	case QualityLossy:
+		return "lossy"
+	case QualityLossyHigh:
+		return "lossy 320 kbps"
+	case QualityCD:
+		return "CD 16 bit / 44.1 kHz"
+	case QualityHiRes:
+		return "hi-res 24 bit / 96 kHz"
+	case QualityHiResMax:
+		return "hi-res 24 bit / 192 kHz"
+	default:
+		return fmt.Sprintf("unknown quality %d", int64(q))
+	}
+}
+
+// Technical data of an audio file, used to tell its quality
+type AudioFormat struct {
+	Lossless bool
+	// 0 when the format has no fixed bit depth, like MP3 or AAC
+	BitDepth int
+	// In Hz
+	SampleRate int
+	// In kbit/s
+	Bitrate int
+}
+
+// Quality places the file on the common scale. A lossless file sampled
+// above 48 kHz counts as hi-res even when its bit depth is unknown.
+func (f AudioFormat) Quality() Quality {
+	switch {
+	case !f.Lossless && f.Bitrate >= 300:
+		return QualityLossyHigh
+	case !f.Lossless:
+		return QualityLossy
+	case f.SampleRate > 96000:
+		return QualityHiResMax
+	case f.BitDepth > 16 || f.SampleRate > 48000:
+		return QualityHiRes
+	default:
+		return QualityCD
+	}
+}
+
+func (f AudioFormat) String() string {
+	if !f.Lossless {
+		return fmt.Sprintf("lossy %d kbps", f.Bitrate)
+	}
+	if f.BitDepth == 0 {
+		return fmt.Sprintf("lossless %.1f kHz", float64(f.SampleRate)/1000)
+	}
+	return fmt.Sprintf("%d bit / %.1f kHz", f.BitDepth, float64(f.SampleRate)/1000)
+}
+
+// Qualities offered by each source
+var sourceQualities = map[Source][2]Quality{
+	SourceQobuz:      {QualityLossyHigh, QualityHiResMax},
+	SourceTidal:      {QualityLossy, QualityHiRes},
+	SourceDeezer:     {QualityLossy, QualityCD},
+	SourceSoundCloud: {QualityLossy, QualityLossy},
+}
+
+// ParseSource validates the name of a source. An empty name means Qobuz,
+// the source Sancho always used.
+func ParseSource(name string) (Source, error) {
+	if name == "" {
+		return SourceQobuz, nil
+	}
+	s := Source(strings.ToLower(name))
+	if _, ok := sourceQualities[s]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownSource, name)
+	}
+	return s, nil
+}
+
+// QualityRange returns the lowest and highest quality the source accepts.
+func (s Source) QualityRange() (lowest, highest Quality) {
+	r := sourceQualities[s]
+	return r[0], r[1]
+}
+
+func (s Source) ValidateQuality(quality Quality) error {
+	if _, ok := sourceQualities[s]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSource, s)
+	}
+	lowest, highest := s.QualityRange()
+	if quality < lowest || quality > highest {
+		return fmt.Errorf("%w: %s accepts qualities from %d to %d, got %d", ErrInvalidQuality, s, lowest, highest, quality)
+	}
+	return nil
+}
+
+// What a download job fetches from the source
+type MediaType string
+
+const (
+	MediaTrack MediaType = "track"
+	MediaAlbum MediaType = "album"
 )
 
 type DownloadResult struct {
 	ID     string
 	Action DownloadAction
+	// Position in the download queue, only set for ActionQueued and
+	// ActionUpgrade (the queue of pending requests isn't numbered)
+	Position int
+}
+
+// A download job as seen by the client. Position is the 1-based place
+// in the download queue and is only set while the job is queued.
+// Album jobs carry the status of each of their tracks in Tracks.
+type DownloadJob struct {
+	ID            string         `json:"downloadId"`
+	User          string         `json:"user"`
+	MediaType     MediaType      `json:"media_type"`
+	ParentID      string         `json:"parentId,omitempty"`
+	Status        DownloadStatus `json:"status"`
+	Position      int            `json:"position,omitempty"`
+	Service       string         `json:"service"`
+	Fallback      string         `json:"fallback,omitempty"`
+	SourceTrackID string         `json:"source_track_id,omitempty"`
+	ISRC          string         `json:"isrc,omitempty"`
+	TrackID       *int64         `json:"track_id,omitempty"`
+	// Quality of the downloaded file, it can be lower than the requested
+	// one when the source doesn't have the song in that quality
+	Quality          *Quality `json:"quality,omitempty"`
+	RequestedQuality *Quality `json:"requested_quality,omitempty"`
+	Title            string   `json:"title,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	// Set for jobs that replace the library's copy of the track with a
+	// better one
+	Upgrade bool `json:"upgrade,omitempty"`
+	// Set when the song was already in the library and was only linked
+	Linked bool `json:"linked,omitempty"`
+	// Number of the attempt running, or of the next one while a job that
+	// failed waits, queued, until NextAttemptAt.
+	Attempts      int64         `json:"attempts"`
+	NextAttemptAt *string       `json:"next_attempt_at,omitempty"`
+	StartedAt     string        `json:"started_at"`
+	CompletedAt   *string       `json:"completed_at,omitempty"`
+	Tracks        []DownloadJob `json:"tracks,omitempty"`
+}
+
+// The outcome of a repair request. Skipped are the failed downloads that
+// can't run again or whose song the user already has.
+type RepairResult struct {
+	Requeued []string `json:"requeued"`
+	Skipped  []string `json:"skipped"`
+}
+
+// Filters of the download history, empty fields match everything. From
+// is inclusive and To exclusive.
+type DownloadHistoryFilter struct {
+	User    string
+	Status  DownloadStatus
+	Service string
+	Quality *Quality
+	From    *time.Time
+	To      *time.Time
+	Limit   int64
+	Offset  int64
+}
+
+// A download or transfer as listed in the history, with the names of
+// what it added to the library
+type DownloadHistoryEntry struct {
+	DownloadJob
+	Album  string `json:"album,omitempty"`
+	Artist string `json:"artist,omitempty"`
+}
+
+// A page of the download history. Total counts every entry matching the
+// filters, not only those in the page.
+type DownloadHistoryPage struct {
+	Entries []DownloadHistoryEntry `json:"entries"`
+	Total   int64                  `json:"total"`
+	Limit   int64                  `json:"limit"`
+	Offset  int64                  `json:"offset"`
+}
+
+// Kind of event pushed to the clients following the downloads.
+// Besides the statuses there are events that are never stored.
+type DownloadEventType string
+
+const (
+	EventQueued      DownloadEventType = "queued"
+	EventDownloading DownloadEventType = "downloading"
+	EventProgress    DownloadEventType = "progress"
+	EventIndexing    DownloadEventType = "indexing"
+	EventLinking     DownloadEventType = "linking"
+	EventSuccess     DownloadEventType = "success"
+	EventFailed      DownloadEventType = "failed"
+	EventCanceled    DownloadEventType = "canceled"
+)
+
+type DownloadEvent struct {
+	DownloadID      string            `json:"downloadId"`
+	ParentID        string            `json:"parentId,omitempty"`
+	User            string            `json:"user"`
+	Type            DownloadEventType `json:"type"`
+	Status          DownloadStatus    `json:"status"`
+	Position        int               `json:"position,omitempty"`
+	BytesDownloaded int64             `json:"bytes_downloaded,omitempty"`
+	BytesTotal      int64             `json:"bytes_total,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Time            string            `json:"time"`
+}
+
+// How an entry of an imported playlist was resolved
+type PlaylistImportResult string
+
+const (
+	ImportMatched   PlaylistImportResult = "matched"
+	ImportQueued    PlaylistImportResult = "queued"
+	ImportAmbiguous PlaylistImportResult = "ambiguous"
+	ImportNotFound  PlaylistImportResult = "not_found"
+	ImportFailed    PlaylistImportResult = "failed"
+)
+
+// One song of an imported playlist. Candidates are the Qobuz tracks the
+// user can choose from when the result is ambiguous, or the track that
+// could not be added when it failed. Error says why it failed.
+type PlaylistImportEntry struct {
+	ID         int64                `json:"id"`
+	Position   int64                `json:"position"`
+	Title      string               `json:"title"`
+	Artist     *string              `json:"artist,omitempty"`
+	Album      *string              `json:"album,omitempty"`
+	ISRC       *string              `json:"isrc,omitempty"`
+	Result     PlaylistImportResult `json:"result"`
+	TrackID    *int64               `json:"track_id,omitempty"`
+	DownloadID *string              `json:"download_id,omitempty"`
+	Candidates []TrackPreview       `json:"candidates,omitempty"`
+	Error      *string              `json:"error,omitempty"`
+}
+
+// The report of a playlist import. Status is "processing" while the
+// entries are being matched and "done" afterwards.
+type PlaylistImport struct {
+	ID        string                       `json:"id"`
+	User      string                       `json:"user"`
+	Name      string                       `json:"name"`
+	Format    string                       `json:"format"`
+	Quality   int64                        `json:"quality"`
+	Status    string                       `json:"status"`
+	CreatedAt string                       `json:"created_at"`
+	Summary   map[PlaylistImportResult]int `json:"summary"`
+	Entries   []PlaylistImportEntry        `json:"entries"`
+}
+
+// Kind of release of a followed artist. Deezer tells albums, EPs, singles
+// and compilations apart, live albums are told by their title.
+type ReleaseType string
+
+const (
+	ReleaseAlbum       ReleaseType = "album"
+	ReleaseEP          ReleaseType = "ep"
+	ReleaseSingle      ReleaseType = "single"
+	ReleaseLive        ReleaseType = "live"
+	ReleaseCompilation ReleaseType = "compilation"
+)
+
+// Release types notified when the follow doesn't choose: like the
+// qobuz_filters of streamrip, live albums and compilations are left out
+// as extras.
+var DefaultReleaseTypes = []ReleaseType{ReleaseAlbum, ReleaseEP, ReleaseSingle}
+
+func ParseReleaseType(s string) (ReleaseType, error) {
+	switch t := ReleaseType(strings.ToLower(strings.TrimSpace(s))); t {
+	case ReleaseAlbum, ReleaseEP, ReleaseSingle, ReleaseLive, ReleaseCompilation:
+		return t, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidReleaseType, s)
+}
+
+// What a user wants from an artist they follow. Quality is the one of
+// the releases downloaded automatically.
+type FollowOptions struct {
+	ReleaseTypes []ReleaseType
+	AutoDownload bool
+	Quality      Quality
+}
+
+// An artist followed by a user, by its Deezer ID
+type ArtistFollow struct {
+	ID            int64         `json:"id"`
+	User          string        `json:"user"`
+	DeezerID      string        `json:"deezer_id"`
+	Name          string        `json:"name"`
+	ReleaseTypes  []ReleaseType `json:"release_types"`
+	AutoDownload  bool          `json:"auto_download"`
+	Quality       Quality       `json:"quality"`
+	LastCheckedAt *string       `json:"last_checked_at,omitempty"`
+	CreatedAt     string        `json:"created_at"`
+}
+
+// A release of a followed artist that came out after the user followed it
+type ReleaseNotification struct {
+	ID          int64       `json:"id"`
+	ArtistID    string      `json:"artist_deezer_id"`
+	Artist      string      `json:"artist"`
+	AlbumID     string      `json:"album_deezer_id"`
+	Title       string      `json:"title"`
+	Type        ReleaseType `json:"release_type"`
+	ReleaseDate *string     `json:"release_date,omitempty"`
+	CoverURL    *string     `json:"cover_url,omitempty"`
+	DownloadID  *string     `json:"download_id,omitempty"`
+	Read        bool        `json:"read"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+// Releases left out of a discography download. They mirror the
+// qobuz_filters of streamrip but apply to every source.
+type DiscographyFilters struct {
+	// Deluxe, anniversary, collector's and expanded editions, demos,
+	// remixes and live recordings
+	Extras bool `json:"extras" form:"extras"`
+	// Of the releases with the same title, only the one in the best
+	// quality is kept
+	Repeats bool `json:"repeats" form:"repeats"`
+	// EPs, singles and compilations
+	NonAlbums bool `json:"non_albums" form:"non_albums"`
+	// Releases of other artists the artist appears on
+	Features bool `json:"features" form:"features"`
+	// Live albums
+	NonStudioAlbums bool `json:"non_studio_albums" form:"non_studio_albums"`
+	// Releases that aren't remasters
+	NonRemaster bool `json:"non_remaster" form:"non_remaster"`
+}
+
+// Why a release is left out of a discography download: the name of the
+// filter, not_found when the source doesn't have it, or over_limit when
+// it came after the most releases a discography looks up
+type DiscographyExclusion string
+
+const (
+	ExcludedExtra       DiscographyExclusion = "extras"
+	ExcludedRepeat      DiscographyExclusion = "repeats"
+	ExcludedNonAlbum    DiscographyExclusion = "non_albums"
+	ExcludedFeature     DiscographyExclusion = "features"
+	ExcludedNonStudio   DiscographyExclusion = "non_studio_albums"
+	ExcludedNonRemaster DiscographyExclusion = "non_remaster"
+	ExcludedNotFound    DiscographyExclusion = "not_found"
+	ExcludedOverLimit   DiscographyExclusion = "over_limit"
+)
+
+// A song of a release and whether the library, and the user, have it
+type DiscographyTrack struct {
+	Title     string `json:"title"`
+	ISRC      string `json:"isrc"`
+	InLibrary bool   `json:"in_library"`
+	Owned     bool   `json:"owned_by_user"`
+}
+
+// A release of the artist as Deezer lists it. AlbumID is its ID in the
+// source it would be downloaded from and Quality the best that source
+// has, when it tells. Only the releases that would be downloaded list
+// their tracks.
+type DiscographyAlbum struct {
+	DeezerID        string               `json:"deezer_id"`
+	Title           string               `json:"title"`
+	Type            ReleaseType          `json:"release_type"`
+	ReleaseDate     string               `json:"release_date,omitempty"`
+	CoverURL        string               `json:"cover_url,omitempty"`
+	AlbumID         string               `json:"album_id,omitempty"`
+	Quality         *Quality             `json:"quality,omitempty"`
+	ExcludedBy      DiscographyExclusion `json:"excluded_by,omitempty"`
+	TracksInLibrary int                  `json:"tracks_in_library"`
+	TracksOwned     int                  `json:"tracks_owned"`
+	Tracks          []DiscographyTrack   `json:"tracks,omitempty"`
+}
+
+// The releases of an artist that a discography download would fetch from
+// the source, and those the filters leave out.
+type Discography struct {
+	ArtistID string             `json:"artist_deezer_id"`
+	Artist   string             `json:"artist"`
+	Source   Source             `json:"source"`
+	Filters  DiscographyFilters `json:"filters"`
+	Albums   []DiscographyAlbum `json:"albums"`
+	Excluded []DiscographyAlbum `json:"excluded"`
+}
+
+// What was done with each release of a discography download
+type DiscographyDownload struct {
+	Artist string                   `json:"artist"`
+	Albums []DiscographyAlbumResult `json:"albums"`
+}
+
+type DiscographyAlbumResult struct {
+	DeezerID   string         `json:"deezer_id"`
+	AlbumID    string         `json:"album_id"`
+	Title      string         `json:"title"`
+	Action     DownloadAction `json:"action,omitempty"`
+	DownloadID *string        `json:"downloadId,omitempty"`
+	RequestID  *string        `json:"requestId,omitempty"`
+	Error      *string        `json:"error,omitempty"`
+}
+
+type RequestStatus string
+
+const (
+	RequestPending  RequestStatus = "pending"
+	RequestApproved RequestStatus = "approved"
+	RequestRejected RequestStatus = "rejected"
+	RequestCanceled RequestStatus = "canceled"
+)
+
+// A download asked for by a user that needs the approval of an admin.
+// Once approved, DownloadID is the download it started, if it had to
+// download anything.
+type DownloadRequest struct {
+	ID         string        `json:"id"`
+	User       string        `json:"user"`
+	MediaType  MediaType     `json:"media_type"`
+	Service    string        `json:"service"`
+	Fallback   string        `json:"fallback,omitempty"`
+	SourceID   string        `json:"source_id"`
+	ISRC       string        `json:"isrc,omitempty"`
+	Quality    Quality       `json:"quality"`
+	Status     RequestStatus `json:"status"`
+	Reason     *string       `json:"reason,omitempty"`
+	ReviewedBy *string       `json:"reviewed_by,omitempty"`
+	ReviewedAt *string       `json:"reviewed_at,omitempty"`
+	DownloadID *string       `json:"download_id,omitempty"`
+	CreatedAt  string        `json:"created_at"`
+}
+
+// Filters of the download requests, empty fields match everything
+type DownloadRequestFilter struct {
+	User   string
+	Status RequestStatus
+}
+
+// What a user can download, or how much of it they used. Downloads are
+// the jobs started in the last 24 hours and 7 days, an album being one
+// and a song that was only linked none. Storage is the size of the songs
+// the user was the first to bring to the library. A limit of 0 means no
+// limit.
+type Quota struct {
+	DailyDownloads  int64 `json:"daily_downloads"`
+	WeeklyDownloads int64 `json:"weekly_downloads"`
+	StorageBytes    int64 `json:"storage_bytes"`
+}
+
+// The limits of a user, the server's defaults for those nil in Custom,
+// and their use of them.
+type UserQuota struct {
+	User   string      `json:"user"`
+	Limits Quota       `json:"limits"`
+	Custom QuotaUpdate `json:"custom"`
+	Used   Quota       `json:"used"`
+}
+
+// Limits set for a user, nil ones are the server's defaults
+type QuotaUpdate struct {
+	DailyDownloads  *int64 `json:"daily_downloads"`
+	WeeklyDownloads *int64 `json:"weekly_downloads"`
+	StorageBytes    *int64 `json:"storage_bytes"`
+}
+
+type BatchItemStatus string
+
+const (
+	BatchItemQueued      BatchItemStatus = "queued"
+	BatchItemDownloading BatchItemStatus = "downloading"
+	BatchItemDone        BatchItemStatus = "done"
+	BatchItemFailed      BatchItemStatus = "failed"
+	// Waiting for an admin to approve the download
+	BatchItemPending BatchItemStatus = "pending"
+)
+
+// A song asked for in a batch. Only the ISRC is required, without the ID
+// the song is looked up by ISRC in the source.
+type DownloadBatchTrack struct {
+	ID   string `json:"id"`
+	ISRC string `json:"isrc"`
+}
+
+// One song of a batch. Action is what was done when it was asked for,
+// empty if that failed; Status follows the download, or the request when
+// it needs approval.
+type DownloadBatchItem struct {
+	Position   int64           `json:"position"`
+	SourceID   *string         `json:"id,omitempty"`
+	ISRC       string          `json:"isrc"`
+	Action     DownloadAction  `json:"action,omitempty"`
+	Status     BatchItemStatus `json:"status"`
+	DownloadID *string         `json:"downloadId,omitempty"`
+	RequestID  *string         `json:"requestId,omitempty"`
+	TrackID    *int64          `json:"track_id,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+}
+
+// Songs downloaded together, with how many of them are in each status.
+// Finished is set once none is queued, downloading or pending.
+type DownloadBatch struct {
+	ID        string                  `json:"id"`
+	User      string                  `json:"user"`
+	Service   string                  `json:"service"`
+	Fallback  string                  `json:"fallback,omitempty"`
+	Quality   Quality                 `json:"quality"`
+	Finished  bool                    `json:"finished"`
+	Summary   map[BatchItemStatus]int `json:"summary"`
+	Items     []DownloadBatchItem     `json:"items"`
+	CreatedAt string                  `json:"created_at"`
+}
+
+type WebhookEventType string
+
+const (
+	WebhookDownloadSucceeded  WebhookEventType = "download.succeeded"
+	WebhookDownloadFailed     WebhookEventType = "download.failed"
+	WebhookTrackLinked        WebhookEventType = "track.linked"
+	WebhookTrackDeleted       WebhookEventType = "track.deleted"
+	WebhookIndexFinished      WebhookEventType = "index.finished"
+	WebhookThumbnailsFinished WebhookEventType = "thumbnails.finished"
+	// Sent on demand to check that a webhook works
+	WebhookTest WebhookEventType = "test"
+)
+
+var WebhookEventTypes = []WebhookEventType{
+	WebhookDownloadSucceeded,
+	WebhookDownloadFailed,
+	WebhookTrackLinked,
+	WebhookTrackDeleted,
+	WebhookIndexFinished,
+	WebhookThumbnailsFinished,
+	WebhookTest,
+}
+
+// The body of every webhook request. Data depends on the type: the
+// DownloadJob of the download events, and the Webhook*Data structs of the
+// others.
+type WebhookEvent struct {
+	ID   string           `json:"id"`
+	Type WebhookEventType `json:"type"`
+	Time string           `json:"time"`
+	Data any              `json:"data"`
+}
+
+// A URL that receives the events of the given types, all of them when
+// Events is empty. The secret is only shown when the webhook is created.
+type Webhook struct {
+	ID        int64              `json:"id"`
+	URL       string             `json:"url"`
+	Events    []WebhookEventType `json:"events"`
+	Secret    string             `json:"secret,omitempty"`
+	CreatedAt string             `json:"created_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending WebhookDeliveryStatus = "pending"
+	DeliverySuccess WebhookDeliveryStatus = "success"
+	DeliveryFailed  WebhookDeliveryStatus = "failed"
+)
+
+// An event sent to a webhook. A pending delivery is still being retried.
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	WebhookID      int64                 `json:"webhook_id"`
+	EventID        string                `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int64                 `json:"attempts"`
+	ResponseStatus *int64                `json:"response_status,omitempty"`
+	Error          *string               `json:"error,omitempty"`
+	CreatedAt      string                `json:"created_at"`
+	CompletedAt    *string               `json:"completed_at,omitempty"`
+}
+
+type WebhookTrackData struct {
+	User    string  `json:"user"`
+	TrackID int64   `json:"track_id"`
+	Title   string  `json:"title"`
+	ISRC    *string `json:"isrc,omitempty"`
 }
+
+// Data of track.deleted. RemovedFromLibrary is set when no other user had
+// the track, so its file was deleted too.
+type WebhookTrackDeletedData struct {
+	WebhookTrackData
+	RemovedFromLibrary bool `json:"removed_from_library"`
+}
+
+type WebhookIndexData struct {
+	User    string `json:"user"`
+	Path    string `json:"path"`
+	Indexed int    `json:"indexed"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+type WebhookThumbnailsData struct {
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Orders of the library listings
+type TrackSort string
+
+const (
+	// When the song came to the library, or to the user's library
+	SortAdded    TrackSort = "added"
+	SortArtist   TrackSort = "artist"
+	SortAlbum    TrackSort = "album"
+	SortYear     TrackSort = "year"
+	SortDuration TrackSort = "duration"
+	// By sample rate, then bitrate
+	SortQuality TrackSort = "quality"
+)
+
+// ParseTrackSort validates the order of a listing, added when empty.
+func ParseTrackSort(s string) (TrackSort, error) {
+	switch sort := TrackSort(strings.ToLower(strings.TrimSpace(s))); sort {
+	case "":
+		return SortAdded, nil
+	case SortAdded, SortArtist, SortAlbum, SortYear, SortDuration, SortQuality:
+		return sort, nil
+	}
+	return "", fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, s)
+}
+
+// Filters of the library listings, each one left empty matches every song
+type TrackFilter struct {
+	ArtistID *int64 `form:"artist_id"`
+	AlbumID  *int64 `form:"album_id"`
+	Genre    string `form:"genre"`
+	// In Hz
+	SampleRate *int64 `form:"sample_rate"`
+	// The extension of the file, like flac or mp3
+	Format string `form:"format"`
+}
+
+// A page of a library listing. User, when set, lists the songs of the
+// user. Cursor is the NextCursor of the previous page, empty for the first.
+// Query, in the library query language, narrows the filter further.
+type TrackListQuery struct {
+	User   string
+	Sort   TrackSort
+	Desc   bool
+	Cursor string
+	Limit  int64
+	Filter TrackFilter
+	Query  string
+}
+
+// A song of the library as listed, with its artist and album
+type LibraryTrack struct {
+	ID           int64   `json:"id"`
+	Title        string  `json:"title"`
+	ArtistID     *int64  `json:"artist_id,omitempty"`
+	Artist       *string `json:"artist,omitempty"`
+	AlbumID      *int64  `json:"album_id,omitempty"`
+	Album        *string `json:"album,omitempty"`
+	AlbumArtPath *string `json:"album_art_path,omitempty"`
+	Genre        *string `json:"genre,omitempty"`
+	Year         *int64  `json:"year,omitempty"`
+	Duration     *int64  `json:"duration,omitempty"`
+	TrackNumber  *int64  `json:"track_number,omitempty"`
+	DiscNumber   *int64  `json:"disc_number,omitempty"`
+	SampleRate   *int64  `json:"sample_rate,omitempty"`
+	Bitrate      *int64  `json:"bitrate,omitempty"`
+	Format       string  `json:"format"`
+	FileSize     *int64  `json:"file_size,omitempty"`
+	ISRC         *string `json:"isrc,omitempty"`
+	AddedAt      string  `json:"added_at"`
+}
+
+// NextCursor is missing on the last page
+type TrackPage struct {
+	Tracks     []LibraryTrack `json:"tracks"`
+	Sort       TrackSort      `json:"sort"`
+	Order      string         `json:"order"`
+	Limit      int64          `json:"limit"`
+	NextCursor *string        `json:"next_cursor,omitempty"`
+}
+
+// LibraryQueryError is a syntax error of a library query. Position counts
+// the characters of the query from 1.
+type LibraryQueryError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *LibraryQueryError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+func (e *LibraryQueryError) Unwrap() error {
+	return ErrInvalidLibraryQuery
+}
+
+// A library query a user saved with a name
+type SavedQuery struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// A search of the library by title, artist, album and composer. User, when
+// set, limits it to the songs of the user.
+type LibrarySearchQuery struct {
+	Query  string
+	User   string
+	Limit  int64
+	Offset int64
+}
+
+// The fields of the song with the words that matched between <mark> and
+// </mark>
+type LibrarySearchHighlights struct {
+	Title    string `json:"title"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	Composer string `json:"composer,omitempty"`
+}
+
+type LibrarySearchResult struct {
+	Track      Track                   `json:"track"`
+	Artist     string                  `json:"artist,omitempty"`
+	Album      string                  `json:"album,omitempty"`
+	Highlights LibrarySearchHighlights `json:"highlights"`
+	// Higher for better matches
+	Score float64 `json:"score"`
+}
+
+// A page of the results, best first. NextOffset is missing on the last one.
+type LibrarySearchPage struct {
+	Query      string                `json:"query"`
+	User       string                `json:"user,omitempty"`
+	Total      int64                 `json:"total"`
+	Limit      int64                 `json:"limit"`
+	Offset     int64                 `json:"offset"`
+	NextOffset *int64                `json:"next_offset,omitempty"`
+	Results    []LibrarySearchResult `json:"results"`
+}
+
+var (
+	ErrDownloadNotFound  = errors.New("download not found")
+	ErrDownloadFinished  = errors.New("download already finished")
+	ErrUnknownSource     = errors.New("unknown source")
+	ErrInvalidSearchType = errors.New("invalid search type")
+	ErrInvalidQuality    = errors.New("invalid quality")
+	ErrTrackNotInSource  = errors.New("track not found in the source")
+	ErrInvalidFilter     = errors.New("invalid filter")
+
+	ErrInvalidPlaylist        = errors.New("invalid playlist")
+	ErrPlaylistImportNotFound = errors.New("playlist import not found")
+	ErrImportEntryNotFound    = errors.New("playlist import entry not found")
+	ErrImportEntryResolved    = errors.New("playlist import entry is neither ambiguous nor failed")
+	ErrUnknownCandidate       = errors.New("track is not one of the entry's candidates")
+
+	ErrInvalidReleaseType = errors.New("invalid release type")
+	ErrFollowNotFound     = errors.New("the user doesn't follow the artist")
+	ErrArtistNotFound     = errors.New("artist not found")
+
+	ErrRequestNotFound = errors.New("download request not found")
+	ErrRequestReviewed = errors.New("download request is not pending")
+	ErrReasonRequired  = errors.New("a reason is required to reject a download request")
+
+	ErrQuotaExceeded = errors.New("download quota exceeded")
+	ErrInvalidQuota  = errors.New("invalid quota")
+
+	ErrInvalidBatch  = errors.New("invalid download batch")
+	ErrBatchNotFound = errors.New("download batch not found")
+
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+
+	ErrInvalidLibrarySearch     = errors.New("invalid library search")
+	ErrLibrarySearchUnavailable = errors.New("library search is not available, the server was built without FTS5")
+
+	ErrInvalidLibraryQuery = errors.New("invalid library query")
+	ErrInvalidQueryName    = errors.New("invalid saved query name")
+	ErrSavedQueryNotFound  = errors.New("saved query not found")
+)
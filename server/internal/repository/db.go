@@ -36,24 +36,48 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.countAlbumsByArtistStmt, err = db.PrepareContext(ctx, countAlbumsByArtist); err != nil {
 		return nil, fmt.Errorf("error preparing query CountAlbumsByArtist: %w", err)
 	}
+	if q.countDownloadHistoryStmt, err = db.PrepareContext(ctx, countDownloadHistory); err != nil {
+		return nil, fmt.Errorf("error preparing query CountDownloadHistory: %w", err)
+	}
 	if q.countTracksInAlbumStmt, err = db.PrepareContext(ctx, countTracksInAlbum); err != nil {
 		return nil, fmt.Errorf("error preparing query CountTracksInAlbum: %w", err)
 	}
+	if q.countUserDownloadsSinceStmt, err = db.PrepareContext(ctx, countUserDownloadsSince); err != nil {
+		return nil, fmt.Errorf("error preparing query CountUserDownloadsSince: %w", err)
+	}
 	if q.countUsersForTrackStmt, err = db.PrepareContext(ctx, countUsersForTrack); err != nil {
 		return nil, fmt.Errorf("error preparing query CountUsersForTrack: %w", err)
 	}
 	if q.deleteAlbumStmt, err = db.PrepareContext(ctx, deleteAlbum); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteAlbum: %w", err)
 	}
+	if q.deleteAlbumDownloadTracksStmt, err = db.PrepareContext(ctx, deleteAlbumDownloadTracks); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteAlbumDownloadTracks: %w", err)
+	}
 	if q.deleteArtistStmt, err = db.PrepareContext(ctx, deleteArtist); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteArtist: %w", err)
 	}
+	if q.deleteArtistFollowStmt, err = db.PrepareContext(ctx, deleteArtistFollow); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteArtistFollow: %w", err)
+	}
+	if q.deleteSavedQueryStmt, err = db.PrepareContext(ctx, deleteSavedQuery); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteSavedQuery: %w", err)
+	}
 	if q.deleteTrackStmt, err = db.PrepareContext(ctx, deleteTrack); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteTrack: %w", err)
 	}
 	if q.deleteUserTrackStmt, err = db.PrepareContext(ctx, deleteUserTrack); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteUserTrack: %w", err)
 	}
+	if q.deleteWebhookStmt, err = db.PrepareContext(ctx, deleteWebhook); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteWebhook: %w", err)
+	}
+	if q.detachDownloadsFromTrackStmt, err = db.PrepareContext(ctx, detachDownloadsFromTrack); err != nil {
+		return nil, fmt.Errorf("error preparing query DetachDownloadsFromTrack: %w", err)
+	}
+	if q.findTracksByNormalizedTitleAndArtistStmt, err = db.PrepareContext(ctx, findTracksByNormalizedTitleAndArtist); err != nil {
+		return nil, fmt.Errorf("error preparing query FindTracksByNormalizedTitleAndArtist: %w", err)
+	}
 	if q.getAlbumByDeezerIDStmt, err = db.PrepareContext(ctx, getAlbumByDeezerID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetAlbumByDeezerID: %w", err)
 	}
@@ -75,63 +99,231 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getArtistByTrackIDStmt, err = db.PrepareContext(ctx, getArtistByTrackID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetArtistByTrackID: %w", err)
 	}
+	if q.getDownloadBatchStmt, err = db.PrepareContext(ctx, getDownloadBatch); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDownloadBatch: %w", err)
+	}
+	if q.getDownloadJobByIDStmt, err = db.PrepareContext(ctx, getDownloadJobByID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDownloadJobByID: %w", err)
+	}
+	if q.getDownloadRequestStmt, err = db.PrepareContext(ctx, getDownloadRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query GetDownloadRequest: %w", err)
+	}
 	if q.getFirstTrackByAlbumIDStmt, err = db.PrepareContext(ctx, getFirstTrackByAlbumID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFirstTrackByAlbumID: %w", err)
 	}
+	if q.getPendingDownloadRequestStmt, err = db.PrepareContext(ctx, getPendingDownloadRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query GetPendingDownloadRequest: %w", err)
+	}
+	if q.getPlaylistImportByIDStmt, err = db.PrepareContext(ctx, getPlaylistImportByID); err != nil {
+		return nil, fmt.Errorf("error preparing query GetPlaylistImportByID: %w", err)
+	}
+	if q.getPlaylistImportEntryStmt, err = db.PrepareContext(ctx, getPlaylistImportEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query GetPlaylistImportEntry: %w", err)
+	}
+	if q.getSavedQueryStmt, err = db.PrepareContext(ctx, getSavedQuery); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSavedQuery: %w", err)
+	}
 	if q.getTrackByIDStmt, err = db.PrepareContext(ctx, getTrackByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetTrackByID: %w", err)
 	}
 	if q.getUserByUsernameStmt, err = db.PrepareContext(ctx, getUserByUsername); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUserByUsername: %w", err)
 	}
+	if q.getUserQuotaStmt, err = db.PrepareContext(ctx, getUserQuota); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUserQuota: %w", err)
+	}
+	if q.getUserStorageUsedStmt, err = db.PrepareContext(ctx, getUserStorageUsed); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUserStorageUsed: %w", err)
+	}
 	if q.getUserTrackStmt, err = db.PrepareContext(ctx, getUserTrack); err != nil {
 		return nil, fmt.Errorf("error preparing query GetUserTrack: %w", err)
 	}
+	if q.getWebhookStmt, err = db.PrepareContext(ctx, getWebhook); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWebhook: %w", err)
+	}
+	if q.getWebhookDeliveryStmt, err = db.PrepareContext(ctx, getWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query GetWebhookDelivery: %w", err)
+	}
+	if q.hasUpgradeToQualityStmt, err = db.PrepareContext(ctx, hasUpgradeToQuality); err != nil {
+		return nil, fmt.Errorf("error preparing query HasUpgradeToQuality: %w", err)
+	}
 	if q.insertAlbumStmt, err = db.PrepareContext(ctx, insertAlbum); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertAlbum: %w", err)
 	}
 	if q.insertArtistStmt, err = db.PrepareContext(ctx, insertArtist); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertArtist: %w", err)
 	}
+	if q.insertDownloadBatchStmt, err = db.PrepareContext(ctx, insertDownloadBatch); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertDownloadBatch: %w", err)
+	}
+	if q.insertDownloadBatchItemStmt, err = db.PrepareContext(ctx, insertDownloadBatchItem); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertDownloadBatchItem: %w", err)
+	}
 	if q.insertDownloadHistoryStmt, err = db.PrepareContext(ctx, insertDownloadHistory); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertDownloadHistory: %w", err)
 	}
+	if q.insertDownloadRequestStmt, err = db.PrepareContext(ctx, insertDownloadRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertDownloadRequest: %w", err)
+	}
+	if q.insertPlaylistImportStmt, err = db.PrepareContext(ctx, insertPlaylistImport); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertPlaylistImport: %w", err)
+	}
+	if q.insertPlaylistImportEntryStmt, err = db.PrepareContext(ctx, insertPlaylistImportEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertPlaylistImportEntry: %w", err)
+	}
+	if q.insertReleaseNotificationStmt, err = db.PrepareContext(ctx, insertReleaseNotification); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertReleaseNotification: %w", err)
+	}
 	if q.insertTrackStmt, err = db.PrepareContext(ctx, insertTrack); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertTrack: %w", err)
 	}
 	if q.insertUserStmt, err = db.PrepareContext(ctx, insertUser); err != nil {
 		return nil, fmt.Errorf("error preparing query InsertUser: %w", err)
 	}
+	if q.insertWebhookStmt, err = db.PrepareContext(ctx, insertWebhook); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertWebhook: %w", err)
+	}
+	if q.insertWebhookDeliveryStmt, err = db.PrepareContext(ctx, insertWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query InsertWebhookDelivery: %w", err)
+	}
 	if q.isTrackLinkedToUserByUsernameAndISRCStmt, err = db.PrepareContext(ctx, isTrackLinkedToUserByUsernameAndISRC); err != nil {
 		return nil, fmt.Errorf("error preparing query IsTrackLinkedToUserByUsernameAndISRC: %w", err)
 	}
+	if q.listActiveDownloadsStmt, err = db.PrepareContext(ctx, listActiveDownloads); err != nil {
+		return nil, fmt.Errorf("error preparing query ListActiveDownloads: %w", err)
+	}
+	if q.listAlbumDownloadTracksStmt, err = db.PrepareContext(ctx, listAlbumDownloadTracks); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAlbumDownloadTracks: %w", err)
+	}
+	if q.listArtistFollowsStmt, err = db.PrepareContext(ctx, listArtistFollows); err != nil {
+		return nil, fmt.Errorf("error preparing query ListArtistFollows: %w", err)
+	}
+	if q.listDownloadBatchItemsStmt, err = db.PrepareContext(ctx, listDownloadBatchItems); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDownloadBatchItems: %w", err)
+	}
+	if q.listDownloadHistoryStmt, err = db.PrepareContext(ctx, listDownloadHistory); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDownloadHistory: %w", err)
+	}
+	if q.listDownloadRequestsStmt, err = db.PrepareContext(ctx, listDownloadRequests); err != nil {
+		return nil, fmt.Errorf("error preparing query ListDownloadRequests: %w", err)
+	}
+	if q.listFailedDownloadsStmt, err = db.PrepareContext(ctx, listFailedDownloads); err != nil {
+		return nil, fmt.Errorf("error preparing query ListFailedDownloads: %w", err)
+	}
+	if q.listPendingWebhookDeliveriesStmt, err = db.PrepareContext(ctx, listPendingWebhookDeliveries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPendingWebhookDeliveries: %w", err)
+	}
+	if q.listPlaylistImportEntriesStmt, err = db.PrepareContext(ctx, listPlaylistImportEntries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPlaylistImportEntries: %w", err)
+	}
+	if q.listPlaylistImportsByUsernameStmt, err = db.PrepareContext(ctx, listPlaylistImportsByUsername); err != nil {
+		return nil, fmt.Errorf("error preparing query ListPlaylistImportsByUsername: %w", err)
+	}
+	if q.listReleaseNotificationsStmt, err = db.PrepareContext(ctx, listReleaseNotifications); err != nil {
+		return nil, fmt.Errorf("error preparing query ListReleaseNotifications: %w", err)
+	}
+	if q.listSavedQueriesStmt, err = db.PrepareContext(ctx, listSavedQueries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListSavedQueries: %w", err)
+	}
+	if q.listTrackUsersStmt, err = db.PrepareContext(ctx, listTrackUsers); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTrackUsers: %w", err)
+	}
 	if q.listTracksByDateStmt, err = db.PrepareContext(ctx, listTracksByDate); err != nil {
 		return nil, fmt.Errorf("error preparing query ListTracksByDate: %w", err)
 	}
+	if q.listTracksByISRCsStmt, err = db.PrepareContext(ctx, listTracksByISRCs); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTracksByISRCs: %w", err)
+	}
 	if q.listTracksByUsernameStmt, err = db.PrepareContext(ctx, listTracksByUsername); err != nil {
 		return nil, fmt.Errorf("error preparing query ListTracksByUsername: %w", err)
 	}
+	if q.listTracksUnderPathStmt, err = db.PrepareContext(ctx, listTracksUnderPath); err != nil {
+		return nil, fmt.Errorf("error preparing query ListTracksUnderPath: %w", err)
+	}
+	if q.listUserArtistFollowsStmt, err = db.PrepareContext(ctx, listUserArtistFollows); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUserArtistFollows: %w", err)
+	}
+	if q.listWebhookDeliveriesStmt, err = db.PrepareContext(ctx, listWebhookDeliveries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhookDeliveries: %w", err)
+	}
+	if q.listWebhooksStmt, err = db.PrepareContext(ctx, listWebhooks); err != nil {
+		return nil, fmt.Errorf("error preparing query ListWebhooks: %w", err)
+	}
+	if q.markReleaseNotificationsReadStmt, err = db.PrepareContext(ctx, markReleaseNotificationsRead); err != nil {
+		return nil, fmt.Errorf("error preparing query MarkReleaseNotificationsRead: %w", err)
+	}
+	if q.reopenDownloadRequestStmt, err = db.PrepareContext(ctx, reopenDownloadRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query ReopenDownloadRequest: %w", err)
+	}
+	if q.requeueFailedDownloadStmt, err = db.PrepareContext(ctx, requeueFailedDownload); err != nil {
+		return nil, fmt.Errorf("error preparing query RequeueFailedDownload: %w", err)
+	}
+	if q.reviewDownloadRequestStmt, err = db.PrepareContext(ctx, reviewDownloadRequest); err != nil {
+		return nil, fmt.Errorf("error preparing query ReviewDownloadRequest: %w", err)
+	}
 	if q.searchTracksByISRCStmt, err = db.PrepareContext(ctx, searchTracksByISRC); err != nil {
 		return nil, fmt.Errorf("error preparing query SearchTracksByISRC: %w", err)
 	}
 	if q.searchTracksByTitleStmt, err = db.PrepareContext(ctx, searchTracksByTitle); err != nil {
 		return nil, fmt.Errorf("error preparing query SearchTracksByTitle: %w", err)
 	}
+	if q.setDownloadRequestDownloadStmt, err = db.PrepareContext(ctx, setDownloadRequestDownload); err != nil {
+		return nil, fmt.Errorf("error preparing query SetDownloadRequestDownload: %w", err)
+	}
+	if q.setReleaseNotificationDownloadStmt, err = db.PrepareContext(ctx, setReleaseNotificationDownload); err != nil {
+		return nil, fmt.Errorf("error preparing query SetReleaseNotificationDownload: %w", err)
+	}
 	if q.trackExistsByISRCStmt, err = db.PrepareContext(ctx, trackExistsByISRC); err != nil {
 		return nil, fmt.Errorf("error preparing query TrackExistsByISRC: %w", err)
 	}
 	if q.updateAlbumArtPathStmt, err = db.PrepareContext(ctx, updateAlbumArtPath); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateAlbumArtPath: %w", err)
 	}
+	if q.updateArtistFollowCheckedStmt, err = db.PrepareContext(ctx, updateArtistFollowChecked); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateArtistFollowChecked: %w", err)
+	}
 	if q.updateDownloadCompletionStmt, err = db.PrepareContext(ctx, updateDownloadCompletion); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateDownloadCompletion: %w", err)
 	}
+	if q.updateDownloadRetryStmt, err = db.PrepareContext(ctx, updateDownloadRetry); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateDownloadRetry: %w", err)
+	}
+	if q.updateDownloadSourceStmt, err = db.PrepareContext(ctx, updateDownloadSource); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateDownloadSource: %w", err)
+	}
+	if q.updateDownloadStatusStmt, err = db.PrepareContext(ctx, updateDownloadStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateDownloadStatus: %w", err)
+	}
 	if q.updateLastLoginStmt, err = db.PrepareContext(ctx, updateLastLogin); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateLastLogin: %w", err)
 	}
+	if q.updatePlaylistImportEntryResultStmt, err = db.PrepareContext(ctx, updatePlaylistImportEntryResult); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdatePlaylistImportEntryResult: %w", err)
+	}
+	if q.updatePlaylistImportStatusStmt, err = db.PrepareContext(ctx, updatePlaylistImportStatus); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdatePlaylistImportStatus: %w", err)
+	}
+	if q.updateTrackFileStmt, err = db.PrepareContext(ctx, updateTrackFile); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateTrackFile: %w", err)
+	}
 	if q.updateTrackFilePathStmt, err = db.PrepareContext(ctx, updateTrackFilePath); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateTrackFilePath: %w", err)
 	}
+	if q.updateUserTrackSymlinkStmt, err = db.PrepareContext(ctx, updateUserTrackSymlink); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateUserTrackSymlink: %w", err)
+	}
+	if q.updateWebhookDeliveryStmt, err = db.PrepareContext(ctx, updateWebhookDelivery); err != nil {
+		return nil, fmt.Errorf("error preparing query UpdateWebhookDelivery: %w", err)
+	}
+	if q.upsertArtistFollowStmt, err = db.PrepareContext(ctx, upsertArtistFollow); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertArtistFollow: %w", err)
+	}
+	if q.upsertSavedQueryStmt, err = db.PrepareContext(ctx, upsertSavedQuery); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertSavedQuery: %w", err)
+	}
+	if q.upsertUserQuotaStmt, err = db.PrepareContext(ctx, upsertUserQuota); err != nil {
+		return nil, fmt.Errorf("error preparing query UpsertUserQuota: %w", err)
+	}
 	return &q, nil
 }
 
@@ -157,11 +349,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing countAlbumsByArtistStmt: %w", cerr)
 		}
 	}
+	if q.countDownloadHistoryStmt != nil {
+		if cerr := q.countDownloadHistoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countDownloadHistoryStmt: %w", cerr)
+		}
+	}
 	if q.countTracksInAlbumStmt != nil {
 		if cerr := q.countTracksInAlbumStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing countTracksInAlbumStmt: %w", cerr)
 		}
 	}
+	if q.countUserDownloadsSinceStmt != nil {
+		if cerr := q.countUserDownloadsSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing countUserDownloadsSinceStmt: %w", cerr)
+		}
+	}
 	if q.countUsersForTrackStmt != nil {
 		if cerr := q.countUsersForTrackStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing countUsersForTrackStmt: %w", cerr)
@@ -172,11 +374,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteAlbumStmt: %w", cerr)
 		}
 	}
+	if q.deleteAlbumDownloadTracksStmt != nil {
+		if cerr := q.deleteAlbumDownloadTracksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteAlbumDownloadTracksStmt: %w", cerr)
+		}
+	}
 	if q.deleteArtistStmt != nil {
 		if cerr := q.deleteArtistStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteArtistStmt: %w", cerr)
 		}
 	}
+	if q.deleteArtistFollowStmt != nil {
+		if cerr := q.deleteArtistFollowStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteArtistFollowStmt: %w", cerr)
+		}
+	}
+	if q.deleteSavedQueryStmt != nil {
+		if cerr := q.deleteSavedQueryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteSavedQueryStmt: %w", cerr)
+		}
+	}
 	if q.deleteTrackStmt != nil {
 		if cerr := q.deleteTrackStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteTrackStmt: %w", cerr)
@@ -187,6 +404,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteUserTrackStmt: %w", cerr)
 		}
 	}
+	if q.deleteWebhookStmt != nil {
+		if cerr := q.deleteWebhookStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteWebhookStmt: %w", cerr)
+		}
+	}
+	if q.detachDownloadsFromTrackStmt != nil {
+		if cerr := q.detachDownloadsFromTrackStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing detachDownloadsFromTrackStmt: %w", cerr)
+		}
+	}
+	if q.findTracksByNormalizedTitleAndArtistStmt != nil {
+		if cerr := q.findTracksByNormalizedTitleAndArtistStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing findTracksByNormalizedTitleAndArtistStmt: %w", cerr)
+		}
+	}
 	if q.getAlbumByDeezerIDStmt != nil {
 		if cerr := q.getAlbumByDeezerIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getAlbumByDeezerIDStmt: %w", cerr)
@@ -222,11 +454,46 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getArtistByTrackIDStmt: %w", cerr)
 		}
 	}
+	if q.getDownloadBatchStmt != nil {
+		if cerr := q.getDownloadBatchStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDownloadBatchStmt: %w", cerr)
+		}
+	}
+	if q.getDownloadJobByIDStmt != nil {
+		if cerr := q.getDownloadJobByIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDownloadJobByIDStmt: %w", cerr)
+		}
+	}
+	if q.getDownloadRequestStmt != nil {
+		if cerr := q.getDownloadRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getDownloadRequestStmt: %w", cerr)
+		}
+	}
 	if q.getFirstTrackByAlbumIDStmt != nil {
 		if cerr := q.getFirstTrackByAlbumIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFirstTrackByAlbumIDStmt: %w", cerr)
 		}
 	}
+	if q.getPendingDownloadRequestStmt != nil {
+		if cerr := q.getPendingDownloadRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getPendingDownloadRequestStmt: %w", cerr)
+		}
+	}
+	if q.getPlaylistImportByIDStmt != nil {
+		if cerr := q.getPlaylistImportByIDStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getPlaylistImportByIDStmt: %w", cerr)
+		}
+	}
+	if q.getPlaylistImportEntryStmt != nil {
+		if cerr := q.getPlaylistImportEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getPlaylistImportEntryStmt: %w", cerr)
+		}
+	}
+	if q.getSavedQueryStmt != nil {
+		if cerr := q.getSavedQueryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSavedQueryStmt: %w", cerr)
+		}
+	}
 	if q.getTrackByIDStmt != nil {
 		if cerr := q.getTrackByIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getTrackByIDStmt: %w", cerr)
@@ -237,11 +504,36 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getUserByUsernameStmt: %w", cerr)
 		}
 	}
+	if q.getUserQuotaStmt != nil {
+		if cerr := q.getUserQuotaStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUserQuotaStmt: %w", cerr)
+		}
+	}
+	if q.getUserStorageUsedStmt != nil {
+		if cerr := q.getUserStorageUsedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUserStorageUsedStmt: %w", cerr)
+		}
+	}
 	if q.getUserTrackStmt != nil {
 		if cerr := q.getUserTrackStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getUserTrackStmt: %w", cerr)
 		}
 	}
+	if q.getWebhookStmt != nil {
+		if cerr := q.getWebhookStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWebhookStmt: %w", cerr)
+		}
+	}
+	if q.getWebhookDeliveryStmt != nil {
+		if cerr := q.getWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getWebhookDeliveryStmt: %w", cerr)
+		}
+	}
+	if q.hasUpgradeToQualityStmt != nil {
+		if cerr := q.hasUpgradeToQualityStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing hasUpgradeToQualityStmt: %w", cerr)
+		}
+	}
 	if q.insertAlbumStmt != nil {
 		if cerr := q.insertAlbumStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertAlbumStmt: %w", cerr)
@@ -252,11 +544,41 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing insertArtistStmt: %w", cerr)
 		}
 	}
+	if q.insertDownloadBatchStmt != nil {
+		if cerr := q.insertDownloadBatchStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertDownloadBatchStmt: %w", cerr)
+		}
+	}
+	if q.insertDownloadBatchItemStmt != nil {
+		if cerr := q.insertDownloadBatchItemStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertDownloadBatchItemStmt: %w", cerr)
+		}
+	}
 	if q.insertDownloadHistoryStmt != nil {
 		if cerr := q.insertDownloadHistoryStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertDownloadHistoryStmt: %w", cerr)
 		}
 	}
+	if q.insertDownloadRequestStmt != nil {
+		if cerr := q.insertDownloadRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertDownloadRequestStmt: %w", cerr)
+		}
+	}
+	if q.insertPlaylistImportStmt != nil {
+		if cerr := q.insertPlaylistImportStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertPlaylistImportStmt: %w", cerr)
+		}
+	}
+	if q.insertPlaylistImportEntryStmt != nil {
+		if cerr := q.insertPlaylistImportEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertPlaylistImportEntryStmt: %w", cerr)
+		}
+	}
+	if q.insertReleaseNotificationStmt != nil {
+		if cerr := q.insertReleaseNotificationStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertReleaseNotificationStmt: %w", cerr)
+		}
+	}
 	if q.insertTrackStmt != nil {
 		if cerr := q.insertTrackStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing insertTrackStmt: %w", cerr)
@@ -267,21 +589,141 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing insertUserStmt: %w", cerr)
 		}
 	}
+	if q.insertWebhookStmt != nil {
+		if cerr := q.insertWebhookStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertWebhookStmt: %w", cerr)
+		}
+	}
+	if q.insertWebhookDeliveryStmt != nil {
+		if cerr := q.insertWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing insertWebhookDeliveryStmt: %w", cerr)
+		}
+	}
 	if q.isTrackLinkedToUserByUsernameAndISRCStmt != nil {
 		if cerr := q.isTrackLinkedToUserByUsernameAndISRCStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing isTrackLinkedToUserByUsernameAndISRCStmt: %w", cerr)
 		}
 	}
+	if q.listActiveDownloadsStmt != nil {
+		if cerr := q.listActiveDownloadsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listActiveDownloadsStmt: %w", cerr)
+		}
+	}
+	if q.listAlbumDownloadTracksStmt != nil {
+		if cerr := q.listAlbumDownloadTracksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAlbumDownloadTracksStmt: %w", cerr)
+		}
+	}
+	if q.listArtistFollowsStmt != nil {
+		if cerr := q.listArtistFollowsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listArtistFollowsStmt: %w", cerr)
+		}
+	}
+	if q.listDownloadBatchItemsStmt != nil {
+		if cerr := q.listDownloadBatchItemsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDownloadBatchItemsStmt: %w", cerr)
+		}
+	}
+	if q.listDownloadHistoryStmt != nil {
+		if cerr := q.listDownloadHistoryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDownloadHistoryStmt: %w", cerr)
+		}
+	}
+	if q.listDownloadRequestsStmt != nil {
+		if cerr := q.listDownloadRequestsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listDownloadRequestsStmt: %w", cerr)
+		}
+	}
+	if q.listFailedDownloadsStmt != nil {
+		if cerr := q.listFailedDownloadsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listFailedDownloadsStmt: %w", cerr)
+		}
+	}
+	if q.listPendingWebhookDeliveriesStmt != nil {
+		if cerr := q.listPendingWebhookDeliveriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPendingWebhookDeliveriesStmt: %w", cerr)
+		}
+	}
+	if q.listPlaylistImportEntriesStmt != nil {
+		if cerr := q.listPlaylistImportEntriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPlaylistImportEntriesStmt: %w", cerr)
+		}
+	}
+	if q.listPlaylistImportsByUsernameStmt != nil {
+		if cerr := q.listPlaylistImportsByUsernameStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listPlaylistImportsByUsernameStmt: %w", cerr)
+		}
+	}
+	if q.listReleaseNotificationsStmt != nil {
+		if cerr := q.listReleaseNotificationsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listReleaseNotificationsStmt: %w", cerr)
+		}
+	}
+	if q.listSavedQueriesStmt != nil {
+		if cerr := q.listSavedQueriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listSavedQueriesStmt: %w", cerr)
+		}
+	}
+	if q.listTrackUsersStmt != nil {
+		if cerr := q.listTrackUsersStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTrackUsersStmt: %w", cerr)
+		}
+	}
 	if q.listTracksByDateStmt != nil {
 		if cerr := q.listTracksByDateStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listTracksByDateStmt: %w", cerr)
 		}
 	}
+	if q.listTracksByISRCsStmt != nil {
+		if cerr := q.listTracksByISRCsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTracksByISRCsStmt: %w", cerr)
+		}
+	}
 	if q.listTracksByUsernameStmt != nil {
 		if cerr := q.listTracksByUsernameStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listTracksByUsernameStmt: %w", cerr)
 		}
 	}
+	if q.listTracksUnderPathStmt != nil {
+		if cerr := q.listTracksUnderPathStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listTracksUnderPathStmt: %w", cerr)
+		}
+	}
+	if q.listUserArtistFollowsStmt != nil {
+		if cerr := q.listUserArtistFollowsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUserArtistFollowsStmt: %w", cerr)
+		}
+	}
+	if q.listWebhookDeliveriesStmt != nil {
+		if cerr := q.listWebhookDeliveriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhookDeliveriesStmt: %w", cerr)
+		}
+	}
+	if q.listWebhooksStmt != nil {
+		if cerr := q.listWebhooksStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listWebhooksStmt: %w", cerr)
+		}
+	}
+	if q.markReleaseNotificationsReadStmt != nil {
+		if cerr := q.markReleaseNotificationsReadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markReleaseNotificationsReadStmt: %w", cerr)
+		}
+	}
+	if q.reopenDownloadRequestStmt != nil {
+		if cerr := q.reopenDownloadRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing reopenDownloadRequestStmt: %w", cerr)
+		}
+	}
+	if q.requeueFailedDownloadStmt != nil {
+		if cerr := q.requeueFailedDownloadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing requeueFailedDownloadStmt: %w", cerr)
+		}
+	}
+	if q.reviewDownloadRequestStmt != nil {
+		if cerr := q.reviewDownloadRequestStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing reviewDownloadRequestStmt: %w", cerr)
+		}
+	}
 	if q.searchTracksByISRCStmt != nil {
 		if cerr := q.searchTracksByISRCStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing searchTracksByISRCStmt: %w", cerr)
@@ -292,6 +734,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing searchTracksByTitleStmt: %w", cerr)
 		}
 	}
+	if q.setDownloadRequestDownloadStmt != nil {
+		if cerr := q.setDownloadRequestDownloadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setDownloadRequestDownloadStmt: %w", cerr)
+		}
+	}
+	if q.setReleaseNotificationDownloadStmt != nil {
+		if cerr := q.setReleaseNotificationDownloadStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setReleaseNotificationDownloadStmt: %w", cerr)
+		}
+	}
 	if q.trackExistsByISRCStmt != nil {
 		if cerr := q.trackExistsByISRCStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing trackExistsByISRCStmt: %w", cerr)
@@ -302,21 +754,81 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing updateAlbumArtPathStmt: %w", cerr)
 		}
 	}
+	if q.updateArtistFollowCheckedStmt != nil {
+		if cerr := q.updateArtistFollowCheckedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateArtistFollowCheckedStmt: %w", cerr)
+		}
+	}
 	if q.updateDownloadCompletionStmt != nil {
 		if cerr := q.updateDownloadCompletionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateDownloadCompletionStmt: %w", cerr)
 		}
 	}
+	if q.updateDownloadRetryStmt != nil {
+		if cerr := q.updateDownloadRetryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateDownloadRetryStmt: %w", cerr)
+		}
+	}
+	if q.updateDownloadSourceStmt != nil {
+		if cerr := q.updateDownloadSourceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateDownloadSourceStmt: %w", cerr)
+		}
+	}
+	if q.updateDownloadStatusStmt != nil {
+		if cerr := q.updateDownloadStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateDownloadStatusStmt: %w", cerr)
+		}
+	}
 	if q.updateLastLoginStmt != nil {
 		if cerr := q.updateLastLoginStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateLastLoginStmt: %w", cerr)
 		}
 	}
+	if q.updatePlaylistImportEntryResultStmt != nil {
+		if cerr := q.updatePlaylistImportEntryResultStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updatePlaylistImportEntryResultStmt: %w", cerr)
+		}
+	}
+	if q.updatePlaylistImportStatusStmt != nil {
+		if cerr := q.updatePlaylistImportStatusStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updatePlaylistImportStatusStmt: %w", cerr)
+		}
+	}
+	if q.updateTrackFileStmt != nil {
+		if cerr := q.updateTrackFileStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateTrackFileStmt: %w", cerr)
+		}
+	}
 	if q.updateTrackFilePathStmt != nil {
 		if cerr := q.updateTrackFilePathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateTrackFilePathStmt: %w", cerr)
 		}
 	}
+	if q.updateUserTrackSymlinkStmt != nil {
+		if cerr := q.updateUserTrackSymlinkStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateUserTrackSymlinkStmt: %w", cerr)
+		}
+	}
+	if q.updateWebhookDeliveryStmt != nil {
+		if cerr := q.updateWebhookDeliveryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing updateWebhookDeliveryStmt: %w", cerr)
+		}
+	}
+	if q.upsertArtistFollowStmt != nil {
+		if cerr := q.upsertArtistFollowStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertArtistFollowStmt: %w", cerr)
+		}
+	}
+	if q.upsertSavedQueryStmt != nil {
+		if cerr := q.upsertSavedQueryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertSavedQueryStmt: %w", cerr)
+		}
+	}
+	if q.upsertUserQuotaStmt != nil {
+		if cerr := q.upsertUserQuotaStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing upsertUserQuotaStmt: %w", cerr)
+		}
+	}
 	return err
 }
 
@@ -360,12 +872,20 @@ type Queries struct {
 	albumExistsByDeezerIDStmt                *sql.Stmt
 	artistExistsByDeezerIDStmt               *sql.Stmt
 	countAlbumsByArtistStmt                  *sql.Stmt
+	countDownloadHistoryStmt                 *sql.Stmt
 	countTracksInAlbumStmt                   *sql.Stmt
+	countUserDownloadsSinceStmt              *sql.Stmt
 	countUsersForTrackStmt                   *sql.Stmt
 	deleteAlbumStmt                          *sql.Stmt
+	deleteAlbumDownloadTracksStmt            *sql.Stmt
 	deleteArtistStmt                         *sql.Stmt
+	deleteArtistFollowStmt                   *sql.Stmt
+	deleteSavedQueryStmt                     *sql.Stmt
 	deleteTrackStmt                          *sql.Stmt
 	deleteUserTrackStmt                      *sql.Stmt
+	deleteWebhookStmt                        *sql.Stmt
+	detachDownloadsFromTrackStmt             *sql.Stmt
+	findTracksByNormalizedTitleAndArtistStmt *sql.Stmt
 	getAlbumByDeezerIDStmt                   *sql.Stmt
 	getAlbumByNormalizedTitleAndArtistStmt   *sql.Stmt
 	getAlbumByTrackIDStmt                    *sql.Stmt
@@ -373,25 +893,81 @@ type Queries struct {
 	getArtistByDeezerIDStmt                  *sql.Stmt
 	getArtistByNormalizedNameStmt            *sql.Stmt
 	getArtistByTrackIDStmt                   *sql.Stmt
+	getDownloadBatchStmt                     *sql.Stmt
+	getDownloadJobByIDStmt                   *sql.Stmt
+	getDownloadRequestStmt                   *sql.Stmt
 	getFirstTrackByAlbumIDStmt               *sql.Stmt
+	getPendingDownloadRequestStmt            *sql.Stmt
+	getPlaylistImportByIDStmt                *sql.Stmt
+	getPlaylistImportEntryStmt               *sql.Stmt
+	getSavedQueryStmt                        *sql.Stmt
 	getTrackByIDStmt                         *sql.Stmt
 	getUserByUsernameStmt                    *sql.Stmt
+	getUserQuotaStmt                         *sql.Stmt
+	getUserStorageUsedStmt                   *sql.Stmt
 	getUserTrackStmt                         *sql.Stmt
+	getWebhookStmt                           *sql.Stmt
+	getWebhookDeliveryStmt                   *sql.Stmt
+	hasUpgradeToQualityStmt                  *sql.Stmt
 	insertAlbumStmt                          *sql.Stmt
 	insertArtistStmt                         *sql.Stmt
+	insertDownloadBatchStmt                  *sql.Stmt
+	insertDownloadBatchItemStmt              *sql.Stmt
 	insertDownloadHistoryStmt                *sql.Stmt
+	insertDownloadRequestStmt                *sql.Stmt
+	insertPlaylistImportStmt                 *sql.Stmt
+	insertPlaylistImportEntryStmt            *sql.Stmt
+	insertReleaseNotificationStmt            *sql.Stmt
 	insertTrackStmt                          *sql.Stmt
 	insertUserStmt                           *sql.Stmt
+	insertWebhookStmt                        *sql.Stmt
+	insertWebhookDeliveryStmt                *sql.Stmt
 	isTrackLinkedToUserByUsernameAndISRCStmt *sql.Stmt
+	listActiveDownloadsStmt                  *sql.Stmt
+	listAlbumDownloadTracksStmt              *sql.Stmt
+	listArtistFollowsStmt                    *sql.Stmt
+	listDownloadBatchItemsStmt               *sql.Stmt
+	listDownloadHistoryStmt                  *sql.Stmt
+	listDownloadRequestsStmt                 *sql.Stmt
+	listFailedDownloadsStmt                  *sql.Stmt
+	listPendingWebhookDeliveriesStmt         *sql.Stmt
+	listPlaylistImportEntriesStmt            *sql.Stmt
+	listPlaylistImportsByUsernameStmt        *sql.Stmt
+	listReleaseNotificationsStmt             *sql.Stmt
+	listSavedQueriesStmt                     *sql.Stmt
+	listTrackUsersStmt                       *sql.Stmt
 	listTracksByDateStmt                     *sql.Stmt
+	listTracksByISRCsStmt                    *sql.Stmt
 	listTracksByUsernameStmt                 *sql.Stmt
+	listTracksUnderPathStmt                  *sql.Stmt
+	listUserArtistFollowsStmt                *sql.Stmt
+	listWebhookDeliveriesStmt                *sql.Stmt
+	listWebhooksStmt                         *sql.Stmt
+	markReleaseNotificationsReadStmt         *sql.Stmt
+	reopenDownloadRequestStmt                *sql.Stmt
+	requeueFailedDownloadStmt                *sql.Stmt
+	reviewDownloadRequestStmt                *sql.Stmt
 	searchTracksByISRCStmt                   *sql.Stmt
 	searchTracksByTitleStmt                  *sql.Stmt
+	setDownloadRequestDownloadStmt           *sql.Stmt
+	setReleaseNotificationDownloadStmt       *sql.Stmt
 	trackExistsByISRCStmt                    *sql.Stmt
 	updateAlbumArtPathStmt                   *sql.Stmt
+	updateArtistFollowCheckedStmt            *sql.Stmt
 	updateDownloadCompletionStmt             *sql.Stmt
+	updateDownloadRetryStmt                  *sql.Stmt
+	updateDownloadSourceStmt                 *sql.Stmt
+	updateDownloadStatusStmt                 *sql.Stmt
 	updateLastLoginStmt                      *sql.Stmt
+	updatePlaylistImportEntryResultStmt      *sql.Stmt
+	updatePlaylistImportStatusStmt           *sql.Stmt
+	updateTrackFileStmt                      *sql.Stmt
 	updateTrackFilePathStmt                  *sql.Stmt
+	updateUserTrackSymlinkStmt               *sql.Stmt
+	updateWebhookDeliveryStmt                *sql.Stmt
+	upsertArtistFollowStmt                   *sql.Stmt
+	upsertSavedQueryStmt                     *sql.Stmt
+	upsertUserQuotaStmt                      *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
@@ -402,12 +978,20 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		albumExistsByDeezerIDStmt:                q.albumExistsByDeezerIDStmt,
 		artistExistsByDeezerIDStmt:               q.artistExistsByDeezerIDStmt,
 		countAlbumsByArtistStmt:                  q.countAlbumsByArtistStmt,
+		countDownloadHistoryStmt:                 q.countDownloadHistoryStmt,
 		countTracksInAlbumStmt:                   q.countTracksInAlbumStmt,
+		countUserDownloadsSinceStmt:              q.countUserDownloadsSinceStmt,
 		countUsersForTrackStmt:                   q.countUsersForTrackStmt,
 		deleteAlbumStmt:                          q.deleteAlbumStmt,
+		deleteAlbumDownloadTracksStmt:            q.deleteAlbumDownloadTracksStmt,
 		deleteArtistStmt:                         q.deleteArtistStmt,
+		deleteArtistFollowStmt:                   q.deleteArtistFollowStmt,
+		deleteSavedQueryStmt:                     q.deleteSavedQueryStmt,
 		deleteTrackStmt:                          q.deleteTrackStmt,
 		deleteUserTrackStmt:                      q.deleteUserTrackStmt,
+		deleteWebhookStmt:                        q.deleteWebhookStmt,
+		detachDownloadsFromTrackStmt:             q.detachDownloadsFromTrackStmt,
+		findTracksByNormalizedTitleAndArtistStmt: q.findTracksByNormalizedTitleAndArtistStmt,
 		getAlbumByDeezerIDStmt:                   q.getAlbumByDeezerIDStmt,
 		getAlbumByNormalizedTitleAndArtistStmt:   q.getAlbumByNormalizedTitleAndArtistStmt,
 		getAlbumByTrackIDStmt:                    q.getAlbumByTrackIDStmt,
@@ -415,24 +999,80 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		getArtistByDeezerIDStmt:                  q.getArtistByDeezerIDStmt,
 		getArtistByNormalizedNameStmt:            q.getArtistByNormalizedNameStmt,
 		getArtistByTrackIDStmt:                   q.getArtistByTrackIDStmt,
+		getDownloadBatchStmt:                     q.getDownloadBatchStmt,
+		getDownloadJobByIDStmt:                   q.getDownloadJobByIDStmt,
+		getDownloadRequestStmt:                   q.getDownloadRequestStmt,
 		getFirstTrackByAlbumIDStmt:               q.getFirstTrackByAlbumIDStmt,
+		getPendingDownloadRequestStmt:            q.getPendingDownloadRequestStmt,
+		getPlaylistImportByIDStmt:                q.getPlaylistImportByIDStmt,
+		getPlaylistImportEntryStmt:               q.getPlaylistImportEntryStmt,
+		getSavedQueryStmt:                        q.getSavedQueryStmt,
 		getTrackByIDStmt:                         q.getTrackByIDStmt,
 		getUserByUsernameStmt:                    q.getUserByUsernameStmt,
+		getUserQuotaStmt:                         q.getUserQuotaStmt,
+		getUserStorageUsedStmt:                   q.getUserStorageUsedStmt,
 		getUserTrackStmt:                         q.getUserTrackStmt,
+		getWebhookStmt:                           q.getWebhookStmt,
+		getWebhookDeliveryStmt:                   q.getWebhookDeliveryStmt,
+		hasUpgradeToQualityStmt:                  q.hasUpgradeToQualityStmt,
 		insertAlbumStmt:                          q.insertAlbumStmt,
 		insertArtistStmt:                         q.insertArtistStmt,
+		insertDownloadBatchStmt:                  q.insertDownloadBatchStmt,
+		insertDownloadBatchItemStmt:              q.insertDownloadBatchItemStmt,
 		insertDownloadHistoryStmt:                q.insertDownloadHistoryStmt,
+		insertDownloadRequestStmt:                q.insertDownloadRequestStmt,
+		insertPlaylistImportStmt:                 q.insertPlaylistImportStmt,
+		insertPlaylistImportEntryStmt:            q.insertPlaylistImportEntryStmt,
+		insertReleaseNotificationStmt:            q.insertReleaseNotificationStmt,
 		insertTrackStmt:                          q.insertTrackStmt,
 		insertUserStmt:                           q.insertUserStmt,
+		insertWebhookStmt:                        q.insertWebhookStmt,
+		insertWebhookDeliveryStmt:                q.insertWebhookDeliveryStmt,
 		isTrackLinkedToUserByUsernameAndISRCStmt: q.isTrackLinkedToUserByUsernameAndISRCStmt,
+		listActiveDownloadsStmt:                  q.listActiveDownloadsStmt,
+		listAlbumDownloadTracksStmt:              q.listAlbumDownloadTracksStmt,
+		listArtistFollowsStmt:                    q.listArtistFollowsStmt,
+		listDownloadBatchItemsStmt:               q.listDownloadBatchItemsStmt,
+		listDownloadHistoryStmt:                  q.listDownloadHistoryStmt,
+		listDownloadRequestsStmt:                 q.listDownloadRequestsStmt,
+		listFailedDownloadsStmt:                  q.listFailedDownloadsStmt,
+		listPendingWebhookDeliveriesStmt:         q.listPendingWebhookDeliveriesStmt,
+		listPlaylistImportEntriesStmt:            q.listPlaylistImportEntriesStmt,
+		listPlaylistImportsByUsernameStmt:        q.listPlaylistImportsByUsernameStmt,
+		listReleaseNotificationsStmt:             q.listReleaseNotificationsStmt,
+		listSavedQueriesStmt:                     q.listSavedQueriesStmt,
+		listTrackUsersStmt:                       q.listTrackUsersStmt,
 		listTracksByDateStmt:                     q.listTracksByDateStmt,
+		listTracksByISRCsStmt:                    q.listTracksByISRCsStmt,
 		listTracksByUsernameStmt:                 q.listTracksByUsernameStmt,
+		listTracksUnderPathStmt:                  q.listTracksUnderPathStmt,
+		listUserArtistFollowsStmt:                q.listUserArtistFollowsStmt,
+		listWebhookDeliveriesStmt:                q.listWebhookDeliveriesStmt,
+		listWebhooksStmt:                         q.listWebhooksStmt,
+		markReleaseNotificationsReadStmt:         q.markReleaseNotificationsReadStmt,
+		reopenDownloadRequestStmt:                q.reopenDownloadRequestStmt,
+		requeueFailedDownloadStmt:                q.requeueFailedDownloadStmt,
+		reviewDownloadRequestStmt:                q.reviewDownloadRequestStmt,
 		searchTracksByISRCStmt:                   q.searchTracksByISRCStmt,
 		searchTracksByTitleStmt:                  q.searchTracksByTitleStmt,
+		setDownloadRequestDownloadStmt:           q.setDownloadRequestDownloadStmt,
+		setReleaseNotificationDownloadStmt:       q.setReleaseNotificationDownloadStmt,
 		trackExistsByISRCStmt:                    q.trackExistsByISRCStmt,
 		updateAlbumArtPathStmt:                   q.updateAlbumArtPathStmt,
+		updateArtistFollowCheckedStmt:            q.updateArtistFollowCheckedStmt,
 		updateDownloadCompletionStmt:             q.updateDownloadCompletionStmt,
+		updateDownloadRetryStmt:                  q.updateDownloadRetryStmt,
+		updateDownloadSourceStmt:                 q.updateDownloadSourceStmt,
+		updateDownloadStatusStmt:                 q.updateDownloadStatusStmt,
 		updateLastLoginStmt:                      q.updateLastLoginStmt,
+		updatePlaylistImportEntryResultStmt:      q.updatePlaylistImportEntryResultStmt,
+		updatePlaylistImportStatusStmt:           q.updatePlaylistImportStatusStmt,
+		updateTrackFileStmt:                      q.updateTrackFileStmt,
 		updateTrackFilePathStmt:                  q.updateTrackFilePathStmt,
+		updateUserTrackSymlinkStmt:               q.updateUserTrackSymlinkStmt,
+		updateWebhookDeliveryStmt:                q.updateWebhookDeliveryStmt,
+		upsertArtistFollowStmt:                   q.upsertArtistFollowStmt,
+		upsertSavedQueryStmt:                     q.upsertSavedQueryStmt,
+		upsertUserQuotaStmt:                      q.upsertUserQuotaStmt,
 	}
 }
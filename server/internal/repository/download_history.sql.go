@@ -10,27 +10,155 @@ import (
 	"database/sql"
 )
 
+const countDownloadHistory = `-- name: CountDownloadHistory :one
+SELECT COUNT(*) FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE (?1 IS NULL OR u.username = ?1)
+  AND (?2 IS NULL OR dh.status = ?2)
+  AND (?3 IS NULL OR dh.service = ?3)
+  AND (?4 IS NULL OR dh.quality = ?4)
+  AND (?5 IS NULL OR datetime(dh.started_at) >= datetime(?5))
+  AND (?6 IS NULL OR datetime(dh.started_at) < datetime(?6))
+`
+
+type CountDownloadHistoryParams struct {
+	Username      sql.NullString `json:"username"`
+	Status        sql.NullString `json:"status"`
+	Service       sql.NullString `json:"service"`
+	Quality       sql.NullInt64  `json:"quality"`
+	StartedAfter  sql.NullTime   `json:"started_after"`
+	StartedBefore sql.NullTime   `json:"started_before"`
+}
+
+func (q *Queries) CountDownloadHistory(ctx context.Context, arg CountDownloadHistoryParams) (int64, error) {
+	row := q.queryRow(ctx, q.countDownloadHistoryStmt, countDownloadHistory,
+		arg.Username,
+		arg.Status,
+		arg.Service,
+		arg.Quality,
+		arg.StartedAfter,
+		arg.StartedBefore,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAlbumDownloadTracks = `-- name: DeleteAlbumDownloadTracks :exec
+DELETE FROM download_history
+WHERE parent_id = ?1
+`
+
+func (q *Queries) DeleteAlbumDownloadTracks(ctx context.Context, parentID sql.NullString) error {
+	_, err := q.exec(ctx, q.deleteAlbumDownloadTracksStmt, deleteAlbumDownloadTracks, parentID)
+	return err
+}
+
+const detachDownloadsFromTrack = `-- name: DetachDownloadsFromTrack :exec
+UPDATE download_history
+SET track_id = NULL
+WHERE track_id = ?1
+`
+
+func (q *Queries) DetachDownloadsFromTrack(ctx context.Context, trackID sql.NullInt64) error {
+	_, err := q.exec(ctx, q.detachDownloadsFromTrackStmt, detachDownloadsFromTrack, trackID)
+	return err
+}
+
+const getDownloadJobByID = `-- name: GetDownloadJobByID :one
+SELECT dh.id, dh.user_id, dh.track_id, dh.quality, dh.status, dh.service, dh.started_at, dh.completed_at, dh.error_message, dh.source_track_id, dh.isrc, dh.media_type, dh.parent_id, dh.fallback_service, dh.requested_quality, dh.attempts, dh.next_attempt_at, dh.upgrade, dh.linked, u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.id = ?1
+LIMIT 1
+`
+
+type GetDownloadJobByIDRow struct {
+	DownloadHistory DownloadHistory `json:"download_history"`
+	Username        string          `json:"username"`
+}
+
+func (q *Queries) GetDownloadJobByID(ctx context.Context, id string) (GetDownloadJobByIDRow, error) {
+	row := q.queryRow(ctx, q.getDownloadJobByIDStmt, getDownloadJobByID, id)
+	var i GetDownloadJobByIDRow
+	err := row.Scan(
+		&i.DownloadHistory.ID,
+		&i.DownloadHistory.UserID,
+		&i.DownloadHistory.TrackID,
+		&i.DownloadHistory.Quality,
+		&i.DownloadHistory.Status,
+		&i.DownloadHistory.Service,
+		&i.DownloadHistory.StartedAt,
+		&i.DownloadHistory.CompletedAt,
+		&i.DownloadHistory.ErrorMessage,
+		&i.DownloadHistory.SourceTrackID,
+		&i.DownloadHistory.Isrc,
+		&i.DownloadHistory.MediaType,
+		&i.DownloadHistory.ParentID,
+		&i.DownloadHistory.FallbackService,
+		&i.DownloadHistory.RequestedQuality,
+		&i.DownloadHistory.Attempts,
+		&i.DownloadHistory.NextAttemptAt,
+		&i.DownloadHistory.Upgrade,
+		&i.DownloadHistory.Linked,
+		&i.Username,
+	)
+	return i, err
+}
+
+const hasUpgradeToQuality = `-- name: HasUpgradeToQuality :one
+SELECT EXISTS (
+  SELECT 1 FROM download_history
+  WHERE upgrade AND isrc = ?1 AND requested_quality >= ?2
+    AND status IN ('queued', 'downloading', 'indexing', 'success', 'skipped')
+)
+`
+
+type HasUpgradeToQualityParams struct {
+	Isrc             sql.NullString `json:"isrc"`
+	RequestedQuality sql.NullInt64  `json:"requested_quality"`
+}
+
+func (q *Queries) HasUpgradeToQuality(ctx context.Context, arg HasUpgradeToQualityParams) (int64, error) {
+	row := q.queryRow(ctx, q.hasUpgradeToQualityStmt, hasUpgradeToQuality, arg.Isrc, arg.RequestedQuality)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
 const insertDownloadHistory = `-- name: InsertDownloadHistory :one
 INSERT INTO download_history (
-  id, user_id, track_id, quality, 
-  status, service, completed_at, error_message
+  id, user_id, track_id, quality,
+  status, service, completed_at, error_message,
+  source_track_id, isrc, media_type, parent_id,
+  fallback_service, requested_quality, upgrade, linked
 ) VALUES (
   ?1, ?2, ?3,
-  ?4, ?5, ?6, 
-  ?7, ?8
+  ?4, ?5, ?6,
+  ?7, ?8,
+  ?9, ?10,
+  ?11, ?12,
+  ?13, ?14, ?15, ?16
 )
-RETURNING id, user_id, track_id, quality, status, service, started_at, completed_at, error_message
+RETURNING id, user_id, track_id, quality, status, service, started_at, completed_at, error_message, source_track_id, isrc, media_type, parent_id, fallback_service, requested_quality, attempts, next_attempt_at, upgrade, linked
 `
 
 type InsertDownloadHistoryParams struct {
-	ID           string         `json:"id"`
-	UserID       sql.NullInt64  `json:"user_id"`
-	TrackID      sql.NullInt64  `json:"track_id"`
-	Quality      sql.NullInt64  `json:"quality"`
-	Status       sql.NullString `json:"status"`
-	Service      sql.NullString `json:"service"`
-	CompletedAt  sql.NullTime   `json:"completed_at"`
-	ErrorMessage sql.NullString `json:"error_message"`
+	ID               string         `json:"id"`
+	UserID           sql.NullInt64  `json:"user_id"`
+	TrackID          sql.NullInt64  `json:"track_id"`
+	Quality          sql.NullInt64  `json:"quality"`
+	Status           sql.NullString `json:"status"`
+	Service          sql.NullString `json:"service"`
+	CompletedAt      sql.NullTime   `json:"completed_at"`
+	ErrorMessage     sql.NullString `json:"error_message"`
+	SourceTrackID    sql.NullString `json:"source_track_id"`
+	Isrc             sql.NullString `json:"isrc"`
+	MediaType        string         `json:"media_type"`
+	ParentID         sql.NullString `json:"parent_id"`
+	FallbackService  sql.NullString `json:"fallback_service"`
+	RequestedQuality sql.NullInt64  `json:"requested_quality"`
+	Upgrade          bool           `json:"upgrade"`
+	Linked           bool           `json:"linked"`
 }
 
 func (q *Queries) InsertDownloadHistory(ctx context.Context, arg InsertDownloadHistoryParams) (DownloadHistory, error) {
@@ -43,6 +171,14 @@ func (q *Queries) InsertDownloadHistory(ctx context.Context, arg InsertDownloadH
 		arg.Service,
 		arg.CompletedAt,
 		arg.ErrorMessage,
+		arg.SourceTrackID,
+		arg.Isrc,
+		arg.MediaType,
+		arg.ParentID,
+		arg.FallbackService,
+		arg.RequestedQuality,
+		arg.Upgrade,
+		arg.Linked,
 	)
 	var i DownloadHistory
 	err := row.Scan(
@@ -55,23 +191,378 @@ func (q *Queries) InsertDownloadHistory(ctx context.Context, arg InsertDownloadH
 		&i.StartedAt,
 		&i.CompletedAt,
 		&i.ErrorMessage,
+		&i.SourceTrackID,
+		&i.Isrc,
+		&i.MediaType,
+		&i.ParentID,
+		&i.FallbackService,
+		&i.RequestedQuality,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.Upgrade,
+		&i.Linked,
 	)
 	return i, err
 }
 
+const listActiveDownloads = `-- name: ListActiveDownloads :many
+SELECT dh.id, dh.user_id, dh.track_id, dh.quality, dh.status, dh.service, dh.started_at, dh.completed_at, dh.error_message, dh.source_track_id, dh.isrc, dh.media_type, dh.parent_id, dh.fallback_service, dh.requested_quality, dh.attempts, dh.next_attempt_at, dh.upgrade, dh.linked, u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.status IN ('queued', 'downloading', 'indexing')
+  AND (?1 IS NULL OR u.username = ?1)
+ORDER BY dh.started_at
+`
+
+type ListActiveDownloadsRow struct {
+	DownloadHistory DownloadHistory `json:"download_history"`
+	Username        string          `json:"username"`
+}
+
+func (q *Queries) ListActiveDownloads(ctx context.Context, username sql.NullString) ([]ListActiveDownloadsRow, error) {
+	rows, err := q.query(ctx, q.listActiveDownloadsStmt, listActiveDownloads, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListActiveDownloadsRow{}
+	for rows.Next() {
+		var i ListActiveDownloadsRow
+		if err := rows.Scan(
+			&i.DownloadHistory.ID,
+			&i.DownloadHistory.UserID,
+			&i.DownloadHistory.TrackID,
+			&i.DownloadHistory.Quality,
+			&i.DownloadHistory.Status,
+			&i.DownloadHistory.Service,
+			&i.DownloadHistory.StartedAt,
+			&i.DownloadHistory.CompletedAt,
+			&i.DownloadHistory.ErrorMessage,
+			&i.DownloadHistory.SourceTrackID,
+			&i.DownloadHistory.Isrc,
+			&i.DownloadHistory.MediaType,
+			&i.DownloadHistory.ParentID,
+			&i.DownloadHistory.FallbackService,
+			&i.DownloadHistory.RequestedQuality,
+			&i.DownloadHistory.Attempts,
+			&i.DownloadHistory.NextAttemptAt,
+			&i.DownloadHistory.Upgrade,
+			&i.DownloadHistory.Linked,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAlbumDownloadTracks = `-- name: ListAlbumDownloadTracks :many
+SELECT dh.id, dh.user_id, dh.track_id, dh.quality, dh.status, dh.service, dh.started_at, dh.completed_at, dh.error_message, dh.source_track_id, dh.isrc, dh.media_type, dh.parent_id, dh.fallback_service, dh.requested_quality, dh.attempts, dh.next_attempt_at, dh.upgrade, dh.linked, u.username, t.title AS track_title FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+LEFT JOIN track AS t ON dh.track_id = t.id
+WHERE dh.parent_id = ?1
+ORDER BY t.disc_number, t.track_number, dh.started_at
+`
+
+type ListAlbumDownloadTracksRow struct {
+	DownloadHistory DownloadHistory `json:"download_history"`
+	Username        string          `json:"username"`
+	TrackTitle      sql.NullString  `json:"track_title"`
+}
+
+func (q *Queries) ListAlbumDownloadTracks(ctx context.Context, parentID sql.NullString) ([]ListAlbumDownloadTracksRow, error) {
+	rows, err := q.query(ctx, q.listAlbumDownloadTracksStmt, listAlbumDownloadTracks, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAlbumDownloadTracksRow{}
+	for rows.Next() {
+		var i ListAlbumDownloadTracksRow
+		if err := rows.Scan(
+			&i.DownloadHistory.ID,
+			&i.DownloadHistory.UserID,
+			&i.DownloadHistory.TrackID,
+			&i.DownloadHistory.Quality,
+			&i.DownloadHistory.Status,
+			&i.DownloadHistory.Service,
+			&i.DownloadHistory.StartedAt,
+			&i.DownloadHistory.CompletedAt,
+			&i.DownloadHistory.ErrorMessage,
+			&i.DownloadHistory.SourceTrackID,
+			&i.DownloadHistory.Isrc,
+			&i.DownloadHistory.MediaType,
+			&i.DownloadHistory.ParentID,
+			&i.DownloadHistory.FallbackService,
+			&i.DownloadHistory.RequestedQuality,
+			&i.DownloadHistory.Attempts,
+			&i.DownloadHistory.NextAttemptAt,
+			&i.DownloadHistory.Upgrade,
+			&i.DownloadHistory.Linked,
+			&i.Username,
+			&i.TrackTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDownloadHistory = `-- name: ListDownloadHistory :many
+SELECT dh.id, dh.user_id, dh.track_id, dh.quality, dh.status, dh.service, dh.started_at, dh.completed_at, dh.error_message, dh.source_track_id, dh.isrc, dh.media_type, dh.parent_id, dh.fallback_service, dh.requested_quality, dh.attempts, dh.next_attempt_at, dh.upgrade, dh.linked, u.username, t.title AS track_title, al.title AS album_title, ar.name AS artist_name FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+LEFT JOIN track AS t ON dh.track_id = t.id
+LEFT JOIN album AS al ON t.album_id = al.id
+LEFT JOIN artist AS ar ON t.artist_id = ar.id
+WHERE (?1 IS NULL OR u.username = ?1)
+  AND (?2 IS NULL OR dh.status = ?2)
+  AND (?3 IS NULL OR dh.service = ?3)
+  AND (?4 IS NULL OR dh.quality = ?4)
+  AND (?5 IS NULL OR datetime(dh.started_at) >= datetime(?5))
+  AND (?6 IS NULL OR datetime(dh.started_at) < datetime(?6))
+ORDER BY dh.started_at DESC, dh.id
+LIMIT ?7 OFFSET ?8
+`
+
+type ListDownloadHistoryRow struct {
+	DownloadHistory DownloadHistory `json:"download_history"`
+	Username        string          `json:"username"`
+	TrackTitle      sql.NullString  `json:"track_title"`
+	AlbumTitle      sql.NullString  `json:"album_title"`
+	ArtistName      sql.NullString  `json:"artist_name"`
+}
+
+type ListDownloadHistoryParams struct {
+	Username      sql.NullString `json:"username"`
+	Status        sql.NullString `json:"status"`
+	Service       sql.NullString `json:"service"`
+	Quality       sql.NullInt64  `json:"quality"`
+	StartedAfter  sql.NullTime   `json:"started_after"`
+	StartedBefore sql.NullTime   `json:"started_before"`
+	Limit         int64          `json:"limit"`
+	Offset        int64          `json:"offset"`
+}
+
+func (q *Queries) ListDownloadHistory(ctx context.Context, arg ListDownloadHistoryParams) ([]ListDownloadHistoryRow, error) {
+	rows, err := q.query(ctx, q.listDownloadHistoryStmt, listDownloadHistory,
+		arg.Username,
+		arg.Status,
+		arg.Service,
+		arg.Quality,
+		arg.StartedAfter,
+		arg.StartedBefore,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDownloadHistoryRow{}
+	for rows.Next() {
+		var i ListDownloadHistoryRow
+		if err := rows.Scan(
+			&i.DownloadHistory.ID,
+			&i.DownloadHistory.UserID,
+			&i.DownloadHistory.TrackID,
+			&i.DownloadHistory.Quality,
+			&i.DownloadHistory.Status,
+			&i.DownloadHistory.Service,
+			&i.DownloadHistory.StartedAt,
+			&i.DownloadHistory.CompletedAt,
+			&i.DownloadHistory.ErrorMessage,
+			&i.DownloadHistory.SourceTrackID,
+			&i.DownloadHistory.Isrc,
+			&i.DownloadHistory.MediaType,
+			&i.DownloadHistory.ParentID,
+			&i.DownloadHistory.FallbackService,
+			&i.DownloadHistory.RequestedQuality,
+			&i.DownloadHistory.Attempts,
+			&i.DownloadHistory.NextAttemptAt,
+			&i.DownloadHistory.Upgrade,
+			&i.DownloadHistory.Linked,
+			&i.Username,
+			&i.TrackTitle,
+			&i.AlbumTitle,
+			&i.ArtistName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFailedDownloads = `-- name: ListFailedDownloads :many
+SELECT dh.id, dh.user_id, dh.track_id, dh.quality, dh.status, dh.service, dh.started_at, dh.completed_at, dh.error_message, dh.source_track_id, dh.isrc, dh.media_type, dh.parent_id, dh.fallback_service, dh.requested_quality, dh.attempts, dh.next_attempt_at, dh.upgrade, dh.linked, u.username FROM download_history AS dh
+JOIN user AS u ON dh.user_id = u.id
+WHERE dh.status = 'failed'
+  AND (?1 IS NULL OR u.username = ?1)
+ORDER BY dh.started_at
+`
+
+type ListFailedDownloadsRow struct {
+	DownloadHistory DownloadHistory `json:"download_history"`
+	Username        string          `json:"username"`
+}
+
+func (q *Queries) ListFailedDownloads(ctx context.Context, username sql.NullString) ([]ListFailedDownloadsRow, error) {
+	rows, err := q.query(ctx, q.listFailedDownloadsStmt, listFailedDownloads, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListFailedDownloadsRow{}
+	for rows.Next() {
+		var i ListFailedDownloadsRow
+		if err := rows.Scan(
+			&i.DownloadHistory.ID,
+			&i.DownloadHistory.UserID,
+			&i.DownloadHistory.TrackID,
+			&i.DownloadHistory.Quality,
+			&i.DownloadHistory.Status,
+			&i.DownloadHistory.Service,
+			&i.DownloadHistory.StartedAt,
+			&i.DownloadHistory.CompletedAt,
+			&i.DownloadHistory.ErrorMessage,
+			&i.DownloadHistory.SourceTrackID,
+			&i.DownloadHistory.Isrc,
+			&i.DownloadHistory.MediaType,
+			&i.DownloadHistory.ParentID,
+			&i.DownloadHistory.FallbackService,
+			&i.DownloadHistory.RequestedQuality,
+			&i.DownloadHistory.Attempts,
+			&i.DownloadHistory.NextAttemptAt,
+			&i.DownloadHistory.Upgrade,
+			&i.DownloadHistory.Linked,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueFailedDownload = `-- name: RequeueFailedDownload :execrows
+UPDATE download_history
+SET status = 'queued', attempts = 1, error_message = NULL, completed_at = NULL, next_attempt_at = NULL
+WHERE id = ?1 AND status = 'failed'
+`
+
+func (q *Queries) RequeueFailedDownload(ctx context.Context, id string) (int64, error) {
+	result, err := q.exec(ctx, q.requeueFailedDownloadStmt, requeueFailedDownload, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateDownloadCompletion = `-- name: UpdateDownloadCompletion :exec
 UPDATE download_history
-SET completed_at = CURRENT_TIMESTAMP, status = ?1, error_message = ?2
-WHERE id = ?3
+SET completed_at = CURRENT_TIMESTAMP, status = ?1, error_message = ?2,
+  track_id = COALESCE(?3, track_id), quality = COALESCE(?4, quality),
+  next_attempt_at = NULL
+WHERE id = ?5
 `
 
 type UpdateDownloadCompletionParams struct {
 	Status       sql.NullString `json:"status"`
 	ErrorMessage sql.NullString `json:"error_message"`
+	TrackID      sql.NullInt64  `json:"track_id"`
+	Quality      sql.NullInt64  `json:"quality"`
 	ID           string         `json:"id"`
 }
 
 func (q *Queries) UpdateDownloadCompletion(ctx context.Context, arg UpdateDownloadCompletionParams) error {
-	_, err := q.exec(ctx, q.updateDownloadCompletionStmt, updateDownloadCompletion, arg.Status, arg.ErrorMessage, arg.ID)
+	_, err := q.exec(ctx, q.updateDownloadCompletionStmt, updateDownloadCompletion,
+		arg.Status,
+		arg.ErrorMessage,
+		arg.TrackID,
+		arg.Quality,
+		arg.ID,
+	)
+	return err
+}
+
+const updateDownloadRetry = `-- name: UpdateDownloadRetry :exec
+UPDATE download_history
+SET status = 'queued', attempts = ?1, error_message = ?2,
+  next_attempt_at = ?3
+WHERE id = ?4
+`
+
+type UpdateDownloadRetryParams struct {
+	Attempts      int64          `json:"attempts"`
+	ErrorMessage  sql.NullString `json:"error_message"`
+	NextAttemptAt sql.NullTime   `json:"next_attempt_at"`
+	ID            string         `json:"id"`
+}
+
+func (q *Queries) UpdateDownloadRetry(ctx context.Context, arg UpdateDownloadRetryParams) error {
+	_, err := q.exec(ctx, q.updateDownloadRetryStmt, updateDownloadRetry,
+		arg.Attempts,
+		arg.ErrorMessage,
+		arg.NextAttemptAt,
+		arg.ID,
+	)
+	return err
+}
+
+const updateDownloadSource = `-- name: UpdateDownloadSource :exec
+UPDATE download_history
+SET service = ?1, source_track_id = ?2
+WHERE id = ?3
+`
+
+type UpdateDownloadSourceParams struct {
+	Service       sql.NullString `json:"service"`
+	SourceTrackID sql.NullString `json:"source_track_id"`
+	ID            string         `json:"id"`
+}
+
+func (q *Queries) UpdateDownloadSource(ctx context.Context, arg UpdateDownloadSourceParams) error {
+	_, err := q.exec(ctx, q.updateDownloadSourceStmt, updateDownloadSource, arg.Service, arg.SourceTrackID, arg.ID)
+	return err
+}
+
+const updateDownloadStatus = `-- name: UpdateDownloadStatus :exec
+UPDATE download_history
+SET status = ?1
+WHERE id = ?2
+`
+
+type UpdateDownloadStatusParams struct {
+	Status sql.NullString `json:"status"`
+	ID     string         `json:"id"`
+}
+
+func (q *Queries) UpdateDownloadStatus(ctx context.Context, arg UpdateDownloadStatusParams) error {
+	_, err := q.exec(ctx, q.updateDownloadStatusStmt, updateDownloadStatus, arg.Status, arg.ID)
 	return err
 }
@@ -30,18 +30,125 @@ type Artist struct {
 	CreatedAt      time.Time      `json:"created_at"`
 }
 
+type ArtistFollow struct {
+	ID             int64        `json:"id"`
+	UserID         int64        `json:"user_id"`
+	DeezerArtistID string       `json:"deezer_artist_id"`
+	ArtistName     string       `json:"artist_name"`
+	ReleaseTypes   string       `json:"release_types"`
+	AutoDownload   bool         `json:"auto_download"`
+	Quality        int64        `json:"quality"`
+	LastCheckedAt  sql.NullTime `json:"last_checked_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type DownloadBatch struct {
+	ID        string         `json:"id"`
+	UserID    int64          `json:"user_id"`
+	Source    string         `json:"source"`
+	Fallback  sql.NullString `json:"fallback"`
+	Quality   int64          `json:"quality"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+type DownloadBatchItem struct {
+	ID           int64          `json:"id"`
+	BatchID      string         `json:"batch_id"`
+	Position     int64          `json:"position"`
+	SourceID     sql.NullString `json:"source_id"`
+	Isrc         string         `json:"isrc"`
+	Action       sql.NullString `json:"action"`
+	DownloadID   sql.NullString `json:"download_id"`
+	RequestID    sql.NullString `json:"request_id"`
+	ErrorMessage sql.NullString `json:"error_message"`
+}
+
 type DownloadHistory struct {
-	ID           string         `json:"id"`
-	UserID       sql.NullInt64  `json:"user_id"`
+	ID               string         `json:"id"`
+	UserID           sql.NullInt64  `json:"user_id"`
+	TrackID          sql.NullInt64  `json:"track_id"`
+	Quality          sql.NullInt64  `json:"quality"`
+	Status           sql.NullString `json:"status"`
+	Service          sql.NullString `json:"service"`
+	StartedAt        time.Time      `json:"started_at"`
+	CompletedAt      sql.NullTime   `json:"completed_at"`
+	ErrorMessage     sql.NullString `json:"error_message"`
+	SourceTrackID    sql.NullString `json:"source_track_id"`
+	Isrc             sql.NullString `json:"isrc"`
+	MediaType        string         `json:"media_type"`
+	ParentID         sql.NullString `json:"parent_id"`
+	FallbackService  sql.NullString `json:"fallback_service"`
+	RequestedQuality sql.NullInt64  `json:"requested_quality"`
+	Attempts         int64          `json:"attempts"`
+	NextAttemptAt    sql.NullTime   `json:"next_attempt_at"`
+	Upgrade          bool           `json:"upgrade"`
+	Linked           bool           `json:"linked"`
+}
+
+type DownloadRequest struct {
+	ID         string         `json:"id"`
+	UserID     int64          `json:"user_id"`
+	MediaType  string         `json:"media_type"`
+	Source     string         `json:"source"`
+	Fallback   sql.NullString `json:"fallback"`
+	SourceID   string         `json:"source_id"`
+	Isrc       sql.NullString `json:"isrc"`
+	Quality    int64          `json:"quality"`
+	Status     string         `json:"status"`
+	Reason     sql.NullString `json:"reason"`
+	ReviewedBy sql.NullString `json:"reviewed_by"`
+	ReviewedAt sql.NullTime   `json:"reviewed_at"`
+	DownloadID sql.NullString `json:"download_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type PlaylistImport struct {
+	ID        string    `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Format    string    `json:"format"`
+	Quality   int64     `json:"quality"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PlaylistImportEntry struct {
+	ID           int64          `json:"id"`
+	ImportID     string         `json:"import_id"`
+	Position     int64          `json:"position"`
+	Title        string         `json:"title"`
+	Artist       sql.NullString `json:"artist"`
+	Album        sql.NullString `json:"album"`
+	Isrc         sql.NullString `json:"isrc"`
+	Result       string         `json:"result"`
 	TrackID      sql.NullInt64  `json:"track_id"`
-	Quality      sql.NullInt64  `json:"quality"`
-	Status       sql.NullString `json:"status"`
-	Service      sql.NullString `json:"service"`
-	StartedAt    time.Time      `json:"started_at"`
-	CompletedAt  sql.NullTime   `json:"completed_at"`
+	DownloadID   sql.NullString `json:"download_id"`
+	Candidates   sql.NullString `json:"candidates"`
 	ErrorMessage sql.NullString `json:"error_message"`
 }
 
+type ReleaseNotification struct {
+	ID            int64          `json:"id"`
+	FollowID      int64          `json:"follow_id"`
+	DeezerAlbumID string         `json:"deezer_album_id"`
+	Title         string         `json:"title"`
+	ReleaseType   string         `json:"release_type"`
+	ReleaseDate   sql.NullString `json:"release_date"`
+	CoverUrl      sql.NullString `json:"cover_url"`
+	DownloadID    sql.NullString `json:"download_id"`
+	ReadAt        sql.NullTime   `json:"read_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type SavedQuery struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type Track struct {
 	ID              int64          `json:"id"`
 	Title           string         `json:"title"`
@@ -72,9 +179,39 @@ type User struct {
 	IsActive     sql.NullBool   `json:"is_active"`
 }
 
+type UserQuota struct {
+	UserID          int64         `json:"user_id"`
+	DailyDownloads  sql.NullInt64 `json:"daily_downloads"`
+	WeeklyDownloads sql.NullInt64 `json:"weekly_downloads"`
+	StorageBytes    sql.NullInt64 `json:"storage_bytes"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
 type UserTrack struct {
 	UserID      sql.NullInt64 `json:"user_id"`
 	TrackID     sql.NullInt64 `json:"track_id"`
 	SymlinkPath string        `json:"symlink_path"`
 	LinkedDate  time.Time     `json:"linked_date"`
 }
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    string    `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	WebhookID      int64          `json:"webhook_id"`
+	EventID        string         `json:"event_id"`
+	EventType      string         `json:"event_type"`
+	Payload        string         `json:"payload"`
+	Status         string         `json:"status"`
+	Attempts       int64          `json:"attempts"`
+	ResponseStatus sql.NullInt64  `json:"response_status"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	CreatedAt      time.Time      `json:"created_at"`
+	CompletedAt    sql.NullTime   `json:"completed_at"`
+}
@@ -14,12 +14,20 @@ type Querier interface {
 	AlbumExistsByDeezerID(ctx context.Context, deezerID sql.NullString) (int64, error)
 	ArtistExistsByDeezerID(ctx context.Context, deezerID sql.NullString) (int64, error)
 	CountAlbumsByArtist(ctx context.Context, artistID int64) (int64, error)
+	CountDownloadHistory(ctx context.Context, arg CountDownloadHistoryParams) (int64, error)
 	CountTracksInAlbum(ctx context.Context, albumID sql.NullInt64) (int64, error)
+	CountUserDownloadsSince(ctx context.Context, arg CountUserDownloadsSinceParams) (int64, error)
 	CountUsersForTrack(ctx context.Context, trackID sql.NullInt64) (int64, error)
 	DeleteAlbum(ctx context.Context, id int64) error
+	DeleteAlbumDownloadTracks(ctx context.Context, parentID sql.NullString) error
 	DeleteArtist(ctx context.Context, id int64) error
+	DeleteArtistFollow(ctx context.Context, arg DeleteArtistFollowParams) (int64, error)
+	DeleteSavedQuery(ctx context.Context, arg DeleteSavedQueryParams) (int64, error)
 	DeleteTrack(ctx context.Context, id int64) error
 	DeleteUserTrack(ctx context.Context, arg DeleteUserTrackParams) error
+	DeleteWebhook(ctx context.Context, id int64) (int64, error)
+	DetachDownloadsFromTrack(ctx context.Context, trackID sql.NullInt64) error
+	FindTracksByNormalizedTitleAndArtist(ctx context.Context, arg FindTracksByNormalizedTitleAndArtistParams) ([]Track, error)
 	GetAlbumByDeezerID(ctx context.Context, deezerID sql.NullString) (Album, error)
 	GetAlbumByNormalizedTitleAndArtist(ctx context.Context, arg GetAlbumByNormalizedTitleAndArtistParams) (Album, error)
 	GetAlbumByTrackID(ctx context.Context, trackID int64) (Album, error)
@@ -27,25 +35,81 @@ type Querier interface {
 	GetArtistByDeezerID(ctx context.Context, deezerID sql.NullString) (Artist, error)
 	GetArtistByNormalizedName(ctx context.Context, normalizedName string) (Artist, error)
 	GetArtistByTrackID(ctx context.Context, trackID int64) (Artist, error)
+	GetDownloadBatch(ctx context.Context, id string) (GetDownloadBatchRow, error)
+	GetDownloadJobByID(ctx context.Context, id string) (GetDownloadJobByIDRow, error)
+	GetDownloadRequest(ctx context.Context, id string) (GetDownloadRequestRow, error)
 	GetFirstTrackByAlbumID(ctx context.Context, albumID sql.NullInt64) (Track, error)
+	GetPendingDownloadRequest(ctx context.Context, arg GetPendingDownloadRequestParams) (DownloadRequest, error)
+	GetPlaylistImportByID(ctx context.Context, id string) (GetPlaylistImportByIDRow, error)
+	GetPlaylistImportEntry(ctx context.Context, arg GetPlaylistImportEntryParams) (PlaylistImportEntry, error)
+	GetSavedQuery(ctx context.Context, arg GetSavedQueryParams) (SavedQuery, error)
 	GetTrackByID(ctx context.Context, id int64) (Track, error)
 	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetUserQuota(ctx context.Context, userID int64) (UserQuota, error)
+	GetUserStorageUsed(ctx context.Context, userID sql.NullInt64) (int64, error)
 	GetUserTrack(ctx context.Context, arg GetUserTrackParams) (UserTrack, error)
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	HasUpgradeToQuality(ctx context.Context, arg HasUpgradeToQualityParams) (int64, error)
 	InsertAlbum(ctx context.Context, arg InsertAlbumParams) (Album, error)
 	InsertArtist(ctx context.Context, arg InsertArtistParams) (Artist, error)
+	InsertDownloadBatch(ctx context.Context, arg InsertDownloadBatchParams) (DownloadBatch, error)
+	InsertDownloadBatchItem(ctx context.Context, arg InsertDownloadBatchItemParams) (DownloadBatchItem, error)
 	InsertDownloadHistory(ctx context.Context, arg InsertDownloadHistoryParams) (DownloadHistory, error)
+	InsertDownloadRequest(ctx context.Context, arg InsertDownloadRequestParams) (DownloadRequest, error)
+	InsertPlaylistImport(ctx context.Context, arg InsertPlaylistImportParams) (PlaylistImport, error)
+	InsertPlaylistImportEntry(ctx context.Context, arg InsertPlaylistImportEntryParams) (PlaylistImportEntry, error)
+	InsertReleaseNotification(ctx context.Context, arg InsertReleaseNotificationParams) (ReleaseNotification, error)
 	InsertTrack(ctx context.Context, arg InsertTrackParams) (Track, error)
 	InsertUser(ctx context.Context, arg InsertUserParams) (User, error)
+	InsertWebhook(ctx context.Context, arg InsertWebhookParams) (Webhook, error)
+	InsertWebhookDelivery(ctx context.Context, arg InsertWebhookDeliveryParams) (WebhookDelivery, error)
 	IsTrackLinkedToUserByUsernameAndISRC(ctx context.Context, arg IsTrackLinkedToUserByUsernameAndISRCParams) (int64, error)
+	ListActiveDownloads(ctx context.Context, username sql.NullString) ([]ListActiveDownloadsRow, error)
+	ListAlbumDownloadTracks(ctx context.Context, parentID sql.NullString) ([]ListAlbumDownloadTracksRow, error)
+	ListArtistFollows(ctx context.Context) ([]ListArtistFollowsRow, error)
+	ListDownloadBatchItems(ctx context.Context, batchID string) ([]ListDownloadBatchItemsRow, error)
+	ListDownloadHistory(ctx context.Context, arg ListDownloadHistoryParams) ([]ListDownloadHistoryRow, error)
+	ListDownloadRequests(ctx context.Context, arg ListDownloadRequestsParams) ([]ListDownloadRequestsRow, error)
+	ListFailedDownloads(ctx context.Context, username sql.NullString) ([]ListFailedDownloadsRow, error)
+	ListPendingWebhookDeliveries(ctx context.Context) ([]ListPendingWebhookDeliveriesRow, error)
+	ListPlaylistImportEntries(ctx context.Context, importID string) ([]PlaylistImportEntry, error)
+	ListPlaylistImportsByUsername(ctx context.Context, username string) ([]PlaylistImport, error)
+	ListReleaseNotifications(ctx context.Context, arg ListReleaseNotificationsParams) ([]ListReleaseNotificationsRow, error)
+	ListSavedQueries(ctx context.Context, userID int64) ([]SavedQuery, error)
+	ListTrackUsers(ctx context.Context, trackID sql.NullInt64) ([]ListTrackUsersRow, error)
 	ListTracksByDate(ctx context.Context) ([]Track, error)
+	ListTracksByISRCs(ctx context.Context, arg ListTracksByISRCsParams) ([]ListTracksByISRCsRow, error)
 	ListTracksByUsername(ctx context.Context, username string) ([]ListTracksByUsernameRow, error)
+	ListTracksUnderPath(ctx context.Context, prefix string) ([]Track, error)
+	ListUserArtistFollows(ctx context.Context, userID int64) ([]ArtistFollow, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	MarkReleaseNotificationsRead(ctx context.Context, arg MarkReleaseNotificationsReadParams) (int64, error)
+	ReopenDownloadRequest(ctx context.Context, id string) error
+	RequeueFailedDownload(ctx context.Context, id string) (int64, error)
+	ReviewDownloadRequest(ctx context.Context, arg ReviewDownloadRequestParams) (int64, error)
 	SearchTracksByISRC(ctx context.Context, isrc sql.NullString) (Track, error)
 	SearchTracksByTitle(ctx context.Context, title sql.NullString) ([]Track, error)
+	SetDownloadRequestDownload(ctx context.Context, arg SetDownloadRequestDownloadParams) error
+	SetReleaseNotificationDownload(ctx context.Context, arg SetReleaseNotificationDownloadParams) error
 	TrackExistsByISRC(ctx context.Context, isrc sql.NullString) (int64, error)
 	UpdateAlbumArtPath(ctx context.Context, arg UpdateAlbumArtPathParams) error
+	UpdateArtistFollowChecked(ctx context.Context, id int64) error
 	UpdateDownloadCompletion(ctx context.Context, arg UpdateDownloadCompletionParams) error
+	UpdateDownloadRetry(ctx context.Context, arg UpdateDownloadRetryParams) error
+	UpdateDownloadSource(ctx context.Context, arg UpdateDownloadSourceParams) error
+	UpdateDownloadStatus(ctx context.Context, arg UpdateDownloadStatusParams) error
 	UpdateLastLogin(ctx context.Context, id int64) error
+	UpdatePlaylistImportEntryResult(ctx context.Context, arg UpdatePlaylistImportEntryResultParams) error
+	UpdatePlaylistImportStatus(ctx context.Context, arg UpdatePlaylistImportStatusParams) error
+	UpdateTrackFile(ctx context.Context, arg UpdateTrackFileParams) error
 	UpdateTrackFilePath(ctx context.Context, arg UpdateTrackFilePathParams) error
+	UpdateUserTrackSymlink(ctx context.Context, arg UpdateUserTrackSymlinkParams) error
+	UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) (WebhookDelivery, error)
+	UpsertArtistFollow(ctx context.Context, arg UpsertArtistFollowParams) (ArtistFollow, error)
+	UpsertSavedQuery(ctx context.Context, arg UpsertSavedQueryParams) (SavedQuery, error)
+	UpsertUserQuota(ctx context.Context, arg UpsertUserQuotaParams) (UserQuota, error)
 }
 
 var _ Querier = (*Queries)(nil)
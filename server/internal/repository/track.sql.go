@@ -20,6 +20,59 @@ func (q *Queries) DeleteTrack(ctx context.Context, id int64) error {
 	return err
 }
 
+const findTracksByNormalizedTitleAndArtist = `-- name: FindTracksByNormalizedTitleAndArtist :many
+SELECT track.id, track.title, track.normalized_title, track.artist_id, track.album_id, track.duration, track.track_number, track.disc_number, track.sample_rate, track.bitrate, track.channels, track.file_path, track.file_size, track.isrc, track.composer, track.created_at FROM track
+JOIN artist ON track.artist_id = artist.id
+WHERE track.normalized_title = ?1
+  AND artist.normalized_name = ?2
+ORDER BY track.created_at
+`
+
+type FindTracksByNormalizedTitleAndArtistParams struct {
+	NormalizedTitle string `json:"normalized_title"`
+	NormalizedName  string `json:"normalized_name"`
+}
+
+func (q *Queries) FindTracksByNormalizedTitleAndArtist(ctx context.Context, arg FindTracksByNormalizedTitleAndArtistParams) ([]Track, error) {
+	rows, err := q.query(ctx, q.findTracksByNormalizedTitleAndArtistStmt, findTracksByNormalizedTitleAndArtist, arg.NormalizedTitle, arg.NormalizedName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Track{}
+	for rows.Next() {
+		var i Track
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.NormalizedTitle,
+			&i.ArtistID,
+			&i.AlbumID,
+			&i.Duration,
+			&i.TrackNumber,
+			&i.DiscNumber,
+			&i.SampleRate,
+			&i.Bitrate,
+			&i.Channels,
+			&i.FilePath,
+			&i.FileSize,
+			&i.Isrc,
+			&i.Composer,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAlbumByTrackID = `-- name: GetAlbumByTrackID :one
 SELECT album.id, album.deezer_id, album.title, album.normalized_title, album.artist_id, album.release_date, album.album_art_path, album.genre, album.total_tracks, album.created_at FROM track
 JOIN album ON track.album_id = album.id
@@ -237,6 +290,114 @@ func (q *Queries) ListTracksByDate(ctx context.Context) ([]Track, error) {
 	return items, nil
 }
 
+const listTracksByISRCs = `-- name: ListTracksByISRCs :many
+SELECT t.id, t.title, t.normalized_title, t.artist_id, t.album_id, t.duration, t.track_number, t.disc_number, t.sample_rate, t.bitrate, t.channels, t.file_path, t.file_size, t.isrc, t.composer, t.created_at,
+  EXISTS (
+    SELECT 1 FROM user_track AS ut
+    JOIN user AS u ON ut.user_id = u.id
+    WHERE ut.track_id = t.id AND u.username = ?2
+  ) AS owned
+FROM track AS t
+WHERE t.isrc IN (SELECT value FROM json_each(?1))
+`
+
+type ListTracksByISRCsRow struct {
+	Track Track `json:"track"`
+	Owned int64 `json:"owned"`
+}
+
+type ListTracksByISRCsParams struct {
+	Isrcs    string `json:"isrcs"`
+	Username string `json:"username"`
+}
+
+func (q *Queries) ListTracksByISRCs(ctx context.Context, arg ListTracksByISRCsParams) ([]ListTracksByISRCsRow, error) {
+	rows, err := q.query(ctx, q.listTracksByISRCsStmt, listTracksByISRCs, arg.Isrcs, arg.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTracksByISRCsRow{}
+	for rows.Next() {
+		var i ListTracksByISRCsRow
+		if err := rows.Scan(
+			&i.Track.ID,
+			&i.Track.Title,
+			&i.Track.NormalizedTitle,
+			&i.Track.ArtistID,
+			&i.Track.AlbumID,
+			&i.Track.Duration,
+			&i.Track.TrackNumber,
+			&i.Track.DiscNumber,
+			&i.Track.SampleRate,
+			&i.Track.Bitrate,
+			&i.Track.Channels,
+			&i.Track.FilePath,
+			&i.Track.FileSize,
+			&i.Track.Isrc,
+			&i.Track.Composer,
+			&i.Track.CreatedAt,
+			&i.Owned,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTracksUnderPath = `-- name: ListTracksUnderPath :many
+SELECT id, title, normalized_title, artist_id, album_id, duration, track_number, disc_number, sample_rate, bitrate, channels, file_path, file_size, isrc, composer, created_at FROM track
+WHERE substr(file_path, 1, length(?1)) = ?1
+ORDER BY id
+`
+
+func (q *Queries) ListTracksUnderPath(ctx context.Context, prefix string) ([]Track, error) {
+	rows, err := q.query(ctx, q.listTracksUnderPathStmt, listTracksUnderPath, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Track{}
+	for rows.Next() {
+		var i Track
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.NormalizedTitle,
+			&i.ArtistID,
+			&i.AlbumID,
+			&i.Duration,
+			&i.TrackNumber,
+			&i.DiscNumber,
+			&i.SampleRate,
+			&i.Bitrate,
+			&i.Channels,
+			&i.FilePath,
+			&i.FileSize,
+			&i.Isrc,
+			&i.Composer,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const searchTracksByISRC = `-- name: SearchTracksByISRC :one
 SELECT id, title, normalized_title, artist_id, album_id, duration, track_number, disc_number, sample_rate, bitrate, channels, file_path, file_size, isrc, composer, created_at FROM track
 WHERE isrc = ?1
@@ -326,6 +487,36 @@ func (q *Queries) TrackExistsByISRC(ctx context.Context, isrc sql.NullString) (i
 	return column_1, err
 }
 
+const updateTrackFile = `-- name: UpdateTrackFile :exec
+UPDATE track
+SET file_path = ?1, duration = ?2, sample_rate = ?3, bitrate = ?4,
+  channels = ?5, file_size = ?6
+WHERE id = ?7
+`
+
+type UpdateTrackFileParams struct {
+	FilePath   string        `json:"file_path"`
+	Duration   sql.NullInt64 `json:"duration"`
+	SampleRate sql.NullInt64 `json:"sample_rate"`
+	Bitrate    sql.NullInt64 `json:"bitrate"`
+	Channels   sql.NullInt64 `json:"channels"`
+	FileSize   sql.NullInt64 `json:"file_size"`
+	TrackID    int64         `json:"track_id"`
+}
+
+func (q *Queries) UpdateTrackFile(ctx context.Context, arg UpdateTrackFileParams) error {
+	_, err := q.exec(ctx, q.updateTrackFileStmt, updateTrackFile,
+		arg.FilePath,
+		arg.Duration,
+		arg.SampleRate,
+		arg.Bitrate,
+		arg.Channels,
+		arg.FileSize,
+		arg.TrackID,
+	)
+	return err
+}
+
 const updateTrackFilePath = `-- name: UpdateTrackFilePath :exec
 UPDATE track
 SET file_path = ?1
@@ -97,6 +97,47 @@ func (q *Queries) IsTrackLinkedToUserByUsernameAndISRC(ctx context.Context, arg
 	return column_1, err
 }
 
+const listTrackUsers = `-- name: ListTrackUsers :many
+SELECT ut.user_id, ut.track_id, ut.symlink_path, ut.linked_date, u.username FROM user_track AS ut
+JOIN user AS u ON ut.user_id = u.id
+WHERE ut.track_id = ?1
+ORDER BY u.username
+`
+
+type ListTrackUsersRow struct {
+	UserTrack UserTrack `json:"user_track"`
+	Username  string    `json:"username"`
+}
+
+func (q *Queries) ListTrackUsers(ctx context.Context, trackID sql.NullInt64) ([]ListTrackUsersRow, error) {
+	rows, err := q.query(ctx, q.listTrackUsersStmt, listTrackUsers, trackID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTrackUsersRow{}
+	for rows.Next() {
+		var i ListTrackUsersRow
+		if err := rows.Scan(
+			&i.UserTrack.UserID,
+			&i.UserTrack.TrackID,
+			&i.UserTrack.SymlinkPath,
+			&i.UserTrack.LinkedDate,
+			&i.Username,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTracksByUsername = `-- name: ListTracksByUsername :many
 SELECT
   t.id,
@@ -152,3 +193,20 @@ func (q *Queries) ListTracksByUsername(ctx context.Context, username string) ([]
 	}
 	return items, nil
 }
+
+const updateUserTrackSymlink = `-- name: UpdateUserTrackSymlink :exec
+UPDATE user_track
+SET symlink_path = ?1
+WHERE user_id = ?2 AND track_id = ?3
+`
+
+type UpdateUserTrackSymlinkParams struct {
+	SymlinkPath string        `json:"symlink_path"`
+	UserID      sql.NullInt64 `json:"user_id"`
+	TrackID     sql.NullInt64 `json:"track_id"`
+}
+
+func (q *Queries) UpdateUserTrackSymlink(ctx context.Context, arg UpdateUserTrackSymlinkParams) error {
+	_, err := q.exec(ctx, q.updateUserTrackSymlinkStmt, updateUserTrackSymlink, arg.SymlinkPath, arg.UserID, arg.TrackID)
+	return err
+}
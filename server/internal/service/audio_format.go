@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	tag "go.senan.xyz/taglib"
+)
+
+// Files that are lossless whatever their bitrate
+var losslessExtensions = map[string]bool{
+	".flac": true,
+	".wav":  true,
+	".aiff": true,
+	".alac": true,
+}
+
+// An .m4a can be AAC or ALAC, no AAC stream gets near this bitrate
+const minLosslessBitrate = 700
+
+// readAudioFormat reads the technical data needed to tell the quality of
+// the file. taglib doesn't report the bit depth, it is read from the FLAC
+// header and left at 0 for other formats.
+func readAudioFormat(path string) (model.AudioFormat, error) {
+	properties, err := tag.ReadProperties(path)
+	if err != nil {
+		return model.AudioFormat{}, fmt.Errorf("error reading properties: %w", err)
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	format := model.AudioFormat{
+		Lossless:   losslessExtensions[ext] || (ext == ".m4a" && properties.Bitrate >= minLosslessBitrate),
+		SampleRate: int(properties.SampleRate),
+		Bitrate:    int(properties.Bitrate),
+	}
+	if ext == ".flac" {
+		if format.BitDepth, err = readFLACBitDepth(path); err != nil {
+			return model.AudioFormat{}, err
+		}
+	}
+	return format, nil
+}
+
+// readFLACBitDepth reads the bits per sample of the STREAMINFO block, which
+// the FLAC format requires to be the first one after the "fLaC" marker.
+func readFLACBitDepth(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// marker (4) + block header (4) + STREAMINFO up to the bit depth (14)
+	header := make([]byte, 22)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0, fmt.Errorf("error reading flac header: %w", err)
+	}
+	if !bytes.Equal(header[:4], []byte("fLaC")) || header[4]&0x7f != 0 {
+		return 0, fmt.Errorf("%s has no flac stream info", filepath.Base(path))
+	}
+	info := header[8:]
+	// 20 bits of sample rate and 3 of channels come first, then 5 bits of
+	// bits per sample minus one
+	return int((info[12]&0x01)<<4|info[13]>>4) + 1, nil
+}
+
+// checkQuality compares the downloaded file with the requested quality and
+// returns the quality of the file. Sources hand out the best they have up
+// to the requested quality, so a lower one is logged but not an error.
+func checkQuality(downloadID, path string, requested model.Quality) (model.Quality, error) {
+	format, err := readAudioFormat(path)
+	if err != nil {
+		return 0, err
+	}
+	actual := format.Quality()
+	if actual < requested {
+		log.Printf("Download %s: requested %s but %s is %s (%s)", downloadID, requested, filepath.Base(path), actual, format)
+	}
+	return actual, nil
+}
+
+// fileQuality returns the quality of a file already in the library, nil
+// when it can't be read.
+func fileQuality(path string) *model.Quality {
+	format, err := readAudioFormat(path)
+	if err != nil {
+		log.Printf("Could not read the quality of %s: %v", path, err)
+		return nil
+	}
+	q := format.Quality()
+	return &q
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// The same words streamrip's qobuz_filters look for in the titles
+var (
+	extraTitleRe    = regexp.MustCompile(`(?i)\b(anniversary|deluxe|live|collector|demo|expanded|remix(es)?)\b`)
+	remasterTitleRe = regexp.MustCompile(`(?i)\b(re)?master(ed)?\b`)
+)
+
+type deezerAlbumDetails struct {
+	ID     int64 `json:"id"`
+	Artist struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+	Error *deezerAPIError `json:"error"`
+}
+
+type deezerAlbumTracksResponse struct {
+	Data []struct {
+		Title string `json:"title"`
+		ISRC  string `json:"isrc"`
+	} `json:"data"`
+	Error *deezerAPIError `json:"error"`
+}
+
+// PreviewDiscography lists the releases of the artist that a discography
+// download with the filters would fetch from the source, with their
+// tracks and which of them the library and the user already have, and
+// the releases it would leave out. The user may be empty. It waits for a
+// lookup of each release, see discography for what they cost.
+func (s *Streamrip) PreviewDiscography(ctx context.Context, user, artistID string, source model.Source, filters model.DiscographyFilters) (model.Discography, error) {
+	ctx = context.Background()
+	return s.discography(ctx, user, artistID, source, filters)
+}
+
+// DownloadDiscography queues the download of the releases the preview
+// lists, or of those of them in albumIDs (their Deezer IDs) when given.
+// Releases whose every track the user has are skipped. A release that
+// can't be queued fails on its own, the rest go on. It makes the same
+// lookups as the preview before queueing anything.
+func (s *Streamrip) DownloadDiscography(ctx context.Context, user, artistID string, source model.Source, quality model.Quality, filters model.DiscographyFilters, albumIDs []string) (model.DiscographyDownload, error) {
+	ctx = context.Background()
+	if err := source.ValidateQuality(quality); err != nil {
+		return model.DiscographyDownload{}, err
+	}
+	if _, err := s.queries.GetUserByUsername(ctx, user); err != nil {
+		return model.DiscographyDownload{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	discography, err := s.discography(ctx, user, artistID, source, filters)
+	if err != nil {
+		return model.DiscographyDownload{}, err
+	}
+
+	chosen := make(map[string]bool, len(albumIDs))
+	for _, id := range albumIDs {
+		chosen[strings.TrimSpace(id)] = true
+	}
+	result := model.DiscographyDownload{Artist: discography.Artist, Albums: []model.DiscographyAlbumResult{}}
+	for _, album := range discography.Albums {
+		if len(chosen) > 0 && !chosen[album.DeezerID] {
+			continue
+		}
+		delete(chosen, album.DeezerID)
+		result.Albums = append(result.Albums, s.downloadDiscographyAlbum(ctx, user, source, quality, album))
+	}
+	// What was asked for but won't be downloaded
+	for _, album := range discography.Excluded {
+		if !chosen[album.DeezerID] {
+			continue
+		}
+		delete(chosen, album.DeezerID)
+		msg := fmt.Sprintf("left out by the %s filter", album.ExcludedBy)
+		switch album.ExcludedBy {
+		case model.ExcludedNotFound:
+			msg = fmt.Sprintf("%s doesn't have it", source)
+		case model.ExcludedOverLimit:
+			msg = fmt.Sprintf("only the first %d releases are looked up", config.DiscographyMaxReleases)
+		}
+		result.Albums = append(result.Albums, model.DiscographyAlbumResult{DeezerID: album.DeezerID, Title: album.Title, Error: &msg})
+	}
+	for id := range chosen {
+		msg := fmt.Sprintf("not a release of %s", discography.Artist)
+		result.Albums = append(result.Albums, model.DiscographyAlbumResult{DeezerID: id, Error: &msg})
+	}
+	log.Printf("User %s asked for %d releases of the discography of %s", user, len(result.Albums), discography.Artist)
+	return result, nil
+}
+
+func (s *Streamrip) downloadDiscographyAlbum(ctx context.Context, user string, source model.Source, quality model.Quality, album model.DiscographyAlbum) model.DiscographyAlbumResult {
+	item := model.DiscographyAlbumResult{DeezerID: album.DeezerID, AlbumID: album.AlbumID, Title: album.Title}
+	if len(album.Tracks) > 0 && album.TracksOwned == len(album.Tracks) {
+		item.Action = model.ActionNoop
+		return item
+	}
+	result, err := s.EnsureAlbumForUser(ctx, source, album.AlbumID, user, quality)
+	if err != nil {
+		log.Printf("Error queueing the album %s for user %s: %v", album.Title, user, err)
+		msg := err.Error()
+		item.Error = &msg
+		return item
+	}
+	item.Action = result.Action
+	if result.Action == model.ActionPending {
+		item.RequestID = &result.ID
+	} else {
+		item.DownloadID = &result.ID
+	}
+	return item
+}
+
+// discography takes the releases from Deezer, which lists them best, and
+// looks for each one the filters keep in the source. Each release looked
+// up costs a Deezer call when features are left out and a rip search
+// outside Deezer, and each kept one a call for its tracks: they run
+// DiscographyLookups at a time and stop at DiscographyMaxReleases.
+func (s *Streamrip) discography(ctx context.Context, user, artistID string, source model.Source, filters model.DiscographyFilters) (model.Discography, error) {
+	if _, err := strconv.ParseInt(artistID, 10, 64); err != nil {
+		return model.Discography{}, fmt.Errorf("%w: invalid Deezer ID %q", model.ErrArtistNotFound, artistID)
+	}
+	if _, err := model.ParseSource(string(source)); err != nil {
+		return model.Discography{}, err
+	}
+	artist, err := artistName(ctx, s.queries, artistID)
+	if err != nil {
+		return model.Discography{}, err
+	}
+	releases, err := getDeezerArtistAlbums(artistID)
+	if err != nil {
+		return model.Discography{}, fmt.Errorf("error getting the releases of %s: %w", artist, err)
+	}
+
+	discography := model.Discography{
+		ArtistID: artistID,
+		Artist:   artist,
+		Source:   source,
+		Filters:  filters,
+		Albums:   []model.DiscographyAlbum{},
+		Excluded: []model.DiscographyAlbum{},
+	}
+	// The filters that only need the title go first, so that only the
+	// releases left are looked up, at most DiscographyMaxReleases of them
+	albums := make([]model.DiscographyAlbum, len(releases))
+	var lookups []int
+	for i, release := range releases {
+		albums[i] = model.DiscographyAlbum{
+			DeezerID:    strconv.FormatInt(release.ID, 10),
+			Title:       release.Title,
+			Type:        classifyRelease(release),
+			ReleaseDate: release.ReleaseDate,
+			CoverURL:    release.CoverMedium,
+		}
+		albums[i].ExcludedBy = excludedByTitle(albums[i], filters)
+		if albums[i].ExcludedBy != "" {
+			continue
+		}
+		if len(lookups) == config.DiscographyMaxReleases {
+			albums[i].ExcludedBy = model.ExcludedOverLimit
+			continue
+		}
+		lookups = append(lookups, i)
+	}
+	err = forEachLimit(len(lookups), config.DiscographyLookups, func(i int) error {
+		return s.lookUpRelease(source, artist, artistID, filters, &albums[lookups[i]])
+	})
+	if err != nil {
+		return model.Discography{}, err
+	}
+
+	var kept []model.DiscographyAlbum
+	for _, album := range albums {
+		if album.ExcludedBy != "" {
+			discography.Excluded = append(discography.Excluded, album)
+			continue
+		}
+		kept = append(kept, album)
+	}
+	if filters.Repeats {
+		kept = dropRepeats(kept, &discography.Excluded)
+	}
+
+	if err := s.addDiscographyTracks(ctx, user, kept); err != nil {
+		return model.Discography{}, err
+	}
+	discography.Albums = append(discography.Albums, kept...)
+	return discography, nil
+}
+
+// excludedByTitle returns the first filter that leaves the release out
+// by its title and type alone.
+func excludedByTitle(album model.DiscographyAlbum, filters model.DiscographyFilters) model.DiscographyExclusion {
+	switch {
+	case filters.NonAlbums && album.Type != model.ReleaseAlbum && album.Type != model.ReleaseLive:
+		return model.ExcludedNonAlbum
+	case filters.NonStudioAlbums && album.Type == model.ReleaseLive:
+		return model.ExcludedNonStudio
+	case filters.Extras && extraTitleRe.MatchString(album.Title):
+		return model.ExcludedExtra
+	case filters.NonRemaster && !remasterTitleRe.MatchString(album.Title):
+		return model.ExcludedNonRemaster
+	}
+	return ""
+}
+
+// lookUpRelease asks Deezer for the main artist of the release when
+// features are left out, and then looks for it in the source.
+func (s *Streamrip) lookUpRelease(source model.Source, artist, artistID string, filters model.DiscographyFilters, album *model.DiscographyAlbum) error {
+	if filters.Features {
+		var details deezerAlbumDetails
+		if err := getDeezer(fmt.Sprintf("%s/album/%s", config.DeezerAPIURL, album.DeezerID), &details); err != nil {
+			return fmt.Errorf("error getting the album %s from Deezer: %w", album.DeezerID, err)
+		}
+		if details.Error != nil {
+			return details.Error.asError()
+		}
+		if strconv.FormatInt(details.Artist.ID, 10) != artistID {
+			album.ExcludedBy = model.ExcludedFeature
+			return nil
+		}
+	}
+	return s.findSourceAlbum(source, artist, album)
+}
+
+// forEachLimit calls fn with every index below n, at most limit at a
+// time, and returns the error of the first index that failed. Once one
+// fails the indexes not started yet are skipped.
+func forEachLimit(n, limit int, fn func(i int) error) error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for i := 0; i < n && !failed.Load(); i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if errs[i] = fn(i); errs[i] != nil {
+				failed.Store(true)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findSourceAlbum fills in the ID and quality of the release in the
+// source, or marks it as not found. Deezer's IDs are the release's own.
+func (s *Streamrip) findSourceAlbum(source model.Source, artist string, album *model.DiscographyAlbum) error {
+	if source == model.SourceDeezer {
+		_, highest := source.QualityRange()
+		album.AlbumID = album.DeezerID
+		album.Quality = &highest
+		return nil
+	}
+	results, err := s.SearchSong(string(source), string(model.MediaAlbum), artist+" "+album.Title)
+	if err != nil {
+		return fmt.Errorf("error searching for %s in %s: %w", album.Title, source, err)
+	}
+	for _, result := range results {
+		if !sameTitle(result.Data.Title, album.Title) || !sameArtist(result.Data.Artist.Name, artist) {
+			continue
+		}
+		album.AlbumID = result.ID
+		album.Quality = searchResultQuality(source, result)
+		return nil
+	}
+	album.ExcludedBy = model.ExcludedNotFound
+	return nil
+}
+
+// searchResultQuality returns the best quality of an album result, nil
+// when the source doesn't tell.
+func searchResultQuality(source model.Source, result model.StreamripSearchResult) *model.Quality {
+	var quality model.Quality
+	switch {
+	case source == model.SourceQobuz && result.Data.MaximumBitDepth > 0:
+		quality = model.AudioFormat{
+			Lossless:   true,
+			BitDepth:   result.Data.MaximumBitDepth,
+			SampleRate: int(result.Data.MaximumSamplingRate * 1000),
+		}.Quality()
+	case source == model.SourceTidal && result.Data.AudioQuality != "":
+		switch result.Data.AudioQuality {
+		case "HI_RES", "HI_RES_LOSSLESS":
+			quality = model.QualityHiRes
+		case "LOSSLESS":
+			quality = model.QualityCD
+		case "HIGH":
+			quality = model.QualityLossyHigh
+		default:
+			quality = model.QualityLossy
+		}
+	default:
+		return nil
+	}
+	return &quality
+}
+
+// dropRepeats keeps, of the releases with the same title once versions
+// and editions are left aside, the one in the best quality and, among
+// those, the newest. The rest go to excluded.
+func dropRepeats(albums []model.DiscographyAlbum, excluded *[]model.DiscographyAlbum) []model.DiscographyAlbum {
+	best := make(map[string]int)
+	keys := make([]string, len(albums))
+	for i, album := range albums {
+		key, err := NormalizeText(titleDecorationRe.ReplaceAllString(album.Title, ""))
+		if err != nil || key == "" {
+			key = album.DeezerID
+		}
+		keys[i] = key
+		j, ok := best[key]
+		if !ok || betterRelease(album, albums[j]) {
+			best[key] = i
+		}
+	}
+
+	kept := make([]model.DiscographyAlbum, 0, len(best))
+	for i, album := range albums {
+		if best[keys[i]] == i {
+			kept = append(kept, album)
+			continue
+		}
+		album.ExcludedBy = model.ExcludedRepeat
+		*excluded = append(*excluded, album)
+	}
+	return kept
+}
+
+func betterRelease(a, b model.DiscographyAlbum) bool {
+	qa, qb := model.Quality(-1), model.Quality(-1)
+	if a.Quality != nil {
+		qa = *a.Quality
+	}
+	if b.Quality != nil {
+		qb = *b.Quality
+	}
+	if c := cmp.Compare(qa, qb); c != 0 {
+		return c > 0
+	}
+	return a.ReleaseDate > b.ReleaseDate
+}
+
+// addDiscographyTracks lists the tracks of the releases as Deezer has
+// them, with whether the library and the user have each one. Deezer is
+// asked for DiscographyLookups releases at a time.
+func (s *Streamrip) addDiscographyTracks(ctx context.Context, user string, albums []model.DiscographyAlbum) error {
+	err := forEachLimit(len(albums), config.DiscographyLookups, func(i int) error {
+		var result deezerAlbumTracksResponse
+		url := fmt.Sprintf("%s/album/%s/tracks?limit=500", config.DeezerAPIURL, albums[i].DeezerID)
+		if err := getDeezer(url, &result); err != nil {
+			return fmt.Errorf("error getting the tracks of %s: %w", albums[i].Title, err)
+		}
+		if result.Error != nil {
+			return result.Error.asError()
+		}
+		albums[i].Tracks = make([]model.DiscographyTrack, 0, len(result.Data))
+		for _, track := range result.Data {
+			albums[i].Tracks = append(albums[i].Tracks, model.DiscographyTrack{Title: track.Title, ISRC: track.ISRC})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var isrcs []string
+	for _, album := range albums {
+		for _, track := range album.Tracks {
+			if track.ISRC != "" {
+				isrcs = append(isrcs, track.ISRC)
+			}
+		}
+	}
+	library, err := s.libraryTracksByISRC(ctx, user, isrcs)
+	if err != nil {
+		return err
+	}
+
+	for i := range albums {
+		for j := range albums[i].Tracks {
+			track := &albums[i].Tracks[j]
+			found, ok := library[strings.ToUpper(track.ISRC)]
+			if !ok || track.ISRC == "" {
+				continue
+			}
+			track.InLibrary, track.Owned = true, found.Owned
+			albums[i].TracksInLibrary++
+			if found.Owned {
+				albums[i].TracksOwned++
+			}
+		}
+	}
+	return nil
+}
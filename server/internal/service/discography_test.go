@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestDiscographyFiltersReleases(t *testing.T) {
+	env := newTestEnv(t)
+	env.deezer.AddArtist("1000", "Blondie",
+		deezerAlbum{ID: 1, Title: "Parallel Lines (Remastered)", ReleaseDate: "2001-05-01", RecordType: "album"},
+		deezerAlbum{ID: 2, Title: "Parallel Lines", ReleaseDate: "1978-09-23", RecordType: "album"},
+		deezerAlbum{ID: 3, Title: "Parallel Lines (Deluxe Edition)", ReleaseDate: "2008-06-24", RecordType: "album"},
+		deezerAlbum{ID: 4, Title: "Call Me", ReleaseDate: "1980-02-01", RecordType: "single"},
+		deezerAlbum{ID: 5, Title: "Live in Philadelphia 1978", ReleaseDate: "2012-01-01", RecordType: "album"},
+		deezerAlbum{ID: 6, Title: "Blondie Tribute", ReleaseDate: "2015-01-01", RecordType: "album"},
+	)
+	env.deezer.AddAlbum("1", 1000, callMe, heartOfGlass)
+	env.deezer.AddAlbum("2", 1000, heartOfGlass)
+	env.deezer.AddAlbum("6", 2000, callMe)
+	env.add(model.SourceDeezer, "1", FakeRelease{Tracks: []FakeTrack{callMe, heartOfGlass}})
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("alice", "q2", heartOfGlass, model.QualityHiRes).ID)
+	ctx := context.Background()
+
+	filters := model.DiscographyFilters{Extras: true, Repeats: true, NonAlbums: true, Features: true, NonStudioAlbums: true}
+	discography, err := env.streamrip.PreviewDiscography(ctx, "bob", "1000", model.SourceDeezer, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discography.Albums) != 1 || discography.Albums[0].DeezerID != "1" {
+		t.Fatalf("albums = %+v, want only the remaster", discography.Albums)
+	}
+	if album := discography.Albums[0]; album.TracksInLibrary != 2 || album.TracksOwned != 0 || len(album.Tracks) != 2 {
+		t.Errorf("remaster has %d of %d tracks in the library and %d owned, want 2 of 2 and 0", album.TracksInLibrary, len(album.Tracks), album.TracksOwned)
+	}
+	want := map[string]model.DiscographyExclusion{
+		"2": model.ExcludedRepeat,
+		"3": model.ExcludedExtra,
+		"4": model.ExcludedNonAlbum,
+		"5": model.ExcludedNonStudio,
+		"6": model.ExcludedFeature,
+	}
+	for _, album := range discography.Excluded {
+		if want[album.DeezerID] != album.ExcludedBy {
+			t.Errorf("%s excluded by %q, want %q", album.Title, album.ExcludedBy, want[album.DeezerID])
+		}
+	}
+	if len(discography.Excluded) != len(want) {
+		t.Errorf("%d releases excluded, want %d", len(discography.Excluded), len(want))
+	}
+
+	// Alice has every track already
+	download, err := env.streamrip.DownloadDiscography(ctx, "alice", "1000", model.SourceDeezer, model.QualityCD, filters, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(download.Albums) != 1 || download.Albums[0].Action != model.ActionNoop {
+		t.Errorf("alice's download = %+v, want nothing to do", download.Albums)
+	}
+
+	download, err = env.streamrip.DownloadDiscography(ctx, "bob", "1000", model.SourceDeezer, model.QualityCD, filters, []string{"1", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(download.Albums) != 2 {
+		t.Fatalf("bob's download = %+v, want the remaster and the deluxe edition", download.Albums)
+	}
+	if album := download.Albums[0]; album.Action != model.ActionQueued || album.DownloadID == nil {
+		t.Errorf("remaster = %+v, want it queued", album)
+	}
+	if album := download.Albums[1]; album.DeezerID != "3" || album.Error == nil {
+		t.Errorf("deluxe edition = %+v, want it left out", album)
+	}
+	env.waitIdle()
+}
+
+func TestDiscographyLooksUpAtMostTheMaxReleases(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.DiscographyMaxReleases, 2)
+	setConfig(t, &config.DiscographyLookups, 2)
+	env.deezer.AddArtist("1000", "Blondie",
+		deezerAlbum{ID: 1, Title: "Parallel Lines", ReleaseDate: "1978-09-23", RecordType: "album"},
+		deezerAlbum{ID: 2, Title: "Call Me", ReleaseDate: "1980-02-01", RecordType: "single"},
+		deezerAlbum{ID: 3, Title: "Eat to the Beat", ReleaseDate: "1979-10-03", RecordType: "album"},
+		deezerAlbum{ID: 4, Title: "Autoamerican", ReleaseDate: "1980-11-14", RecordType: "album"},
+	)
+	env.deezer.AddAlbum("1", 1000, callMe, heartOfGlass)
+	var parallelLines model.StreamripSearchResult
+	parallelLines.Source, parallelLines.MediaType, parallelLines.ID = "qobuz", "album", "q1"
+	parallelLines.Data.Title, parallelLines.Data.Artist.Name = "Parallel Lines", "Blondie"
+	env.streamrip.searches.set(searchKey("qobuz", "album", "Blondie Parallel Lines"), []model.StreamripSearchResult{parallelLines})
+	env.streamrip.searches.set(searchKey("qobuz", "album", "Blondie Eat to the Beat"), []model.StreamripSearchResult{})
+	ctx := context.Background()
+
+	// The single is left out by its type, so it doesn't count
+	filters := model.DiscographyFilters{NonAlbums: true}
+	discography, err := env.streamrip.PreviewDiscography(ctx, "", "1000", model.SourceQobuz, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discography.Albums) != 1 || discography.Albums[0].AlbumID != "q1" || len(discography.Albums[0].Tracks) != 2 {
+		t.Fatalf("albums = %+v, want Parallel Lines from Qobuz with its tracks", discography.Albums)
+	}
+	want := map[string]model.DiscographyExclusion{
+		"2": model.ExcludedNonAlbum,
+		"3": model.ExcludedNotFound,
+		"4": model.ExcludedOverLimit,
+	}
+	for _, album := range discography.Excluded {
+		if want[album.DeezerID] != album.ExcludedBy {
+			t.Errorf("%s excluded by %q, want %q", album.Title, album.ExcludedBy, want[album.DeezerID])
+		}
+	}
+	if len(discography.Excluded) != len(want) {
+		t.Errorf("%d releases excluded, want %d", len(discography.Excluded), len(want))
+	}
+
+	download, err := env.streamrip.DownloadDiscography(ctx, "bob", "1000", model.SourceQobuz, model.QualityCD, filters, []string{"4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(download.Albums) != 1 || download.Albums[0].Error == nil {
+		t.Errorf("download past the limit = %+v, want it left out", download.Albums)
+	}
+}
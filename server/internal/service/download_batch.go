@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Songs allowed in one batch
+const maxBatchTracks = 500
+
+// CreateDownloadBatch asks for every song as EnsureTrackForUser would and
+// records the outcome of each one. A song that can't be asked for fails
+// on its own, the rest of the batch goes on.
+func (s *Streamrip) CreateDownloadBatch(ctx context.Context, user string, source, fallback model.Source, quality model.Quality, tracks []model.DownloadBatchTrack) (model.DownloadBatch, error) {
+	ctx = context.Background()
+	if len(tracks) == 0 {
+		return model.DownloadBatch{}, fmt.Errorf("%w: there are no tracks", model.ErrInvalidBatch)
+	}
+	if len(tracks) > maxBatchTracks {
+		return model.DownloadBatch{}, fmt.Errorf("%w: %d tracks, at most %d are allowed", model.ErrInvalidBatch, len(tracks), maxBatchTracks)
+	}
+	if err := source.ValidateQuality(quality); err != nil {
+		return model.DownloadBatch{}, err
+	}
+	if fallback != "" {
+		if _, err := model.ParseSource(string(fallback)); err != nil {
+			return model.DownloadBatch{}, err
+		}
+	}
+	userData, err := s.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.DownloadBatch{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+
+	batch, err := s.queries.InsertDownloadBatch(ctx, db.InsertDownloadBatchParams{
+		ID:       uuid.New().String(),
+		UserID:   userData.ID,
+		Source:   string(source),
+		Fallback: sql.NullString{String: string(fallback), Valid: fallback != ""},
+		Quality:  int64(quality),
+	})
+	if err != nil {
+		return model.DownloadBatch{}, fmt.Errorf("error saving the download batch: %w", err)
+	}
+
+	// A song listed twice gets the outcome of its first time
+	asked := make(map[string]db.InsertDownloadBatchItemParams)
+	for i, track := range tracks {
+		sourceID, isrc := strings.TrimSpace(track.ID), strings.TrimSpace(track.ISRC)
+		params, ok := asked[strings.ToUpper(isrc)]
+		if !ok {
+			params = s.askBatchTrack(ctx, user, source, fallback, quality, sourceID, isrc)
+			if isrc != "" {
+				asked[strings.ToUpper(isrc)] = params
+			}
+		}
+		params.BatchID = batch.ID
+		params.Position = int64(i + 1)
+		params.SourceID = sql.NullString{String: sourceID, Valid: sourceID != ""}
+		params.Isrc = isrc
+		if _, err := s.queries.InsertDownloadBatchItem(ctx, params); err != nil {
+			log.Printf("Error saving track %d of download batch %s: %v", i+1, batch.ID, err)
+		}
+	}
+	log.Printf("User %s started download batch %s with %d tracks", user, batch.ID, len(tracks))
+	return s.GetDownloadBatch(ctx, batch.ID)
+}
+
+// askBatchTrack asks for one song of a batch, returning what is saved of
+// the outcome.
+func (s *Streamrip) askBatchTrack(ctx context.Context, user string, source, fallback model.Source, quality model.Quality, sourceID, isrc string) db.InsertDownloadBatchItemParams {
+	var params db.InsertDownloadBatchItemParams
+	if isrc == "" {
+		params.ErrorMessage = sql.NullString{String: "the ISRC is required", Valid: true}
+		return params
+	}
+	result, err := s.EnsureTrackForUser(ctx, source, fallback, sourceID, user, isrc, quality)
+	if err != nil {
+		log.Printf("Error adding track %s to user %s: %v", isrc, user, err)
+		params.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
+		return params
+	}
+	params.Action = sql.NullString{String: string(result.Action), Valid: true}
+	switch result.Action {
+	case model.ActionNoop:
+		// Nothing was started
+	case model.ActionPending:
+		params.RequestID = sql.NullString{String: result.ID, Valid: true}
+	default:
+		params.DownloadID = sql.NullString{String: result.ID, Valid: true}
+	}
+	return params
+}
+
+// GetDownloadBatch returns the batch with the current status of each of
+// its songs.
+func (s *Streamrip) GetDownloadBatch(ctx context.Context, id string) (model.DownloadBatch, error) {
+	ctx = context.Background()
+	row, err := s.queries.GetDownloadBatch(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DownloadBatch{}, model.ErrBatchNotFound
+	}
+	if err != nil {
+		return model.DownloadBatch{}, fmt.Errorf("error reading download batch %s: %w", id, err)
+	}
+	items, err := s.queries.ListDownloadBatchItems(ctx, id)
+	if err != nil {
+		return model.DownloadBatch{}, fmt.Errorf("error reading the tracks of download batch %s: %w", id, err)
+	}
+
+	batch := model.DownloadBatchFromDB(row.DownloadBatch, row.Username)
+	batch.Summary = map[model.BatchItemStatus]int{}
+	batch.Items = make([]model.DownloadBatchItem, 0, len(items))
+	batch.Finished = true
+	for _, row := range items {
+		item := batchItem(row)
+		batch.Summary[item.Status]++
+		if item.Status == model.BatchItemQueued || item.Status == model.BatchItemDownloading || item.Status == model.BatchItemPending {
+			batch.Finished = false
+		}
+		batch.Items = append(batch.Items, item)
+	}
+	return batch, nil
+}
+
+// batchItem sums up the download of the song, or the request for it while
+// it had none, in one status.
+func batchItem(row db.ListDownloadBatchItemsRow) model.DownloadBatchItem {
+	item := model.DownloadBatchItemFromDB(row.DownloadBatchItem)
+	item.TrackID = toInt64Ptr(row.TrackID)
+	fail := func(msg string) {
+		item.Status = model.BatchItemFailed
+		if item.Error == nil && msg != "" {
+			item.Error = &msg
+		}
+	}
+
+	switch {
+	case row.JobStatus.Valid:
+		// An approved request has a download of its own
+		item.DownloadID = &row.JobID.String
+		switch model.DownloadStatus(row.JobStatus.String) {
+		case model.StatusQueued:
+			item.Status = model.BatchItemQueued
+		case model.StatusDownloading, model.StatusIndexing:
+			item.Status = model.BatchItemDownloading
+		case model.StatusCanceled:
+			fail("the download was canceled")
+		case model.StatusFailed:
+			fail(row.JobError.String)
+		default:
+			item.Status = model.BatchItemDone
+		}
+	case row.RequestStatus.Valid:
+		switch model.RequestStatus(row.RequestStatus.String) {
+		case model.RequestPending:
+			item.Status = model.BatchItemPending
+		case model.RequestRejected:
+			fail("the download was rejected: " + row.RequestReason.String)
+		case model.RequestCanceled:
+			fail("the request was canceled")
+		default:
+			// Approved when the user already had the song
+			item.Status = model.BatchItemDone
+		}
+	case row.DownloadBatchItem.DownloadID.Valid:
+		fail("the download is no longer in the history")
+	case item.Action != "":
+		item.Status = model.BatchItemDone
+	default:
+		fail("")
+	}
+	return item
+}
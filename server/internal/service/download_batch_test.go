@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestDownloadBatchSumsUpItsSongs(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	ctx := context.Background()
+
+	tracks := []model.DownloadBatchTrack{
+		{ID: "q1", ISRC: callMe.ISRC},
+		{ID: "q2", ISRC: heartOfGlass.ISRC},
+		{ID: "q3"},
+		{ID: "q2", ISRC: heartOfGlass.ISRC},
+	}
+	batch, err := env.streamrip.CreateDownloadBatch(ctx, "bob", model.SourceQobuz, "", model.QualityHiRes, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Items) != len(tracks) {
+		t.Fatalf("%d items, want %d", len(batch.Items), len(tracks))
+	}
+	items := batch.Items
+	if items[0].Action != model.ActionLinked || items[1].Action != model.ActionQueued {
+		t.Errorf("actions = %s, %s, want linked and queued", items[0].Action, items[1].Action)
+	}
+	if items[2].Status != model.BatchItemFailed || items[2].Error == nil {
+		t.Errorf("track without ISRC = %+v, want failed", items[2])
+	}
+	if items[3].DownloadID == nil || *items[3].DownloadID != *items[1].DownloadID {
+		t.Errorf("repeated track has its own download, want the one of its first time")
+	}
+
+	env.waitIdle()
+	batch, err = env.streamrip.GetDownloadBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Finished || batch.Summary[model.BatchItemDone] != 3 || batch.Summary[model.BatchItemFailed] != 1 {
+		t.Errorf("batch finished = %v with %v, want 3 done and 1 failed", batch.Finished, batch.Summary)
+	}
+	if !env.isLinked("bob", heartOfGlass.ISRC) {
+		t.Errorf("bob doesn't have %s", heartOfGlass.Title)
+	}
+}
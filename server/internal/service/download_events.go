@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// DownloadEventBus fans out download events to every subscriber, usually
+// one per open browser tab. Slow subscribers lose events instead of
+// blocking the downloads.
+type DownloadEventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan model.DownloadEvent]string
+}
+
+func NewDownloadEventBus() *DownloadEventBus {
+	return &DownloadEventBus{
+		subscribers: make(map[chan model.DownloadEvent]string),
+	}
+}
+
+// Subscribe returns a channel with the events of the given user, or of
+// every user when user is empty, and the function that closes it.
+func (b *DownloadEventBus) Subscribe(user string) (<-chan model.DownloadEvent, func()) {
+	ch := make(chan model.DownloadEvent, 32)
+	b.mu.Lock()
+	b.subscribers[ch] = user
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+func (b *DownloadEventBus) Publish(event model.DownloadEvent) {
+	if event.Time == "" {
+		event.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, user := range b.subscribers {
+		if user != "" && user != event.User {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// rip draws its progress bars as "12.3/45.6 MB"
+var ripProgressRe = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([kMG]?B)?\s*/\s*(\d+(?:\.\d+)?)\s*([kMG]?B)`)
+
+var byteUnits = map[string]float64{
+	"B":  1,
+	"kB": 1e3,
+	"MB": 1e6,
+	"GB": 1e9,
+}
+
+// parseRipProgress extracts the downloaded and total bytes of a line of
+// rip output. ok is false when the line has no progress information.
+func parseRipProgress(line string) (downloaded, total int64, ok bool) {
+	matches := ripProgressRe.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return 0, 0, false
+	}
+	// The last bar drawn on the line is the most recent one
+	m := matches[len(matches)-1]
+
+	totalUnit := byteUnits[m[4]]
+	doneUnit := totalUnit
+	if m[2] != "" {
+		doneUnit = byteUnits[m[2]]
+	}
+	done, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseFloat(m[3], 64)
+	if err != nil || size == 0 {
+		return 0, 0, false
+	}
+	return int64(done * doneUnit), int64(size * totalUnit), true
+}
+
+// progressWriter receives rip's output and reports the progress found in
+// it, at most once every interval. Writes may come from several
+// goroutines, but lines cut in two must come from a single stream, so
+// every stream needs its own writer.
+type progressWriter struct {
+	mu       sync.Mutex
+	report   func(downloaded, total int64)
+	interval time.Duration
+	last     time.Time
+	buf      []byte
+}
+
+func newProgressWriter(report func(downloaded, total int64)) *progressWriter {
+	return &progressWriter{
+		report:   report,
+		interval: 500 * time.Millisecond,
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	// Progress bars are redrawn with \r, so both \r and \n end a line
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+
+		downloaded, total, ok := parseRipProgress(line)
+		if !ok {
+			continue
+		}
+		if time.Since(w.last) < w.interval && downloaded < total {
+			continue
+		}
+		w.last = time.Now()
+		w.report(downloaded, total)
+	}
+	return len(p), nil
+}
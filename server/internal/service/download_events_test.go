@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestProgressWriterFromTwoGoroutines(t *testing.T) {
+	var mu sync.Mutex
+	var reports [][2]int64
+	w := newProgressWriter(func(downloaded, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, [2]int64{downloaded, total})
+	})
+	w.interval = 0
+
+	// rip draws a bar on stdout and another on stderr
+	var wg sync.WaitGroup
+	for _, total := range []int{10, 20} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for done := 1; done <= total; done++ {
+				fmt.Fprintf(w, "%d.0/%d.0 MB\r", done, total)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(reports) != 30 {
+		t.Fatalf("%d reports, want 30", len(reports))
+	}
+	for _, r := range reports {
+		if (r[1] != 10e6 && r[1] != 20e6) || r[0] <= 0 || r[0] > r[1] {
+			t.Errorf("report of %d/%d bytes mixes both bars", r[0], r[1])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// Page size of the history when none is given, and the largest allowed
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// Statuses a history row can have
+var historyStatuses = map[model.DownloadStatus]bool{
+	model.StatusSuccess:     true,
+	model.StatusQueued:      true,
+	model.StatusDownloading: true,
+	model.StatusIndexing:    true,
+	model.StatusFailed:      true,
+	model.StatusCanceled:    true,
+	model.StatusSkipped:     true,
+	model.StatusTransfered:  true,
+}
+
+// DownloadHistory lists the downloads and transfers matching the filter,
+// newest first, together with the number of entries in all its pages.
+// An empty filter user lists the history of every user.
+func (s *Streamrip) DownloadHistory(ctx context.Context, filter model.DownloadHistoryFilter) (model.DownloadHistoryPage, error) {
+	ctx = context.Background()
+	if filter.Status != "" && !historyStatuses[filter.Status] {
+		return model.DownloadHistoryPage{}, fmt.Errorf("%w: unknown status %q", model.ErrInvalidFilter, filter.Status)
+	}
+	if filter.Quality != nil && (*filter.Quality < model.QualityLossy || *filter.Quality > model.QualityHiResMax) {
+		return model.DownloadHistoryPage{}, fmt.Errorf("%w: %d", model.ErrInvalidQuality, *filter.Quality)
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return model.DownloadHistoryPage{}, fmt.Errorf("%w: the start of the range must be before its end", model.ErrInvalidFilter)
+	}
+	if filter.Limit < 0 || filter.Limit > maxHistoryLimit || filter.Offset < 0 {
+		return model.DownloadHistoryPage{}, fmt.Errorf("%w: the limit must be between 1 and %d and the offset can't be negative", model.ErrInvalidFilter, maxHistoryLimit)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultHistoryLimit
+	}
+
+	params := db.CountDownloadHistoryParams{
+		Username: sql.NullString{String: filter.User, Valid: filter.User != ""},
+		Status:   sql.NullString{String: string(filter.Status), Valid: filter.Status != ""},
+		Service:  sql.NullString{String: filter.Service, Valid: filter.Service != ""},
+	}
+	if filter.Quality != nil {
+		params.Quality = sql.NullInt64{Int64: int64(*filter.Quality), Valid: true}
+	}
+	if filter.From != nil {
+		params.StartedAfter = sql.NullTime{Time: filter.From.UTC(), Valid: true}
+	}
+	if filter.To != nil {
+		params.StartedBefore = sql.NullTime{Time: filter.To.UTC(), Valid: true}
+	}
+
+	total, err := s.queries.CountDownloadHistory(ctx, params)
+	if err != nil {
+		return model.DownloadHistoryPage{}, fmt.Errorf("error counting the download history: %w", err)
+	}
+	rows, err := s.queries.ListDownloadHistory(ctx, db.ListDownloadHistoryParams{
+		Username:      params.Username,
+		Status:        params.Status,
+		Service:       params.Service,
+		Quality:       params.Quality,
+		StartedAfter:  params.StartedAfter,
+		StartedBefore: params.StartedBefore,
+		Limit:         filter.Limit,
+		Offset:        filter.Offset,
+	})
+	if err != nil {
+		return model.DownloadHistoryPage{}, fmt.Errorf("error listing the download history: %w", err)
+	}
+
+	page := model.DownloadHistoryPage{
+		Entries: make([]model.DownloadHistoryEntry, 0, len(rows)),
+		Total:   total,
+		Limit:   filter.Limit,
+		Offset:  filter.Offset,
+	}
+	for _, row := range rows {
+		entry := model.DownloadHistoryEntry{
+			DownloadJob: model.DownloadJobFromDB(row.DownloadHistory, row.Username),
+			Album:       row.AlbumTitle.String,
+			Artist:      row.ArtistName.String,
+		}
+		entry.Title = row.TrackTitle.String
+		page.Entries = append(page.Entries, entry)
+	}
+	return page, nil
+}
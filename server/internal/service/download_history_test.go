@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestDownloadHistoryFilters(t *testing.T) {
+	env := newTestEnv(t)
+	dreaming := FakeTrack{Title: "Dreaming", Artist: "Blondie", Album: "Eat to the Beat", ISRC: "USCH37900201", TrackNumber: 1}
+	missing := FakeTrack{Title: "Atomic", Artist: "Blondie", ISRC: "USCH37900202"}
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceDeezer, "d1", FakeRelease{Tracks: []FakeTrack{dreaming}})
+	env.deezer.addNew(missing.ISRC)
+	before := time.Now().Add(-time.Minute)
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensureWithFallback(model.SourceDeezer, "", "bob", "d1", dreaming, model.QualityCD).ID)
+	failed := env.wait(env.ensure("bob", "q9", missing, model.QualityCD).ID)
+	after := time.Now().Add(time.Minute)
+	ctx := context.Background()
+
+	history := func(filter model.DownloadHistoryFilter) model.DownloadHistoryPage {
+		t.Helper()
+		page, err := env.streamrip.DownloadHistory(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	if page := history(model.DownloadHistoryFilter{}); page.Total != 3 || page.Limit != defaultHistoryLimit {
+		t.Errorf("whole history: %d entries, limit %d, want 3 and the default limit", page.Total, page.Limit)
+	}
+	page := history(model.DownloadHistoryFilter{User: "bob", Limit: 1, Offset: 1})
+	if page.Total != 2 || len(page.Entries) != 1 {
+		t.Errorf("bob's second page: %d of %d entries, want 1 of 2", len(page.Entries), page.Total)
+	}
+	page = history(model.DownloadHistoryFilter{Status: model.StatusFailed})
+	if page.Total != 1 || page.Entries[0].ID != failed.ID {
+		t.Errorf("failed downloads: %+v, want bob's Atomic", page.Entries)
+	}
+	page = history(model.DownloadHistoryFilter{Service: string(model.SourceDeezer)})
+	if page.Total != 1 || page.Entries[0].Title != "Dreaming" || page.Entries[0].Artist != "Blondie" {
+		t.Errorf("deezer downloads: %+v, want Dreaming with its artist", page.Entries)
+	}
+	hiRes := model.QualityHiRes
+	page = history(model.DownloadHistoryFilter{Quality: &hiRes})
+	if page.Total != 1 || page.Entries[0].User != "alice" {
+		t.Errorf("hi-res downloads: %+v, want alice's Call Me", page.Entries)
+	}
+
+	if page := history(model.DownloadHistoryFilter{From: &before, To: &after}); page.Total != 3 {
+		t.Errorf("%d downloads in the range, want 3", page.Total)
+	}
+	if page := history(model.DownloadHistoryFilter{From: &after}); page.Total != 0 {
+		t.Errorf("%d downloads after the range, want none", page.Total)
+	}
+	if page := history(model.DownloadHistoryFilter{To: &before}); page.Total != 0 {
+		t.Errorf("%d downloads before the range, want none", page.Total)
+	}
+
+	tooHigh := model.QualityHiResMax + 1
+	invalid := []struct {
+		name   string
+		filter model.DownloadHistoryFilter
+		want   error
+	}{
+		{"unknown status", model.DownloadHistoryFilter{Status: "lost"}, model.ErrInvalidFilter},
+		{"unknown quality", model.DownloadHistoryFilter{Quality: &tooHigh}, model.ErrInvalidQuality},
+		{"empty range", model.DownloadHistoryFilter{From: &after, To: &before}, model.ErrInvalidFilter},
+		{"limit too high", model.DownloadHistoryFilter{Limit: maxHistoryLimit + 1}, model.ErrInvalidFilter},
+		{"negative offset", model.DownloadHistoryFilter{Offset: -1}, model.ErrInvalidFilter},
+	}
+	for _, tt := range invalid {
+		if _, err := env.streamrip.DownloadHistory(ctx, tt.filter); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// What a job downloads and for whom, as stored in its download_history row
+type downloadSpec struct {
+	MediaType model.MediaType
+	Source    model.Source
+	// Tried when Source fails to download the track, empty when there is
+	// none. Albums never fall back, their IDs only make sense in one source.
+	Fallback model.Source
+	// ID of the track or album in the source
+	SourceID string
+	User     string
+	// Empty for albums
+	ISRC    string
+	Quality model.Quality
+	// Replaces the library's copy of the track instead of adding a new one
+	Upgrade bool
+}
+
+// A job waiting for, or being run by, a download worker
+type downloadJob struct {
+	ID string
+	downloadSpec
+	// Number of the attempt, the first one is 1
+	Attempt int64
+	// Set by a failed attempt that will be retried, only the worker
+	// running the job touches it
+	retryDelay time.Duration
+	// Set once rip finished, a failure from then on keeps the staging
+	// folder for inspection instead of removing it
+	ingesting bool
+
+	// Canceled when the user cancels the job, it kills the rip process
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Set on a job attached to another one that downloads the same
+	// track, the job never runs and ends with that one
+	primary *downloadJob
+
+	// The last status recorded through setStatus, so that jobs attaching
+	// later start with it. statusMu is held while the statuses of the job
+	// and its waiters are written.
+	statusMu sync.Mutex
+	status   model.DownloadStatus
+
+	mu       sync.Mutex
+	canceled bool
+	finished bool
+	// Jobs attached to this one
+	waiters []*downloadJob
+}
+
+func newDownloadJob(id string, spec downloadSpec) *downloadJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &downloadJob{
+		ID:           id,
+		downloadSpec: spec,
+		Attempt:      1,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// canFallback tells whether the job can still try its fallback source.
+// Sources are matched by ISRC, so only tracks can fall back.
+func (j *downloadJob) canFallback() bool {
+	return j.MediaType == model.MediaTrack && j.Fallback != "" && j.Fallback != j.Source && j.ISRC != "" && !j.isCanceled()
+}
+
+// useFallback switches the job to the track with the given ID in the
+// fallback source, keeping the quality within what that source accepts.
+func (j *downloadJob) useFallback(sourceID string) {
+	j.Source, j.SourceID = j.Fallback, sourceID
+	lowest, highest := j.Source.QualityRange()
+	j.Quality = max(lowest, min(j.Quality, highest))
+}
+
+// requestCancel marks the job as canceled and stops the rip process if
+// there is one. It fails when the job already reached a final state.
+func (j *downloadJob) requestCancel() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished {
+		return model.ErrDownloadFinished
+	}
+	j.canceled = true
+	j.cancel()
+	return nil
+}
+
+// finish runs the step that leaves the job in a final state, unless the
+// job was canceled before. Holding the lock while it runs makes a cancel
+// request wait for it instead of racing with it.
+func (j *downloadJob) finish(step func()) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.canceled {
+		return false
+	}
+	step()
+	j.finished = true
+	return true
+}
+
+func (j *downloadJob) isCanceled() bool {
+	return j.ctx.Err() != nil
+}
+
+// dedupKey identifies the jobs that can share a download, it is empty
+// for those that can't: albums and upgrades, which replace a file.
+func (j *downloadJob) dedupKey() string {
+	if j.MediaType != model.MediaTrack || j.Upgrade {
+		return ""
+	}
+	return j.ISRC
+}
+
+// attach makes w end with the job instead of downloading the track
+// again. It fails once the job is finished or canceled.
+func (j *downloadJob) attach(w *downloadJob) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.canceled || j.finished {
+		return false
+	}
+	w.primary = j
+	j.waiters = append(j.waiters, w)
+	return true
+}
+
+// detach stops w from waiting for the job. It returns false when the
+// job already ended w.
+func (j *downloadJob) detach(w *downloadJob) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, waiter := range j.waiters {
+		if waiter == w {
+			j.waiters = append(j.waiters[:i], j.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// waiting returns the jobs attached to the job.
+func (j *downloadJob) waiting() []*downloadJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*downloadJob(nil), j.waiters...)
+}
+
+// takeWaiters detaches and returns every job attached to a canceled job.
+func (j *downloadJob) takeWaiters() []*downloadJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	waiters := j.waiters
+	j.waiters = nil
+	return waiters
+}
+
+// DownloadQueue runs download jobs in FIFO order with a fixed number of
+// workers, so no more than that many rip processes run at the same time.
+type DownloadQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*downloadJob
+	process func(job *downloadJob)
+}
+
+func NewDownloadQueue(workers int, process func(job *downloadJob)) *DownloadQueue {
+	q := &DownloadQueue{
+		process: process,
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue adds the job at the end of the queue and returns its position.
+func (q *DownloadQueue) Enqueue(job *downloadJob) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, job)
+	q.cond.Signal()
+	return len(q.pending)
+}
+
+// Remove takes a pending job out of the queue. It returns false when the
+// job is not waiting, for example because a worker already picked it.
+func (q *DownloadQueue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range q.pending {
+		if job.ID == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Position returns the 1-based position of a pending job, or 0 when the
+// job is not waiting in the queue.
+func (q *DownloadQueue) Position(id string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range q.pending {
+		if job.ID == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (q *DownloadQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		job := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		q.process(job)
+	}
+}
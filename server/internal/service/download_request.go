@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// needsApproval tells whether the downloads of the user wait for an admin
+// to approve them.
+func (s *Streamrip) needsApproval(user string) bool {
+	return config.DownloadApproval && !config.AdminUsers[user]
+}
+
+// requestApproval records the download as pending for an admin to review.
+// Asking again for something still pending returns the same request. A
+// user out of quota can't ask for more.
+func (s *Streamrip) requestApproval(ctx context.Context, spec downloadSpec) (*model.DownloadResult, error) {
+	unlock := s.lockQuota(spec.User)
+	defer unlock()
+	if err := s.checkQuota(ctx, spec.User); err != nil {
+		return nil, err
+	}
+	userData, err := s.queries.GetUserByUsername(ctx, spec.User)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the user %s: %w", spec.User, err)
+	}
+	pending, err := s.queries.GetPendingDownloadRequest(ctx, db.GetPendingDownloadRequestParams{
+		UserID:    userData.ID,
+		MediaType: string(spec.MediaType),
+		Source:    string(spec.Source),
+		SourceID:  spec.SourceID,
+	})
+	if err == nil {
+		return &model.DownloadResult{ID: pending.ID, Action: model.ActionPending}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("error searching for a pending request: %w", err)
+	}
+
+	request, err := s.queries.InsertDownloadRequest(ctx, db.InsertDownloadRequestParams{
+		ID:        uuid.New().String(),
+		UserID:    userData.ID,
+		MediaType: string(spec.MediaType),
+		Source:    string(spec.Source),
+		Fallback:  sql.NullString{String: string(spec.Fallback), Valid: spec.Fallback != ""},
+		SourceID:  spec.SourceID,
+		Isrc:      sql.NullString{String: spec.ISRC, Valid: spec.ISRC != ""},
+		Quality:   int64(spec.Quality),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving the download request: %w", err)
+	}
+	log.Printf("User %s requested the %s %s from %s, request %s", spec.User, spec.MediaType, spec.SourceID, spec.Source, request.ID)
+	return &model.DownloadResult{ID: request.ID, Action: model.ActionPending}, nil
+}
+
+// ListDownloadRequests returns the requests matching the filter, oldest
+// first.
+func (s *Streamrip) ListDownloadRequests(ctx context.Context, filter model.DownloadRequestFilter) ([]model.DownloadRequest, error) {
+	ctx = context.Background()
+	switch filter.Status {
+	case "", model.RequestPending, model.RequestApproved, model.RequestRejected, model.RequestCanceled:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", model.ErrInvalidFilter, filter.Status)
+	}
+	rows, err := s.queries.ListDownloadRequests(ctx, db.ListDownloadRequestsParams{
+		Username: sql.NullString{String: filter.User, Valid: filter.User != ""},
+		Status:   sql.NullString{String: string(filter.Status), Valid: filter.Status != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the download requests: %w", err)
+	}
+	requests := make([]model.DownloadRequest, 0, len(rows))
+	for _, row := range rows {
+		requests = append(requests, model.DownloadRequestFromDB(row.DownloadRequest, row.Username))
+	}
+	return requests, nil
+}
+
+// ApproveDownloadRequest starts the requested download as if the user had
+// asked for it, except that it doesn't need approval. If by now the
+// library has the song it is only linked.
+func (s *Streamrip) ApproveDownloadRequest(ctx context.Context, id, admin string) (model.DownloadRequest, error) {
+	ctx = context.Background()
+	row, err := s.getDownloadRequest(ctx, id)
+	if err != nil {
+		return model.DownloadRequest{}, err
+	}
+	// Marked first so that two admins can't start the download twice
+	if err := s.reviewDownloadRequest(ctx, id, model.RequestApproved, admin, ""); err != nil {
+		return model.DownloadRequest{}, err
+	}
+
+	request := row.DownloadRequest
+	spec := downloadSpec{
+		MediaType: model.MediaType(request.MediaType),
+		Source:    model.Source(request.Source),
+		Fallback:  model.Source(request.Fallback.String),
+		SourceID:  request.SourceID,
+		User:      row.Username,
+		ISRC:      request.Isrc.String,
+		Quality:   model.Quality(request.Quality),
+	}
+	var result *model.DownloadResult
+	if spec.MediaType == model.MediaAlbum {
+		result, err = s.ensureAlbum(ctx, spec)
+	} else {
+		result, err = s.ensureTrack(ctx, spec, true)
+	}
+	if err != nil {
+		if err := s.queries.ReopenDownloadRequest(ctx, id); err != nil {
+			log.Printf("Error reopening download request %s: %v", id, err)
+		}
+		return model.DownloadRequest{}, fmt.Errorf("error starting the download: %w", err)
+	}
+	log.Printf("%s approved download request %s of %s", admin, id, row.Username)
+
+	// A song the user got meanwhile leaves nothing to track
+	if result.Action != model.ActionNoop {
+		params := db.SetDownloadRequestDownloadParams{
+			DownloadID: sql.NullString{String: result.ID, Valid: true},
+			ID:         id,
+		}
+		if err := s.queries.SetDownloadRequestDownload(ctx, params); err != nil {
+			log.Printf("Error saving the download of request %s: %v", id, err)
+		}
+	}
+	return s.downloadRequest(ctx, id)
+}
+
+// RejectDownloadRequest turns down the request, the reason is shown to the
+// user.
+func (s *Streamrip) RejectDownloadRequest(ctx context.Context, id, admin, reason string) (model.DownloadRequest, error) {
+	ctx = context.Background()
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return model.DownloadRequest{}, model.ErrReasonRequired
+	}
+	if _, err := s.getDownloadRequest(ctx, id); err != nil {
+		return model.DownloadRequest{}, err
+	}
+	if err := s.reviewDownloadRequest(ctx, id, model.RequestRejected, admin, reason); err != nil {
+		return model.DownloadRequest{}, err
+	}
+	log.Printf("%s rejected download request %s: %s", admin, id, reason)
+	return s.downloadRequest(ctx, id)
+}
+
+// CancelDownloadRequest withdraws a pending request of the user.
+func (s *Streamrip) CancelDownloadRequest(ctx context.Context, id, user string) (model.DownloadRequest, error) {
+	ctx = context.Background()
+	row, err := s.getDownloadRequest(ctx, id)
+	if err != nil {
+		return model.DownloadRequest{}, err
+	}
+	if row.Username != user {
+		return model.DownloadRequest{}, model.ErrRequestNotFound
+	}
+	if err := s.reviewDownloadRequest(ctx, id, model.RequestCanceled, user, ""); err != nil {
+		return model.DownloadRequest{}, err
+	}
+	return s.downloadRequest(ctx, id)
+}
+
+func (s *Streamrip) getDownloadRequest(ctx context.Context, id string) (db.GetDownloadRequestRow, error) {
+	row, err := s.queries.GetDownloadRequest(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.GetDownloadRequestRow{}, model.ErrRequestNotFound
+	}
+	if err != nil {
+		return db.GetDownloadRequestRow{}, fmt.Errorf("error getting the download request: %w", err)
+	}
+	return row, nil
+}
+
+func (s *Streamrip) downloadRequest(ctx context.Context, id string) (model.DownloadRequest, error) {
+	row, err := s.getDownloadRequest(ctx, id)
+	if err != nil {
+		return model.DownloadRequest{}, err
+	}
+	return model.DownloadRequestFromDB(row.DownloadRequest, row.Username), nil
+}
+
+// reviewDownloadRequest moves a pending request to the status, failing
+// with ErrRequestReviewed if it was no longer pending.
+func (s *Streamrip) reviewDownloadRequest(ctx context.Context, id string, status model.RequestStatus, by, reason string) error {
+	n, err := s.queries.ReviewDownloadRequest(ctx, db.ReviewDownloadRequestParams{
+		Status:     string(status),
+		Reason:     sql.NullString{String: reason, Valid: reason != ""},
+		ReviewedBy: sql.NullString{String: by, Valid: by != ""},
+		ID:         id,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating the download request: %w", err)
+	}
+	if n == 0 {
+		return model.ErrRequestReviewed
+	}
+	return nil
+}
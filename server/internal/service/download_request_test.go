@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestDownloadsWaitForApproval(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.DownloadApproval, true)
+	setConfig(t, &config.AdminUsers, map[string]bool{"alice": true})
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	ctx := context.Background()
+
+	result := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	if result.Action != model.ActionPending {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionPending)
+	}
+	if again := env.ensure("bob", "q1", callMe, model.QualityHiRes); again.ID != result.ID {
+		t.Errorf("asking again made request %s, want %s", again.ID, result.ID)
+	}
+	if n := len(env.downloader.Requests()); n != 0 {
+		t.Fatalf("%d downloads before the approval", n)
+	}
+
+	if _, err := env.streamrip.RejectDownloadRequest(ctx, result.ID, "alice", " "); !errors.Is(err, model.ErrReasonRequired) {
+		t.Errorf("rejecting without a reason: %v, want %v", err, model.ErrReasonRequired)
+	}
+	request, err := env.streamrip.ApproveDownloadRequest(ctx, result.ID, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.Status != model.RequestApproved || request.DownloadID == nil {
+		t.Fatalf("approved request = %+v, want approved with a download", request)
+	}
+	if job := env.wait(*request.DownloadID); job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if !env.isLinked("bob", callMe.ISRC) {
+		t.Error("track not linked to bob")
+	}
+	if _, err := env.streamrip.ApproveDownloadRequest(ctx, result.ID, "alice"); !errors.Is(err, model.ErrRequestReviewed) {
+		t.Errorf("approving twice: %v, want %v", err, model.ErrRequestReviewed)
+	}
+
+	pending, err := env.streamrip.ListDownloadRequests(ctx, model.DownloadRequestFilter{Status: model.RequestPending})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d requests still pending", len(pending))
+	}
+}
+
+func TestSongsInTheLibraryDontNeedApproval(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.DownloadApproval, true)
+	setConfig(t, &config.AdminUsers, map[string]bool{"alice": true})
+	cd := callMe
+	cd.BitDepth, cd.SampleRate = 16, 44100
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{cd}})
+
+	// Admins download without asking
+	result := env.ensure("alice", "q1", cd, model.QualityCD)
+	if result.Action != model.ActionQueued {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionQueued)
+	}
+	env.wait(result.ID)
+
+	if result := env.ensure("bob", "q1", callMe, model.QualityHiRes); result.Action != model.ActionLinked {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionLinked)
+	}
+	// The better copy is a download like any other
+	if result := env.ensure("bob", "q1", callMe, model.QualityHiRes); result.Action != model.ActionPending {
+		t.Fatalf("asking for a better copy: action = %s, want %s", result.Action, model.ActionPending)
+	}
+	if n := len(env.downloader.Requests()); n != 1 {
+		t.Errorf("the track was downloaded %d times, want 1", n)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+)
+
+// No retry waits longer than this, whatever the attempt
+const maxRetryDelay = 15 * time.Minute
+
+// temporaryError marks a failure that may go away by trying again later,
+// like a network error or a rate limit.
+type temporaryError struct {
+	err error
+}
+
+func (e temporaryError) Error() string { return e.err.Error() }
+func (e temporaryError) Unwrap() error { return e.err }
+
+func temporary(err error) error {
+	return temporaryError{err: err}
+}
+
+// rip only reports errors as text, these fragments of its output (and of
+// Go's network errors) point to a temporary failure
+var temporaryErrorHints = []string{
+	"timed out",
+	"timeout",
+	"deadline exceeded",
+	"connection reset",
+	"connection refused",
+	"connection aborted",
+	"server disconnected",
+	"temporary failure",
+	"no such host",
+	"network is unreachable",
+	"unexpected eof",
+	"too many requests",
+	"rate limit",
+	"status 429",
+	"status 500",
+	"status 502",
+	"status 503",
+	"status 504",
+}
+
+func isTemporary(err error) bool {
+	var tmp temporaryError
+	if errors.As(err, &tmp) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range temporaryErrorHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay is the wait before the given attempt of a download, the
+// first one being 1.
+func retryDelay(attempt int64) time.Duration {
+	return backoff(config.DownloadRetryDelay, attempt)
+}
+
+// backoff waits base before the second attempt and doubles it on each
+// retry, up to maxRetryDelay.
+func backoff(base time.Duration, attempt int64) time.Duration {
+	delay := base
+	for i := int64(2); i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
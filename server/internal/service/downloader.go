@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// What to download and where
+type DownloadRequest struct {
+	Source    model.Source
+	MediaType model.MediaType
+	// ID of the track or album in the source
+	SourceID string
+	Quality  model.Quality
+	// Folder the files must be written into, it already exists
+	Dir string
+}
+
+// Downloader fetches tracks and albums from the sources. Download returns
+// the path of the downloaded file for tracks and an empty path for
+// albums, whose files are looked for in the folder. It must stop as soon
+// as ctx is canceled and report progress, when it knows it, with
+// progress.
+type Downloader interface {
+	Download(ctx context.Context, req DownloadRequest, progress func(downloaded, total int64)) (string, error)
+}
+
+// RipDownloader downloads with streamrip's rip command
+type RipDownloader struct{}
+
+func NewRipDownloader() *RipDownloader {
+	return &RipDownloader{}
+}
+
+type streamripJSONOutput struct {
+	DownloadPath string `json:"downloadPath"`
+}
+
+// Download runs rip in the requested quality instead of the one in
+// streamrip's config. The process is killed when ctx is canceled.
+func (r *RipDownloader) Download(ctx context.Context, req DownloadRequest, progress func(downloaded, total int64)) (string, error) {
+	quality := strconv.FormatInt(int64(req.Quality), 10)
+	// cmd := exec.CommandContext(ctx, "srip", "--quality", quality, "--folder", req.Dir, "--no-db", "id", string(req.Source), string(req.MediaType), req.SourceID)
+	cmd := exec.CommandContext(ctx, "rip", "--quality", quality, "--folder", req.Dir, "--no-db", "id", string(req.Source), string(req.MediaType), req.SourceID)
+	// rip starts its own children, kill the whole process group on cancel
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	// exec copies each pipe in its own goroutine, and a line cut in two
+	// in one of them must not be joined with the other's
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, newProgressWriter(progress))
+	cmd.Stderr = io.MultiWriter(&stderr, newProgressWriter(progress))
+
+	log.Printf("Executing command: %v", cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("rip error: %v\n%s", err, stderr.String())
+	}
+	if req.MediaType == model.MediaAlbum {
+		return "", nil
+	}
+
+	downloadPath, err := extractDownloadPath(stdout.String())
+	if err != nil {
+		return "", fmt.Errorf("parse error: %w", err)
+	}
+	return downloadPath, nil
+}
+
+func extractDownloadPath(output string) (string, error) {
+	start := strings.Index(output, "---BEGIN JSON---")
+	end := strings.Index(output, "---END JSON---")
+
+	if start == -1 || end == -1 || start >= end {
+		return "", fmt.Errorf("could not find JSON delimiters")
+	}
+
+	jsonRaw := output[start+len("---BEGIN JSON---") : end]
+	jsonRaw = strings.TrimSpace(jsonRaw)
+
+	var parsed streamripJSONOutput
+	if err := json.Unmarshal([]byte(jsonRaw), &parsed); err != nil {
+		return "", fmt.Errorf("error parsing json block: %w", err)
+	}
+
+	if parsed.DownloadPath == "" {
+		return "", fmt.Errorf("parsed JSON has empty downloadPath")
+	}
+
+	return parsed.DownloadPath, nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// A track served by the FakeDownloader. The file it writes is a FLAC
+// stream with the tags and format given, but no audio.
+type FakeTrack struct {
+	Title       string
+	Artist      string
+	Album       string
+	ISRC        string
+	TrackNumber int
+	// 16 bit / 44.1 kHz when left at 0
+	BitDepth   int
+	SampleRate int
+}
+
+// How the FakeDownloader answers the requests of one source ID
+type FakeRelease struct {
+	// One for a track, every track of the album otherwise
+	Tracks []FakeTrack
+	// Returned, in order, by the first attempts, the following ones succeed
+	Errors []error
+	// Time every attempt takes, unless the download is canceled first
+	Delay time.Duration
+	// Writes a file that isn't audio in place of each track
+	Corrupt bool
+	// Reports a path where nothing was written
+	Missing bool
+}
+
+// FakeDownloader is a Downloader that doesn't leave the process, for
+// tests. It serves the releases added to it and fails with
+// model.ErrTrackNotInSource for any other ID.
+type FakeDownloader struct {
+	mu       sync.Mutex
+	releases map[string]*FakeRelease
+	requests []DownloadRequest
+}
+
+func NewFakeDownloader() *FakeDownloader {
+	return &FakeDownloader{
+		releases: make(map[string]*FakeRelease),
+	}
+}
+
+func fakeReleaseKey(source model.Source, id string) string {
+	return string(source) + "/" + id
+}
+
+// Add makes the track or album with the ID in source downloadable.
+func (f *FakeDownloader) Add(source model.Source, id string, release FakeRelease) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.releases[fakeReleaseKey(source, id)] = &release
+}
+
+// Requests returns every request received so far, failed ones included.
+func (f *FakeDownloader) Requests() []DownloadRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]DownloadRequest(nil), f.requests...)
+}
+
+func (f *FakeDownloader) Download(ctx context.Context, req DownloadRequest, progress func(downloaded, total int64)) (string, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	release, ok := f.releases[fakeReleaseKey(req.Source, req.SourceID)]
+	var attemptErr error
+	if ok && len(release.Errors) > 0 {
+		attemptErr, release.Errors = release.Errors[0], release.Errors[1:]
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no %s with ID %s", model.ErrTrackNotInSource, req.Source, req.MediaType, req.SourceID)
+	}
+	if release.Delay > 0 {
+		select {
+		case <-time.After(release.Delay):
+		case <-ctx.Done():
+			return "", fmt.Errorf("download interrupted: %w", ctx.Err())
+		}
+	}
+	if attemptErr != nil {
+		return "", attemptErr
+	}
+
+	dir := req.Dir
+	if req.MediaType == model.MediaAlbum && len(release.Tracks) > 0 {
+		dir = filepath.Join(dir, sanitizeFilename(release.Tracks[0].Album))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+
+	var path string
+	for i, track := range release.Tracks {
+		path = filepath.Join(dir, sanitizeFilename(fmt.Sprintf("%s - %s.flac", track.Artist, track.Title)))
+		if release.Missing {
+			continue
+		}
+		data := []byte("this is not an audio file")
+		if !release.Corrupt {
+			data = fakeFLAC(track)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return "", err
+		}
+		if progress != nil {
+			progress(int64(i+1), int64(len(release.Tracks)))
+		}
+	}
+	if req.MediaType == model.MediaAlbum {
+		return "", nil
+	}
+	if path == "" {
+		return "", fmt.Errorf("release %s has no tracks", req.SourceID)
+	}
+	return path, nil
+}
+
+// fakeFLAC builds a FLAC stream made of its STREAMINFO and VORBIS_COMMENT
+// blocks, three minutes long according to the former.
+func fakeFLAC(track FakeTrack) []byte {
+	bitDepth, sampleRate := track.BitDepth, track.SampleRate
+	if bitDepth == 0 {
+		bitDepth = 16
+	}
+	if sampleRate == 0 {
+		sampleRate = 44100
+	}
+
+	// Min and max block size, then sample rate (20 bits), channels - 1 (3),
+	// bits per sample - 1 (5) and total samples (36). Frame sizes and MD5
+	// are left at 0, which means unknown.
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint16(info[0:], 4096)
+	binary.BigEndian.PutUint16(info[2:], 4096)
+	samples := uint64(sampleRate) * 180
+	binary.BigEndian.PutUint64(info[10:], uint64(sampleRate)<<44|1<<41|uint64(bitDepth-1)<<36|samples)
+
+	// Vorbis comments use little endian lengths
+	var comments []byte
+	appendString := func(s string) {
+		comments = binary.LittleEndian.AppendUint32(comments, uint32(len(s)))
+		comments = append(comments, s...)
+	}
+	fields := []string{
+		"TITLE=" + track.Title,
+		"ARTIST=" + track.Artist,
+		"ALBUM=" + track.Album,
+		"ISRC=" + track.ISRC,
+		"TRACKNUMBER=" + strconv.Itoa(track.TrackNumber),
+	}
+	appendString("sancho")
+	comments = binary.LittleEndian.AppendUint32(comments, uint32(len(fields)))
+	for _, field := range fields {
+		appendString(field)
+	}
+
+	stream := []byte("fLaC")
+	stream = append(stream, 0, 0, 0, byte(len(info)))
+	stream = append(stream, info...)
+	// 0x80 marks the last metadata block, 4 is VORBIS_COMMENT
+	size := len(comments)
+	stream = append(stream, 0x80|4, byte(size>>16), byte(size>>8), byte(size))
+	return append(stream, comments...)
+}
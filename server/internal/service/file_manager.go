@@ -3,29 +3,87 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/alejandro-bustamante/sancho/server/internal/config"
 	model "github.com/alejandro-bustamante/sancho/server/internal/model"
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	tag "go.senan.xyz/taglib"
 )
 
 type FileManager struct {
-	db      *sql.DB
-	queries *db.Queries
+	db       *sql.DB
+	queries  *db.Queries
+	webhooks *WebhookDispatcher
 }
 
-func NewFileManager(db *sql.DB, queries *db.Queries) *FileManager {
+func NewFileManager(db *sql.DB, queries *db.Queries, webhooks *WebhookDispatcher) *FileManager {
 	return &FileManager{
-		db:      db,
-		queries: queries,
+		db:       db,
+		queries:  queries,
+		webhooks: webhooks,
 	}
 }
 
-func (fm *FileManager) renameTrack(ctx context.Context, track model.Track, artistName string) (string, error) {
+// fileUndo records the filesystem changes of an operation so they can be
+// reverted, newest first, when one of its later steps fails.
+type fileUndo []func() error
+
+func (u *fileUndo) add(step func() error) {
+	*u = append(*u, step)
+}
+
+func (u fileUndo) rollback() error {
+	var errs []error
+	for i := len(u) - 1; i >= 0; i-- {
+		if err := u[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// move renames the file and records how to move it back.
+func (u *fileUndo) move(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	u.add(func() error { return os.Rename(to, from) })
+	return nil
+}
+
+// mkdirAll creates dir and records how to remove the directories it
+// created, as long as nothing else ended up in them.
+func (u *fileUndo) mkdirAll(dir string) error {
+	var created []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	u.add(func() error {
+		for _, d := range created {
+			if err := removeDirIfEmpty(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (fm *FileManager) renameTrack(ctx context.Context, qtx *db.Queries, undo *fileUndo, track model.Track, artistName string) (string, error) {
 	//Temporal empty context to avoid timeouts
 	ctx = context.Background()
 
@@ -46,11 +104,11 @@ func (fm *FileManager) renameTrack(ctx context.Context, track model.Track, artis
 	newFileName := fmt.Sprintf("%s. %s - %s%s", trackNumber, safeTitle, safeArtist, ext)
 	newPath := filepath.Join(baseDir, newFileName)
 
-	if err := os.Rename(track.FilePath, newPath); err != nil {
+	if err := undo.move(track.FilePath, newPath); err != nil {
 		return "", fmt.Errorf("error renaming file: %w", err)
 	}
 
-	err := fm.queries.UpdateTrackFilePath(ctx, db.UpdateTrackFilePathParams{
+	err := qtx.UpdateTrackFilePath(ctx, db.UpdateTrackFilePathParams{
 		FilePath: newPath,
 		TrackID:  track.ID,
 	})
@@ -61,7 +119,7 @@ func (fm *FileManager) renameTrack(ctx context.Context, track model.Track, artis
 	return newPath, nil
 }
 
-func (fm *FileManager) moveTrackToLibrary(ctx context.Context, track model.Track) (trackPath string, err error) {
+func (fm *FileManager) moveTrackToLibrary(ctx context.Context, qtx *db.Queries, undo *fileUndo, track model.Track) (trackPath string, err error) {
 	//Temporal empty context to avoid timeouts
 	ctx = context.Background()
 	// Expected folder structure in the library is:
@@ -76,11 +134,11 @@ func (fm *FileManager) moveTrackToLibrary(ctx context.Context, track model.Track
 	sanchoRoot := config.SanchoPath
 	libraryRoot := filepath.Join(sanchoRoot, "library")
 
-	artist, err := fm.queries.GetArtistByTrackID(ctx, track.ID)
+	artist, err := qtx.GetArtistByTrackID(ctx, track.ID)
 	if err != nil {
 		return "", fmt.Errorf("error fetching artist: %w", err)
 	}
-	album, err := fm.queries.GetAlbumByTrackID(ctx, track.ID)
+	album, err := qtx.GetAlbumByTrackID(ctx, track.ID)
 	if err != nil {
 		return "", fmt.Errorf("error fetching album: %w", err)
 	}
@@ -89,18 +147,18 @@ func (fm *FileManager) moveTrackToLibrary(ctx context.Context, track model.Track
 	safeAlbum := sanitizeFilename(album.Title)
 
 	targetDir := filepath.Join(libraryRoot, safeArtist, safeAlbum)
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := undo.mkdirAll(targetDir); err != nil {
 		return "", fmt.Errorf("error creating directory structure: %w", err)
 	}
 
 	fileName := filepath.Base(track.FilePath)
 	newPath := filepath.Join(targetDir, fileName)
 
-	if err := os.Rename(track.FilePath, newPath); err != nil {
+	if err := undo.move(track.FilePath, newPath); err != nil {
 		return "", fmt.Errorf("error moving file to library: %w", err)
 	}
 
-	err = fm.queries.UpdateTrackFilePath(ctx, db.UpdateTrackFilePathParams{
+	err = qtx.UpdateTrackFilePath(ctx, db.UpdateTrackFilePathParams{
 		FilePath: newPath,
 		TrackID:  track.ID,
 	})
@@ -111,7 +169,10 @@ func (fm *FileManager) moveTrackToLibrary(ctx context.Context, track model.Track
 	return newPath, nil
 }
 
-func (fm *FileManager) LinkTrackToUser(ctx context.Context, isrc, user string) (string, error) {
+// LinkTrackToUser moves the track into the library, if it is not there
+// yet, and links it to the user. It either completes or leaves the file,
+// the DB and the user's folder as they were.
+func (fm *FileManager) LinkTrackToUser(ctx context.Context, isrc, user string) (userFilePath string, err error) {
 	//Temporal empty context to avoid timeouts
 	ctx = context.Background()
 	isrcNull := sql.NullString{String: isrc, Valid: true}
@@ -126,15 +187,39 @@ func (fm *FileManager) LinkTrackToUser(ctx context.Context, isrc, user string) (
 		return "", fmt.Errorf("error fetching artist: %w", err)
 	}
 
+	userDB, err := fm.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return "", fmt.Errorf("error searching user in the DB: %w", err)
+	}
+
+	tx, err := fm.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := fm.queries.WithTx(tx)
+
+	// The DB changes are rolled back with the transaction, the files are
+	// put back where they were
+	var undo fileUndo
+	defer func() {
+		if err == nil {
+			return
+		}
+		if undoErr := undo.rollback(); undoErr != nil {
+			err = fmt.Errorf("%w (could not undo the changes: %v)", err, undoErr)
+		}
+	}()
+
 	trackModel := model.TrackFromDB(trackDB)
 
-	renamedPath, err := fm.renameTrack(ctx, trackModel, artist.Name)
+	renamedPath, err := fm.renameTrack(ctx, qtx, &undo, trackModel, artist.Name)
 	if err != nil {
 		return "", err
 	}
 	trackModel.FilePath = renamedPath
 
-	finalPath, err := fm.moveTrackToLibrary(ctx, trackModel)
+	finalPath, err := fm.moveTrackToLibrary(ctx, qtx, &undo, trackModel)
 	if err != nil {
 		return "", err
 	}
@@ -148,10 +233,10 @@ func (fm *FileManager) LinkTrackToUser(ctx context.Context, isrc, user string) (
 	}
 
 	userLibraryDir := filepath.Join(sanchoRoot, fmt.Sprintf("%s_library", user))
-	userFilePath := filepath.Join(userLibraryDir, relativeTrackPath)
-	userDir := filepath.Dir(userFilePath)
+	linkPath := filepath.Join(userLibraryDir, relativeTrackPath)
+	userDir := filepath.Dir(linkPath)
 
-	if err := os.MkdirAll(userDir, 0755); err != nil {
+	if err := undo.mkdirAll(userDir); err != nil {
 		return "", fmt.Errorf("error creating user directory: %w", err)
 	}
 
@@ -161,25 +246,165 @@ func (fm *FileManager) LinkTrackToUser(ctx context.Context, isrc, user string) (
 		return "", fmt.Errorf("error generating relative symlink target: %w", err)
 	}
 
-	if err := os.Symlink(relativeSymlinkTarget, userFilePath); err != nil {
+	if err := os.Symlink(relativeSymlinkTarget, linkPath); err != nil {
 		return "", fmt.Errorf("error creating symlink: %w", err)
 	}
+	undo.add(func() error { return os.Remove(linkPath) })
 
-	userDB, err := fm.queries.GetUserByUsername(ctx, user)
-	if err != nil {
-		return "", fmt.Errorf("error searching user in the DB: %w", err)
-	}
 	trackUserParams := db.AddTrackToUserParams{
 		UserID:      sql.NullInt64{Int64: userDB.ID, Valid: userDB.ID > 0},
 		TrackID:     sql.NullInt64{Int64: trackDB.ID, Valid: trackDB.ID > 0},
 		SymlinkPath: relativeSymlinkTarget, // <-- aquí se guarda el path RELATIVO
 	}
-	err = fm.queries.AddTrackToUser(ctx, trackUserParams)
+	err = qtx.AddTrackToUser(ctx, trackUserParams)
 	if err != nil {
 		return "", fmt.Errorf("error adding row to user_track table: %w", err)
 	}
 
-	return userFilePath, nil
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("could not commit transaction: %w", err)
+	}
+	fm.webhooks.Emit(model.WebhookTrackLinked, webhookTrackData(user, trackDB))
+	return linkPath, nil
+}
+
+// ReplaceTrackFile swaps the library's file of the track for newFile,
+// updating the technical data of the track. The new file keeps the path
+// of the old one but for the extension, when it changes the links of
+// every user of the track are pointed to the new path. It either
+// completes or leaves the files, the DB and the users' folders as they
+// were.
+func (fm *FileManager) ReplaceTrackFile(ctx context.Context, trackID int64, newFile string) (trackPath string, err error) {
+	//Temporal empty context to avoid timeouts
+	ctx = context.Background()
+	track, err := fm.queries.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return "", fmt.Errorf("error finding track: %w", err)
+	}
+	users, err := fm.queries.ListTrackUsers(ctx, sql.NullInt64{Int64: trackID, Valid: true})
+	if err != nil {
+		return "", fmt.Errorf("error listing the users of the track: %w", err)
+	}
+	info, err := os.Stat(newFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading new file: %w", err)
+	}
+	properties, err := tag.ReadProperties(newFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading properties: %w", err)
+	}
+
+	oldPath := track.FilePath
+	newPath := strings.TrimSuffix(oldPath, filepath.Ext(oldPath)) + filepath.Ext(newFile)
+
+	tx, err := fm.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := fm.queries.WithTx(tx)
+
+	var undo fileUndo
+	defer func() {
+		if err == nil {
+			return
+		}
+		if undoErr := undo.rollback(); undoErr != nil {
+			err = fmt.Errorf("%w (could not undo the changes: %v)", err, undoErr)
+		}
+	}()
+
+	// The old file waits next to the new one until the swap is committed.
+	// A library that lost it gets the new one all the same.
+	backup := filepath.Join(filepath.Dir(newFile), ".replaced"+filepath.Ext(oldPath))
+	if err := undo.move(oldPath, backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("error moving the old file aside: %w", err)
+	}
+	if err := undo.move(newFile, newPath); err != nil {
+		return "", fmt.Errorf("error moving file to library: %w", err)
+	}
+
+	duration := properties.Length.Milliseconds()
+	err = qtx.UpdateTrackFile(ctx, db.UpdateTrackFileParams{
+		FilePath:   newPath,
+		Duration:   sql.NullInt64{Int64: duration, Valid: duration > 0},
+		SampleRate: sql.NullInt64{Int64: int64(properties.SampleRate), Valid: properties.SampleRate > 0},
+		Bitrate:    sql.NullInt64{Int64: int64(properties.Bitrate), Valid: properties.Bitrate > 0},
+		Channels:   sql.NullInt64{Int64: int64(properties.Channels), Valid: properties.Channels > 0},
+		FileSize:   sql.NullInt64{Int64: info.Size(), Valid: true},
+		TrackID:    trackID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating track in DB: %w", err)
+	}
+
+	if newPath != oldPath {
+		for _, user := range users {
+			if err := fm.relinkTrack(ctx, qtx, &undo, user, oldPath, newPath); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("could not commit transaction: %w", err)
+	}
+	if err := os.Remove(backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Could not remove the replaced file %s: %v", backup, err)
+	}
+	return newPath, nil
+}
+
+// relinkTrack points the user's link of the track at its new path. The
+// user_track row stays, only its symlink path changes.
+func (fm *FileManager) relinkTrack(ctx context.Context, qtx *db.Queries, undo *fileUndo, user db.ListTrackUsersRow, oldPath, newPath string) error {
+	oldLink, err := userLinkPath(user.Username, oldPath)
+	if err != nil {
+		return err
+	}
+	newLink, err := userLinkPath(user.Username, newPath)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Rel(filepath.Dir(newLink), newPath)
+	if err != nil {
+		return fmt.Errorf("error generating relative symlink target: %w", err)
+	}
+
+	if err := os.Remove(oldLink); err == nil {
+		oldTarget := user.UserTrack.SymlinkPath
+		undo.add(func() error { return os.Symlink(oldTarget, oldLink) })
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove symlink %s: %w", oldLink, err)
+	}
+	if err := undo.mkdirAll(filepath.Dir(newLink)); err != nil {
+		return fmt.Errorf("error creating user directory: %w", err)
+	}
+	if err := os.Symlink(target, newLink); err != nil {
+		return fmt.Errorf("error creating symlink: %w", err)
+	}
+	undo.add(func() error { return os.Remove(newLink) })
+
+	err = qtx.UpdateUserTrackSymlink(ctx, db.UpdateUserTrackSymlinkParams{
+		SymlinkPath: target,
+		UserID:      user.UserTrack.UserID,
+		TrackID:     user.UserTrack.TrackID,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating symlink of %s in DB: %w", user.Username, err)
+	}
+	return nil
+}
+
+// userLinkPath is where the user's library links the track at trackPath,
+// which mirrors its place in the library.
+func userLinkPath(user, trackPath string) (string, error) {
+	libraryRoot := filepath.Join(config.SanchoPath, "library")
+	relativeTrackPath, err := filepath.Rel(libraryRoot, trackPath)
+	if err != nil {
+		return "", fmt.Errorf("error computing relative track path: %w", err)
+	}
+	return filepath.Join(config.SanchoPath, fmt.Sprintf("%s_library", user), relativeTrackPath), nil
 }
 
 func sanitizeFilename(name string) string {
@@ -261,7 +486,7 @@ func (fm *FileManager) DeleteTrackForUser(ctx context.Context, username string,
 		globalArtistDir := filepath.Dir(globalAlbumDir)
 
 		if err := os.Remove(track.FilePath); err != nil {
-			return fmt.Errorf("could not remove physical file %s: %w", err)
+			return fmt.Errorf("could not remove physical file %s: %w", track.FilePath, err)
 		}
 
 		if err := removeDirIfEmpty(globalAlbumDir); err != nil {
@@ -274,28 +499,80 @@ func (fm *FileManager) DeleteTrackForUser(ctx context.Context, username string,
 			}
 		}
 
-		if err := qtx.DeleteTrack(ctx, track.ID); err != nil {
-			return fmt.Errorf("error deleting track from database: %w", err)
+		if err := deleteTrackRecords(ctx, qtx, track); err != nil {
+			return err
 		}
+	}
 
-		// Clean up orphaned album and artist records.
-		if track.AlbumID.Valid {
-			albumTracks, _ := qtx.CountTracksInAlbum(ctx, track.AlbumID)
-			if albumTracks == 0 {
-				qtx.DeleteAlbum(ctx, track.AlbumID.Int64)
-			}
-		}
-		if track.ArtistID.Valid {
-			artistAlbums, _ := qtx.CountAlbumsByArtist(ctx, track.ArtistID.Int64)
-			if artistAlbums == 0 {
-				qtx.DeleteArtist(ctx, track.ArtistID.Int64)
-			}
-		}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	fm.webhooks.Emit(model.WebhookTrackDeleted, model.WebhookTrackDeletedData{
+		WebhookTrackData:   webhookTrackData(username, track),
+		RemovedFromLibrary: count == 0,
+	})
+	return nil
+}
+
+func webhookTrackData(user string, track db.Track) model.WebhookTrackData {
+	data := model.WebhookTrackData{
+		User:    user,
+		TrackID: track.ID,
+		Title:   track.Title,
+	}
+	if track.Isrc.Valid {
+		data.ISRC = &track.Isrc.String
+	}
+	return data
+}
+
+// discardTrack removes a track that was indexed but never linked to anyone,
+// together with its album and artist if they end up empty. The file itself
+// is left to the caller.
+func (fm *FileManager) discardTrack(ctx context.Context, trackID int64) error {
+	ctx = context.Background()
+	track, err := fm.queries.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return fmt.Errorf("error finding track: %w", err)
 	}
 
+	tx, err := fm.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteTrackRecords(ctx, fm.queries.WithTx(tx), track); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
 
+func deleteTrackRecords(ctx context.Context, qtx *db.Queries, track db.Track) error {
+	// The history of its downloads stays, without the track
+	if err := qtx.DetachDownloadsFromTrack(ctx, sql.NullInt64{Int64: track.ID, Valid: true}); err != nil {
+		return fmt.Errorf("error detaching the downloads of the track: %w", err)
+	}
+	if err := qtx.DeleteTrack(ctx, track.ID); err != nil {
+		return fmt.Errorf("error deleting track from database: %w", err)
+	}
+
+	// Clean up orphaned album and artist records.
+	if track.AlbumID.Valid {
+		albumTracks, _ := qtx.CountTracksInAlbum(ctx, track.AlbumID)
+		if albumTracks == 0 {
+			qtx.DeleteAlbum(ctx, track.AlbumID.Int64)
+		}
+	}
+	if track.ArtistID.Valid {
+		artistAlbums, _ := qtx.CountAlbumsByArtist(ctx, track.ArtistID.Int64)
+		if artistAlbums == 0 {
+			qtx.DeleteArtist(ctx, track.ArtistID.Int64)
+		}
+	}
+	return nil
+}
+
 func removeDirIfEmpty(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil
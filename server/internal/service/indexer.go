@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
 	model "github.com/alejandro-bustamante/sancho/server/internal/model"
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
 	"github.com/google/uuid"
@@ -21,14 +22,18 @@ import (
 )
 
 type Indexer struct {
+	db          *sql.DB
 	queries     *db.Queries
 	fileManager *FileManager
+	webhooks    *WebhookDispatcher
 }
 
-func NewIndexer(queries *db.Queries, fileManager *FileManager) *Indexer {
+func NewIndexer(db *sql.DB, queries *db.Queries, fileManager *FileManager, webhooks *WebhookDispatcher) *Indexer {
 	return &Indexer{
+		db:          db,
 		queries:     queries,
 		fileManager: fileManager,
+		webhooks:    webhooks,
 	}
 }
 
@@ -56,19 +61,27 @@ func NewTrackExistsError(isrc string, trackID int64) *TrackExistsError {
 
 func (x *Indexer) IndexFolder(ctx context.Context, rootDir, user, service string, quality int) error {
 	ctx = context.Background()
-	return filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+	result := model.WebhookIndexData{User: user, Path: rootDir}
+	err := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil || info.IsDir() || !x.isAudioFile(path) {
 			return nil
 		}
 
 		if err := x.RegisterLocalTrack(ctx, path, user, service, quality); err != nil {
 			log.Printf("Error registrando %s: %v", path, err)
+			result.Failed++
 		} else {
 			log.Printf("✓ Registrado %s", path)
+			result.Indexed++
 		}
 
 		return nil
 	})
+	if err != nil {
+		result.Error = err.Error()
+	}
+	x.webhooks.Emit(model.WebhookIndexFinished, result)
+	return err
 }
 
 func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user string) (trackID int64, err error) {
@@ -132,6 +145,24 @@ func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user st
 		return 0, fmt.Errorf("error in db consistency: found album with deezer ID %v with no artist", deezerIDs.AlbumID)
 	}
 
+	// Deezer is asked before writing anything, so the transaction below
+	// doesn't hold the DB while waiting for it
+	var totalTracks int
+	if !albumInDb {
+		totalTracks, err = x.getAlbumTrackNumber(deezerIDs.AlbumID)
+		if err != nil {
+			return 0, fmt.Errorf("error normalizing albums name: %w", err)
+		}
+	}
+
+	// The artist, album and track are inserted together or not at all
+	tx, err := x.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := x.queries.WithTx(tx)
+
 	// We need this var to store the artistID we generate when inserting the artist
 	var artistID int64
 	// If there is no artist, we insert both artist and album
@@ -145,14 +176,14 @@ func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user st
 			Name:           get(tag.Artist),
 			NormalizedName: normalizedArtistName,
 		}
-		artist, err := x.queries.InsertArtist(ctx, artistParams)
+		artist, err := qtx.InsertArtist(ctx, artistParams)
 		if err != nil {
 			return 0, fmt.Errorf("error inserting artist into the db %w", err)
 		}
 		artistID = artist.ID
 	} else { // If there is artist we search it and store its ID
 		deeezerArtistID := sql.NullString{String: strconv.Itoa(deezerIDs.ArtistID), Valid: true}
-		artist, err := x.queries.GetArtistByDeezerID(ctx, deeezerArtistID)
+		artist, err := qtx.GetArtistByDeezerID(ctx, deeezerArtistID)
 		if err != nil {
 			return 0, fmt.Errorf("error searching artist in the db %w", err)
 		}
@@ -168,11 +199,6 @@ func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user st
 		if err != nil {
 			return 0, fmt.Errorf("error normalizing albums name: %w", err)
 		}
-		totalTracks, err := x.getAlbumTrackNumber(deezerIDs.AlbumID)
-		if err != nil {
-			return 0, fmt.Errorf("error normalizing albums name: %w", err)
-		}
-
 		releaseDate := get(tag.Date)
 		genre := get(tag.Genre)
 		albumParams := db.InsertAlbumParams{
@@ -185,14 +211,14 @@ func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user st
 			Genre:           sql.NullString{String: genre, Valid: genre != ""},
 			TotalTracks:     sql.NullInt64{Int64: int64(totalTracks), Valid: totalTracks > 0},
 		}
-		album, err := x.queries.InsertAlbum(ctx, albumParams)
+		album, err := qtx.InsertAlbum(ctx, albumParams)
 		if err != nil {
 			return 0, fmt.Errorf("error inserting album into the db %w", err)
 		}
 		albumID = album.ID
 	} else {
 		deeezerAlbumID := sql.NullString{String: strconv.Itoa(deezerIDs.AlbumID), Valid: true}
-		album, err := x.queries.GetAlbumByDeezerID(ctx, deeezerAlbumID)
+		album, err := qtx.GetAlbumByDeezerID(ctx, deeezerAlbumID)
 		if err != nil {
 			return 0, fmt.Errorf("error searching artist in the db %w", err)
 		}
@@ -226,10 +252,13 @@ func (x *Indexer) IndexFile(ctx context.Context, info os.FileInfo, path, user st
 		Isrc:            sql.NullString{String: isrc, Valid: isrc != ""},
 		Composer:        sql.NullString{String: composer, Valid: composer != ""},
 	}
-	track, err := x.queries.InsertTrack(ctx, params)
+	track, err := qtx.InsertTrack(ctx, params)
 	if err != nil {
 		return 0, fmt.Errorf("error storing track: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("could not commit transaction: %w", err)
+	}
 
 	fmt.Printf("✓ %s (%s)\n", track.Title, track.FilePath)
 	return track.ID, nil
@@ -258,10 +287,43 @@ type deezerIDResponse struct {
 	Album struct {
 		ID int `json:"id"`
 	} `json:"album"`
+	Error *deezerAPIError `json:"error"`
+}
+
+// Deezer reports most errors with status 200 and this object
+type deezerAPIError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// Code of the "Quota limit exceeded" error, Deezer's rate limit
+const deezerQuotaErrorCode = 4
+
+func (e *deezerAPIError) Error() string {
+	return fmt.Sprintf("deezer API error %d (%s): %s", e.Code, e.Type, e.Message)
+}
+
+// asError returns the error, marked as temporary when it is the rate limit.
+func (e *deezerAPIError) asError() error {
+	if e.Code == deezerQuotaErrorCode {
+		return temporary(e)
+	}
+	return e
+}
+
+// deezerStatusError builds the error of a response that is not 200.
+// Rate limits and server errors are worth retrying.
+func deezerStatusError(status int) error {
+	err := fmt.Errorf("deezer API returned status %d", status)
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return temporary(err)
+	}
+	return err
 }
 
 func (x *Indexer) getDeezerIDs(isrc string) (DeezerIDs, error) {
-	url := fmt.Sprintf("https://api.deezer.com/track/isrc:%s", isrc)
+	url := fmt.Sprintf("%s/track/isrc:%s", config.DeezerAPIURL, isrc)
 
 	client := &http.Client{
 		Timeout: 10 * time.Second,
@@ -269,18 +331,21 @@ func (x *Indexer) getDeezerIDs(isrc string) (DeezerIDs, error) {
 
 	resp, err := client.Get(url)
 	if err != nil {
-		return DeezerIDs{}, fmt.Errorf("error making request to Deezer: %w", err)
+		return DeezerIDs{}, fmt.Errorf("error making request to Deezer: %w", temporary(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return DeezerIDs{}, fmt.Errorf("deezer API returned status %d", resp.StatusCode)
+		return DeezerIDs{}, deezerStatusError(resp.StatusCode)
 	}
 
 	var result deezerIDResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return DeezerIDs{}, fmt.Errorf("error decoding deezer response: %w", err)
 	}
+	if result.Error != nil {
+		return DeezerIDs{}, result.Error.asError()
+	}
 
 	return DeezerIDs{
 		ArtistID: result.Artist.ID,
@@ -293,7 +358,7 @@ type deezerNumTracksResponse struct {
 }
 
 func (x *Indexer) getAlbumTrackNumber(deezerID int) (int, error) {
-	url := fmt.Sprintf("https://api.deezer.com/album/%d", deezerID)
+	url := fmt.Sprintf("%s/album/%d", config.DeezerAPIURL, deezerID)
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
@@ -378,25 +443,13 @@ func (x *Indexer) RegisterLocalTrack(ctx context.Context, fullPath, user, servic
 		return fmt.Errorf("could not fetch track after indexing: %w", err)
 	}
 
-	artist, err := x.queries.GetArtistByTrackID(ctx, trackID)
-	if err != nil {
-		return fmt.Errorf("error fetching artist: %w", err)
-	}
-
-	trackModel := model.TrackFromDB(track)
-	renamedPath, err := x.fileManager.renameTrack(ctx, trackModel, artist.Name)
-	if err != nil {
-		return fmt.Errorf("error renaming track: %w", err)
-	}
-	trackModel.FilePath = renamedPath
-
-	_, err = x.fileManager.moveTrackToLibrary(ctx, trackModel)
-	if err != nil {
-		return fmt.Errorf("error moving track to library: %w", err)
-	}
-
-	_, err = x.fileManager.LinkTrackToUser(ctx, *trackModel.ISRC, user)
+	// LinkTrackToUser renames and moves the file into the library
+	_, err = x.fileManager.LinkTrackToUser(ctx, track.Isrc.String, user)
 	if err != nil {
+		// The file stays where it was, so does the library without it
+		if discardErr := x.fileManager.discardTrack(ctx, trackID); discardErr != nil {
+			log.Printf("Could not remove track %d after a failed link: %v", trackID, discardErr)
+		}
 		return fmt.Errorf("error linking track to user: %w", err)
 	}
 
@@ -428,6 +481,7 @@ func (x *Indexer) saveTransferHistory(
 		Status:      sql.NullString{String: status, Valid: status != ""},
 		Service:     sql.NullString{String: service, Valid: service != ""},
 		CompletedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		MediaType:   string(model.MediaTrack),
 	}
 
 	_, err = x.queries.InsertDownloadHistory(ctx, params)
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// Songs in a page of a library listing, by default and at most
+const (
+	defaultTrackListLimit = 50
+	maxTrackListLimit     = 500
+)
+
+var formatRe = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// The value each order sorts by. NULLs become empty values, so that the
+// cursor of a page can be compared with them. added is the column of the
+// date the song came to the listed library.
+func trackSortKey(sort model.TrackSort, added string) string {
+	switch sort {
+	case model.SortArtist:
+		return "COALESCE(ar.normalized_name, '')"
+	case model.SortAlbum:
+		return "COALESCE(al.normalized_title, '')"
+	case model.SortYear:
+		return "COALESCE(CAST(substr(al.release_date, 1, 4) AS INTEGER), 0)"
+	case model.SortDuration:
+		return "COALESCE(t.duration, 0)"
+	case model.SortQuality:
+		// Bitrates in kbit/s never reach a million
+		return "COALESCE(t.sample_rate, 0) * 1000000 + COALESCE(t.bitrate, 0)"
+	default:
+		return "CAST(strftime('%s', " + added + ") AS INTEGER)"
+	}
+}
+
+// The place where a page ended: the sort value and ID of its last song.
+// The order is kept to reject cursors of another listing.
+type trackCursor struct {
+	Sort model.TrackSort `json:"s"`
+	Desc bool            `json:"d"`
+	Key  any             `json:"k"`
+	ID   int64           `json:"id"`
+}
+
+func (c trackCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTrackCursor(s string, sort model.TrackSort, desc bool) (trackCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor, it belongs to another listing or was altered", model.ErrInvalidFilter)
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return trackCursor{}, invalid
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var cursor trackCursor
+	if err := decoder.Decode(&cursor); err != nil || cursor.Sort != sort || cursor.Desc != desc {
+		return trackCursor{}, invalid
+	}
+	// Text keys stay strings, the rest are integers as SQLite compares
+	// them by type first
+	switch key := cursor.Key.(type) {
+	case string:
+		if sort != model.SortArtist && sort != model.SortAlbum {
+			return trackCursor{}, invalid
+		}
+	case json.Number:
+		n, err := key.Int64()
+		if err != nil || sort == model.SortArtist || sort == model.SortAlbum {
+			return trackCursor{}, invalid
+		}
+		cursor.Key = n
+	default:
+		return trackCursor{}, invalid
+	}
+	return cursor, nil
+}
+
+// trackFilterSQL returns the conditions of the filter, with their
+// arguments in order.
+func trackFilterSQL(filter model.TrackFilter) ([]string, []any, error) {
+	var where []string
+	var args []any
+	if filter.ArtistID != nil {
+		where = append(where, "t.artist_id = ?")
+		args = append(args, *filter.ArtistID)
+	}
+	if filter.AlbumID != nil {
+		where = append(where, "t.album_id = ?")
+		args = append(args, *filter.AlbumID)
+	}
+	if genre := strings.TrimSpace(filter.Genre); genre != "" {
+		where = append(where, "al.genre = ? COLLATE NOCASE")
+		args = append(args, genre)
+	}
+	if filter.SampleRate != nil {
+		where = append(where, "t.sample_rate = ?")
+		args = append(args, *filter.SampleRate)
+	}
+	if filter.Format != "" {
+		format := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(filter.Format), "."))
+		if !formatRe.MatchString(format) {
+			return nil, nil, fmt.Errorf("%w: invalid format %q", model.ErrInvalidFilter, filter.Format)
+		}
+		where = append(where, "t.file_path LIKE ?")
+		args = append(args, "%."+format)
+	}
+	return where, args, nil
+}
+
+// ListTracks returns a page of the songs of the library, or of the user's
+// library, in the order asked for. Songs with the same sort value follow
+// the order of their IDs.
+func (l *LibrarySearch) ListTracks(ctx context.Context, query model.TrackListQuery) (model.TrackPage, error) {
+	ctx = context.Background()
+	if query.Sort == "" {
+		query.Sort = model.SortAdded
+	}
+	if _, err := model.ParseTrackSort(string(query.Sort)); err != nil {
+		return model.TrackPage{}, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultTrackListLimit
+	}
+	if query.Limit > maxTrackListLimit {
+		return model.TrackPage{}, fmt.Errorf("%w: at most %d songs fit in a page", model.ErrInvalidFilter, maxTrackListLimit)
+	}
+	if query.User != "" {
+		if _, err := l.queries.GetUserByUsername(ctx, query.User); err != nil {
+			return model.TrackPage{}, fmt.Errorf("could not find the user %s: %w", query.User, err)
+		}
+	}
+
+	from := `FROM track AS t
+LEFT JOIN artist AS ar ON ar.id = t.artist_id
+LEFT JOIN album AS al ON al.id = t.album_id`
+	added := "t.created_at"
+	var where []string
+	var args []any
+	if query.User != "" {
+		from += `
+JOIN user_track AS ut ON ut.track_id = t.id
+JOIN user AS u ON u.id = ut.user_id`
+		added = "ut.linked_date"
+		where = append(where, "u.username = ?")
+		args = append(args, query.User)
+	}
+	filterWhere, filterArgs, err := trackFilterSQL(query.Filter)
+	if err != nil {
+		return model.TrackPage{}, err
+	}
+	where = append(where, filterWhere...)
+	args = append(args, filterArgs...)
+	queryWhere, queryArgs, err := compileLibraryQuery(query.Query)
+	if err != nil {
+		return model.TrackPage{}, err
+	}
+	where = append(where, queryWhere...)
+	args = append(args, queryArgs...)
+
+	key := trackSortKey(query.Sort, added)
+	order, cmp := "ASC", ">"
+	if query.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeTrackCursor(query.Cursor, query.Sort, query.Desc)
+		if err != nil {
+			return model.TrackPage{}, err
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND t.id %[2]s ?))", key, cmp))
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+	}
+
+	stmt := `SELECT t.id, t.title, t.artist_id, ar.name, t.album_id, al.title, al.album_art_path, al.genre,
+  CAST(substr(al.release_date, 1, 4) AS INTEGER), t.duration, t.track_number, t.disc_number,
+  t.sample_rate, t.bitrate, t.file_path, t.file_size, t.isrc, ` + added + `, ` + key + `
+` + from
+	if len(where) > 0 {
+		stmt += "\nWHERE " + strings.Join(where, " AND ")
+	}
+	stmt += fmt.Sprintf("\nORDER BY %s %s, t.id %s\nLIMIT ?", key, order, order)
+	// One more tells whether there is a next page
+	args = append(args, query.Limit+1)
+
+	rows, err := l.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return model.TrackPage{}, fmt.Errorf("error listing the tracks: %w", err)
+	}
+	defer rows.Close()
+
+	page := model.TrackPage{Tracks: []model.LibraryTrack{}, Sort: query.Sort, Order: strings.ToLower(order), Limit: query.Limit}
+	var last trackCursor
+	for rows.Next() {
+		if int64(len(page.Tracks)) == query.Limit {
+			next := last.encode()
+			page.NextCursor = &next
+			break
+		}
+		var (
+			track                                                                     model.LibraryTrack
+			artistID, albumID, year, duration, trackNumber, discNumber, rate, bitrate sql.NullInt64
+			fileSize                                                                  sql.NullInt64
+			artist, album, artPath, genre, isrc                                       sql.NullString
+			addedAt                                                                   time.Time
+			sortKey                                                                   any
+			filePath                                                                  string
+		)
+		err := rows.Scan(&track.ID, &track.Title, &artistID, &artist, &albumID, &album, &artPath, &genre,
+			&year, &duration, &trackNumber, &discNumber, &rate, &bitrate, &filePath, &fileSize, &isrc, &addedAt, &sortKey)
+		if err != nil {
+			return model.TrackPage{}, fmt.Errorf("error reading the tracks: %w", err)
+		}
+		track.ArtistID, track.Artist = toInt64Ptr(artistID), toStringPtr(artist)
+		track.AlbumID, track.Album, track.AlbumArtPath, track.Genre = toInt64Ptr(albumID), toStringPtr(album), toStringPtr(artPath), toStringPtr(genre)
+		if year.Int64 > 0 {
+			track.Year = &year.Int64
+		}
+		track.Duration, track.TrackNumber, track.DiscNumber = toInt64Ptr(duration), toInt64Ptr(trackNumber), toInt64Ptr(discNumber)
+		track.SampleRate, track.Bitrate, track.FileSize = toInt64Ptr(rate), toInt64Ptr(bitrate), toInt64Ptr(fileSize)
+		track.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+		track.ISRC = toStringPtr(isrc)
+		track.AddedAt = addedAt.Format(time.RFC3339)
+		page.Tracks = append(page.Tracks, track)
+
+		if b, ok := sortKey.([]byte); ok {
+			sortKey = string(b)
+		}
+		last = trackCursor{Sort: query.Sort, Desc: query.Desc, Key: sortKey, ID: track.ID}
+	}
+	if err := rows.Err(); err != nil {
+		return model.TrackPage{}, fmt.Errorf("error reading the tracks: %w", err)
+	}
+	return page, nil
+}
+
+func toStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestListTracksPagesSortsAndFilters(t *testing.T) {
+	env := newTestEnv(t)
+	library := NewLibrarySearch(env.conn, env.queries)
+	ctx := context.Background()
+	atomic := FakeTrack{Title: "Atomic", Artist: "Blondie", Album: "Parallel Lines", ISRC: "USCH37900099", TrackNumber: 1}
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.add(model.SourceQobuz, "q3", FakeRelease{Tracks: []FakeTrack{atomic}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("alice", "q2", heartOfGlass, model.QualityHiRes).ID)
+	env.wait(env.ensure("alice", "q3", atomic, model.QualityCD).ID)
+	env.wait(env.ensure("bob", "q1", callMe, model.QualityHiRes).ID)
+
+	list := func(query model.TrackListQuery) model.TrackPage {
+		t.Helper()
+		page, err := library.ListTracks(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	// Every order goes through the whole library a page at a time
+	for _, sort := range []model.TrackSort{model.SortAdded, model.SortAlbum, model.SortQuality} {
+		seen := make(map[int64]bool)
+		query := model.TrackListQuery{User: "alice", Sort: sort, Desc: true, Limit: 1}
+		for {
+			page := list(query)
+			for _, track := range page.Tracks {
+				if seen[track.ID] {
+					t.Errorf("%s: %s listed twice", sort, track.Title)
+				}
+				seen[track.ID] = true
+			}
+			if page.NextCursor == nil {
+				break
+			}
+			query.Cursor = *page.NextCursor
+		}
+		if len(seen) != 3 {
+			t.Errorf("%s: listed %d songs of alice, want 3", sort, len(seen))
+		}
+	}
+
+	page := list(model.TrackListQuery{User: "alice", Sort: model.SortQuality, Limit: 2})
+	if len(page.Tracks) != 2 || page.Tracks[0].Title != "Atomic" || page.NextCursor == nil {
+		t.Errorf("by quality: %+v, want Atomic first and more to come", page.Tracks)
+	}
+	rate := int64(44100)
+	page = list(model.TrackListQuery{Filter: model.TrackFilter{SampleRate: &rate}})
+	if len(page.Tracks) != 1 || page.Tracks[0].Title != "Atomic" {
+		t.Errorf("at 44.1 kHz: %+v, want Atomic", page.Tracks)
+	}
+	if page := list(model.TrackListQuery{User: "bob"}); len(page.Tracks) != 1 {
+		t.Errorf("bob has %d songs, want 1", len(page.Tracks))
+	}
+
+	_, err := library.ListTracks(ctx, model.TrackListQuery{Sort: model.SortArtist, Cursor: *list(model.TrackListQuery{Limit: 1}).NextCursor})
+	if !errors.Is(err, model.ErrInvalidFilter) {
+		t.Errorf("the cursor of another order gave %v", err)
+	}
+	if _, err := library.ListTracks(ctx, model.TrackListQuery{Filter: model.TrackFilter{Format: "%"}}); !errors.Is(err, model.ErrInvalidFilter) {
+		t.Errorf("the format %% gave %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// Characters a saved query name can have at most
+const maxQueryNameLength = 100
+
+// How a field of the library query language compares its values
+type queryFieldKind int
+
+const (
+	// Contains the words of the value, compared normalized
+	queryWords queryFieldKind = iota
+	// Contains the value, ignoring the case
+	queryText
+	// Equals the value, ignoring the case
+	queryExact
+	// The extension of the file
+	queryFormat
+	// Compared as an integer, with any operator
+	queryNumber
+	// In seconds, or minutes and seconds as 3:25
+	queryDuration
+)
+
+// The fields of the language and the expressions they compare. Nothing
+// the user types ever becomes part of the SQL, only of its arguments.
+var queryFields = map[string]struct {
+	kind queryFieldKind
+	expr string
+}{
+	"title":      {queryWords, "t.normalized_title"},
+	"artist":     {queryWords, "ar.normalized_name"},
+	"album":      {queryWords, "al.normalized_title"},
+	"genre":      {queryText, "al.genre"},
+	"composer":   {queryText, "t.composer"},
+	"isrc":       {queryExact, "t.isrc"},
+	"format":     {queryFormat, "t.file_path"},
+	"year":       {queryNumber, "CAST(substr(al.release_date, 1, 4) AS INTEGER)"},
+	"samplerate": {queryNumber, "t.sample_rate"},
+	"bitrate":    {queryNumber, "t.bitrate"},
+	"track":      {queryNumber, "t.track_number"},
+	"disc":       {queryNumber, "t.disc_number"},
+	"duration":   {queryDuration, "t.duration / 1000"},
+}
+
+// Words without a field look for the title, artist and album
+const queryAnyWords = "t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')"
+
+// Operators of the numeric fields, the longest first
+var queryOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// compileLibraryQuery turns a query of the library query language into
+// conditions over track (t), artist (ar) and album (al), with their
+// arguments in order. A query is a list of terms that must all match,
+// separated by spaces:
+//
+//	word or "some words"   in the title, artist or album
+//	field:value            see queryFields, quoting values with spaces
+//	field:>=value          also >, <, <= and != for numeric fields
+//	-term                  songs the term doesn't match
+//
+// Syntax errors are *model.LibraryQueryError with their position.
+func compileLibraryQuery(query string) ([]string, []any, error) {
+	p := queryParser{input: []rune(query)}
+	var where []string
+	var args []any
+	for {
+		p.skipSpaces()
+		if p.done() {
+			return where, args, nil
+		}
+		cond, condArgs, err := p.term()
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+}
+
+type queryParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *queryParser) skipSpaces() {
+	for !p.done() && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// errorAt reports an error at the character at pos, counted from 0
+func (p *queryParser) errorAt(pos int, format string, a ...any) error {
+	return &model.LibraryQueryError{Position: pos + 1, Message: fmt.Sprintf(format, a...)}
+}
+
+func (p *queryParser) term() (string, []any, error) {
+	negated := false
+	if p.input[p.pos] == '-' {
+		negated = true
+		p.pos++
+		if p.done() || unicode.IsSpace(p.input[p.pos]) {
+			return "", nil, p.errorAt(p.pos-1, "expected a term after -")
+		}
+	}
+
+	start := p.pos
+	var cond string
+	var args []any
+	var err error
+	if p.input[p.pos] == '"' {
+		cond, args, err = p.words(start)
+	} else {
+		word := p.bare(func(r rune) bool { return r == ':' || r == '"' })
+		if !p.done() && p.input[p.pos] == ':' {
+			if word == "" {
+				return "", nil, p.errorAt(p.pos, "expected a field before :")
+			}
+			p.pos++
+			cond, args, err = p.field(strings.ToLower(word), start)
+		} else {
+			p.pos = start
+			cond, args, err = p.words(start)
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if negated {
+		// Songs without the field don't match it either
+		cond = "NOT COALESCE(" + cond + ", 0)"
+	}
+	return cond, args, nil
+}
+
+// bare reads up to the next space or a stop character
+func (p *queryParser) bare(stop func(rune) bool) string {
+	start := p.pos
+	for !p.done() && !unicode.IsSpace(p.input[p.pos]) && !stop(p.input[p.pos]) {
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
+
+// value reads a bare or quoted value. In quotes \" and \\ stand for " and \.
+func (p *queryParser) value() (string, error) {
+	if p.done() || p.input[p.pos] != '"' {
+		return p.bare(func(rune) bool { return false }), nil
+	}
+	open := p.pos
+	p.pos++
+	var value strings.Builder
+	for !p.done() {
+		r := p.input[p.pos]
+		p.pos++
+		switch {
+		case r == '"':
+			return value.String(), nil
+		case r == '\\' && !p.done() && (p.input[p.pos] == '"' || p.input[p.pos] == '\\'):
+			value.WriteRune(p.input[p.pos])
+			p.pos++
+		default:
+			value.WriteRune(r)
+		}
+	}
+	return "", p.errorAt(open, "unterminated quote")
+}
+
+// words matches a value without a field against the title, artist and album
+func (p *queryParser) words(start int) (string, []any, error) {
+	value, err := p.value()
+	if err != nil {
+		return "", nil, err
+	}
+	pattern, err := p.wordsPattern(value, start)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + queryAnyWords + ") LIKE ?", []any{pattern}, nil
+}
+
+func (p *queryParser) wordsPattern(value string, pos int) (string, error) {
+	normalized, err := NormalizeText(value)
+	if err != nil {
+		return "", fmt.Errorf("error normalizing the query: %w", err)
+	}
+	if normalized == "" {
+		return "", p.errorAt(pos, "expected letters or digits")
+	}
+	// Normalized text has neither % nor _
+	return "%" + normalized + "%", nil
+}
+
+func (p *queryParser) field(name string, start int) (string, []any, error) {
+	field, ok := queryFields[name]
+	if !ok {
+		return "", nil, p.errorAt(start, "unknown field %q", name)
+	}
+	opPos := p.pos
+	op := "="
+	for _, candidate := range queryOperators {
+		if strings.HasPrefix(string(p.input[p.pos:]), candidate) {
+			op = candidate
+			p.pos += utf8.RuneCountInString(candidate)
+			break
+		}
+	}
+	valuePos := p.pos
+	value, err := p.value()
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimSpace(value) == "" {
+		return "", nil, p.errorAt(valuePos, "expected a value for %s", name)
+	}
+	if op != "=" && field.kind != queryNumber && field.kind != queryDuration {
+		return "", nil, p.errorAt(opPos, "%s only compares numeric fields, not %s", op, name)
+	}
+
+	switch field.kind {
+	case queryWords:
+		pattern, err := p.wordsPattern(value, valuePos)
+		if err != nil {
+			return "", nil, err
+		}
+		return field.expr + " LIKE ?", []any{pattern}, nil
+	case queryText:
+		return field.expr + ` LIKE ? ESCAPE '\'`, []any{"%" + likeEscaper.Replace(value) + "%"}, nil
+	case queryExact:
+		return field.expr + " = ? COLLATE NOCASE", []any{value}, nil
+	case queryFormat:
+		format := strings.ToLower(strings.TrimPrefix(value, "."))
+		if !formatRe.MatchString(format) {
+			return "", nil, p.errorAt(valuePos, "invalid format %q", value)
+		}
+		return field.expr + " LIKE ?", []any{"%." + format}, nil
+	case queryDuration:
+		seconds, ok := parseQueryDuration(value)
+		if !ok {
+			return "", nil, p.errorAt(valuePos, "expected seconds or minutes:seconds, not %q", value)
+		}
+		return field.expr + " " + op + " ?", []any{seconds}, nil
+	default:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, p.errorAt(valuePos, "expected a number, not %q", value)
+		}
+		return field.expr + " " + op + " ?", []any{n}, nil
+	}
+}
+
+func parseQueryDuration(value string) (int64, bool) {
+	minutes, seconds, found := strings.Cut(value, ":")
+	if !found {
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil && n >= 0
+	}
+	m, err := strconv.ParseInt(minutes, 10, 64)
+	if err != nil || m < 0 {
+		return 0, false
+	}
+	s, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil || s < 0 || s >= 60 || len(seconds) != 2 {
+		return 0, false
+	}
+	return m*60 + s, true
+}
+
+// SaveQuery saves the query for the user with the name, replacing the one
+// saved with it before.
+func (l *LibrarySearch) SaveQuery(ctx context.Context, user, name, query string) (model.SavedQuery, error) {
+	ctx = context.Background()
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxQueryNameLength {
+		return model.SavedQuery{}, fmt.Errorf("%w: it has between 1 and %d characters", model.ErrInvalidQueryName, maxQueryNameLength)
+	}
+	if strings.TrimSpace(query) == "" {
+		return model.SavedQuery{}, fmt.Errorf("%w: the query is empty", model.ErrInvalidLibraryQuery)
+	}
+	if _, _, err := compileLibraryQuery(query); err != nil {
+		return model.SavedQuery{}, err
+	}
+	userData, err := l.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.SavedQuery{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	saved, err := l.queries.UpsertSavedQuery(ctx, db.UpsertSavedQueryParams{UserID: userData.ID, Name: name, Query: query})
+	if err != nil {
+		return model.SavedQuery{}, fmt.Errorf("error saving the query: %w", err)
+	}
+	return model.SavedQueryFromDB(saved), nil
+}
+
+func (l *LibrarySearch) SavedQuery(ctx context.Context, user, name string) (model.SavedQuery, error) {
+	ctx = context.Background()
+	userData, err := l.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.SavedQuery{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	saved, err := l.queries.GetSavedQuery(ctx, db.GetSavedQueryParams{UserID: userData.ID, Name: name})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.SavedQuery{}, model.ErrSavedQueryNotFound
+	}
+	if err != nil {
+		return model.SavedQuery{}, fmt.Errorf("error getting the saved query: %w", err)
+	}
+	return model.SavedQueryFromDB(saved), nil
+}
+
+func (l *LibrarySearch) ListSavedQueries(ctx context.Context, user string) ([]model.SavedQuery, error) {
+	ctx = context.Background()
+	userData, err := l.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	rows, err := l.queries.ListSavedQueries(ctx, userData.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the saved queries: %w", err)
+	}
+	queries := make([]model.SavedQuery, 0, len(rows))
+	for _, row := range rows {
+		queries = append(queries, model.SavedQueryFromDB(row))
+	}
+	return queries, nil
+}
+
+func (l *LibrarySearch) DeleteSavedQuery(ctx context.Context, user, name string) error {
+	ctx = context.Background()
+	userData, err := l.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	n, err := l.queries.DeleteSavedQuery(ctx, db.DeleteSavedQueryParams{UserID: userData.ID, Name: name})
+	if err != nil {
+		return fmt.Errorf("error deleting the saved query: %w", err)
+	}
+	if n == 0 {
+		return model.ErrSavedQueryNotFound
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestCompileLibraryQuery(t *testing.T) {
+	where, args, err := compileLibraryQuery(`artist:"boards of canada" year:>=1998 samplerate:>=96000 format:FLAC -genre:live duration:<4:30`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{"%boards of canada%", int64(1998), int64(96000), "%.flac", "%live%", int64(270)}
+	if len(where) != 6 || !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+	// The values only reach the SQL as arguments
+	_, args, err = compileLibraryQuery(`title:"x' OR 1=1 --" composer:100%`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"%x or 1 1%", `%100\%%`}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+
+	for query, position := range map[string]int{
+		`artist:"boards of canada`: 8,
+		`year:>=199x`:              8,
+		`bpm:120`:                  1,
+		`blondie -`:                9,
+		`genre:>rock`:              7,
+		`format:`:                  8,
+		`año:2000`:                 1,
+		`año año:2000`:             5,
+	} {
+		_, _, err := compileLibraryQuery(query)
+		var syntaxErr *model.LibraryQueryError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Position != position || !errors.Is(err, model.ErrInvalidLibraryQuery) {
+			t.Errorf("%s: %v, want an error at position %d", query, err, position)
+		}
+	}
+}
+
+func TestSavedLibraryQueries(t *testing.T) {
+	env := newTestEnv(t)
+	library := NewLibrarySearch(env.conn, env.queries)
+	ctx := context.Background()
+	atomic := FakeTrack{Title: "Atomic", Artist: "Blondie", Album: "Parallel Lines", ISRC: "USCH37900099", TrackNumber: 1}
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{atomic}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("alice", "q2", atomic, model.QualityCD).ID)
+
+	if _, err := library.SaveQuery(ctx, "alice", "hires", "samplerate:>=96000 format:"); !errors.Is(err, model.ErrInvalidLibraryQuery) {
+		t.Errorf("saving a query with a syntax error gave %v", err)
+	}
+	if _, err := library.SaveQuery(ctx, "alice", "hires", "samplerate:>=48000"); err != nil {
+		t.Fatal(err)
+	}
+	// Saving with the same name replaces it
+	if _, err := library.SaveQuery(ctx, "alice", "hires", "blondie samplerate:>=88200 format:flac -title:atomic"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := library.ListSavedQueries(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Name != "hires" {
+		t.Fatalf("saved = %+v, want only hires", saved)
+	}
+	page, err := library.ListTracks(ctx, model.TrackListQuery{User: "alice", Query: saved[0].Query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Tracks) != 1 || page.Tracks[0].Title != "Call Me" {
+		t.Errorf("hires: %+v, want Call Me", page.Tracks)
+	}
+	if _, err := library.SavedQuery(ctx, "bob", "hires"); !errors.Is(err, model.ErrSavedQueryNotFound) {
+		t.Errorf("bob got alice's query: %v", err)
+	}
+	if err := library.DeleteSavedQuery(ctx, "alice", "hires"); err != nil {
+		t.Fatal(err)
+	}
+	if err := library.DeleteSavedQuery(ctx, "alice", "hires"); !errors.Is(err, model.ErrSavedQueryNotFound) {
+		t.Errorf("deleting twice gave %v", err)
+	}
+}
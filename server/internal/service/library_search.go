@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// Results of a library search in a page, by default and at most
+const (
+	defaultLibrarySearchLimit = 20
+	maxLibrarySearchLimit     = 100
+)
+
+// The title weighs the most in the ranking, the normalized text the least
+// as it repeats the other fields
+const searchLibrary = `SELECT t.id, t.title, t.normalized_title, t.artist_id, t.album_id, t.duration, t.track_number, t.disc_number, t.sample_rate, t.bitrate, t.channels, t.file_path, t.file_size, t.isrc, t.composer, t.created_at,
+  COALESCE(ar.name, ''), COALESCE(al.title, ''),
+  highlight(track_search, 0, '<mark>', '</mark>'),
+  highlight(track_search, 1, '<mark>', '</mark>'),
+  highlight(track_search, 2, '<mark>', '</mark>'),
+  highlight(track_search, 3, '<mark>', '</mark>'),
+  bm25(track_search, 10.0, 5.0, 3.0, 1.0, 0.5) AS rank
+FROM track_search
+JOIN track AS t ON t.id = track_search.rowid
+LEFT JOIN artist AS ar ON ar.id = t.artist_id
+LEFT JOIN album AS al ON al.id = t.album_id
+WHERE track_search MATCH ?1 AND (?2 = '' OR EXISTS (
+  SELECT 1 FROM user_track AS ut
+  JOIN user AS u ON ut.user_id = u.id
+  WHERE ut.track_id = t.id AND u.username = ?2
+))
+ORDER BY rank, t.id
+LIMIT ?3 OFFSET ?4`
+
+const countLibrarySearch = `SELECT COUNT(*)
+FROM track_search
+JOIN track AS t ON t.id = track_search.rowid
+WHERE track_search MATCH ?1 AND (?2 = '' OR EXISTS (
+  SELECT 1 FROM user_track AS ut
+  JOIN user AS u ON ut.user_id = u.id
+  WHERE ut.track_id = t.id AND u.username = ?2
+))`
+
+// LibrarySearch finds songs of the library by any word of their title,
+// artist, album or composer, with the full-text index of SQLite's FTS5.
+// The index and the triggers keeping it in sync are created by the
+// migrations in migrations/search. The driver only has FTS5 when built
+// with the sqlite_fts5 tag, without it every search fails with
+// ErrLibrarySearchUnavailable.
+type LibrarySearch struct {
+	db        *sql.DB
+	queries   *db.Queries
+	available bool
+}
+
+func NewLibrarySearch(conn *sql.DB, queries *db.Queries) *LibrarySearch {
+	return &LibrarySearch{db: conn, queries: queries}
+}
+
+// Setup enables the search when SQLite has FTS5.
+func (l *LibrarySearch) Setup(ctx context.Context) error {
+	ctx = context.Background()
+	available, err := LibrarySearchAvailable(ctx, l.db)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return model.ErrLibrarySearchUnavailable
+	}
+	l.available = true
+	return nil
+}
+
+// LibrarySearchAvailable tells whether SQLite has FTS5, which the index of
+// the search needs.
+func LibrarySearchAvailable(ctx context.Context, conn *sql.DB) (bool, error) {
+	var available bool
+	if err := conn.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available); err != nil {
+		return false, fmt.Errorf("error checking for FTS5: %w", err)
+	}
+	return available, nil
+}
+
+// Search returns a page of the songs matching every word of the query,
+// the best matches first.
+func (l *LibrarySearch) Search(ctx context.Context, query model.LibrarySearchQuery) (model.LibrarySearchPage, error) {
+	ctx = context.Background()
+	if !l.available {
+		return model.LibrarySearchPage{}, model.ErrLibrarySearchUnavailable
+	}
+	match, err := librarySearchMatch(query.Query)
+	if err != nil {
+		return model.LibrarySearchPage{}, err
+	}
+	if query.Limit <= 0 {
+		query.Limit = defaultLibrarySearchLimit
+	}
+	if query.Limit > maxLibrarySearchLimit || query.Offset < 0 {
+		return model.LibrarySearchPage{}, fmt.Errorf("%w: the limit goes up to %d and the offset can't be negative", model.ErrInvalidLibrarySearch, maxLibrarySearchLimit)
+	}
+	if query.User != "" {
+		if _, err := l.queries.GetUserByUsername(ctx, query.User); err != nil {
+			return model.LibrarySearchPage{}, fmt.Errorf("could not find the user %s: %w", query.User, err)
+		}
+	}
+
+	results, total, err := l.search(ctx, match, query)
+	if err != nil {
+		return model.LibrarySearchPage{}, err
+	}
+	page := model.LibrarySearchPage{
+		Query:   query.Query,
+		User:    query.User,
+		Total:   total,
+		Limit:   query.Limit,
+		Offset:  query.Offset,
+		Results: results,
+	}
+	if next := query.Offset + int64(len(results)); next < total {
+		page.NextOffset = &next
+	}
+	return page, nil
+}
+
+// librarySearchMatch turns the query into one for FTS5 that matches the
+// songs with every word, whole or as the start of a longer one. Each word
+// is quoted so that nothing the user types is taken as FTS5 syntax.
+func librarySearchMatch(query string) (string, error) {
+	normalized, err := NormalizeText(query)
+	if err != nil {
+		return "", fmt.Errorf("error normalizing the query: %w", err)
+	}
+	words := strings.Fields(normalized)
+	if len(words) == 0 {
+		return "", fmt.Errorf("%w: the query has no words", model.ErrInvalidLibrarySearch)
+	}
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"*`
+	}
+	return strings.Join(words, " "), nil
+}
+
+func (l *LibrarySearch) search(ctx context.Context, match string, query model.LibrarySearchQuery) ([]model.LibrarySearchResult, int64, error) {
+	var total int64
+	if err := l.db.QueryRowContext(ctx, countLibrarySearch, match, query.User).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting the results: %w", err)
+	}
+
+	rows, err := l.db.QueryContext(ctx, searchLibrary, match, query.User, query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error searching the library: %w", err)
+	}
+	defer rows.Close()
+	results := []model.LibrarySearchResult{}
+	for rows.Next() {
+		var (
+			result model.LibrarySearchResult
+			track  db.Track
+			h      = &result.Highlights
+			rank   float64
+		)
+		if err := rows.Scan(
+			&track.ID, &track.Title, &track.NormalizedTitle, &track.ArtistID, &track.AlbumID, &track.Duration,
+			&track.TrackNumber, &track.DiscNumber, &track.SampleRate, &track.Bitrate, &track.Channels,
+			&track.FilePath, &track.FileSize, &track.Isrc, &track.Composer, &track.CreatedAt,
+			&result.Artist, &result.Album,
+			&h.Title, &h.Artist, &h.Album, &h.Composer,
+			&rank,
+		); err != nil {
+			return nil, 0, fmt.Errorf("error reading the results: %w", err)
+		}
+		result.Track = model.TrackFromDB(track)
+		// bm25 is lower for better matches
+		result.Score = -rank
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading the results: %w", err)
+	}
+	return results, total, nil
+}
@@ -0,0 +1,87 @@
+//go:build sqlite_fts5
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestLibrarySearchFollowsTheLibrary(t *testing.T) {
+	env := newTestEnv(t)
+	search := NewLibrarySearch(env.conn, env.queries)
+	ctx := context.Background()
+	if err := search.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("bob", "q2", heartOfGlass, model.QualityHiRes).ID)
+
+	find := func(query, user string, limit int64) model.LibrarySearchPage {
+		t.Helper()
+		page, err := search.Search(ctx, model.LibrarySearchQuery{Query: query, User: user, Limit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	page := find("blondie", "", 1)
+	if page.Total != 2 || len(page.Results) != 1 || page.NextOffset == nil || *page.NextOffset != 1 {
+		t.Errorf("blondie: %d results of %d, next %v, want 1 of 2 and more to come", len(page.Results), page.Total, page.NextOffset)
+	}
+	page = find("CALL", "", 0)
+	if page.Total != 1 || page.Results[0].Highlights.Title != "<mark>Call</mark> Me" {
+		t.Errorf("call: %+v, want Call Me highlighted", page.Results)
+	}
+	if page := find("glass", "alice", 0); page.Total != 0 {
+		t.Errorf("alice found %d songs of bob", page.Total)
+	}
+
+	// Renaming the artist reindexes their songs
+	if _, err := env.conn.Exec(`UPDATE artist SET name = 'Debbie Harry', normalized_name = 'debbie harry'`); err != nil {
+		t.Fatal(err)
+	}
+	if page := find("debbie par", "", 0); page.Total != 2 || page.Results[0].Highlights.Artist != "<mark>Debbie</mark> Harry" {
+		t.Errorf("debbie: %+v, want both songs", page.Results)
+	}
+	if err := env.streamrip.fileManager.DeleteTrackForUser(ctx, "alice", env.mustTrack(callMe.ISRC).ID); err != nil {
+		t.Fatal(err)
+	}
+	if page := find("debbie", "", 0); page.Total != 1 {
+		t.Errorf("%d songs after deleting one, want 1", page.Total)
+	}
+}
+
+func TestLibrarySearchMigrationIndexesTheLibrary(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("bob", "q2", heartOfGlass, model.QualityHiRes).ID)
+
+	// Songs added before the index existed are indexed when it is created
+	m := searchMigrations(t, env.conn)
+	if err := m.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	search := NewLibrarySearch(env.conn, env.queries)
+	ctx := context.Background()
+	if err := search.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	page, err := search.Search(ctx, model.LibrarySearchQuery{Query: "blondie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 {
+		t.Errorf("blondie: %d results, want both songs", page.Total)
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+import "testing"
+
+func TestLibrarySearchMatchQuotesEveryWord(t *testing.T) {
+	match, err := librarySearchMatch(`Beyoncé "AND" heart-of OR*`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"beyonce"* "and"* "heart"* "of"* "or"*`; match != want {
+		t.Errorf("match = %s, want %s", match, want)
+	}
+	if _, err := librarySearchMatch(" -*- "); err == nil {
+		t.Error("a query without words was accepted")
+	}
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// How many Qobuz results are offered when a song is ambiguous
+const maxImportCandidates = 5
+
+// PlaylistImporter matches the songs of an uploaded playlist against the
+// library and Qobuz. Songs found in the library are linked to the user,
+// songs with a single clear match in Qobuz are queued for download and
+// the rest are left for the user to pick or dismiss.
+type PlaylistImporter struct {
+	queries   *db.Queries
+	streamrip *Streamrip
+}
+
+func NewPlaylistImporter(queries *db.Queries, streamrip *Streamrip) *PlaylistImporter {
+	return &PlaylistImporter{
+		queries:   queries,
+		streamrip: streamrip,
+	}
+}
+
+// Import reads the playlist and starts matching its songs in the
+// background. The returned import has no entries yet, they show up in
+// GetImport as they are matched.
+func (p *PlaylistImporter) Import(ctx context.Context, user, name, filename string, quality int64, r io.Reader) (model.PlaylistImport, error) {
+	ctx = context.Background()
+	// Songs are searched in Qobuz, the quality has to be one it offers
+	if err := model.SourceQobuz.ValidateQuality(model.Quality(quality)); err != nil {
+		return model.PlaylistImport{}, err
+	}
+	format, err := playlistFormat(filename)
+	if err != nil {
+		return model.PlaylistImport{}, err
+	}
+	playlist, err := parsePlaylist(format, r)
+	if err != nil {
+		return model.PlaylistImport{}, err
+	}
+
+	userData, err := p.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.PlaylistImport{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	if name == "" {
+		name = playlist.Name
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	params := db.InsertPlaylistImportParams{
+		ID:      uuid.New().String(),
+		UserID:  userData.ID,
+		Name:    name,
+		Format:  format,
+		Quality: quality,
+		Status:  "processing",
+	}
+	imported, err := p.queries.InsertPlaylistImport(ctx, params)
+	if err != nil {
+		return model.PlaylistImport{}, fmt.Errorf("error saving playlist import: %w", err)
+	}
+
+	go p.matchEntries(imported.ID, user, quality, playlist.Entries)
+
+	result := model.PlaylistImportFromDB(imported, user)
+	result.Summary = map[model.PlaylistImportResult]int{}
+	result.Entries = []model.PlaylistImportEntry{}
+	return result, nil
+}
+
+func (p *PlaylistImporter) matchEntries(importID, user string, quality int64, entries []playlistEntry) {
+	ctx := context.Background()
+	for i, entry := range entries {
+		m := p.match(ctx, user, quality, entry)
+
+		params := db.InsertPlaylistImportEntryParams{
+			ImportID:     importID,
+			Position:     int64(i + 1),
+			Title:        entry.Title,
+			Artist:       sql.NullString{String: entry.Artist, Valid: entry.Artist != ""},
+			Album:        sql.NullString{String: entry.Album, Valid: entry.Album != ""},
+			Isrc:         sql.NullString{String: entry.ISRC, Valid: entry.ISRC != ""},
+			Result:       string(m.Result),
+			TrackID:      sql.NullInt64{Int64: m.TrackID, Valid: m.TrackID > 0},
+			DownloadID:   sql.NullString{String: m.DownloadID, Valid: m.DownloadID != ""},
+			ErrorMessage: sql.NullString{String: m.Error, Valid: m.Error != ""},
+		}
+		if len(m.Candidates) > 0 {
+			candidates, err := json.Marshal(m.Candidates)
+			if err != nil {
+				log.Printf("Could not save the candidates of %q: %v", entry.Title, err)
+			} else {
+				params.Candidates = sql.NullString{String: string(candidates), Valid: true}
+			}
+		}
+		if _, err := p.queries.InsertPlaylistImportEntry(ctx, params); err != nil {
+			log.Printf("Error saving entry %d of playlist import %s: %v", i+1, importID, err)
+		}
+	}
+
+	statusParams := db.UpdatePlaylistImportStatusParams{ID: importID, Status: "done"}
+	if err := p.queries.UpdatePlaylistImportStatus(ctx, statusParams); err != nil {
+		log.Printf("Error finishing playlist import %s: %v", importID, err)
+	}
+	log.Printf("Playlist import %s finished (%d songs)", importID, len(entries))
+}
+
+type importMatch struct {
+	Result     model.PlaylistImportResult
+	TrackID    int64
+	DownloadID string
+	Candidates []model.TrackPreview
+	Error      string
+}
+
+// match looks for the song by ISRC in the library, then by normalized
+// title and artist in the library, and last in Qobuz.
+func (p *PlaylistImporter) match(ctx context.Context, user string, quality int64, entry playlistEntry) importMatch {
+	if entry.ISRC != "" {
+		track, err := p.queries.SearchTracksByISRC(ctx, sql.NullString{String: entry.ISRC, Valid: true})
+		if err == nil {
+			m, _ := p.addToUser(ctx, user, quality, "", track.Isrc.String)
+			return m
+		}
+		if err != sql.ErrNoRows {
+			log.Printf("Error searching ISRC %s in the library: %v", entry.ISRC, err)
+		}
+	}
+
+	if track, ok := p.findInLibrary(ctx, entry); ok {
+		m, _ := p.addToUser(ctx, user, quality, "", track.Isrc.String)
+		return m
+	}
+
+	query := strings.TrimSpace(entry.Artist + " " + entry.Title)
+	results, err := p.streamrip.SearchSong("qobuz", "track", query)
+	if err != nil {
+		log.Printf("Error searching %q in Qobuz: %v", query, err)
+		return importMatch{Result: model.ImportNotFound}
+	}
+	previews := model.MapToTrackPreviews(results)
+
+	chosen, candidates := chooseCandidate(entry, previews)
+	if chosen == nil {
+		if len(candidates) == 0 {
+			return importMatch{Result: model.ImportNotFound}
+		}
+		return importMatch{Result: model.ImportAmbiguous, Candidates: candidates}
+	}
+
+	m, err := p.addToUser(ctx, user, quality, chosen.TrackID, chosen.ISRC)
+	if err != nil {
+		// The download could not be started, let the user retry it
+		m.Candidates = []model.TrackPreview{*chosen}
+	}
+	return m
+}
+
+func (p *PlaylistImporter) findInLibrary(ctx context.Context, entry playlistEntry) (db.Track, bool) {
+	if entry.Artist == "" {
+		return db.Track{}, false
+	}
+	title, err := NormalizeText(entry.Title)
+	if err != nil {
+		return db.Track{}, false
+	}
+	artist, err := NormalizeText(entry.Artist)
+	if err != nil {
+		return db.Track{}, false
+	}
+
+	params := db.FindTracksByNormalizedTitleAndArtistParams{
+		NormalizedTitle: title,
+		NormalizedName:  artist,
+	}
+	tracks, err := p.queries.FindTracksByNormalizedTitleAndArtist(ctx, params)
+	if err != nil {
+		log.Printf("Error searching %q in the library: %v", entry.Title, err)
+		return db.Track{}, false
+	}
+	if len(tracks) == 0 {
+		return db.Track{}, false
+	}
+
+	// The same song can be in several albums, prefer the one of the playlist
+	if entry.Album != "" {
+		for _, track := range tracks {
+			album, err := p.queries.GetAlbumByTrackID(ctx, track.ID)
+			if err == nil && sameTitle(album.Title, entry.Album) {
+				return track, true
+			}
+		}
+	}
+	return tracks[0], true
+}
+
+// addToUser links the track to the user when it is in the library and
+// queues its download otherwise. When neither can be done the match is a
+// failure that carries the error.
+func (p *PlaylistImporter) addToUser(ctx context.Context, user string, quality int64, sourceID, isrc string) (importMatch, error) {
+	result, err := p.streamrip.EnsureTrackForUser(ctx, model.SourceQobuz, "", sourceID, user, isrc, model.Quality(quality))
+	if err != nil {
+		log.Printf("Error adding track %s to user %s: %v", isrc, user, err)
+		return importMatch{Result: model.ImportFailed, Error: err.Error()}, err
+	}
+
+	if result.Action == model.ActionQueued || result.Action == model.ActionDownloading {
+		return importMatch{Result: model.ImportQueued, DownloadID: result.ID}, nil
+	}
+	// Waiting for an admin, there is no download to track yet
+	if result.Action == model.ActionPending {
+		return importMatch{Result: model.ImportQueued}, nil
+	}
+	m := importMatch{Result: model.ImportMatched}
+	if result.Action == model.ActionLinked {
+		m.DownloadID = result.ID
+	}
+	if track, err := p.queries.SearchTracksByISRC(ctx, sql.NullString{String: isrc, Valid: isrc != ""}); err == nil {
+		m.TrackID = track.ID
+	}
+	return m, nil
+}
+
+// chooseCandidate returns the result that is clearly the song of the
+// entry, or nil and the results the user has to choose from.
+func chooseCandidate(entry playlistEntry, results []model.TrackPreview) (*model.TrackPreview, []model.TrackPreview) {
+	if entry.ISRC != "" {
+		for i := range results {
+			if strings.EqualFold(results[i].ISRC, entry.ISRC) {
+				return &results[i], nil
+			}
+		}
+	}
+
+	var matches []model.TrackPreview
+	for _, r := range results {
+		if sameTitle(r.Title, entry.Title) && (entry.Artist == "" || sameArtist(r.Artist, entry.Artist)) {
+			matches = append(matches, r)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, model.LimitResults(results, maxImportCandidates)
+	}
+
+	// Several editions of the same recording share the ISRC
+	for _, m := range matches[1:] {
+		if m.ISRC != matches[0].ISRC {
+			return nil, model.LimitResults(matches, maxImportCandidates)
+		}
+	}
+	if entry.Artist == "" && len(results) > 1 {
+		// Without an artist a title alone is not enough
+		return nil, model.LimitResults(matches, maxImportCandidates)
+	}
+	return &matches[0], nil
+}
+
+// Versions, remasters and features usually come between brackets or after a dash
+var titleDecorationRe = regexp.MustCompile(`\s*(\(.*?\)|\[.*?\]|\s-\s.*$)`)
+
+func sameTitle(a, b string) bool {
+	na, errA := NormalizeText(a)
+	nb, errB := NormalizeText(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if na == nb {
+		return true
+	}
+	na, errA = NormalizeText(titleDecorationRe.ReplaceAllString(a, ""))
+	nb, errB = NormalizeText(titleDecorationRe.ReplaceAllString(b, ""))
+	return errA == nil && errB == nil && na != "" && na == nb
+}
+
+// Exports often list every artist of the song, so one containing the
+// other is enough.
+func sameArtist(a, b string) bool {
+	na, errA := NormalizeText(a)
+	nb, errB := NormalizeText(b)
+	if errA != nil || errB != nil || na == "" || nb == "" {
+		return false
+	}
+	return strings.Contains(na, nb) || strings.Contains(nb, na)
+}
+
+func (p *PlaylistImporter) GetImport(ctx context.Context, id string) (model.PlaylistImport, error) {
+	ctx = context.Background()
+	row, err := p.queries.GetPlaylistImportByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.PlaylistImport{}, model.ErrPlaylistImportNotFound
+		}
+		return model.PlaylistImport{}, fmt.Errorf("error reading playlist import %s: %w", id, err)
+	}
+
+	entries, err := p.queries.ListPlaylistImportEntries(ctx, id)
+	if err != nil {
+		return model.PlaylistImport{}, fmt.Errorf("error reading the songs of playlist import %s: %w", id, err)
+	}
+
+	result := model.PlaylistImportFromDB(row.PlaylistImport, row.Username)
+	result.Summary = map[model.PlaylistImportResult]int{}
+	result.Entries = make([]model.PlaylistImportEntry, 0, len(entries))
+	for _, e := range entries {
+		entry := model.PlaylistImportEntryFromDB(e)
+		result.Summary[entry.Result]++
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+// ListImports returns the user's imports, newest first, without entries.
+func (p *PlaylistImporter) ListImports(ctx context.Context, user string) ([]model.PlaylistImport, error) {
+	rows, err := p.queries.ListPlaylistImportsByUsername(context.Background(), user)
+	if err != nil {
+		return nil, fmt.Errorf("error listing playlist imports: %w", err)
+	}
+	imports := make([]model.PlaylistImport, 0, len(rows))
+	for _, row := range rows {
+		imports = append(imports, model.PlaylistImportFromDB(row, user))
+	}
+	return imports, nil
+}
+
+// ResolveEntry settles an ambiguous entry with the candidate the user
+// picked, or retries a failed one. An empty trackID dismisses every
+// candidate and marks the song as not found.
+func (p *PlaylistImporter) ResolveEntry(ctx context.Context, importID string, entryID int64, trackID string) (model.PlaylistImportEntry, error) {
+	ctx = context.Background()
+	row, err := p.queries.GetPlaylistImportByID(ctx, importID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.PlaylistImportEntry{}, model.ErrPlaylistImportNotFound
+		}
+		return model.PlaylistImportEntry{}, fmt.Errorf("error reading playlist import %s: %w", importID, err)
+	}
+	entryParams := db.GetPlaylistImportEntryParams{ID: entryID, ImportID: importID}
+	stored, err := p.queries.GetPlaylistImportEntry(ctx, entryParams)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.PlaylistImportEntry{}, model.ErrImportEntryNotFound
+		}
+		return model.PlaylistImportEntry{}, fmt.Errorf("error reading playlist import entry %d: %w", entryID, err)
+	}
+
+	entry := model.PlaylistImportEntryFromDB(stored)
+	if entry.Result != model.ImportAmbiguous && entry.Result != model.ImportFailed {
+		return model.PlaylistImportEntry{}, model.ErrImportEntryResolved
+	}
+
+	m := importMatch{Result: model.ImportNotFound}
+	if trackID != "" {
+		var chosen *model.TrackPreview
+		for i := range entry.Candidates {
+			if entry.Candidates[i].TrackID == trackID {
+				chosen = &entry.Candidates[i]
+				break
+			}
+		}
+		if chosen == nil {
+			return model.PlaylistImportEntry{}, model.ErrUnknownCandidate
+		}
+		m, err = p.addToUser(ctx, row.Username, row.PlaylistImport.Quality, chosen.TrackID, chosen.ISRC)
+		if err != nil {
+			return model.PlaylistImportEntry{}, fmt.Errorf("could not add the track %s to the user: %w", chosen.TrackID, err)
+		}
+	}
+
+	params := db.UpdatePlaylistImportEntryResultParams{
+		ID:         entryID,
+		Result:     string(m.Result),
+		TrackID:    sql.NullInt64{Int64: m.TrackID, Valid: m.TrackID > 0},
+		DownloadID: sql.NullString{String: m.DownloadID, Valid: m.DownloadID != ""},
+	}
+	if err := p.queries.UpdatePlaylistImportEntryResult(ctx, params); err != nil {
+		return model.PlaylistImportEntry{}, fmt.Errorf("error saving playlist import entry %d: %w", entryID, err)
+	}
+
+	entry.Result = m.Result
+	entry.TrackID = nil
+	if m.TrackID > 0 {
+		entry.TrackID = &m.TrackID
+	}
+	entry.DownloadID = nil
+	if m.DownloadID != "" {
+		entry.DownloadID = &m.DownloadID
+	}
+	entry.Error = nil
+	return entry, nil
+}
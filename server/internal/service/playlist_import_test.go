@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// qobuzResult is what rip answers for the track in a Qobuz search.
+func qobuzResult(id string, track FakeTrack) model.StreamripSearchResult {
+	var r model.StreamripSearchResult
+	r.Source, r.MediaType, r.ID = "qobuz", "track", id
+	r.Data.Title = track.Title
+	r.Data.Performer.Name = track.Artist
+	r.Data.Album.Title = track.Album
+	r.Data.ISRC = track.ISRC
+	return r
+}
+
+// importPlaylist imports the file and waits until all its songs are matched.
+func (e *testEnv) importPlaylist(importer *PlaylistImporter, user, filename, content string) model.PlaylistImport {
+	e.t.Helper()
+	ctx := context.Background()
+	imported, err := importer.Import(ctx, user, "", filename, int64(model.QualityCD), strings.NewReader(content))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		report, err := importer.GetImport(ctx, imported.ID)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		if report.Status == "done" {
+			return report
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	e.t.Fatalf("playlist import %s never finished", imported.ID)
+	return model.PlaylistImport{}
+}
+
+func TestImportMatchesEverySong(t *testing.T) {
+	env := newTestEnv(t)
+	importer := NewPlaylistImporter(env.queries, env.streamrip)
+	atomic := FakeTrack{Title: "Atomic", Artist: "Blondie", Album: "Eat to the Beat", ISRC: "USCH37900101", BitDepth: 16, SampleRate: 44100}
+	atomicLive := atomic
+	atomicLive.Album, atomicLive.ISRC = "Live", "USCH37900102"
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.add(model.SourceQobuz, "q4", FakeRelease{Tracks: []FakeTrack{atomicLive}})
+	env.streamrip.searches.set(searchKey("qobuz", "track", "Blondie Heart of Glass"), []model.StreamripSearchResult{qobuzResult("q2", heartOfGlass)})
+	env.streamrip.searches.set(searchKey("qobuz", "track", "Blondie Atomic"), []model.StreamripSearchResult{qobuzResult("q3", atomic), qobuzResult("q4", atomicLive)})
+	env.streamrip.searches.set(searchKey("qobuz", "track", "Nobody Nothing"), []model.StreamripSearchResult{})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityCD).ID)
+	ctx := context.Background()
+
+	// Call Me is in the library, Heart of Glass has a single clear match in
+	// Qobuz, Atomic two editions and the last song nothing at all
+	report := env.importPlaylist(importer, "bob", "blondie.csv", "title,artist,isrc\n"+
+		"Call Me,Blondie,"+callMe.ISRC+"\n"+
+		"Heart of Glass,Blondie,\n"+
+		"Atomic,Blondie,\n"+
+		"Nothing,Nobody,\n")
+	if report.Name != "blondie" || len(report.Entries) != 4 {
+		t.Fatalf("import %q has %d songs, want blondie with 4", report.Name, len(report.Entries))
+	}
+	want := []model.PlaylistImportResult{model.ImportMatched, model.ImportQueued, model.ImportAmbiguous, model.ImportNotFound}
+	for i, entry := range report.Entries {
+		if entry.Result != want[i] {
+			t.Errorf("%s is %s, want %s", entry.Title, entry.Result, want[i])
+		}
+	}
+	if report.Summary[model.ImportMatched] != 1 || report.Summary[model.ImportNotFound] != 1 {
+		t.Errorf("summary = %v, want one song of each result", report.Summary)
+	}
+	matched, queued, ambiguous := report.Entries[0], report.Entries[1], report.Entries[2]
+	if matched.TrackID == nil || *matched.TrackID != env.mustTrack(callMe.ISRC).ID || !env.isLinked("bob", callMe.ISRC) {
+		t.Errorf("Call Me is track %v, want the library's linked to bob", matched.TrackID)
+	}
+	if queued.DownloadID == nil {
+		t.Fatal("Heart of Glass has no download")
+	}
+	env.wait(*queued.DownloadID)
+	if !env.isLinked("bob", heartOfGlass.ISRC) {
+		t.Error("Heart of Glass is not in bob's library")
+	}
+	if len(ambiguous.Candidates) != 2 {
+		t.Fatalf("Atomic has %d candidates, want 2", len(ambiguous.Candidates))
+	}
+
+	if _, err := importer.ResolveEntry(ctx, report.ID, ambiguous.ID, "q9"); !errors.Is(err, model.ErrUnknownCandidate) {
+		t.Errorf("resolve with another track: %v, want %v", err, model.ErrUnknownCandidate)
+	}
+	resolved, err := importer.ResolveEntry(ctx, report.ID, ambiguous.ID, "q4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Result != model.ImportQueued || resolved.DownloadID == nil {
+		t.Fatalf("resolved Atomic = %+v, want queued", resolved)
+	}
+	env.wait(*resolved.DownloadID)
+	if !env.isLinked("bob", atomicLive.ISRC) {
+		t.Error("the chosen edition of Atomic is not in bob's library")
+	}
+	if _, err := importer.ResolveEntry(ctx, report.ID, ambiguous.ID, ""); !errors.Is(err, model.ErrImportEntryResolved) {
+		t.Errorf("resolve twice: %v, want %v", err, model.ErrImportEntryResolved)
+	}
+
+	report, err = importer.GetImport(ctx, report.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary[model.ImportQueued] != 2 || report.Summary[model.ImportAmbiguous] != 0 {
+		t.Errorf("summary after resolving = %v, want 2 queued and none ambiguous", report.Summary)
+	}
+}
+
+func TestFailedImportsKeepTheirError(t *testing.T) {
+	env := newTestEnv(t)
+	importer := NewPlaylistImporter(env.queries, env.streamrip)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	env.streamrip.searches.set(searchKey("qobuz", "track", "Blondie Heart of Glass"), []model.StreamripSearchResult{qobuzResult("q2", heartOfGlass)})
+	ctx := context.Background()
+
+	one := int64(1)
+	if _, err := env.streamrip.SetUserQuota(ctx, "bob", model.QuotaUpdate{DailyDownloads: &one}); err != nil {
+		t.Fatal(err)
+	}
+	env.wait(env.ensure("bob", "q1", callMe, model.QualityCD).ID)
+
+	report := env.importPlaylist(importer, "bob", "blondie.csv", "title,artist,isrc\nHeart of Glass,Blondie,"+heartOfGlass.ISRC+"\n")
+	entry := report.Entries[0]
+	if entry.Result != model.ImportFailed || entry.Error == nil || !strings.Contains(*entry.Error, "limit is 1") {
+		t.Fatalf("entry = %s with error %v, want failed over the quota", entry.Result, entry.Error)
+	}
+	if len(entry.Candidates) != 1 || entry.Candidates[0].TrackID != "q2" {
+		t.Fatalf("candidates = %+v, want the track to retry", entry.Candidates)
+	}
+
+	// Retrying keeps the cause, so that the API answers 429
+	_, err := importer.ResolveEntry(ctx, report.ID, entry.ID, "q2")
+	if !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Fatalf("retry over the quota: %v, want %v", err, model.ErrQuotaExceeded)
+	}
+
+	zero := int64(0)
+	if _, err := env.streamrip.SetUserQuota(ctx, "bob", model.QuotaUpdate{DailyDownloads: &zero}); err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := importer.ResolveEntry(ctx, report.ID, entry.ID, "q2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Result != model.ImportQueued || resolved.DownloadID == nil || resolved.Error != nil {
+		t.Fatalf("retried entry = %+v, want queued without error", resolved)
+	}
+	env.wait(*resolved.DownloadID)
+	if !env.isLinked("bob", heartOfGlass.ISRC) {
+		t.Error("the retried song is not in bob's library")
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// A song as read from a playlist file, before matching it
+type playlistEntry struct {
+	Title  string
+	Artist string
+	Album  string
+	ISRC   string
+}
+
+type parsedPlaylist struct {
+	// Only some formats carry the name of the playlist
+	Name    string
+	Entries []playlistEntry
+}
+
+// playlistFormat maps the extension of an uploaded file to its format.
+func playlistFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u", ".m3u8":
+		return "m3u", nil
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("%w: unsupported file type %q", model.ErrInvalidPlaylist, filepath.Ext(filename))
+	}
+}
+
+func parsePlaylist(format string, r io.Reader) (parsedPlaylist, error) {
+	var (
+		playlist parsedPlaylist
+		err      error
+	)
+	switch format {
+	case "m3u":
+		playlist, err = parseM3U(r)
+	case "csv":
+		playlist, err = parseCSV(r)
+	case "json":
+		playlist, err = parseSpotifyJSON(r)
+	default:
+		return parsedPlaylist{}, fmt.Errorf("%w: unknown format %q", model.ErrInvalidPlaylist, format)
+	}
+	if err != nil {
+		return parsedPlaylist{}, fmt.Errorf("%w: %v", model.ErrInvalidPlaylist, err)
+	}
+	if len(playlist.Entries) == 0 {
+		return parsedPlaylist{}, fmt.Errorf("%w: the playlist has no songs", model.ErrInvalidPlaylist)
+	}
+	return playlist, nil
+}
+
+// Sancho names its files "04. Call Me - Blondie.flac"
+var sanchoFileNameRe = regexp.MustCompile(`^\d+\.\s+(.+?)\s+-\s+(.+)$`)
+
+// Other players usually use "01 - Blondie - Call Me.flac" or "Blondie - Call Me.flac"
+var trackNumberPrefixRe = regexp.MustCompile(`^\d+\s*[-.]\s*`)
+
+func parseM3U(r io.Reader) (parsedPlaylist, error) {
+	var playlist parsedPlaylist
+	var info playlistEntry
+	var hasInfo bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			playlist.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:<seconds>,<artist> - <title>
+			_, desc, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			info.Artist, info.Title = splitArtistTitle(desc)
+			hasInfo = info.Title != ""
+		case strings.HasPrefix(line, "#EXTALB:"):
+			info.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			// The location of the song, it closes the entry
+			entry := info
+			if !hasInfo {
+				entry.Artist, entry.Title = entryFromFileName(line)
+			}
+			if entry.Title != "" {
+				playlist.Entries = append(playlist.Entries, entry)
+			}
+			info, hasInfo = playlistEntry{}, false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return parsedPlaylist{}, fmt.Errorf("error reading m3u: %w", err)
+	}
+	return playlist, nil
+}
+
+func splitArtistTitle(desc string) (artist, title string) {
+	desc = strings.TrimSpace(desc)
+	if a, t, ok := strings.Cut(desc, " - "); ok {
+		return strings.TrimSpace(a), strings.TrimSpace(t)
+	}
+	return "", desc
+}
+
+func entryFromFileName(location string) (artist, title string) {
+	// Locations can be Windows paths or URLs as well
+	location = strings.ReplaceAll(location, "\\", "/")
+	name := strings.TrimSuffix(filepath.Base(location), filepath.Ext(location))
+
+	if m := sanchoFileNameRe.FindStringSubmatch(name); m != nil {
+		return m[2], m[1]
+	}
+	return splitArtistTitle(trackNumberPrefixRe.ReplaceAllString(name, ""))
+}
+
+// Column names used by the usual CSV exports (Exportify, Soundiiz, TuneMyMusic)
+var csvColumnAliases = map[string]string{
+	"title":            "title",
+	"track":            "title",
+	"track name":       "title",
+	"song":             "title",
+	"name":             "title",
+	"artist":           "artist",
+	"artists":          "artist",
+	"artist name":      "artist",
+	"artist name(s)":   "artist",
+	"album":            "album",
+	"album name":       "album",
+	"isrc":             "isrc",
+	"track isrc":       "isrc",
+	"external id isrc": "isrc",
+}
+
+func parseCSV(r io.Reader) (parsedPlaylist, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return parsedPlaylist{}, fmt.Errorf("error reading csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumnAliases[name]; ok {
+			if _, seen := columns[field]; !seen {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return parsedPlaylist{}, fmt.Errorf("the csv has no title column")
+	}
+
+	get := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var playlist parsedPlaylist
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parsedPlaylist{}, fmt.Errorf("error reading csv: %w", err)
+		}
+		entry := playlistEntry{
+			Title:  get(record, "title"),
+			Artist: get(record, "artist"),
+			Album:  get(record, "album"),
+			ISRC:   strings.ToUpper(get(record, "isrc")),
+		}
+		if entry.Title != "" {
+			playlist.Entries = append(playlist.Entries, entry)
+		}
+	}
+	return playlist, nil
+}
+
+// A track as found in the Spotify Web API and in the Spotify account
+// data export, which uses different field names.
+type spotifyTrack struct {
+	Name    string `json:"name"`
+	Artists []struct {
+		Name string `json:"name"`
+	} `json:"artists"`
+	Album struct {
+		Name string `json:"name"`
+	} `json:"album"`
+	ExternalIDs struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
+
+	TrackName  string `json:"trackName"`
+	ArtistName string `json:"artistName"`
+	AlbumName  string `json:"albumName"`
+}
+
+type spotifyItem struct {
+	Track *spotifyTrack `json:"track"`
+}
+
+// parseSpotifyJSON reads a playlist from the Web API (a playlist object or
+// its tracks page), from the account data export (only its first
+// playlist) or a bare list of items or tracks.
+func parseSpotifyJSON(r io.Reader) (parsedPlaylist, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return parsedPlaylist{}, fmt.Errorf("error reading json: %w", err)
+	}
+
+	var playlist parsedPlaylist
+	var items []json.RawMessage
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return parsedPlaylist{}, fmt.Errorf("error reading json: %w", err)
+		}
+	} else {
+		var doc struct {
+			Name   string            `json:"name"`
+			Items  []json.RawMessage `json:"items"`
+			Tracks struct {
+				Items []json.RawMessage `json:"items"`
+			} `json:"tracks"`
+			Playlists []struct {
+				Name  string            `json:"name"`
+				Items []json.RawMessage `json:"items"`
+			} `json:"playlists"`
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return parsedPlaylist{}, fmt.Errorf("error reading json: %w", err)
+		}
+		playlist.Name = doc.Name
+		switch {
+		case len(doc.Items) > 0:
+			items = doc.Items
+		case len(doc.Tracks.Items) > 0:
+			items = doc.Tracks.Items
+		case len(doc.Playlists) > 0:
+			playlist.Name = doc.Playlists[0].Name
+			items = doc.Playlists[0].Items
+		}
+	}
+
+	for _, item := range items {
+		var wrapped spotifyItem
+		if err := json.Unmarshal(item, &wrapped); err != nil {
+			return parsedPlaylist{}, fmt.Errorf("error reading json item: %w", err)
+		}
+		track := wrapped.Track
+		if track == nil {
+			track = &spotifyTrack{}
+			if err := json.Unmarshal(item, track); err != nil {
+				return parsedPlaylist{}, fmt.Errorf("error reading json item: %w", err)
+			}
+		}
+
+		entry := playlistEntry{
+			Title:  track.Name,
+			Artist: track.ArtistName,
+			Album:  track.Album.Name,
+			ISRC:   strings.ToUpper(track.ExternalIDs.ISRC),
+		}
+		if entry.Title == "" {
+			entry.Title = track.TrackName
+		}
+		if entry.Album == "" {
+			entry.Album = track.AlbumName
+		}
+		if entry.Artist == "" && len(track.Artists) > 0 {
+			entry.Artist = track.Artists[0].Name
+		}
+		if entry.Title != "" {
+			playlist.Entries = append(playlist.Entries, entry)
+		}
+	}
+	return playlist, nil
+}
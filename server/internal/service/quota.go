@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// UserQuota returns the limits of the user and how much of them they used.
+func (s *Streamrip) UserQuota(ctx context.Context, user string) (model.UserQuota, error) {
+	ctx = context.Background()
+	userData, err := s.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.UserQuota{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	quota := model.UserQuota{
+		User: user,
+		Limits: model.Quota{
+			DailyDownloads:  config.QuotaDailyDownloads,
+			WeeklyDownloads: config.QuotaWeeklyDownloads,
+			StorageBytes:    config.QuotaStorageBytes,
+		},
+	}
+
+	custom, err := s.queries.GetUserQuota(ctx, userData.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return model.UserQuota{}, fmt.Errorf("error getting the quota of %s: %w", user, err)
+	}
+	quota.Custom = model.QuotaUpdate{
+		DailyDownloads:  toInt64Ptr(custom.DailyDownloads),
+		WeeklyDownloads: toInt64Ptr(custom.WeeklyDownloads),
+		StorageBytes:    toInt64Ptr(custom.StorageBytes),
+	}
+	if quota.Custom.DailyDownloads != nil {
+		quota.Limits.DailyDownloads = *quota.Custom.DailyDownloads
+	}
+	if quota.Custom.WeeklyDownloads != nil {
+		quota.Limits.WeeklyDownloads = *quota.Custom.WeeklyDownloads
+	}
+	if quota.Custom.StorageBytes != nil {
+		quota.Limits.StorageBytes = *quota.Custom.StorageBytes
+	}
+
+	userID := sql.NullInt64{Int64: userData.ID, Valid: true}
+	now := time.Now().UTC()
+	if quota.Used.DailyDownloads, err = s.queries.CountUserDownloadsSince(ctx, db.CountUserDownloadsSinceParams{UserID: userID, Since: now.Add(-24 * time.Hour)}); err != nil {
+		return model.UserQuota{}, fmt.Errorf("error counting the downloads of %s: %w", user, err)
+	}
+	if quota.Used.WeeklyDownloads, err = s.queries.CountUserDownloadsSince(ctx, db.CountUserDownloadsSinceParams{UserID: userID, Since: now.AddDate(0, 0, -7)}); err != nil {
+		return model.UserQuota{}, fmt.Errorf("error counting the downloads of %s: %w", user, err)
+	}
+	if quota.Used.StorageBytes, err = s.queries.GetUserStorageUsed(ctx, userID); err != nil {
+		return model.UserQuota{}, fmt.Errorf("error adding up the storage of %s: %w", user, err)
+	}
+	return quota, nil
+}
+
+// SetUserQuota replaces the limits set for the user, nil ones go back to
+// the server's defaults.
+func (s *Streamrip) SetUserQuota(ctx context.Context, user string, update model.QuotaUpdate) (model.UserQuota, error) {
+	ctx = context.Background()
+	for _, limit := range []*int64{update.DailyDownloads, update.WeeklyDownloads, update.StorageBytes} {
+		if limit != nil && *limit < 0 {
+			return model.UserQuota{}, fmt.Errorf("%w: limits can't be negative, 0 means no limit", model.ErrInvalidQuota)
+		}
+	}
+	userData, err := s.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.UserQuota{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	_, err = s.queries.UpsertUserQuota(ctx, db.UpsertUserQuotaParams{
+		UserID:          userData.ID,
+		DailyDownloads:  toNullInt64(update.DailyDownloads),
+		WeeklyDownloads: toNullInt64(update.WeeklyDownloads),
+		StorageBytes:    toNullInt64(update.StorageBytes),
+	})
+	if err != nil {
+		return model.UserQuota{}, fmt.Errorf("error saving the quota of %s: %w", user, err)
+	}
+	log.Printf("Quota of %s changed", user)
+	return s.UserQuota(ctx, user)
+}
+
+// checkQuota fails with ErrQuotaExceeded when the user can't start another
+// download.
+func (s *Streamrip) checkQuota(ctx context.Context, user string) error {
+	quota, err := s.UserQuota(ctx, user)
+	if err != nil {
+		return err
+	}
+	limits, used := quota.Limits, quota.Used
+	switch {
+	case limits.DailyDownloads > 0 && used.DailyDownloads >= limits.DailyDownloads:
+		return fmt.Errorf("%w: %s already started %d downloads in the last 24 hours, the limit is %d", model.ErrQuotaExceeded, user, used.DailyDownloads, limits.DailyDownloads)
+	case limits.WeeklyDownloads > 0 && used.WeeklyDownloads >= limits.WeeklyDownloads:
+		return fmt.Errorf("%w: %s already started %d downloads in the last 7 days, the limit is %d", model.ErrQuotaExceeded, user, used.WeeklyDownloads, limits.WeeklyDownloads)
+	case limits.StorageBytes > 0 && used.StorageBytes >= limits.StorageBytes:
+		return fmt.Errorf("%w: %s already brought %s to the library, the limit is %s", model.ErrQuotaExceeded, user, formatBytes(used.StorageBytes), formatBytes(limits.StorageBytes))
+	}
+	return nil
+}
+
+// startWithinQuota runs start, which records the download, only when the
+// user has quota left. Checks of the same user wait for each other, so
+// downloads started at once can't all pass before any of them is recorded.
+func (s *Streamrip) startWithinQuota(ctx context.Context, user string, start func() error) error {
+	unlock := s.lockQuota(user)
+	defer unlock()
+	if err := s.checkQuota(ctx, user); err != nil {
+		return err
+	}
+	return start()
+}
+
+// lockQuota takes the quota lock of the user and returns its unlock.
+func (s *Streamrip) lockQuota(user string) func() {
+	s.quotaMu.Lock()
+	lock, ok := s.quotaLocks[user]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.quotaLocks[user] = lock
+	}
+	s.quotaMu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+func toInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+func toNullInt64(n *int64) sql.NullInt64 {
+	if n == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *n, Valid: true}
+}
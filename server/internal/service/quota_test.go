@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+var heartOfGlass = FakeTrack{
+	Title:       "Heart of Glass",
+	Artist:      "Blondie",
+	Album:       "Parallel Lines",
+	ISRC:        "USCH37900013",
+	TrackNumber: 3,
+	BitDepth:    24,
+	SampleRate:  96000,
+}
+
+func TestEnsureTrackForUserEnforcesTheDownloadQuota(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.QuotaWeeklyDownloads, 5)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}})
+	ctx := context.Background()
+
+	one := int64(1)
+	if _, err := env.streamrip.SetUserQuota(ctx, "bob", model.QuotaUpdate{DailyDownloads: &one}); err != nil {
+		t.Fatal(err)
+	}
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	env.wait(env.ensure("bob", "q2", heartOfGlass, model.QualityHiRes).ID)
+
+	// Songs in the library are only linked, they don't count
+	if result := env.ensure("bob", "q1", callMe, model.QualityHiRes); result.Action != model.ActionLinked {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionLinked)
+	}
+	quota, err := env.streamrip.UserQuota(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Used.DailyDownloads != 1 || quota.Limits.DailyDownloads != 1 || quota.Limits.WeeklyDownloads != 5 {
+		t.Errorf("quota = %+v, want 1 of 1 daily downloads and 5 weekly", quota)
+	}
+	if quota.Used.StorageBytes <= 0 {
+		t.Errorf("bob brought %d bytes, want the size of the song", quota.Used.StorageBytes)
+	}
+
+	other := callMe
+	other.ISRC = "USCH37900014"
+	env.add(model.SourceQobuz, "q3", FakeRelease{Tracks: []FakeTrack{other}})
+	_, err = env.streamrip.EnsureTrackForUser(ctx, model.SourceQobuz, "", "q3", "bob", other.ISRC, model.QualityHiRes)
+	if !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Fatalf("over the quota: %v, want %v", err, model.ErrQuotaExceeded)
+	}
+
+	// Alice's storage is all the song alice brought, bob only linked it
+	aliceQuota, err := env.streamrip.UserQuota(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := aliceQuota.Used.StorageBytes
+	if _, err := env.streamrip.SetUserQuota(ctx, "alice", model.QuotaUpdate{StorageBytes: &limit}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.streamrip.EnsureTrackForUser(ctx, model.SourceQobuz, "", "q3", "alice", other.ISRC, model.QualityHiRes)
+	if !errors.Is(err, model.ErrQuotaExceeded) {
+		t.Fatalf("over the storage quota: %v, want %v", err, model.ErrQuotaExceeded)
+	}
+}
+
+// Downloads started at once count the ones before them, however close
+func TestConcurrentDownloadsDontExceedTheQuota(t *testing.T) {
+	// One worker, so that the songs of the same new artist are indexed in turn
+	setConfig(t, &config.DownloadWorkers, 1)
+	env := newTestEnv(t)
+	const n = 8
+	setConfig(t, &config.QuotaDailyDownloads, n-1)
+	tracks := make([]FakeTrack, n)
+	for i := range tracks {
+		tracks[i] = callMe
+		tracks[i].Title = fmt.Sprintf("Call Me (Take %d)", i)
+		tracks[i].ISRC = fmt.Sprintf("USCH379001%02d", i)
+		env.add(model.SourceQobuz, fmt.Sprintf("q%d", i), FakeRelease{Tracks: []FakeTrack{tracks[i]}})
+	}
+	ctx := context.Background()
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range tracks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = env.streamrip.EnsureTrackForUser(ctx, model.SourceQobuz, "", fmt.Sprintf("q%d", i), "bob", tracks[i].ISRC, model.QualityCD)
+		}()
+	}
+	wg.Wait()
+
+	exceeded := 0
+	for _, err := range errs {
+		switch {
+		case errors.Is(err, model.ErrQuotaExceeded):
+			exceeded++
+		case err != nil:
+			t.Errorf("download failed: %v", err)
+		}
+	}
+	if exceeded != 1 {
+		t.Errorf("%d downloads over the quota, want 1", exceeded)
+	}
+	env.waitIdle()
+	quota, err := env.streamrip.UserQuota(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quota.Used.DailyDownloads != n-1 {
+		t.Errorf("bob started %d downloads, want %d", quota.Used.DailyDownloads, n-1)
+	}
+}
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// Deezer's error code for an ID it doesn't know
+const deezerNotFoundErrorCode = 800
+
+// No artist has more releases than this many pages of the Deezer API
+const maxDeezerAlbumPages = 20
+
+type deezerArtistResponse struct {
+	ID    int64           `json:"id"`
+	Name  string          `json:"name"`
+	Error *deezerAPIError `json:"error"`
+}
+
+type deezerAlbum struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	CoverMedium string `json:"cover_medium"`
+	ReleaseDate string `json:"release_date"`
+	RecordType  string `json:"record_type"`
+}
+
+type deezerAlbumsResponse struct {
+	Data  []deezerAlbum   `json:"data"`
+	Next  string          `json:"next"`
+	Error *deezerAPIError `json:"error"`
+}
+
+// Deezer has no record type for live albums, their titles tell them apart
+var liveTitleRe = regexp.MustCompile(`(?i)[(\[-]\s*live\b|\blive (at|in|from|on)\b|\bunplugged\b`)
+
+// ReleaseWatcher keeps track of the artists users follow. Every
+// config.ReleaseCheckInterval it looks for their releases in Deezer and
+// records the ones that came out since the user followed the artist, and
+// queues their download when the user asked for it.
+type ReleaseWatcher struct {
+	queries   *db.Queries
+	streamrip *Streamrip
+	// Only one check runs at a time
+	checking sync.Mutex
+}
+
+func NewReleaseWatcher(queries *db.Queries, streamrip *Streamrip) *ReleaseWatcher {
+	return &ReleaseWatcher{
+		queries:   queries,
+		streamrip: streamrip,
+	}
+}
+
+// Start checks for new releases in the background, the first time right
+// away.
+func (w *ReleaseWatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(config.ReleaseCheckInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := w.CheckReleases(context.Background()); err != nil {
+				log.Printf("Error checking for new releases: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Follow makes the user follow the artist with the Deezer ID, or changes
+// the options of an artist they already follow. No release types means
+// the default ones.
+func (w *ReleaseWatcher) Follow(ctx context.Context, user, deezerID string, opts model.FollowOptions) (model.ArtistFollow, error) {
+	ctx = context.Background()
+	if _, err := strconv.ParseInt(deezerID, 10, 64); err != nil {
+		return model.ArtistFollow{}, fmt.Errorf("%w: invalid Deezer ID %q", model.ErrArtistNotFound, deezerID)
+	}
+	if opts.Quality < model.QualityLossy || opts.Quality > model.QualityHiResMax {
+		return model.ArtistFollow{}, fmt.Errorf("%w: %d", model.ErrInvalidQuality, opts.Quality)
+	}
+	types := opts.ReleaseTypes
+	if len(types) == 0 {
+		types = model.DefaultReleaseTypes
+	}
+	var names []string
+	seen := make(map[model.ReleaseType]bool)
+	for _, t := range types {
+		t, err := model.ParseReleaseType(string(t))
+		if err != nil {
+			return model.ArtistFollow{}, err
+		}
+		if !seen[t] {
+			seen[t] = true
+			names = append(names, string(t))
+		}
+	}
+
+	userData, err := w.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return model.ArtistFollow{}, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	name, err := artistName(ctx, w.queries, deezerID)
+	if err != nil {
+		return model.ArtistFollow{}, err
+	}
+
+	follow, err := w.queries.UpsertArtistFollow(ctx, db.UpsertArtistFollowParams{
+		UserID:         userData.ID,
+		DeezerArtistID: deezerID,
+		ArtistName:     name,
+		ReleaseTypes:   strings.Join(names, ","),
+		AutoDownload:   opts.AutoDownload,
+		Quality:        int64(opts.Quality),
+	})
+	if err != nil {
+		return model.ArtistFollow{}, fmt.Errorf("error saving the follow: %w", err)
+	}
+	log.Printf("User %s follows %s (Deezer ID: %s)", user, name, deezerID)
+	return model.ArtistFollowFromDB(follow, user), nil
+}
+
+// artistName takes the name from the library when the artist is there and
+// asks Deezer otherwise.
+func artistName(ctx context.Context, queries *db.Queries, deezerID string) (string, error) {
+	artist, err := queries.GetArtistByDeezerID(ctx, sql.NullString{String: deezerID, Valid: true})
+	if err == nil {
+		return artist.Name, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("error searching for the artist in the DB: %w", err)
+	}
+
+	var result deezerArtistResponse
+	if err := getDeezer(fmt.Sprintf("%s/artist/%s", config.DeezerAPIURL, deezerID), &result); err != nil {
+		return "", err
+	}
+	if result.Error != nil && result.Error.Code == deezerNotFoundErrorCode || result.Error == nil && result.ID == 0 {
+		return "", fmt.Errorf("%w: deezer has no artist with the ID %s", model.ErrArtistNotFound, deezerID)
+	}
+	if result.Error != nil {
+		return "", result.Error.asError()
+	}
+	return result.Name, nil
+}
+
+func (w *ReleaseWatcher) Unfollow(ctx context.Context, user, deezerID string) error {
+	ctx = context.Background()
+	userData, err := w.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	n, err := w.queries.DeleteArtistFollow(ctx, db.DeleteArtistFollowParams{UserID: userData.ID, DeezerArtistID: deezerID})
+	if err != nil {
+		return fmt.Errorf("error removing the follow: %w", err)
+	}
+	if n == 0 {
+		return model.ErrFollowNotFound
+	}
+	return nil
+}
+
+func (w *ReleaseWatcher) ListFollows(ctx context.Context, user string) ([]model.ArtistFollow, error) {
+	ctx = context.Background()
+	userData, err := w.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	rows, err := w.queries.ListUserArtistFollows(ctx, userData.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the followed artists: %w", err)
+	}
+	follows := make([]model.ArtistFollow, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, model.ArtistFollowFromDB(row, user))
+	}
+	return follows, nil
+}
+
+// ListNotifications returns the new releases of the artists the user
+// follows, newest first.
+func (w *ReleaseWatcher) ListNotifications(ctx context.Context, user string, unreadOnly bool) ([]model.ReleaseNotification, error) {
+	ctx = context.Background()
+	userData, err := w.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	rows, err := w.queries.ListReleaseNotifications(ctx, db.ListReleaseNotificationsParams{UserID: userData.ID, UnreadOnly: unreadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the release notifications: %w", err)
+	}
+	notifications := make([]model.ReleaseNotification, 0, len(rows))
+	for _, row := range rows {
+		notifications = append(notifications, model.ReleaseNotificationFromDB(row.ReleaseNotification, row.DeezerArtistID, row.ArtistName))
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks as read the notification with the ID, or
+// every notification of the user when id is 0, and returns how many
+// were unread.
+func (w *ReleaseWatcher) MarkNotificationsRead(ctx context.Context, user string, id int64) (int64, error) {
+	ctx = context.Background()
+	userData, err := w.queries.GetUserByUsername(ctx, user)
+	if err != nil {
+		return 0, fmt.Errorf("could not find the user %s: %w", user, err)
+	}
+	n, err := w.queries.MarkReleaseNotificationsRead(ctx, db.MarkReleaseNotificationsReadParams{
+		UserID: userData.ID,
+		ID:     sql.NullInt64{Int64: id, Valid: id > 0},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error marking the notifications as read: %w", err)
+	}
+	return n, nil
+}
+
+// CheckReleases looks for new releases of every followed artist and
+// returns how many notifications it added. Each artist is asked for once,
+// whatever the number of users following it.
+func (w *ReleaseWatcher) CheckReleases(ctx context.Context) (int, error) {
+	ctx = context.Background()
+	w.checking.Lock()
+	defer w.checking.Unlock()
+
+	rows, err := w.queries.ListArtistFollows(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing the followed artists: %w", err)
+	}
+
+	added := 0
+	// Rows come sorted by artist
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && rows[end].ArtistFollow.DeezerArtistID == rows[start].ArtistFollow.DeezerArtistID {
+			end++
+		}
+		follows := rows[start:end]
+		start = end
+
+		artistID := follows[0].ArtistFollow.DeezerArtistID
+		albums, err := getDeezerArtistAlbums(artistID)
+		if err != nil {
+			log.Printf("Could not get the releases of %s (Deezer ID: %s): %v", follows[0].ArtistFollow.ArtistName, artistID, err)
+			continue
+		}
+		for _, follow := range follows {
+			added += w.notify(ctx, follow.ArtistFollow, follow.Username, albums)
+			if err := w.queries.UpdateArtistFollowChecked(ctx, follow.ArtistFollow.ID); err != nil {
+				log.Printf("Error saving the check of follow %d: %v", follow.ArtistFollow.ID, err)
+			}
+		}
+	}
+	if added > 0 {
+		log.Printf("Found %d new releases of the followed artists", added)
+	}
+	return added, nil
+}
+
+// notify records the albums released since the user followed the artist
+// that are of the types they want, and queues their download if they
+// asked for it. It returns how many were new.
+func (w *ReleaseWatcher) notify(ctx context.Context, follow db.ArtistFollow, user string, albums []deezerAlbum) int {
+	wanted := make(map[model.ReleaseType]bool)
+	for _, t := range strings.Split(follow.ReleaseTypes, ",") {
+		wanted[model.ReleaseType(t)] = true
+	}
+	// Deezer only has the day of the release
+	since := follow.CreatedAt.UTC().Format(time.DateOnly)
+
+	added := 0
+	for _, album := range albums {
+		releaseType := classifyRelease(album)
+		if !wanted[releaseType] {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, album.ReleaseDate); err != nil || album.ReleaseDate < since {
+			continue
+		}
+
+		notification, err := w.queries.InsertReleaseNotification(ctx, db.InsertReleaseNotificationParams{
+			FollowID:      follow.ID,
+			DeezerAlbumID: strconv.FormatInt(album.ID, 10),
+			Title:         album.Title,
+			ReleaseType:   string(releaseType),
+			ReleaseDate:   sql.NullString{String: album.ReleaseDate, Valid: true},
+			CoverUrl:      sql.NullString{String: album.CoverMedium, Valid: album.CoverMedium != ""},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Notified in a previous check
+			continue
+		}
+		if err != nil {
+			log.Printf("Error saving the release %d of %s for %s: %v", album.ID, follow.ArtistName, user, err)
+			continue
+		}
+		added++
+		log.Printf("New %s of %s for %s: %s", releaseType, follow.ArtistName, user, album.Title)
+
+		if follow.AutoDownload {
+			w.download(ctx, notification, user, model.Quality(follow.Quality))
+		}
+	}
+	return added
+}
+
+// download queues the release from Deezer, where it was found, in the
+// best quality Deezer has up to the one the user wants.
+func (w *ReleaseWatcher) download(ctx context.Context, notification db.ReleaseNotification, user string, quality model.Quality) {
+	lowest, highest := model.SourceDeezer.QualityRange()
+	quality = max(lowest, min(quality, highest))
+
+	result, err := w.streamrip.EnsureAlbumForUser(ctx, model.SourceDeezer, notification.DeezerAlbumID, user, quality)
+	if err != nil {
+		log.Printf("Could not queue the download of %s for %s: %v", notification.Title, user, err)
+		return
+	}
+	if result.Action == model.ActionPending {
+		log.Printf("The download of %s for %s waits for approval, request %s", notification.Title, user, result.ID)
+		return
+	}
+	params := db.SetReleaseNotificationDownloadParams{
+		DownloadID: sql.NullString{String: result.ID, Valid: true},
+		ID:         notification.ID,
+	}
+	if err := w.queries.SetReleaseNotificationDownload(ctx, params); err != nil {
+		log.Printf("Error saving the download of release notification %d: %v", notification.ID, err)
+	}
+}
+
+func classifyRelease(album deezerAlbum) model.ReleaseType {
+	switch strings.ToLower(album.RecordType) {
+	case "single":
+		return model.ReleaseSingle
+	case "ep":
+		return model.ReleaseEP
+	case "compile", "compilation":
+		return model.ReleaseCompilation
+	}
+	if liveTitleRe.MatchString(album.Title) {
+		return model.ReleaseLive
+	}
+	return model.ReleaseAlbum
+}
+
+// getDeezerArtistAlbums returns every release of the artist, following
+// the pages of the Deezer API.
+func getDeezerArtistAlbums(deezerID string) ([]deezerAlbum, error) {
+	var albums []deezerAlbum
+	url := fmt.Sprintf("%s/artist/%s/albums?limit=100", config.DeezerAPIURL, deezerID)
+	for page := 0; url != "" && page < maxDeezerAlbumPages; page++ {
+		var result deezerAlbumsResponse
+		if err := getDeezer(url, &result); err != nil {
+			return nil, err
+		}
+		if result.Error != nil {
+			return nil, result.Error.asError()
+		}
+		albums = append(albums, result.Data...)
+		url = result.Next
+	}
+	return albums, nil
+}
+
+// getDeezer decodes the response of the Deezer API into result. Errors
+// reported in the body are left to the caller.
+func getDeezer(url string, result any) error {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("error making request to Deezer: %w", temporary(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return deezerStatusError(resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding deezer response: %w", err)
+	}
+	return nil
+}
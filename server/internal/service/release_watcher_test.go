@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestCheckReleasesNotifiesNewReleases(t *testing.T) {
+	env := newTestEnv(t)
+	watcher := NewReleaseWatcher(env.queries, env.streamrip)
+	today := time.Now().UTC().Format(time.DateOnly)
+	env.deezer.AddArtist("1000", "Blondie",
+		deezerAlbum{ID: 1, Title: "Parallel Lines", ReleaseDate: "1978-09-23", RecordType: "album"},
+		deezerAlbum{ID: 2, Title: "Pollinator", ReleaseDate: today, RecordType: "album"},
+		deezerAlbum{ID: 3, Title: "Fun", ReleaseDate: today, RecordType: "single"},
+		deezerAlbum{ID: 4, Title: "Pollinator (Live at the Roundhouse)", ReleaseDate: today, RecordType: "album"},
+	)
+
+	ctx := context.Background()
+	_, err := watcher.Follow(ctx, "alice", "1000", model.FollowOptions{
+		ReleaseTypes: []model.ReleaseType{model.ReleaseAlbum, model.ReleaseLive},
+		AutoDownload: true,
+		Quality:      model.QualityHiResMax,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.Follow(ctx, "bob", "1000", model.FollowOptions{ReleaseTypes: []model.ReleaseType{model.ReleaseSingle}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := watcher.Follow(ctx, "bob", "404", model.FollowOptions{}); err == nil {
+		t.Error("followed an artist Deezer doesn't know")
+	}
+
+	for range 2 {
+		if _, err := watcher.CheckReleases(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifications, err := watcher.ListNotifications(ctx, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("alice got %d notifications, want the new album and the live one: %+v", len(notifications), notifications)
+	}
+	for _, n := range notifications {
+		if n.DownloadID == nil {
+			t.Errorf("%s was not downloaded", n.Title)
+			continue
+		}
+		job, err := env.streamrip.GetDownloadStatus(*n.DownloadID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, highest := model.SourceDeezer.QualityRange(); job.RequestedQuality == nil || *job.RequestedQuality != highest {
+			t.Errorf("%s requested in %v, want the best Deezer has", n.Title, job.RequestedQuality)
+		}
+	}
+
+	notifications, err = watcher.ListNotifications(ctx, "bob", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].Type != model.ReleaseSingle || notifications[0].DownloadID != nil {
+		t.Fatalf("bob got %+v, want the single without downloading it", notifications)
+	}
+	if n, err := watcher.MarkNotificationsRead(ctx, "bob", 0); err != nil || n != 1 {
+		t.Fatalf("marked %d as read (%v), want 1", n, err)
+	}
+	if notifications, _ := watcher.ListNotifications(ctx, "bob", true); len(notifications) != 0 {
+		t.Errorf("bob still has %d unread notifications", len(notifications))
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+)
+
+// Searches kept at most, the expired ones go first when it is full
+const maxCachedSearches = 500
+
+// searchCache keeps the results of the recent searches, each rip search
+// being a process that takes seconds.
+type searchCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSearch
+}
+
+type cachedSearch struct {
+	results []model.StreamripSearchResult
+	expires time.Time
+}
+
+func newSearchCache() *searchCache {
+	return &searchCache{entries: make(map[string]cachedSearch)}
+}
+
+// searchKey ignores the case and spacing of the query.
+func searchKey(source, mediaType, query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return source + "|" + mediaType + "|" + query
+}
+
+func (c *searchCache) get(key string) ([]model.StreamripSearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return slices.Clone(entry.results), true
+}
+
+func (c *searchCache) set(key string, results []model.StreamripSearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCachedSearches {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// Still full of live searches, any of them makes room
+	for k := range c.entries {
+		if len(c.entries) < maxCachedSearches {
+			break
+		}
+		delete(c.entries, k)
+	}
+	c.entries[key] = cachedSearch{results: slices.Clone(results), expires: now.Add(config.SearchCacheTTL)}
+}
+
+// A song of the library and whether a user has it
+type libraryTrack struct {
+	Track db.Track
+	Owned bool
+}
+
+// libraryTracksByISRC finds the songs of the library with the ISRCs, by
+// their ISRC in upper case. user may be empty.
+func (s *Streamrip) libraryTracksByISRC(ctx context.Context, user string, isrcs []string) (map[string]libraryTrack, error) {
+	tracks := make(map[string]libraryTrack)
+	if len(isrcs) == 0 {
+		return tracks, nil
+	}
+	encoded, err := json.Marshal(isrcs)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the ISRCs: %w", err)
+	}
+	rows, err := s.queries.ListTracksByISRCs(ctx, db.ListTracksByISRCsParams{Isrcs: string(encoded), Username: user})
+	if err != nil {
+		return nil, fmt.Errorf("error searching for the tracks in the library: %w", err)
+	}
+	for _, row := range rows {
+		isrc := strings.ToUpper(row.Track.Isrc.String)
+		// The user's copy wins when the library has more than one
+		if found, ok := tracks[isrc]; ok && found.Owned {
+			continue
+		}
+		tracks[isrc] = libraryTrack{Track: row.Track, Owned: row.Owned != 0}
+	}
+	return tracks, nil
+}
+
+// AnnotateTrackPreviews tells of each search result whether the library
+// has the song, in which quality, and whether the user has it. user may
+// be empty.
+func (s *Streamrip) AnnotateTrackPreviews(ctx context.Context, user string, previews []model.TrackPreview) error {
+	ctx = context.Background()
+	var isrcs []string
+	for _, preview := range previews {
+		if preview.ISRC != "" {
+			isrcs = append(isrcs, preview.ISRC)
+		}
+	}
+	tracks, err := s.libraryTracksByISRC(ctx, user, isrcs)
+	if err != nil {
+		return err
+	}
+
+	// A search often lists the same song more than once
+	qualities := make(map[int64]*model.Quality)
+	for i := range previews {
+		track, ok := tracks[strings.ToUpper(previews[i].ISRC)]
+		if !ok || previews[i].ISRC == "" {
+			continue
+		}
+		quality, read := qualities[track.Track.ID]
+		if !read {
+			quality = fileQuality(track.Track.FilePath)
+			qualities[track.Track.ID] = quality
+		}
+		previews[i].InLibrary = true
+		previews[i].Owned = track.Owned
+		previews[i].LibraryQuality = quality
+	}
+	return nil
+}
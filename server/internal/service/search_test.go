@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+func TestSearchResultsTellWhatTheLibraryHas(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	ctx := context.Background()
+
+	search := func(user string) []model.TrackPreview {
+		previews := []model.TrackPreview{
+			{TrackID: "q1", ISRC: callMe.ISRC},
+			{TrackID: "q2", ISRC: heartOfGlass.ISRC},
+			{TrackID: "t1", ISRC: callMe.ISRC},
+		}
+		if err := env.streamrip.AnnotateTrackPreviews(ctx, user, previews); err != nil {
+			t.Fatal(err)
+		}
+		return previews
+	}
+
+	for _, preview := range search("bob") {
+		if preview.InLibrary != (preview.ISRC == callMe.ISRC) || preview.Owned {
+			t.Errorf("bob's %s: in library %v, owned %v", preview.TrackID, preview.InLibrary, preview.Owned)
+		}
+	}
+	alice := search("alice")
+	if !alice[0].Owned || !alice[2].Owned || alice[1].Owned {
+		t.Errorf("alice owns %v, %v, %v, want only Call Me", alice[0].Owned, alice[1].Owned, alice[2].Owned)
+	}
+	if q := alice[0].LibraryQuality; q == nil || *q != model.QualityHiRes {
+		t.Errorf("library quality = %v, want hi-res", q)
+	}
+}
+
+func TestSearchCacheExpires(t *testing.T) {
+	setConfig(t, &config.SearchCacheTTL, 50*time.Millisecond)
+	cache := newSearchCache()
+	results := []model.StreamripSearchResult{{ID: "q1"}}
+	cache.set(searchKey("qobuz", "track", "Call  Me"), results)
+
+	if got, ok := cache.get(searchKey("qobuz", "track", "call me")); !ok || len(got) != 1 {
+		t.Errorf("same search = %v, %v, want the cached results", got, ok)
+	}
+	if _, ok := cache.get(searchKey("tidal", "track", "call me")); ok {
+		t.Error("another source got the cached results")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := cache.get(searchKey("qobuz", "track", "call me")); ok {
+		t.Error("expired search still cached")
+	}
+}
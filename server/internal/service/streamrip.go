@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,77 +18,370 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
 	model "github.com/alejandro-bustamante/sancho/server/internal/model"
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
 	"github.com/google/uuid"
+	tag "go.senan.xyz/taglib"
 )
 
-type deezerTrackSampleResponse struct {
-	Preview string `json:"preview"`
+// A track of the Deezer API. Unknown tracks come back with status 200
+// and the error set.
+type deezerTrackResponse struct {
+	ID      int64           `json:"id"`
+	Preview string          `json:"preview"`
+	Error   *deezerAPIError `json:"error"`
 }
 
-// Code to handle download's status
+// Code to handle download's status.
+// Every job is a row in download_history, written as soon as the job is
+// created and updated on each transition, so the status survives restarts
+// and the history and the live status are the same data.
+// Each transition is also published as an event for the clients, and
+// the outcome of each download is sent to the webhooks.
 type DownloadTracker struct {
-	sync.Mutex
-	status map[string]model.DownloadStatus
-	errs   map[string]string
+	queries  *db.Queries
+	events   *DownloadEventBus
+	webhooks *WebhookDispatcher
 }
 
-func NewDownloadTracker() *DownloadTracker {
+func NewDownloadTracker(queries *db.Queries, events *DownloadEventBus, webhooks *WebhookDispatcher) *DownloadTracker {
 	return &DownloadTracker{
-		status: make(map[string]model.DownloadStatus),
-		errs:   make(map[string]string),
+		queries:  queries,
+		events:   events,
+		webhooks: webhooks,
 	}
 }
 
+// Start registers a new job for the user with the given status.
+func (dt *DownloadTracker) Start(id string, spec downloadSpec, s model.DownloadStatus) error {
+	return dt.start(id, spec, s, false)
+}
+
+// StartLink registers the linking of a song of the library to the user,
+// which doesn't count as a download.
+func (dt *DownloadTracker) StartLink(id string, spec downloadSpec) error {
+	return dt.start(id, spec, model.StatusIndexing, true)
+}
+
+func (dt *DownloadTracker) start(id string, spec downloadSpec, s model.DownloadStatus, linked bool) error {
+	ctx := context.Background()
+	userData, err := dt.queries.GetUserByUsername(ctx, spec.User)
+	if err != nil {
+		return fmt.Errorf("could not find the user %s: %w", spec.User, err)
+	}
+
+	params := db.InsertDownloadHistoryParams{
+		ID:               id,
+		UserID:           sql.NullInt64{Int64: userData.ID, Valid: userData.ID > 0},
+		Status:           sql.NullString{String: string(s), Valid: s != ""},
+		Service:          sql.NullString{String: string(spec.Source), Valid: spec.Source != ""},
+		SourceTrackID:    sql.NullString{String: spec.SourceID, Valid: spec.SourceID != ""},
+		Isrc:             sql.NullString{String: spec.ISRC, Valid: spec.ISRC != ""},
+		MediaType:        string(spec.MediaType),
+		FallbackService:  sql.NullString{String: string(spec.Fallback), Valid: spec.Fallback != ""},
+		RequestedQuality: sql.NullInt64{Int64: int64(spec.Quality), Valid: true},
+		Upgrade:          spec.Upgrade,
+		Linked:           linked,
+	}
+	if _, err := dt.queries.InsertDownloadHistory(ctx, params); err != nil {
+		return fmt.Errorf("error saving download job: %w", err)
+	}
+	dt.publish(id)
+	return nil
+}
+
+// AddAlbumTrack records the outcome of one of the tracks of an album job.
+// The row is created already finished, tracks have no lifecycle of their own.
+func (dt *DownloadTracker) AddAlbumTrack(album *downloadJob, track albumTrack) {
+	ctx := context.Background()
+	userData, err := dt.queries.GetUserByUsername(ctx, album.User)
+	if err != nil {
+		log.Printf("Could not find the user %s: %v", album.User, err)
+		return
+	}
+
+	id := uuid.New().String()
+	params := db.InsertDownloadHistoryParams{
+		ID:           id,
+		UserID:       sql.NullInt64{Int64: userData.ID, Valid: userData.ID > 0},
+		TrackID:      sql.NullInt64{Int64: track.TrackID, Valid: track.TrackID > 0},
+		Quality:      qualityParam(track.Quality),
+		Status:       sql.NullString{String: string(track.Status), Valid: true},
+		Service:      sql.NullString{String: string(album.Source), Valid: true},
+		CompletedAt:  sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		ErrorMessage: sql.NullString{String: track.Error, Valid: track.Error != ""},
+		Isrc:         sql.NullString{String: track.ISRC, Valid: track.ISRC != ""},
+		MediaType:    string(model.MediaTrack),
+		ParentID:     sql.NullString{String: album.ID, Valid: true},
+		// The album's, tracks can't be requested on their own
+		RequestedQuality: sql.NullInt64{Int64: int64(album.Quality), Valid: true},
+	}
+	if _, err := dt.queries.InsertDownloadHistory(ctx, params); err != nil {
+		log.Printf("Error saving track of album download %s: %v", album.ID, err)
+		return
+	}
+	dt.publish(id)
+}
+
 func (dt *DownloadTracker) SetStatus(id string, s model.DownloadStatus) {
-	dt.Lock()
-	defer dt.Unlock()
-	dt.status[id] = s
+	params := db.UpdateDownloadStatusParams{
+		ID:     id,
+		Status: sql.NullString{String: string(s), Valid: true},
+	}
+	if err := dt.queries.UpdateDownloadStatus(context.Background(), params); err != nil {
+		log.Printf("Error updating status of download %s: %v", id, err)
+		return
+	}
+	dt.publish(id)
+}
+
+// SetSource records that the job is now downloading the track with the
+// given ID from another source.
+func (dt *DownloadTracker) SetSource(id string, source model.Source, sourceID string) {
+	params := db.UpdateDownloadSourceParams{
+		ID:            id,
+		Service:       sql.NullString{String: string(source), Valid: true},
+		SourceTrackID: sql.NullString{String: sourceID, Valid: sourceID != ""},
+	}
+	if err := dt.queries.UpdateDownloadSource(context.Background(), params); err != nil {
+		log.Printf("Error updating source of download %s: %v", id, err)
+		return
+	}
+	dt.publish(id)
+}
+
+// SetRetry puts the job back in the queued status until its next attempt,
+// keeping the error of the failed one.
+func (dt *DownloadTracker) SetRetry(id string, attempt int64, msg string, next time.Time) {
+	params := db.UpdateDownloadRetryParams{
+		ID:            id,
+		Attempts:      attempt,
+		ErrorMessage:  sql.NullString{String: msg, Valid: msg != ""},
+		NextAttemptAt: sql.NullTime{Time: next.UTC(), Valid: true},
+	}
+	if err := dt.queries.UpdateDownloadRetry(context.Background(), params); err != nil {
+		log.Printf("Error scheduling retry of download %s: %v", id, err)
+		return
+	}
+	dt.publish(id)
+}
+
+// Requeue puts a failed job back in the queue, as a new first attempt. An
+// album loses the tracks recorded by its last run. It returns false when
+// the job is not failed anymore.
+func (dt *DownloadTracker) Requeue(id string, mediaType model.MediaType) (bool, error) {
+	ctx := context.Background()
+	n, err := dt.queries.RequeueFailedDownload(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("error requeuing download %s: %w", id, err)
+	}
+	if n == 0 {
+		return false, nil
+	}
+	if mediaType == model.MediaAlbum {
+		if err := dt.queries.DeleteAlbumDownloadTracks(ctx, sql.NullString{String: id, Valid: true}); err != nil {
+			return false, fmt.Errorf("error removing the tracks of download %s: %w", id, err)
+		}
+	}
+	dt.publish(id)
+	return true, nil
 }
 
 func (dt *DownloadTracker) SetError(id string, msg string) {
-	dt.Lock()
-	defer dt.Unlock()
-	dt.errs[id] = msg
-	dt.status[id] = model.StatusFailed
+	dt.complete(id, model.StatusFailed, msg, 0, nil)
+}
+
+func (dt *DownloadTracker) SetCanceled(id string) {
+	dt.complete(id, model.StatusCanceled, "", 0, nil)
+}
+
+// SetSuccess records the track the job added to the library and the
+// quality of its file, nil when it couldn't be read.
+func (dt *DownloadTracker) SetSuccess(id string, trackID int64, quality *model.Quality) {
+	dt.complete(id, model.StatusSuccess, "", trackID, quality)
 }
 
-func (dt *DownloadTracker) Get(id string) (model.DownloadStatus, string) {
-	dt.Lock()
-	defer dt.Unlock()
-	return dt.status[id], dt.errs[id]
+// SetSkipped records that the job ended without changing the library,
+// msg tells why.
+func (dt *DownloadTracker) SetSkipped(id string, msg string, trackID int64, quality *model.Quality) {
+	dt.complete(id, model.StatusSkipped, msg, trackID, quality)
 }
 
-func (dt *DownloadTracker) Delete(id string) {
-	dt.Lock()
-	defer dt.Unlock()
-	delete(dt.status, id)
-	delete(dt.errs, id)
+func (dt *DownloadTracker) complete(id string, s model.DownloadStatus, msg string, trackID int64, quality *model.Quality) {
+	params := db.UpdateDownloadCompletionParams{
+		ID:           id,
+		Status:       sql.NullString{String: string(s), Valid: true},
+		ErrorMessage: sql.NullString{String: msg, Valid: msg != ""},
+		TrackID:      sql.NullInt64{Int64: trackID, Valid: trackID > 0},
+		Quality:      qualityParam(quality),
+	}
+	if err := dt.queries.UpdateDownloadCompletion(context.Background(), params); err != nil {
+		log.Printf("Error saving completion of download %s: %v", id, err)
+		return
+	}
+	dt.publish(id)
+	switch s {
+	case model.StatusSuccess:
+		dt.notifyWebhooks(id, model.WebhookDownloadSucceeded)
+	case model.StatusFailed:
+		dt.notifyWebhooks(id, model.WebhookDownloadFailed)
+	}
+}
+
+// notifyWebhooks sends the finished job to the webhooks. Songs that were
+// only linked are sent as track.linked instead, by the FileManager.
+func (dt *DownloadTracker) notifyWebhooks(id string, eventType model.WebhookEventType) {
+	job, err := dt.Get(id)
+	if err != nil {
+		log.Printf("Could not notify the webhooks of download %s: %v", id, err)
+		return
+	}
+	if job.Linked {
+		return
+	}
+	dt.webhooks.Emit(eventType, job)
+}
+
+func qualityParam(q *model.Quality) sql.NullInt64 {
+	if q == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*q), Valid: true}
+}
+
+// publish sends the stored state of the job, so the events always match
+// what a status request would return.
+func (dt *DownloadTracker) publish(id string) {
+	job, err := dt.Get(id)
+	if err != nil {
+		log.Printf("Could not publish the status of download %s: %v", id, err)
+		return
+	}
+	dt.events.Publish(model.DownloadEvent{
+		DownloadID: job.ID,
+		ParentID:   job.ParentID,
+		User:       job.User,
+		Type:       model.DownloadEventType(job.Status),
+		Status:     job.Status,
+		Error:      job.Error,
+	})
+}
+
+func (dt *DownloadTracker) Get(id string) (model.DownloadJob, error) {
+	row, err := dt.queries.GetDownloadJobByID(context.Background(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return model.DownloadJob{}, model.ErrDownloadNotFound
+		}
+		return model.DownloadJob{}, fmt.Errorf("error reading download %s: %w", id, err)
+	}
+	job := model.DownloadJobFromDB(row.DownloadHistory, row.Username)
+	if job.MediaType == model.MediaAlbum {
+		if job.Tracks, err = dt.albumTracks(id); err != nil {
+			return model.DownloadJob{}, err
+		}
+	}
+	return job, nil
+}
+
+func (dt *DownloadTracker) albumTracks(id string) ([]model.DownloadJob, error) {
+	rows, err := dt.queries.ListAlbumDownloadTracks(context.Background(), sql.NullString{String: id, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("error reading the tracks of download %s: %w", id, err)
+	}
+	tracks := make([]model.DownloadJob, 0, len(rows))
+	for _, row := range rows {
+		track := model.DownloadJobFromDB(row.DownloadHistory, row.Username)
+		track.Title = row.TrackTitle.String
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+// Active lists the queued and running jobs, optionally only those of one user.
+func (dt *DownloadTracker) Active(user string) ([]model.DownloadJob, error) {
+	rows, err := dt.queries.ListActiveDownloads(context.Background(), sql.NullString{String: user, Valid: user != ""})
+	if err != nil {
+		return nil, fmt.Errorf("error listing active downloads: %w", err)
+	}
+	jobs := make([]model.DownloadJob, 0, len(rows))
+	for _, row := range rows {
+		jobs = append(jobs, model.DownloadJobFromDB(row.DownloadHistory, row.Username))
+	}
+	return jobs, nil
 }
 
 // Type definition for the main service
 type Streamrip struct {
-	tracker     *DownloadTracker
+	tracker *DownloadTracker
+	events  *DownloadEventBus
+	queue   *DownloadQueue
+	// Queued and running jobs, by download ID
+	jobsMu sync.Mutex
+	jobs   map[string]*downloadJob
+	// Jobs downloading a track other jobs can attach to, by dedupKey
+	inFlight    map[string]*downloadJob
 	indexer     *Indexer
 	fileManager *FileManager
+	downloader  Downloader
 	queries     *db.Queries
+	searches    *searchCache
+	// One lock per user, held from the quota check to the insert of the
+	// download it lets through
+	quotaMu    sync.Mutex
+	quotaLocks map[string]*sync.Mutex
 }
 
-func NewStreamrip(indexer *Indexer, fileManager *FileManager, queries *db.Queries) *Streamrip {
-	return &Streamrip{
-		tracker:     NewDownloadTracker(),
+func NewStreamrip(indexer *Indexer, fileManager *FileManager, downloader Downloader, queries *db.Queries, webhooks *WebhookDispatcher) *Streamrip {
+	events := NewDownloadEventBus()
+	s := &Streamrip{
+		tracker:     NewDownloadTracker(queries, events, webhooks),
+		events:      events,
+		jobs:        make(map[string]*downloadJob),
+		inFlight:    make(map[string]*downloadJob),
 		indexer:     indexer,
 		fileManager: fileManager,
+		downloader:  downloader,
 		queries:     queries,
+		searches:    newSearchCache(),
+		quotaLocks:  make(map[string]*sync.Mutex),
 	}
+	s.queue = NewDownloadQueue(config.DownloadWorkers, s.runDownload)
+	return s
 }
 
-type streamripJSONOutput struct {
-	DownloadPath string `json:"downloadPath"`
+// EnsureTrackForUser links the track to the user, downloading it first from
+// source when the library doesn't have it. fallback, if not empty, is the
+// source tried when source can't download the track. When the library's
+// copy is worse than the requested quality, a better one is queued to
+// replace it. Downloads that need the approval of an admin are only
+// requested.
+func (s *Streamrip) EnsureTrackForUser(ctx context.Context, source, fallback model.Source, songID, user, isrc string, quality model.Quality) (*model.DownloadResult, error) {
+	if err := source.ValidateQuality(quality); err != nil {
+		return nil, err
+	}
+	if fallback != "" {
+		if _, err := model.ParseSource(string(fallback)); err != nil {
+			return nil, err
+		}
+	}
+	spec := downloadSpec{
+		MediaType: model.MediaTrack,
+		Source:    source,
+		Fallback:  fallback,
+		SourceID:  songID,
+		User:      user,
+		ISRC:      isrc,
+		Quality:   quality,
+	}
+	return s.ensureTrack(ctx, spec, false)
 }
 
-func (s *Streamrip) EnsureTrackForUser(ctx context.Context, songID, user, isrc string, quality int64) (*model.DownloadResult, error) {
+// ensureTrack does the work of EnsureTrackForUser, approved tells whether
+// the download can start without asking an admin.
+func (s *Streamrip) ensureTrack(ctx context.Context, spec downloadSpec, approved bool) (*model.DownloadResult, error) {
+	user, isrc, quality := spec.User, spec.ISRC, spec.Quality
 	exists, err := s.indexer.IsTrackInLibrary(context.Background(), isrc)
 	if err != nil {
 		return nil, err
@@ -104,126 +399,1024 @@ func (s *Streamrip) EnsureTrackForUser(ctx context.Context, songID, user, isrc s
 
 	downloadID := uuid.New().String()
 
-	if exists && isLinked {
-		return &model.DownloadResult{ID: downloadID, Action: model.ActionNoop}, nil
-	} else if exists { // Just get the track info from the db and make the symlink to the apropiate user
-		// LinkTrackToUser creates the record for the symlink in the db
-		_, err := s.fileManager.LinkTrackToUser(ctx, isrc, user)
+	if exists {
+		track, err := s.queries.SearchTracksByISRC(context.Background(), sql.NullString{String: isrc, Valid: isrc != ""})
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("error searching for the song by ISRC in the DB: %w", err)
 		}
+		current := fileQuality(track.FilePath)
 
-		s.tracker.SetStatus(downloadID, model.StatusSuccess)
-		track, err := s.queries.SearchTracksByISRC(context.Background(), sql.NullString{String: isrc, Valid: isrc != ""})
-		if err != nil && err != sql.ErrNoRows {
-			return nil, fmt.Errorf("error searching for the song by ISRC in the DB: %w", err)
+		action := model.ActionNoop
+		if !isLinked { // Just make the symlink to the apropiate user
+			// LinkTrackToUser creates the record for the symlink in the db
+			if _, err := s.fileManager.LinkTrackToUser(ctx, isrc, user); err != nil {
+				return nil, err
+			}
+			if err := s.tracker.StartLink(downloadID, spec); err != nil {
+				log.Printf("Error guardando historial de descarga: %v", err)
+			} else {
+				s.tracker.SetSuccess(downloadID, track.ID, current)
+			}
+			action = model.ActionLinked
 		}
 
-		go s.saveDownloadHistory(ctx, downloadID, user, track.ID, quality, string(model.StatusSuccess), "")
-		return &model.DownloadResult{ID: downloadID, Action: model.ActionLinked}, nil
+		// The user keeps the library's copy until the better one replaces it
+		upgrade, err := s.needsUpgrade(ctx, isrc, current, quality)
+		if err != nil {
+			return nil, err
+		}
+		if !upgrade {
+			return &model.DownloadResult{ID: downloadID, Action: action}, nil
+		}
+		if !approved && s.needsApproval(user) {
+			// The user got the song, asking again requests the better copy
+			if action == model.ActionLinked {
+				return &model.DownloadResult{ID: downloadID, Action: action}, nil
+			}
+			return s.requestApproval(ctx, spec)
+		}
+		spec.Upgrade = true
+		upgradeID := uuid.New().String()
+		err = s.startWithinQuota(ctx, user, func() error {
+			return s.tracker.Start(upgradeID, spec, model.StatusQueued)
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Upgrading %s from %s to %s, download %s", isrc, current, quality, upgradeID)
+		position := s.enqueue(newDownloadJob(upgradeID, spec))
+		return &model.DownloadResult{ID: upgradeID, Action: model.ActionUpgrade, Position: position}, nil
 	}
 
-	s.tracker.SetStatus(downloadID, model.StatusDownloading)
+	if !approved && s.needsApproval(user) {
+		return s.requestApproval(ctx, spec)
+	}
+	err = s.startWithinQuota(ctx, user, func() error {
+		return s.tracker.Start(downloadID, spec, model.StatusQueued)
+	})
+	if err != nil {
+		return nil, err
+	}
+	position := s.enqueue(newDownloadJob(downloadID, spec))
 
-	// cmd := exec.Command("srip", "--no-db", "id", "qobuz", "track", songID)
-	cmd := exec.Command("rip", "--no-db", "id", "qobuz", "track", songID)
+	return &model.DownloadResult{ID: downloadID, Action: model.ActionQueued, Position: position}, nil
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// needsUpgrade tells whether the library's copy of the track, in the
+// current quality, is worth downloading again in the requested one. An
+// upgrade to that quality is tried once: sources give the best they have
+// up to the requested quality, so asking again would get the same file.
+func (s *Streamrip) needsUpgrade(ctx context.Context, isrc string, current *model.Quality, requested model.Quality) (bool, error) {
+	// A file that can't be read is not replaced blindly
+	if current == nil || *current >= requested {
+		return false, nil
+	}
+	tried, err := s.queries.HasUpgradeToQuality(ctx, db.HasUpgradeToQualityParams{
+		Isrc:             sql.NullString{String: isrc, Valid: true},
+		RequestedQuality: sql.NullInt64{Int64: int64(requested), Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("error checking previous upgrades of %s: %w", isrc, err)
+	}
+	return tried == 0, nil
+}
 
-	log.Printf("Executing command: %v", cmd.Args)
-	if err := cmd.Start(); err != nil {
-		s.tracker.SetError(downloadID, fmt.Sprintf("start error: %v", err))
-		//To make sure context doesn't timeout
-		ctx = context.Background()
-		go s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", err.Error())
+// EnsureAlbumForUser queues the download of a whole album. Which of its
+// tracks the user already has is only known once rip fetched them, so the
+// album is always downloaded and those tracks are skipped when linking,
+// or requested when it needs the approval of an admin.
+func (s *Streamrip) EnsureAlbumForUser(ctx context.Context, source model.Source, albumID, user string, quality model.Quality) (*model.DownloadResult, error) {
+	if err := source.ValidateQuality(quality); err != nil {
 		return nil, err
 	}
+	spec := downloadSpec{
+		MediaType: model.MediaAlbum,
+		Source:    source,
+		SourceID:  albumID,
+		User:      user,
+		Quality:   quality,
+	}
+	if s.needsApproval(user) {
+		return s.requestApproval(ctx, spec)
+	}
+	return s.ensureAlbum(ctx, spec)
+}
 
-	time.AfterFunc(15*time.Minute, func() {
-		s.tracker.Delete(downloadID)
+func (s *Streamrip) ensureAlbum(ctx context.Context, spec downloadSpec) (*model.DownloadResult, error) {
+	downloadID := uuid.New().String()
+	err := s.startWithinQuota(ctx, spec.User, func() error {
+		return s.tracker.Start(downloadID, spec, model.StatusQueued)
 	})
+	if err != nil {
+		return nil, err
+	}
+	position := s.enqueue(newDownloadJob(downloadID, spec))
+
+	return &model.DownloadResult{ID: downloadID, Action: model.ActionQueued, Position: position}, nil
+}
 
+// ResumeInterrupted puts back in the queue the jobs that were queued or
+// running when the server stopped, keeping their original order. Jobs that
+// lack the information needed to run rip again are marked as failed, and
+// jobs waiting for a retry wait whatever they had left.
+func (s *Streamrip) ResumeInterrupted(ctx context.Context) error {
 	ctx = context.Background()
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			errMsg := fmt.Sprintf("rip error: %v\n%s", err, stderr.String())
-			s.tracker.SetError(downloadID, errMsg)
-			s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", errMsg)
-			return
+	// Every job starts over, whatever they left staged goes first
+	s.clearStaging(ctx)
+
+	rows, err := s.queries.ListActiveDownloads(ctx, sql.NullString{})
+	if err != nil {
+		return fmt.Errorf("error listing unfinished downloads: %w", err)
+	}
+
+	for _, row := range rows {
+		dh := row.DownloadHistory
+		spec, ok := specFromHistory(dh, row.Username)
+		if !ok {
+			s.tracker.SetError(dh.ID, "interrupted by a server restart")
+			continue
 		}
+		job := newDownloadJob(dh.ID, spec)
+		job.Attempt = dh.Attempts
+
+		if wait := time.Until(dh.NextAttemptAt.Time); dh.NextAttemptAt.Valid && wait > 0 {
+			log.Printf("Download %s (%s %s) will be retried in %s", dh.ID, spec.Source, spec.MediaType, wait.Round(time.Second))
+			if s.register(job) == nil {
+				go s.waitRetry(job, wait)
+			}
+			continue
+		}
+		log.Printf("Resuming download %s (%s %s ID: %s) for user %s", dh.ID, spec.Source, spec.MediaType, spec.SourceID, row.Username)
+		s.tracker.SetStatus(dh.ID, model.StatusQueued)
+		s.enqueue(job)
+	}
+	return nil
+}
 
-		downloadPath, err := extractDownloadPath(stdout.String())
+// specFromHistory rebuilds what a stored job downloads. ok is false when
+// the row lacks something rip needs: albums are downloaded by their ID in
+// the source and tracks can be found by their ISRC.
+func specFromHistory(dh db.DownloadHistory, user string) (downloadSpec, bool) {
+	source, err := model.ParseSource(dh.Service.String)
+	if err != nil || !dh.RequestedQuality.Valid {
+		return downloadSpec{}, false
+	}
+	mediaType := model.MediaType(dh.MediaType)
+	if (mediaType == model.MediaAlbum && !dh.SourceTrackID.Valid) || (mediaType == model.MediaTrack && !dh.Isrc.Valid) {
+		return downloadSpec{}, false
+	}
+	return downloadSpec{
+		MediaType: mediaType,
+		Source:    source,
+		Fallback:  model.Source(dh.FallbackService.String),
+		SourceID:  dh.SourceTrackID.String,
+		User:      user,
+		ISRC:      dh.Isrc.String,
+		Quality:   model.Quality(dh.RequestedQuality.Int64),
+		Upgrade:   dh.Upgrade,
+	}, true
+}
+
+// RepairDownloads queues again the failed downloads of the user, or of
+// every user when user is empty, each one with all its attempts. The failed
+// tracks of an album that is requeued too are downloaded with it.
+func (s *Streamrip) RepairDownloads(ctx context.Context, user string) (model.RepairResult, error) {
+	ctx = context.Background()
+	rows, err := s.queries.ListFailedDownloads(ctx, sql.NullString{String: user, Valid: user != ""})
+	if err != nil {
+		return model.RepairResult{}, fmt.Errorf("error listing failed downloads: %w", err)
+	}
+
+	failedAlbums := make(map[string]bool)
+	for _, row := range rows {
+		if row.DownloadHistory.MediaType == string(model.MediaAlbum) {
+			failedAlbums[row.DownloadHistory.ID] = true
+		}
+	}
+
+	result := model.RepairResult{Requeued: []string{}, Skipped: []string{}}
+	for _, row := range rows {
+		dh := row.DownloadHistory
+		if dh.ParentID.Valid && failedAlbums[dh.ParentID.String] {
+			continue
+		}
+		spec, ok := specFromHistory(dh, row.Username)
+		if !ok {
+			result.Skipped = append(result.Skipped, dh.ID)
+			continue
+		}
+		if spec.MediaType == model.MediaTrack {
+			linkedParams := db.IsTrackLinkedToUserByUsernameAndISRCParams{
+				Username: spec.User,
+				Isrc:     sql.NullString{String: spec.ISRC, Valid: true},
+			}
+			isLinked, err := s.queries.IsTrackLinkedToUserByUsernameAndISRC(ctx, linkedParams)
+			if err != nil {
+				return result, fmt.Errorf("error checking the library of %s: %w", spec.User, err)
+			}
+			if isLinked == 1 {
+				result.Skipped = append(result.Skipped, dh.ID)
+				continue
+			}
+		}
+
+		requeued, err := s.tracker.Requeue(dh.ID, spec.MediaType)
 		if err != nil {
-			errMsg := fmt.Sprintf("parse error: %v", err)
-			s.tracker.SetError(downloadID, errMsg)
-			s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", errMsg)
-			return
+			return result, err
+		}
+		if !requeued {
+			result.Skipped = append(result.Skipped, dh.ID)
+			continue
 		}
+		s.enqueue(newDownloadJob(dh.ID, spec))
+		result.Requeued = append(result.Requeued, dh.ID)
+	}
+	log.Printf("Repair requeued %d failed downloads and skipped %d", len(result.Requeued), len(result.Skipped))
+	return result, nil
+}
+
+// enqueue queues the job and returns its position. A track that another
+// job is already downloading isn't downloaded twice, the job is attached
+// to that one and gets its position.
+func (s *Streamrip) enqueue(job *downloadJob) int {
+	if primary := s.register(job); primary != nil {
+		log.Printf("Download %s of %s for %s waits for download %s", job.ID, job.ISRC, job.User, primary.ID)
+		// The download may have started already
+		primary.statusMu.Lock()
+		if primary.status != "" && primary.status != model.StatusQueued {
+			s.tracker.SetStatus(job.ID, primary.status)
+		}
+		primary.statusMu.Unlock()
+		return s.queue.Position(primary.ID)
+	}
+	return s.queue.Enqueue(job)
+}
+
+// register records the job as queued or running. It returns the job it
+// was attached to, nil when the job has to run.
+func (s *Streamrip) register(job *downloadJob) *downloadJob {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	s.jobs[job.ID] = job
+	key := job.dedupKey()
+	if key == "" {
+		return nil
+	}
+	if primary := s.inFlight[key]; primary != nil && primary.attach(job) {
+		return primary
+	}
+	s.inFlight[key] = job
+	return nil
+}
+
+// forget drops the job once it is over, together with the jobs attached
+// to it.
+func (s *Streamrip) forget(id string) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+	delete(s.jobs, id)
+	if key := job.dedupKey(); key != "" && s.inFlight[key] == job {
+		delete(s.inFlight, key)
+	}
+	for waiterID, waiter := range s.jobs {
+		if waiter.primary == job {
+			delete(s.jobs, waiterID)
+		}
+	}
+}
+
+// position returns the position in the queue of the job, or of the job it
+// is attached to.
+func (s *Streamrip) position(id string) int {
+	s.jobsMu.Lock()
+	if job, ok := s.jobs[id]; ok && job.primary != nil {
+		id = job.primary.ID
+	}
+	s.jobsMu.Unlock()
+	return s.queue.Position(id)
+}
+
+// setStatus records the status of the job and of the jobs attached to it.
+func (s *Streamrip) setStatus(job *downloadJob, status model.DownloadStatus) {
+	job.statusMu.Lock()
+	defer job.statusMu.Unlock()
+	job.status = status
+	s.tracker.SetStatus(job.ID, status)
+	for _, waiter := range job.waiting() {
+		s.tracker.SetStatus(waiter.ID, status)
+	}
+}
+
+// Every job downloads into its own staging folder, its files only reach
+// the library once they are indexed and linked.
+func stagingRoot() string {
+	return filepath.Join(config.SanchoPath, "staging")
+}
+
+func jobStagingDir(id string) string {
+	return filepath.Join(stagingRoot(), id)
+}
+
+// Staging folders of failed ingests are moved here to be looked at
+func jobQuarantineDir(id string) string {
+	return filepath.Join(config.SanchoPath, "quarantine", id)
+}
 
-		s.tracker.SetStatus(downloadID, model.StatusIndexing)
+// removeStaging deletes the job's staging folder.
+func removeStaging(id string) {
+	jobDir := jobStagingDir(id)
+	if err := os.RemoveAll(jobDir); err != nil {
+		log.Printf("Could not remove download folder %s: %v", jobDir, err)
+	}
+}
+
+// quarantine moves the job's staging folder out of the way, keeping what
+// rip downloaded. Nothing in the DB may point into the folder anymore.
+func quarantine(id string) {
+	jobDir, target := jobStagingDir(id), jobQuarantineDir(id)
+	if _, err := os.Stat(jobDir); os.IsNotExist(err) {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err == nil {
+		err = os.Rename(jobDir, target)
+	}
+	if err != nil {
+		log.Printf("Could not quarantine download folder %s: %v", jobDir, err)
+		removeStaging(id)
+		return
+	}
+	log.Printf("Download %s failed while ingesting, its files were kept in %s", id, target)
+}
+
+// clearStaging removes what the jobs of a previous run left staged: the
+// tracks indexed but never moved into the library and their files.
+func (s *Streamrip) clearStaging(ctx context.Context) {
+	tracks, err := s.queries.ListTracksUnderPath(ctx, stagingRoot()+string(filepath.Separator))
+	if err != nil {
+		log.Printf("Error listing staged tracks: %v", err)
+		return
+	}
+	for _, track := range tracks {
+		if err := s.fileManager.discardTrack(ctx, track.ID); err != nil {
+			log.Printf("Could not remove staged track %d: %v", track.ID, err)
+		}
+	}
+	if err := os.RemoveAll(stagingRoot()); err != nil {
+		log.Printf("Could not clear the staging folder: %v", err)
+	}
+}
+
+// runDownload is called by a queue worker. It runs an attempt of the job
+// and, when it has to be retried, waits for the next one out of the queue.
+func (s *Streamrip) runDownload(job *downloadJob) {
+	s.download(job)
+	if job.retryDelay > 0 {
+		delay := job.retryDelay
+		job.retryDelay = 0
+		go s.waitRetry(job, delay)
+		return
+	}
+	s.forget(job.ID)
+}
 
-		fileInfo, err := os.Stat(downloadPath)
+// waitRetry puts the job back in the queue once the delay passes, unless
+// it is canceled meanwhile.
+func (s *Streamrip) waitRetry(job *downloadJob, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		s.queue.Enqueue(job)
+	case <-job.ctx.Done():
+		s.forget(job.ID)
+		s.discardDownload(job)
+	}
+}
+
+// retryOrFail schedules another attempt when the error may be temporary
+// and the job has attempts left, and fails the job otherwise.
+func (s *Streamrip) retryOrFail(job *downloadJob, err error) {
+	if !isTemporary(err) || job.Attempt >= int64(config.DownloadMaxAttempts) || job.isCanceled() {
+		s.failDownload(job, err.Error())
+		return
+	}
+	job.Attempt++
+	job.retryDelay = retryDelay(job.Attempt)
+	log.Printf("Download %s failed with a temporary error, attempt %d of %d in %s: %v", job.ID, job.Attempt, config.DownloadMaxAttempts, job.retryDelay, err)
+	job.statusMu.Lock()
+	defer job.statusMu.Unlock()
+	job.status = model.StatusQueued
+	s.tracker.SetRetry(job.ID, job.Attempt, err.Error(), time.Now().Add(job.retryDelay))
+	for _, waiter := range job.waiting() {
+		s.tracker.SetStatus(waiter.ID, model.StatusQueued)
+	}
+}
+
+// download runs rip for the job and indexes and links the result,
+// recording every transition.
+func (s *Streamrip) download(job *downloadJob) {
+	jobDir := jobStagingDir(job.ID)
+	job.ingesting = false
+
+	if job.isCanceled() {
+		s.discardDownload(job)
+		return
+	}
+	s.setStatus(job, model.StatusDownloading)
+
+	// Every attempt starts from scratch
+	if err := os.RemoveAll(jobDir); err != nil {
+		s.failDownload(job, fmt.Sprintf("error cleaning download folder: %v", err))
+		return
+	}
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		s.failDownload(job, fmt.Sprintf("error creating download folder: %v", err))
+		return
+	}
+
+	// Tracks requeued from an album only know their ISRC
+	if job.SourceID == "" {
+		sourceID, err := s.findTrackByISRC(job.Source, job.ISRC)
 		if err != nil {
-			errMsg := fmt.Sprintf("file not found: %v", err)
-			s.tracker.SetError(downloadID, errMsg)
-			s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", errMsg)
+			s.retryOrFail(job, fmt.Errorf("could not find the track in %s: %w", job.Source, err))
 			return
 		}
+		job.SourceID = sourceID
+		s.tracker.SetSource(job.ID, job.Source, sourceID)
+	}
+
+	downloadPath, err := s.rip(job, jobDir)
+	if err != nil && job.canFallback() {
+		downloadPath, err = s.ripFallback(job, jobDir, err)
+	}
+	if err != nil {
+		s.retryOrFail(job, err)
+		return
+	}
+
+	if job.isCanceled() {
+		s.discardDownload(job)
+		return
+	}
+	s.setStatus(job, model.StatusIndexing)
+	job.ingesting = true
 
-		trackID, err := s.indexer.IndexFile(ctx, fileInfo, downloadPath, user)
+	switch {
+	case job.MediaType == model.MediaAlbum:
+		s.ingestAlbum(job, jobDir)
+	case job.Upgrade:
+		s.ingestUpgrade(job, jobDir, downloadPath)
+	default:
+		s.ingestTrack(job, jobDir, downloadPath)
+	}
+}
+
+// rip downloads the job's track or album into jobDir, in the job's
+// quality, and returns the path of the track. The download stops as soon
+// as the job is canceled.
+func (s *Streamrip) rip(job *downloadJob, jobDir string) (string, error) {
+	req := DownloadRequest{
+		Source:    job.Source,
+		MediaType: job.MediaType,
+		SourceID:  job.SourceID,
+		Quality:   job.Quality,
+		Dir:       jobDir,
+	}
+	return s.downloader.Download(job.ctx, req, func(downloaded, total int64) {
+		s.events.Publish(model.DownloadEvent{
+			DownloadID:      job.ID,
+			User:            job.User,
+			Type:            model.EventProgress,
+			Status:          model.StatusDownloading,
+			BytesDownloaded: downloaded,
+			BytesTotal:      total,
+		})
+	})
+}
+
+// ripFallback looks for the job's track in its fallback source, by ISRC,
+// and downloads it from there. The job keeps the fallback source from then
+// on, so a resumed job doesn't go back to the source that failed.
+func (s *Streamrip) ripFallback(job *downloadJob, jobDir string, ripErr error) (string, error) {
+	sourceID, err := s.findTrackByISRC(job.Fallback, job.ISRC)
+	if err != nil {
+		return "", fmt.Errorf("%v\nfallback to %s failed: %w", ripErr, job.Fallback, err)
+	}
+	log.Printf("Download %s failed in %s, retrying with %s ID: %s", job.ID, job.Source, job.Fallback, sourceID)
+
+	job.useFallback(sourceID)
+	s.tracker.SetSource(job.ID, job.Source, job.SourceID)
+
+	// Whatever the failed attempt left must not be taken for the new file
+	if err := os.RemoveAll(jobDir); err != nil {
+		return "", fmt.Errorf("error cleaning download folder: %v", err)
+	}
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return "", fmt.Errorf("error creating download folder: %v", err)
+	}
+	return s.rip(job, jobDir)
+}
+
+// findTrackByISRC returns the ID in source of the track with the ISRC.
+func (s *Streamrip) findTrackByISRC(source model.Source, isrc string) (string, error) {
+	switch source {
+	case model.SourceDeezer:
+		track, err := getDeezerTrack(isrc)
 		if err != nil {
-			errMsg := fmt.Sprintf("indexing error: %v", err)
-			s.tracker.SetError(downloadID, errMsg)
-			s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", errMsg)
-			return
+			return "", err
+		}
+		return strconv.FormatInt(track.ID, 10), nil
+	case model.SourceSoundCloud:
+		return "", fmt.Errorf("%w: soundcloud tracks can't be searched by ISRC", model.ErrTrackNotInSource)
+	}
+
+	results, err := s.SearchSong(string(source), string(model.MediaTrack), isrc)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if strings.EqualFold(r.Data.ISRC, isrc) {
+			return r.ID, nil
 		}
+	}
+	return "", fmt.Errorf("%w: no %s track has the ISRC %s", model.ErrTrackNotInSource, source, isrc)
+}
 
-		_, err = s.fileManager.LinkTrackToUser(ctx, isrc, user)
+func (s *Streamrip) ingestTrack(job *downloadJob, jobDir, downloadPath string) {
+	//To make sure context doesn't timeout
+	ctx := context.Background()
+	// Anything outside the staging folder would escape the rollback
+	if rel, err := filepath.Rel(jobDir, downloadPath); err != nil || strings.HasPrefix(rel, "..") {
+		s.failDownload(job, fmt.Sprintf("rip saved the track outside the download folder: %s", downloadPath))
+		return
+	}
+
+	fileInfo, err := os.Stat(downloadPath)
+	if err != nil {
+		errMsg := fmt.Sprintf("file not found: %v", err)
+		s.failDownload(job, errMsg)
+		return
+	}
+
+	trackID, err := s.indexer.IndexFile(ctx, fileInfo, downloadPath, job.User)
+	// The library got the track meanwhile, the user gets that copy
+	isNew := err == nil
+	var trackExistsErr *TrackExistsError
+	if err != nil && !errors.As(err, &trackExistsErr) {
+		s.retryOrFail(job, fmt.Errorf("indexing error: %w", err))
+		return
+	}
+
+	var quality *model.Quality
+	if !isNew {
+		if track, err := s.queries.GetTrackByID(ctx, trackID); err == nil {
+			quality = fileQuality(track.FilePath)
+		}
+	} else if q, err := checkQuality(job.ID, downloadPath, job.Quality); err != nil {
+		log.Printf("Could not check the quality of download %s: %v", job.ID, err)
+	} else {
+		quality = &q
+	}
+
+	// Linking is the point of no return, a cancel request that arrives
+	// while it runs waits for it and then finds the job finished.
+	linked := job.finish(func() {
+		s.publishLinking(job)
+		_, err = s.fileManager.LinkTrackToUser(ctx, job.ISRC, job.User)
 		if err != nil {
+			// The link was undone and left the file staged, the track
+			// indexed from it can't stay in the library
+			if isNew {
+				s.dropTrack(job, trackID)
+			}
 			errMsg := fmt.Sprintf("symlink error: %v", err)
-			s.tracker.SetError(downloadID, errMsg)
-			s.saveDownloadHistory(ctx, downloadID, user, 0, 0, "failed", errMsg)
+			s.tracker.SetError(job.ID, errMsg)
+			s.failWaiters(job, errMsg)
+			quarantine(job.ID)
 			return
 		}
+		s.tracker.SetSuccess(job.ID, trackID, quality)
+		s.linkWaiters(ctx, job, trackID, quality)
+		removeStaging(job.ID)
+	})
+	if !linked {
+		if isNew {
+			s.discardDownload(job, trackID)
+		} else {
+			s.discardDownload(job)
+		}
+	}
+}
+
+// ingestUpgrade replaces the library's copy of the job's track with the
+// downloaded file, as long as it is the same track in a better quality.
+// The track keeps its ID, so its users keep it in their libraries.
+func (s *Streamrip) ingestUpgrade(job *downloadJob, jobDir, downloadPath string) {
+	ctx := context.Background()
+	if rel, err := filepath.Rel(jobDir, downloadPath); err != nil || strings.HasPrefix(rel, "..") {
+		s.failDownload(job, fmt.Sprintf("rip saved the track outside the download folder: %s", downloadPath))
+		return
+	}
+
+	track, err := s.queries.SearchTracksByISRC(ctx, sql.NullString{String: job.ISRC, Valid: true})
+	if err != nil {
+		s.failDownload(job, fmt.Sprintf("the track is not in the library anymore: %v", err))
+		return
+	}
+	quality, err := verifyUpgrade(downloadPath, job.ISRC)
+	if err != nil {
+		s.failDownload(job, err.Error())
+		return
+	}
+	current := fileQuality(track.FilePath)
+	if current != nil && quality <= *current {
+		log.Printf("Download %s: the source has no better copy of %s than the library's %s", job.ID, job.ISRC, current)
+		skipped := job.finish(func() {
+			s.tracker.SetSkipped(job.ID, fmt.Sprintf("the source has no better copy than the library's (%s)", current), track.ID, &quality)
+			removeStaging(job.ID)
+		})
+		if !skipped {
+			s.discardDownload(job)
+		}
+		return
+	}
 
-		s.tracker.SetStatus(downloadID, model.StatusSuccess)
-		s.saveDownloadHistory(ctx, downloadID, user, trackID, quality, "success", "")
-	}()
+	replaced := job.finish(func() {
+		s.publishLinking(job)
+		if _, err := s.fileManager.ReplaceTrackFile(ctx, track.ID, downloadPath); err != nil {
+			s.tracker.SetError(job.ID, fmt.Sprintf("error replacing the library's copy: %v", err))
+			quarantine(job.ID)
+			return
+		}
+		log.Printf("Download %s replaced %s with a %s copy", job.ID, track.FilePath, quality)
+		s.tracker.SetSuccess(job.ID, track.ID, &quality)
+		removeStaging(job.ID)
+	})
+	if !replaced {
+		s.discardDownload(job)
+	}
+}
 
-	return &model.DownloadResult{ID: downloadID, Action: model.ActionDownloading}, nil
+// verifyUpgrade checks the file can replace the track with the ISRC and
+// returns its quality.
+func verifyUpgrade(path, isrc string) (model.Quality, error) {
+	tags, err := tag.ReadTags(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading tags: %w", err)
+	}
+	if got := tags[tag.ISRC]; len(got) > 0 && !strings.EqualFold(got[0], isrc) {
+		return 0, fmt.Errorf("the downloaded file is another track, its ISRC is %s", got[0])
+	}
+	format, err := readAudioFormat(path)
+	if err != nil {
+		return 0, err
+	}
+	return format.Quality(), nil
 }
 
-func (s *Streamrip) GetDownloadStatus(id string) (model.DownloadStatus, string) {
-	return s.tracker.Get(id)
+// A track found in the folder of an album job
+type albumTrack struct {
+	TrackID int64
+	ISRC    string
+	// Indexed by this job, as opposed to already in the library
+	New bool
+	// Of the file the user ends up with, nil when it couldn't be read
+	Quality *model.Quality
+	Status  model.DownloadStatus
+	Error   string
 }
 
-func extractDownloadPath(output string) (string, error) {
-	start := strings.Index(output, "---BEGIN JSON---")
-	end := strings.Index(output, "---END JSON---")
+// ingestAlbum indexes every file rip left in jobDir and links to the user
+// the tracks they don't have yet. The album succeeds when at least one of
+// its tracks ends up in the user's library.
+func (s *Streamrip) ingestAlbum(job *downloadJob, jobDir string) {
+	ctx := context.Background()
 
-	if start == -1 || end == -1 || start >= end {
-		return "", fmt.Errorf("could not find JSON delimiters")
+	var files []string
+	err := filepath.Walk(jobDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && s.indexer.isAudioFile(path) {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		s.failDownload(job, fmt.Sprintf("error reading download folder: %v", err))
+		return
+	}
+	if len(files) == 0 {
+		s.failDownload(job, "rip did not download any audio file")
+		return
 	}
 
-	jsonRaw := output[start+len("---BEGIN JSON---") : end]
-	jsonRaw = strings.TrimSpace(jsonRaw)
+	tracks := make([]albumTrack, 0, len(files))
+	var indexed []int64
+	for _, path := range files {
+		if job.isCanceled() {
+			s.discardDownload(job, indexed...)
+			return
+		}
+		t := s.indexAlbumFile(ctx, job, path)
+		if t.New && t.Status == model.StatusFailed {
+			s.dropTrack(job, t.TrackID)
+			t.TrackID, t.New = 0, false
+		}
+		if t.New {
+			indexed = append(indexed, t.TrackID)
+		}
+		tracks = append(tracks, t)
+	}
 
-	var parsed streamripJSONOutput
-	if err := json.Unmarshal([]byte(jsonRaw), &parsed); err != nil {
-		return "", fmt.Errorf("error parsing json block: %w", err)
+	linked := job.finish(func() {
+		s.publishLinking(job)
+		added, failed := 0, 0
+		// The album is as good as its worst track
+		var quality *model.Quality
+		for _, t := range tracks {
+			if t.Status == "" {
+				t = s.linkAlbumTrack(ctx, job.User, t)
+			}
+			if t.Status == model.StatusFailed {
+				failed++
+			}
+			if t.Status == model.StatusSuccess || t.Status == model.StatusSkipped {
+				added++
+				if t.Quality != nil && (quality == nil || *t.Quality < *quality) {
+					quality = t.Quality
+				}
+			}
+			s.tracker.AddAlbumTrack(job, t)
+		}
+
+		if added == 0 {
+			s.tracker.SetError(job.ID, "none of the tracks of the album could be added")
+		} else {
+			s.tracker.SetSuccess(job.ID, 0, quality)
+		}
+		// What is left are the files of the tracks the library already
+		// had, and of the failed ones if any
+		if failed > 0 {
+			quarantine(job.ID)
+		} else {
+			removeStaging(job.ID)
+		}
+	})
+	if !linked {
+		s.discardDownload(job, indexed...)
+	}
+}
+
+// indexAlbumFile adds one file of an album to the library. Files of
+// tracks the library already has are left in the job folder, to be
+// removed with it. A failed file comes back with its final status set.
+func (s *Streamrip) indexAlbumFile(ctx context.Context, job *downloadJob, path string) albumTrack {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return albumTrack{Status: model.StatusFailed, Error: fmt.Sprintf("%s: file not found: %v", filepath.Base(path), err)}
+	}
+
+	trackID, err := s.indexer.IndexFile(ctx, fileInfo, path, job.User)
+	isNew := err == nil
+	var trackExistsErr *TrackExistsError
+	if err != nil && !errors.As(err, &trackExistsErr) {
+		return albumTrack{Status: model.StatusFailed, Error: fmt.Sprintf("%s: indexing error: %v", filepath.Base(path), err)}
+	}
+
+	track, err := s.queries.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return albumTrack{TrackID: trackID, New: isNew, Status: model.StatusFailed, Error: fmt.Sprintf("%s: could not read the indexed track: %v", filepath.Base(path), err)}
+	}
+
+	// The user gets the library's copy of the tracks it already had
+	var quality *model.Quality
+	if !isNew {
+		quality = fileQuality(track.FilePath)
+	} else if q, err := checkQuality(job.ID, path, job.Quality); err != nil {
+		log.Printf("Could not check the quality of %s: %v", path, err)
+	} else {
+		quality = &q
+	}
+	return albumTrack{TrackID: trackID, ISRC: track.Isrc.String, New: isNew, Quality: quality}
+}
+
+// linkAlbumTrack links the track to the user unless they already have it.
+func (s *Streamrip) linkAlbumTrack(ctx context.Context, user string, t albumTrack) albumTrack {
+	linkedParams := db.IsTrackLinkedToUserByUsernameAndISRCParams{
+		Username: user,
+		Isrc:     sql.NullString{String: t.ISRC, Valid: t.ISRC != ""},
+	}
+	isLinked, err := s.queries.IsTrackLinkedToUserByUsernameAndISRC(ctx, linkedParams)
+	if err != nil {
+		t.Status, t.Error = model.StatusFailed, fmt.Sprintf("error checking the user's library: %v", err)
+		return t
+	}
+	if isLinked == 1 {
+		t.Status = model.StatusSkipped
+		return t
+	}
+
+	if _, err := s.fileManager.LinkTrackToUser(ctx, t.ISRC, user); err != nil {
+		t.Status, t.Error = model.StatusFailed, fmt.Sprintf("symlink error: %v", err)
+		// The file stays in the job folder, so the track can't stay
+		if t.New {
+			if err := s.fileManager.discardTrack(ctx, t.TrackID); err != nil {
+				log.Printf("Could not remove track %d after a failed link: %v", t.TrackID, err)
+			}
+			t.TrackID = 0
+		}
+		return t
+	}
+	t.Status = model.StatusSuccess
+	return t
+}
+
+func (s *Streamrip) publishLinking(job *downloadJob) {
+	s.events.Publish(model.DownloadEvent{
+		DownloadID: job.ID,
+		User:       job.User,
+		Type:       model.EventLinking,
+		Status:     model.StatusIndexing,
+	})
+}
+
+// failDownload records the error, unless the job was canceled meanwhile.
+// A job that failed before rip finished has nothing worth keeping.
+func (s *Streamrip) failDownload(job *downloadJob, errMsg string) {
+	failed := job.finish(func() {
+		s.tracker.SetError(job.ID, errMsg)
+		s.failWaiters(job, errMsg)
+		if job.ingesting {
+			quarantine(job.ID)
+		} else {
+			removeStaging(job.ID)
+		}
+	})
+	if !failed {
+		s.discardDownload(job)
+	}
+}
+
+// discardDownload undoes the work of a canceled job: the tracks it indexed,
+// if any, and every file it downloaded.
+func (s *Streamrip) discardDownload(job *downloadJob, trackIDs ...int64) {
+	for _, trackID := range trackIDs {
+		s.dropTrack(job, trackID)
+	}
+	removeStaging(job.ID)
+	s.tracker.SetCanceled(job.ID)
+	log.Printf("Download %s was canceled", job.ID)
+
+	// The other users still want the track, they can repair their downloads
+	for _, waiter := range job.takeWaiters() {
+		waiter.finish(func() {
+			s.tracker.SetError(waiter.ID, "the download it was waiting for was canceled")
+		})
+	}
+}
+
+// linkWaiters links the track downloaded by the job to the users of the
+// jobs attached to it. It runs while the job finishes, no job can attach
+// meanwhile.
+func (s *Streamrip) linkWaiters(ctx context.Context, job *downloadJob, trackID int64, quality *model.Quality) {
+	for _, waiter := range job.waiters {
+		waiter.finish(func() {
+			t := s.linkAlbumTrack(ctx, waiter.User, albumTrack{TrackID: trackID, ISRC: job.ISRC, Quality: quality})
+			if t.Status == model.StatusFailed {
+				s.tracker.SetError(waiter.ID, t.Error)
+				return
+			}
+			s.tracker.SetSuccess(waiter.ID, trackID, quality)
+		})
+	}
+}
+
+// failWaiters fails the jobs attached to the job with its error. Like
+// linkWaiters, it runs while the job finishes.
+func (s *Streamrip) failWaiters(job *downloadJob, errMsg string) {
+	for _, waiter := range job.waiters {
+		waiter.finish(func() {
+			s.tracker.SetError(waiter.ID, errMsg)
+		})
+	}
+}
+
+// dropTrack removes a track the job indexed from a staged file.
+func (s *Streamrip) dropTrack(job *downloadJob, trackID int64) {
+	if err := s.fileManager.discardTrack(context.Background(), trackID); err != nil {
+		log.Printf("Could not remove track %d of download %s: %v", trackID, job.ID, err)
+	}
+}
+
+// CancelDownload stops a queued or running job. A running job is cleaned
+// up by its worker as soon as it notices the cancellation. When user is
+// given the job must be theirs, the jobs of others are not found.
+func (s *Streamrip) CancelDownload(id, user string) error {
+	if user != "" {
+		stored, err := s.tracker.Get(id)
+		if err != nil {
+			return err
+		}
+		if stored.User != user {
+			return model.ErrDownloadNotFound
+		}
 	}
 
-	if parsed.DownloadPath == "" {
-		return "", fmt.Errorf("parsed JSON has empty downloadPath")
+	s.jobsMu.Lock()
+	job, ok := s.jobs[id]
+	s.jobsMu.Unlock()
+
+	if !ok {
+		stored, err := s.tracker.Get(id)
+		if err != nil {
+			return err
+		}
+		switch stored.Status {
+		case model.StatusQueued, model.StatusDownloading, model.StatusIndexing:
+			// Not owned by any worker, nothing to stop
+			s.tracker.SetCanceled(id)
+			return nil
+		default:
+			return model.ErrDownloadFinished
+		}
 	}
 
-	return parsed.DownloadPath, nil
+	// An attached job stops waiting, the download goes on for the others
+	if job.primary != nil {
+		if !job.primary.detach(job) {
+			return model.ErrDownloadFinished
+		}
+		if err := job.requestCancel(); err != nil {
+			return err
+		}
+		s.forget(id)
+		s.tracker.SetCanceled(id)
+		return nil
+	}
+
+	if err := job.requestCancel(); err != nil {
+		return err
+	}
+	if s.queue.Remove(id) {
+		s.forget(id)
+		s.discardDownload(job)
+	}
+	return nil
+}
+
+// GetDownloadStatus returns the job with its queue position when it is
+// still waiting for a worker.
+func (s *Streamrip) GetDownloadStatus(id string) (model.DownloadJob, error) {
+	job, err := s.tracker.Get(id)
+	if err != nil {
+		return model.DownloadJob{}, err
+	}
+	if job.Status == model.StatusQueued {
+		job.Position = s.position(job.ID)
+	}
+	return job, nil
+}
+
+// SubscribeDownloadEvents streams the events of the user's downloads, or
+// of every download when user is empty, until unsubscribe is called.
+func (s *Streamrip) SubscribeDownloadEvents(user string) (<-chan model.DownloadEvent, func()) {
+	return s.events.Subscribe(user)
+}
+
+// ListDownloads returns the queued and running jobs in queue order.
+func (s *Streamrip) ListDownloads(user string) ([]model.DownloadJob, error) {
+	jobs, err := s.tracker.Active(user)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		if jobs[i].Status == model.StatusQueued {
+			jobs[i].Position = s.position(jobs[i].ID)
+		}
+	}
+	return jobs, nil
 }
 
-// SearchSong ejecuta una búsqueda usando streamrip y devuelve los resultados en una estructura Go
+// SearchSong searches the catalog of the source with rip. The results of
+// a search are reused for config.SearchCacheTTL.
 func (s *Streamrip) SearchSong(source, mediaType, query string) ([]model.StreamripSearchResult, error) {
+	key := searchKey(source, mediaType, query)
+	if results, ok := s.searches.get(key); ok {
+		return results, nil
+	}
+	results, err := ripSearch(source, mediaType, query)
+	if err != nil {
+		return nil, err
+	}
+	s.searches.set(key, results)
+	return results, nil
+}
+
+// ripSearch ejecuta una búsqueda usando streamrip y devuelve los resultados en una estructura Go
+func ripSearch(source, mediaType, query string) ([]model.StreamripSearchResult, error) {
 	// Verificar que el binario existe
 	// _, err := exec.LookPath("srip")
 	_, err := exec.LookPath("rip")
@@ -255,47 +1448,16 @@ func (s *Streamrip) SearchSong(source, mediaType, query string) ([]model.Streamr
 	return results, nil
 }
 
-func (s *Streamrip) saveDownloadHistory(
-	ctx context.Context,
-	downloadID, user string,
-	trackID int64,
-	quality int64,
-	status string,
-	errorMsg string,
-) {
-	ctx = context.Background()
-	userData, err := s.queries.GetUserByUsername(ctx, user)
-	if err != nil {
-		log.Printf("Could not find the user %s: %v", user, err)
-		return
-	}
-
-	params := db.InsertDownloadHistoryParams{
-		ID:      downloadID,
-		UserID:  sql.NullInt64{Int64: userData.ID, Valid: userData.ID > 0},
-		Status:  sql.NullString{String: status, Valid: status != ""},
-		Service: sql.NullString{String: "qobuz", Valid: true},
-	}
-
-	if errorMsg != "" {
-		params.ErrorMessage = sql.NullString{String: errorMsg, Valid: true}
-		params.CompletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
-	}
-
-	if status == "success" {
-		params.CompletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
-		params.TrackID = sql.NullInt64{Int64: trackID, Valid: true}
-		params.Quality = sql.NullInt64{Int64: quality, Valid: true}
-	}
-
-	_, err = s.queries.InsertDownloadHistory(ctx, params)
+func (s *Streamrip) GetDeezerTrackSample(isrc string) (sampleUrl string, err error) {
+	track, err := getDeezerTrack(isrc)
 	if err != nil {
-		log.Printf("Error guardando historial de descarga: %v", err)
+		return "", err
 	}
+	return track.Preview, nil
 }
 
-func (s *Streamrip) GetDeezerTrackSample(isrc string) (sampleUrl string, err error) {
-	url := fmt.Sprintf("https://api.deezer.com/track/isrc:%s", isrc)
+func getDeezerTrack(isrc string) (deezerTrackResponse, error) {
+	url := fmt.Sprintf("%s/track/isrc:%s", config.DeezerAPIURL, isrc)
 
 	client := &http.Client{
 		Timeout: 10 * time.Second,
@@ -303,17 +1465,23 @@ func (s *Streamrip) GetDeezerTrackSample(isrc string) (sampleUrl string, err err
 
 	resp, err := client.Get(url)
 	if err != nil {
-		return "", fmt.Errorf("error making request to Deezer: %w", err)
+		return deezerTrackResponse{}, fmt.Errorf("error making request to Deezer: %w", temporary(err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("deezer API returned status %d", resp.StatusCode)
+		return deezerTrackResponse{}, deezerStatusError(resp.StatusCode)
 	}
 
-	var result deezerTrackSampleResponse
+	var result deezerTrackResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("error decoding deezer response: %w", err)
+		return deezerTrackResponse{}, fmt.Errorf("error decoding deezer response: %w", err)
+	}
+	if result.Error != nil && result.Error.Code == deezerQuotaErrorCode {
+		return deezerTrackResponse{}, result.Error.asError()
+	}
+	if result.Error != nil || result.ID == 0 {
+		return deezerTrackResponse{}, fmt.Errorf("%w: deezer has no track with the ISRC %s", model.ErrTrackNotInSource, isrc)
 	}
-	return result.Preview, err
+	return result, nil
 }
@@ -0,0 +1,925 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// The song most tests download
+var callMe = FakeTrack{
+	Title:       "Call Me",
+	Artist:      "Blondie",
+	Album:       "Parallel Lines",
+	ISRC:        "USCH37900012",
+	TrackNumber: 2,
+	BitDepth:    24,
+	SampleRate:  96000,
+}
+
+// A Sancho instance with an empty library in a temporary SanchoPath,
+// downloading with a FakeDownloader and asking a fake Deezer API.
+type testEnv struct {
+	t          *testing.T
+	conn       *sql.DB
+	queries    *db.Queries
+	downloader *FakeDownloader
+	streamrip  *Streamrip
+	webhooks   *WebhookDispatcher
+	deezer     *fakeDeezer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	root := t.TempDir()
+
+	deezer := newFakeDeezer()
+	server := httptest.NewServer(deezer)
+	t.Cleanup(server.Close)
+
+	setConfig(t, &config.SanchoPath, root)
+	setConfig(t, &config.LibraryPath, filepath.Join(root, "library"))
+	setConfig(t, &config.DeezerAPIURL, server.URL)
+	setConfig(t, &config.DownloadRetryDelay, 10*time.Millisecond)
+	setConfig(t, &config.DownloadMaxAttempts, 3)
+
+	conn := openTestDB(t, filepath.Join(root, "database.sancho"))
+	queries := db.New(conn)
+	for _, user := range []string{"alice", "bob"} {
+		_, err := queries.InsertUser(context.Background(), db.InsertUserParams{Username: user, PasswordHash: "-"})
+		if err != nil {
+			t.Fatalf("creating user %s: %v", user, err)
+		}
+	}
+
+	webhooks := NewWebhookDispatcher(queries)
+	fileManager := NewFileManager(conn, queries, webhooks)
+	indexer := NewIndexer(conn, queries, fileManager, webhooks)
+	downloader := NewFakeDownloader()
+	env := &testEnv{
+		t:          t,
+		conn:       conn,
+		queries:    queries,
+		downloader: downloader,
+		streamrip:  NewStreamrip(indexer, fileManager, downloader, queries, webhooks),
+		webhooks:   webhooks,
+		deezer:     deezer,
+	}
+	// Jobs and deliveries still running would outlive the database
+	t.Cleanup(webhooks.wait)
+	t.Cleanup(env.cancelAll)
+	return env
+}
+
+// add makes the release downloadable and its tracks known to Deezer.
+func (e *testEnv) add(source model.Source, id string, release FakeRelease) {
+	e.downloader.Add(source, id, release)
+	for _, track := range release.Tracks {
+		e.deezer.addNew(track.ISRC)
+	}
+}
+
+func (e *testEnv) cancelAll() {
+	jobs, err := e.streamrip.ListDownloads("")
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	for _, job := range jobs {
+		if err := e.streamrip.CancelDownload(job.ID, ""); err == nil {
+			e.wait(job.ID)
+		}
+	}
+	e.waitIdle()
+}
+
+// waitIdle waits for the workers to be done with every job. A job reaches
+// its final status a little before its worker is done with it.
+func (e *testEnv) waitIdle() {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.runningJobs() > 0 {
+		if time.Now().After(deadline) {
+			e.t.Fatal("jobs still running")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (e *testEnv) runningJobs() int {
+	e.streamrip.jobsMu.Lock()
+	defer e.streamrip.jobsMu.Unlock()
+	return len(e.streamrip.jobs)
+}
+
+// setConfig changes a config variable for the length of the test.
+func setConfig[T any](t *testing.T, v *T, value T) {
+	old := *v
+	*v = value
+	t.Cleanup(func() { *v = old })
+}
+
+func openTestDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	migrations, err := sql.Open("sqlite3", "file:"+path+"?_fk=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver, err := sqlite.WithInstance(migrations, &sqlite.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatalf("running migrations: %v", err)
+	}
+	// The index of the library search needs FTS5
+	available, err := LibrarySearchAvailable(context.Background(), migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available {
+		if err := searchMigrations(t, migrations).Up(); err != nil {
+			t.Fatalf("running search migrations: %v", err)
+		}
+	}
+	migrations.Close()
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_fk=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// searchMigrations returns the migrations of the library search index,
+// which have their own versions.
+func searchMigrations(t *testing.T, conn *sql.DB) *migrate.Migrate {
+	t.Helper()
+	driver, err := sqlite.WithInstance(conn, &sqlite.Config{MigrationsTable: "search_schema_migrations"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations/search", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// fakeDeezer answers the track by ISRC and album requests of the Deezer
+// API for the tracks added to it, and the artist and album requests for
+// the artists and albums added to it.
+type fakeDeezer struct {
+	mu      sync.Mutex
+	tracks  map[string]int
+	artists map[string]fakeArtist
+	albums  map[string]fakeAlbum
+}
+
+type fakeArtist struct {
+	name   string
+	albums []deezerAlbum
+}
+
+type fakeAlbum struct {
+	artistID int64
+	tracks   []FakeTrack
+}
+
+func newFakeDeezer() *fakeDeezer {
+	return &fakeDeezer{tracks: make(map[string]int), artists: make(map[string]fakeArtist), albums: make(map[string]fakeAlbum)}
+}
+
+// AddAlbum makes Deezer know the album, by the artist, and its tracks.
+func (d *fakeDeezer) AddAlbum(id string, artistID int64, tracks ...FakeTrack) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.albums[id] = fakeAlbum{artistID: artistID, tracks: tracks}
+}
+
+func (d *fakeDeezer) album(id string) (fakeAlbum, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	album, ok := d.albums[id]
+	return album, ok
+}
+
+// AddArtist makes Deezer know the artist and its releases.
+func (d *fakeDeezer) AddArtist(id, name string, albums ...deezerAlbum) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.artists[id] = fakeArtist{name: name, albums: albums}
+}
+
+func (d *fakeDeezer) artist(id string) (fakeArtist, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	artist, ok := d.artists[id]
+	return artist, ok
+}
+
+// Add makes Deezer know the track, with the given ID.
+func (d *fakeDeezer) Add(isrc string, id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tracks[isrc] = id
+}
+
+// addNew makes Deezer know the track with the next free ID, unless it
+// already does.
+func (d *fakeDeezer) addNew(isrc string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.tracks[isrc]; !ok {
+		d.tracks[isrc] = len(d.tracks) + 1
+	}
+}
+
+func (d *fakeDeezer) trackID(isrc string) (int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id, ok := d.tracks[isrc]
+	return id, ok
+}
+
+func (d *fakeDeezer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/track/isrc:"):
+		id, ok := d.trackID(strings.TrimPrefix(r.URL.Path, "/track/isrc:"))
+		if !ok {
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]any{"type": "DataException", "message": "no data", "code": 800},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      id,
+			"preview": fmt.Sprintf("https://cdn.example/%d.mp3", id),
+			"artist":  map[string]any{"id": 1000},
+			"album":   map[string]any{"id": 2000},
+		})
+	case strings.HasPrefix(r.URL.Path, "/album/"):
+		id, tracks := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/album/"), "/tracks")
+		album, ok := d.album(id)
+		switch {
+		case !ok:
+			json.NewEncoder(w).Encode(map[string]any{"nb_tracks": 12})
+		case tracks:
+			data := make([]map[string]any, 0, len(album.tracks))
+			for _, track := range album.tracks {
+				data = append(data, map[string]any{"title": track.Title, "isrc": track.ISRC})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": data, "total": len(data)})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{
+				"id":        json.Number(id),
+				"artist":    map[string]any{"id": album.artistID},
+				"nb_tracks": len(album.tracks),
+			})
+		}
+	case strings.HasPrefix(r.URL.Path, "/artist/"):
+		id, albums := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/artist/"), "/albums")
+		artist, ok := d.artist(id)
+		switch {
+		case !ok:
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]any{"type": "DataException", "message": "no data", "code": 800},
+			})
+		case albums:
+			json.NewEncoder(w).Encode(map[string]any{"data": artist.albums, "total": len(artist.albums)})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"id": json.Number(id), "name": artist.name})
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ensure asks for the track for the user in the given quality.
+func (e *testEnv) ensure(user, songID string, track FakeTrack, quality model.Quality) *model.DownloadResult {
+	e.t.Helper()
+	return e.ensureWithFallback(model.SourceQobuz, "", user, songID, track, quality)
+}
+
+func (e *testEnv) ensureWithFallback(source, fallback model.Source, user, songID string, track FakeTrack, quality model.Quality) *model.DownloadResult {
+	e.t.Helper()
+	result, err := e.streamrip.EnsureTrackForUser(context.Background(), source, fallback, songID, user, track.ISRC, quality)
+	if err != nil {
+		e.t.Fatalf("EnsureTrackForUser: %v", err)
+	}
+	return result
+}
+
+// wait returns the job once it reaches a final status.
+func (e *testEnv) wait(id string) model.DownloadJob {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := e.streamrip.GetDownloadStatus(id)
+		if err != nil {
+			e.t.Fatalf("GetDownloadStatus: %v", err)
+		}
+		switch job.Status {
+		case model.StatusSuccess, model.StatusFailed, model.StatusCanceled, model.StatusSkipped:
+			return job
+		}
+		if time.Now().After(deadline) {
+			e.t.Fatalf("download %s still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitStatus waits until the job is in the given status.
+func (e *testEnv) waitStatus(id string, status model.DownloadStatus) {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := e.streamrip.GetDownloadStatus(id)
+		if err != nil {
+			e.t.Fatalf("GetDownloadStatus: %v", err)
+		}
+		if job.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			e.t.Fatalf("download %s is %s, never reached %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (e *testEnv) libraryTrack(isrc string) (db.Track, bool) {
+	e.t.Helper()
+	track, err := e.queries.SearchTracksByISRC(context.Background(), sql.NullString{String: isrc, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Track{}, false
+	}
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return track, true
+}
+
+func (e *testEnv) mustTrack(isrc string) db.Track {
+	e.t.Helper()
+	track, ok := e.libraryTrack(isrc)
+	if !ok {
+		e.t.Fatalf("track %s not in the library", isrc)
+	}
+	return track
+}
+
+func (e *testEnv) isLinked(user, isrc string) bool {
+	e.t.Helper()
+	linked, err := e.queries.IsTrackLinkedToUserByUsernameAndISRC(context.Background(), db.IsTrackLinkedToUserByUsernameAndISRCParams{
+		Username: user,
+		Isrc:     sql.NullString{String: isrc, Valid: true},
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return linked == 1
+}
+
+// assertNoStaging checks no job left anything staged.
+func (e *testEnv) assertNoStaging() {
+	e.t.Helper()
+	e.waitIdle()
+	entries, err := os.ReadDir(stagingRoot())
+	if err != nil && !os.IsNotExist(err) {
+		e.t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		e.t.Errorf("staging folder not empty: %v", entries)
+	}
+}
+
+func TestEnsureTrackForUserDownloadsIndexesAndLinks(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+
+	result := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	if result.Action != model.ActionQueued {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionQueued)
+	}
+	job := env.wait(result.ID)
+	if job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if job.Quality == nil || *job.Quality != model.QualityHiRes {
+		t.Errorf("quality = %v, want %s", job.Quality, model.QualityHiRes)
+	}
+
+	track, ok := env.libraryTrack(callMe.ISRC)
+	if !ok {
+		t.Fatal("track not in the library")
+	}
+	wantPath := filepath.Join(config.SanchoPath, "library", "Blondie", "Parallel Lines", "02. Call Me - Blondie.flac")
+	if track.FilePath != wantPath {
+		t.Errorf("file path = %s, want %s", track.FilePath, wantPath)
+	}
+	if _, err := os.Stat(wantPath); err != nil {
+		t.Errorf("library file: %v", err)
+	}
+
+	link := filepath.Join(config.SanchoPath, "alice_library", "Blondie", "Parallel Lines", "02. Call Me - Blondie.flac")
+	if target, err := filepath.EvalSymlinks(link); err != nil || target != wantPath {
+		t.Errorf("user link points to %q (%v), want %s", target, err, wantPath)
+	}
+	if !env.isLinked("alice", callMe.ISRC) {
+		t.Error("track not linked to alice")
+	}
+
+	requests := env.downloader.Requests()
+	if len(requests) != 1 || requests[0].Quality != model.QualityHiRes {
+		t.Errorf("requests = %+v, want one in %s", requests, model.QualityHiRes)
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserLinksTracksInTheLibrary(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+
+	result := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	if result.Action != model.ActionLinked {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionLinked)
+	}
+	if !env.isLinked("bob", callMe.ISRC) {
+		t.Error("track not linked to bob")
+	}
+
+	result = env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	if result.Action != model.ActionNoop {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionNoop)
+	}
+	if n := len(env.downloader.Requests()); n != 1 {
+		t.Errorf("the track was downloaded %d times, want 1", n)
+	}
+}
+
+func TestDeleteTrackForUserKeepsItsDownloads(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	track := env.mustTrack(callMe.ISRC)
+
+	if err := env.streamrip.fileManager.DeleteTrackForUser(context.Background(), "alice", track.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := env.libraryTrack(callMe.ISRC); ok {
+		t.Error("the track stayed in the library")
+	}
+	history, err := env.queries.GetDownloadJobByID(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.DownloadHistory.TrackID.Valid {
+		t.Errorf("the download still points to track %d", history.DownloadHistory.TrackID.Int64)
+	}
+}
+
+func TestEnsureTrackForUserRecordsLowerQuality(t *testing.T) {
+	env := newTestEnv(t)
+	cd := callMe
+	cd.BitDepth, cd.SampleRate = 16, 44100
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{cd}})
+
+	job := env.wait(env.ensure("alice", "q1", cd, model.QualityHiResMax).ID)
+	if job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if job.Quality == nil || *job.Quality != model.QualityCD {
+		t.Errorf("quality = %v, want %s", job.Quality, model.QualityCD)
+	}
+	if job.RequestedQuality == nil || *job.RequestedQuality != model.QualityHiResMax {
+		t.Errorf("requested quality = %v, want %s", job.RequestedQuality, model.QualityHiResMax)
+	}
+}
+
+func TestEnsureTrackForUserRetriesTemporaryErrors(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{
+		Tracks: []FakeTrack{callMe},
+		Errors: []error{errors.New("rip error: connection reset by peer")},
+	})
+
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if job.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", job.Attempts)
+	}
+	if n := len(env.downloader.Requests()); n != 2 {
+		t.Errorf("downloader called %d times, want 2", n)
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserGivesUpAfterMaxAttempts(t *testing.T) {
+	env := newTestEnv(t)
+	timeout := errors.New("rip error: read timed out")
+	env.add(model.SourceQobuz, "q1", FakeRelease{
+		Tracks: []FakeTrack{callMe},
+		Errors: []error{timeout, timeout, timeout},
+	})
+
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusFailed {
+		t.Fatalf("status = %s, want failed", job.Status)
+	}
+	if n := len(env.downloader.Requests()); n != config.DownloadMaxAttempts {
+		t.Errorf("downloader called %d times, want %d", n, config.DownloadMaxAttempts)
+	}
+}
+
+func TestEnsureTrackForUserFailsOnPermanentErrors(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{
+		Tracks: []FakeTrack{callMe},
+		Errors: []error{errors.New("rip error: this track is not streamable")},
+	})
+
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusFailed {
+		t.Fatalf("status = %s, want failed", job.Status)
+	}
+	if !strings.Contains(job.Error, "not streamable") {
+		t.Errorf("error = %q, want the downloader's error", job.Error)
+	}
+	if n := len(env.downloader.Requests()); n != 1 {
+		t.Errorf("downloader called %d times, want 1", n)
+	}
+	if _, ok := env.libraryTrack(callMe.ISRC); ok {
+		t.Error("a failed download left a track in the library")
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserFallsBack(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{
+		Tracks: []FakeTrack{callMe},
+		Errors: []error{errors.New("rip error: track not available in your region")},
+	})
+	env.deezer.Add(callMe.ISRC, 3135556)
+	cd := callMe
+	cd.BitDepth, cd.SampleRate = 16, 44100
+	env.add(model.SourceDeezer, "3135556", FakeRelease{Tracks: []FakeTrack{cd}})
+
+	job := env.wait(env.ensureWithFallback(model.SourceQobuz, model.SourceDeezer, "alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if job.Service != string(model.SourceDeezer) || job.SourceTrackID != "3135556" {
+		t.Errorf("downloaded from %s %s, want deezer 3135556", job.Service, job.SourceTrackID)
+	}
+	requests := env.downloader.Requests()
+	if last := requests[len(requests)-1]; last.Quality != model.QualityCD {
+		t.Errorf("fallback quality = %s, want it clamped to %s", last.Quality, model.QualityCD)
+	}
+}
+
+func TestEnsureTrackForUserQuarantinesBadOutput(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Corrupt: true})
+
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusFailed {
+		t.Fatalf("status = %s, want failed", job.Status)
+	}
+	if _, ok := env.libraryTrack(callMe.ISRC); ok {
+		t.Error("a corrupt file was indexed")
+	}
+	kept := filepath.Join(jobQuarantineDir(job.ID), "Blondie - Call Me.flac")
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("corrupt file not quarantined: %v", err)
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserFailsWhenTheFileIsMissing(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Missing: true})
+
+	job := env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	if job.Status != model.StatusFailed || !strings.Contains(job.Error, "file not found") {
+		t.Fatalf("status = %s (%s), want failed with file not found", job.Status, job.Error)
+	}
+	if env.isLinked("alice", callMe.ISRC) {
+		t.Error("a missing file was linked")
+	}
+}
+
+func TestEnsureTrackForUserUpgradesTheLibrarysCopy(t *testing.T) {
+	env := newTestEnv(t)
+	cd := callMe
+	cd.BitDepth, cd.SampleRate = 16, 44100
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{cd}})
+	env.wait(env.ensure("alice", "q1", cd, model.QualityCD).ID)
+	env.ensure("bob", "q1", cd, model.QualityCD)
+	before := env.mustTrack(callMe.ISRC)
+
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	result := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	if result.Action != model.ActionUpgrade {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionUpgrade)
+	}
+	job := env.wait(result.ID)
+	if job.Status != model.StatusSuccess {
+		t.Fatalf("status = %s (%s), want success", job.Status, job.Error)
+	}
+	if job.Quality == nil || *job.Quality != model.QualityHiRes {
+		t.Errorf("quality = %v, want %s", job.Quality, model.QualityHiRes)
+	}
+
+	after := env.mustTrack(callMe.ISRC)
+	if after.ID != before.ID {
+		t.Fatalf("track %d replaced by %d, want the same track", before.ID, after.ID)
+	}
+	if after.FilePath != before.FilePath {
+		t.Errorf("file path = %s, want %s", after.FilePath, before.FilePath)
+	}
+	if after.SampleRate.Int64 != 96000 {
+		t.Errorf("sample rate = %d, want 96000", after.SampleRate.Int64)
+	}
+	if q := fileQuality(after.FilePath); q == nil || *q != model.QualityHiRes {
+		t.Errorf("library file quality = %v, want %s", q, model.QualityHiRes)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if !env.isLinked(user, callMe.ISRC) {
+			t.Errorf("track not linked to %s anymore", user)
+		}
+		link := filepath.Join(config.SanchoPath, user+"_library", "Blondie", "Parallel Lines", "02. Call Me - Blondie.flac")
+		if target, err := filepath.EvalSymlinks(link); err != nil || target != after.FilePath {
+			t.Errorf("%s's link points to %q (%v), want %s", user, target, err, after.FilePath)
+		}
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserTriesEachUpgradeOnce(t *testing.T) {
+	env := newTestEnv(t)
+	cd := callMe
+	cd.BitDepth, cd.SampleRate = 16, 44100
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{cd}})
+	env.wait(env.ensure("alice", "q1", cd, model.QualityCD).ID)
+
+	result := env.ensure("alice", "q1", cd, model.QualityHiRes)
+	if result.Action != model.ActionUpgrade {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionUpgrade)
+	}
+	if job := env.wait(result.ID); job.Status != model.StatusSkipped {
+		t.Fatalf("status = %s (%s), want skipped", job.Status, job.Error)
+	}
+	if q := fileQuality(env.mustTrack(cd.ISRC).FilePath); q == nil || *q != model.QualityCD {
+		t.Errorf("library file quality = %v, want %s", q, model.QualityCD)
+	}
+
+	result = env.ensure("alice", "q1", cd, model.QualityHiRes)
+	if result.Action != model.ActionNoop {
+		t.Fatalf("action = %s, want %s", result.Action, model.ActionNoop)
+	}
+	if n := len(env.downloader.Requests()); n != 2 {
+		t.Errorf("the track was downloaded %d times, want 2", n)
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureTrackForUserSharesDownloadsInFlight(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Delay: 200 * time.Millisecond})
+
+	first := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	second := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	if second.Action != model.ActionQueued || second.ID == first.ID {
+		t.Fatalf("second request = %+v, want another queued download", second)
+	}
+
+	for _, id := range []string{first.ID, second.ID} {
+		job := env.wait(id)
+		if job.Status != model.StatusSuccess {
+			t.Fatalf("download %s of %s is %s (%s), want success", id, job.User, job.Status, job.Error)
+		}
+		if job.TrackID == nil || job.Quality == nil || *job.Quality != model.QualityHiRes {
+			t.Errorf("download of %s: track %v in %v, want the track in %s", job.User, job.TrackID, job.Quality, model.QualityHiRes)
+		}
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if !env.isLinked(user, callMe.ISRC) {
+			t.Errorf("track not linked to %s", user)
+		}
+	}
+	if n := len(env.downloader.Requests()); n != 1 {
+		t.Errorf("the track was downloaded %d times, want 1", n)
+	}
+}
+
+func TestCancelDownloadOfAnAttachedJob(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Delay: 200 * time.Millisecond})
+
+	first := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	second := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	if err := env.streamrip.CancelDownload(second.ID, "bob"); err != nil {
+		t.Fatalf("CancelDownload: %v", err)
+	}
+
+	if job := env.wait(second.ID); job.Status != model.StatusCanceled {
+		t.Errorf("attached download is %s, want canceled", job.Status)
+	}
+	if job := env.wait(first.ID); job.Status != model.StatusSuccess {
+		t.Errorf("download is %s (%s), want success", job.Status, job.Error)
+	}
+	if env.isLinked("bob", callMe.ISRC) {
+		t.Error("track linked to bob after the cancel")
+	}
+}
+
+func TestCancelDownloadFailsTheAttachedJobs(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Delay: time.Minute})
+
+	first := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	second := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	env.waitStatus(second.ID, model.StatusDownloading)
+	if err := env.streamrip.CancelDownload(first.ID, "alice"); err != nil {
+		t.Fatalf("CancelDownload: %v", err)
+	}
+
+	if job := env.wait(first.ID); job.Status != model.StatusCanceled {
+		t.Errorf("download is %s, want canceled", job.Status)
+	}
+	if job := env.wait(second.ID); job.Status != model.StatusFailed {
+		t.Errorf("attached download is %s, want failed", job.Status)
+	}
+	env.assertNoStaging()
+}
+
+func TestCancelDownloadOfAnotherUser(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Delay: time.Minute})
+
+	first := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	second := env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	env.waitStatus(second.ID, model.StatusDownloading)
+	// Bob's job is attached to alice's, but only alice can stop the download
+	if err := env.streamrip.CancelDownload(first.ID, "bob"); !errors.Is(err, model.ErrDownloadNotFound) {
+		t.Fatalf("bob canceling alice's download: %v, want %v", err, model.ErrDownloadNotFound)
+	}
+	if err := env.streamrip.CancelDownload("missing", "bob"); !errors.Is(err, model.ErrDownloadNotFound) {
+		t.Errorf("canceling a missing download: %v, want %v", err, model.ErrDownloadNotFound)
+	}
+	if job, err := env.streamrip.GetDownloadStatus(first.ID); err != nil || job.Status != model.StatusDownloading {
+		t.Errorf("alice's download is %s (%v), want downloading", job.Status, err)
+	}
+	env.cancelAll()
+}
+
+func TestCancelDownloadStopsSlowDownloads(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Delay: time.Minute})
+
+	result := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	env.waitStatus(result.ID, model.StatusDownloading)
+	if err := env.streamrip.CancelDownload(result.ID, "alice"); err != nil {
+		t.Fatalf("CancelDownload: %v", err)
+	}
+
+	job := env.wait(result.ID)
+	if job.Status != model.StatusCanceled {
+		t.Fatalf("status = %s, want canceled", job.Status)
+	}
+	if _, ok := env.libraryTrack(callMe.ISRC); ok {
+		t.Error("a canceled download was indexed")
+	}
+	env.assertNoStaging()
+}
+
+func TestEnsureAlbumForUserLinksEveryTrack(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	env.add(model.SourceQobuz, "a1", FakeRelease{Tracks: []FakeTrack{callMe, heartOfGlass}})
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+
+	result, err := env.streamrip.EnsureAlbumForUser(context.Background(), model.SourceQobuz, "a1", "alice", model.QualityHiRes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := env.wait(result.ID)
+	if job.Status != model.StatusSuccess || job.MediaType != model.MediaAlbum {
+		t.Fatalf("album download is %s %s (%s), want a successful album", job.MediaType, job.Status, job.Error)
+	}
+	if job.Quality == nil || *job.Quality != model.QualityHiRes {
+		t.Errorf("album quality = %v, want %s", job.Quality, model.QualityHiRes)
+	}
+
+	// Alice already had Call Me, only Heart of Glass is new to her
+	statuses := make(map[string]model.DownloadStatus)
+	for _, track := range job.Tracks {
+		if track.ParentID != job.ID {
+			t.Errorf("track %s belongs to %q, want the album", track.ISRC, track.ParentID)
+		}
+		statuses[track.ISRC] = track.Status
+	}
+	if len(job.Tracks) != 2 || statuses[callMe.ISRC] != model.StatusSkipped || statuses[heartOfGlass.ISRC] != model.StatusSuccess {
+		t.Errorf("album tracks = %v, want Call Me skipped and Heart of Glass added", statuses)
+	}
+	if !env.isLinked("alice", heartOfGlass.ISRC) {
+		t.Error("Heart of Glass not linked to alice")
+	}
+	if track := env.mustTrack(callMe.ISRC); track.FilePath != filepath.Join(config.LibraryPath, "Blondie", "Parallel Lines", "02. Call Me - Blondie.flac") {
+		t.Errorf("the album replaced the library's Call Me with %s", track.FilePath)
+	}
+	if requests := env.downloader.Requests(); len(requests) != 2 || requests[1].MediaType != model.MediaAlbum {
+		t.Errorf("requests = %+v, want the track and then the album", requests)
+	}
+	env.assertNoStaging()
+}
+
+func TestRepairDownloadsRequeuesTheFailedOnes(t *testing.T) {
+	env := newTestEnv(t)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}, Missing: true})
+	env.add(model.SourceQobuz, "q2", FakeRelease{Tracks: []FakeTrack{heartOfGlass}, Delay: time.Minute})
+	ctx := context.Background()
+
+	failed := env.ensure("alice", "q1", callMe, model.QualityHiRes)
+	if job := env.wait(failed.ID); job.Status != model.StatusFailed {
+		t.Fatalf("download with a missing file is %s, want failed", job.Status)
+	}
+	stuck := env.ensure("alice", "q2", heartOfGlass, model.QualityHiRes)
+	env.waitStatus(stuck.ID, model.StatusDownloading)
+
+	// Only bob's downloads are repaired, he has none
+	result, err := env.streamrip.RepairDownloads(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Requeued) != 0 || len(result.Skipped) != 0 {
+		t.Fatalf("repair of bob = %+v, want nothing", result)
+	}
+
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	result, err = env.streamrip.RepairDownloads(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Requeued) != 1 || result.Requeued[0] != failed.ID || len(result.Skipped) != 0 {
+		t.Fatalf("repair = %+v, want only the failed download requeued", result)
+	}
+	job := env.wait(failed.ID)
+	if job.Status != model.StatusSuccess || !env.isLinked("alice", callMe.ISRC) {
+		t.Fatalf("repaired download is %s (%s), want Call Me in alice's library", job.Status, job.Error)
+	}
+	if job.Attempts != 1 {
+		t.Errorf("repaired download is on attempt %d, want 1", job.Attempts)
+	}
+
+	// The download still running was left alone
+	if job, err := env.streamrip.GetDownloadStatus(stuck.ID); err != nil || job.Status != model.StatusDownloading {
+		t.Errorf("running download is %s (%v), want still downloading", job.Status, err)
+	}
+	n := 0
+	for _, req := range env.downloader.Requests() {
+		if req.SourceID == "q2" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("the running download was started %d times, want 1", n)
+	}
+
+	// Once repaired there is nothing left to do
+	result, err = env.streamrip.RepairDownloads(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Requeued) != 0 {
+		t.Errorf("second repair requeued %v", result.Requeued)
+	}
+	env.cancelAll()
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
 	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
 )
 
@@ -21,14 +22,16 @@ type ThumbnailGenerationTracker struct {
 }
 
 type ThumbnailService struct {
-	queries *db.Queries
-	tracker *ThumbnailGenerationTracker
+	queries  *db.Queries
+	tracker  *ThumbnailGenerationTracker
+	webhooks *WebhookDispatcher
 }
 
-func NewThumbnailService(queries *db.Queries) *ThumbnailService {
+func NewThumbnailService(queries *db.Queries, webhooks *WebhookDispatcher) *ThumbnailService {
 	return &ThumbnailService{
-		queries: queries,
-		tracker: &ThumbnailGenerationTracker{},
+		queries:  queries,
+		tracker:  &ThumbnailGenerationTracker{},
+		webhooks: webhooks,
 	}
 }
 
@@ -55,7 +58,13 @@ func (s *ThumbnailService) GenerateAlbumThumbnails() {
 		defer func() {
 			s.tracker.Lock()
 			s.tracker.IsRunning = false
+			result := model.WebhookThumbnailsData{
+				Total:     s.tracker.Total,
+				Processed: s.tracker.Processed,
+				Error:     s.tracker.Error,
+			}
 			s.tracker.Unlock()
+			s.webhooks.Emit(model.WebhookThumbnailsFinished, result)
 		}()
 
 		ctx := context.Background()
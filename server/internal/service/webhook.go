@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+	db "github.com/alejandro-bustamante/sancho/server/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Deliveries listed by default, newest first
+const webhookDeliveriesLimit = 50
+
+// WebhookDispatcher sends the events of the server to the configured
+// webhooks. Every request is signed with the secret of the webhook, and
+// every delivery is saved and retried with backoff until it succeeds or
+// runs out of attempts, resuming after a restart.
+type WebhookDispatcher struct {
+	queries *db.Queries
+	client  *http.Client
+	// Running deliveries
+	wg sync.WaitGroup
+}
+
+func NewWebhookDispatcher(queries *db.Queries) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		queries: queries,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Emit sends the event to every webhook subscribed to its type. The
+// deliveries run in the background, Emit never fails the caller.
+func (w *WebhookDispatcher) Emit(eventType model.WebhookEventType, data any) {
+	ctx := context.Background()
+	webhooks, err := w.queries.ListWebhooks(ctx)
+	if err != nil {
+		log.Printf("Error listing webhooks for a %s event: %v", eventType, err)
+		return
+	}
+	var subscribed []db.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Events == "" || slices.Contains(strings.Split(webhook.Events, ","), string(eventType)) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+
+	event := newWebhookEvent(eventType, data)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding the %s event: %v", eventType, err)
+		return
+	}
+	for _, webhook := range subscribed {
+		delivery, err := w.queries.InsertWebhookDelivery(ctx, db.InsertWebhookDeliveryParams{
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: string(eventType),
+			Payload:   string(payload),
+		})
+		if err != nil {
+			log.Printf("Error saving the delivery of event %s to webhook %d: %v", event.ID, webhook.ID, err)
+			continue
+		}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.deliver(webhook, delivery)
+		}()
+	}
+}
+
+// ResumePending picks up the deliveries that were being retried when the
+// server stopped.
+func (w *WebhookDispatcher) ResumePending(ctx context.Context) error {
+	ctx = context.Background()
+	rows, err := w.queries.ListPendingWebhookDeliveries(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing pending webhook deliveries: %w", err)
+	}
+	for _, row := range rows {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.deliver(row.Webhook, row.WebhookDelivery)
+		}()
+	}
+	if len(rows) > 0 {
+		log.Printf("Resumed %d webhook deliveries", len(rows))
+	}
+	return nil
+}
+
+// deliver sends the delivery until the webhook accepts it or the attempts
+// run out, waiting longer after each failure.
+func (w *WebhookDispatcher) deliver(webhook db.Webhook, delivery db.WebhookDelivery) {
+	for attempt := delivery.Attempts + 1; ; attempt++ {
+		responseStatus, err := w.send(webhook, delivery)
+		status := model.DeliverySuccess
+		switch {
+		case err == nil:
+			log.Printf("Delivered event %s (%s) to webhook %d", delivery.EventID, delivery.EventType, webhook.ID)
+		case attempt >= int64(config.WebhookMaxAttempts):
+			status = model.DeliveryFailed
+			log.Printf("Giving up on event %s to webhook %d after %d attempts: %v", delivery.EventID, webhook.ID, attempt, err)
+		default:
+			status = model.DeliveryPending
+			log.Printf("Attempt %d of event %s to webhook %d failed: %v", attempt, delivery.EventID, webhook.ID, err)
+		}
+
+		updated, updateErr := w.saveAttempt(delivery.ID, status, attempt, responseStatus, err)
+		if updateErr != nil {
+			log.Printf("Error saving webhook delivery %d: %v", delivery.ID, updateErr)
+		} else {
+			delivery = updated
+		}
+		if status != model.DeliveryPending {
+			return
+		}
+		time.Sleep(backoff(config.WebhookRetryDelay, attempt+1))
+		if !w.stillPending(webhook.ID, delivery.ID) {
+			log.Printf("Stopped retrying event %s, webhook %d was deleted", delivery.EventID, webhook.ID)
+			return
+		}
+	}
+}
+
+// stillPending tells whether the delivery is still to be retried: its
+// webhook wasn't deleted in the meantime. When the database can't tell,
+// the delivery goes on.
+func (w *WebhookDispatcher) stillPending(webhookID, deliveryID int64) bool {
+	ctx := context.Background()
+	if _, err := w.queries.GetWebhook(ctx, webhookID); errors.Is(err, sql.ErrNoRows) {
+		return false
+	} else if err != nil {
+		log.Printf("Error checking webhook %d: %v", webhookID, err)
+		return true
+	}
+	delivery, err := w.queries.GetWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	} else if err != nil {
+		log.Printf("Error checking webhook delivery %d: %v", deliveryID, err)
+		return true
+	}
+	return delivery.Status == string(model.DeliveryPending)
+}
+
+// send makes one attempt of the delivery. Any answer but a 2xx is a
+// failure. responseStatus is 0 when there was no answer.
+func (w *WebhookDispatcher) send(webhook db.Webhook, delivery db.WebhookDelivery) (responseStatus int, err error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating the request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sancho-Webhook")
+	req.Header.Set("X-Sancho-Event", delivery.EventType)
+	req.Header.Set("X-Sancho-Delivery", delivery.EventID)
+	req.Header.Set("X-Sancho-Signature", signWebhookPayload(webhook.Secret, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *WebhookDispatcher) saveAttempt(id int64, status model.WebhookDeliveryStatus, attempt int64, responseStatus int, err error) (db.WebhookDelivery, error) {
+	params := db.UpdateWebhookDeliveryParams{
+		ID:             id,
+		Status:         string(status),
+		Attempts:       attempt,
+		ResponseStatus: sql.NullInt64{Int64: int64(responseStatus), Valid: responseStatus != 0},
+	}
+	if err != nil {
+		params.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
+	}
+	return w.queries.UpdateWebhookDelivery(context.Background(), params)
+}
+
+// signWebhookPayload is the value of X-Sancho-Signature: the hex encoded
+// HMAC-SHA256 of the body with the secret of the webhook.
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newWebhookEvent(eventType model.WebhookEventType, data any) model.WebhookEvent {
+	return model.WebhookEvent{
+		ID:   uuid.New().String(),
+		Type: eventType,
+		Time: time.Now().UTC().Format(time.RFC3339),
+		Data: data,
+	}
+}
+
+// CreateWebhook adds a webhook for the given events, every event when
+// there are none. Without a secret a random one is generated; it is only
+// returned here.
+func (w *WebhookDispatcher) CreateWebhook(ctx context.Context, rawURL string, events []model.WebhookEventType, secret string) (model.Webhook, error) {
+	ctx = context.Background()
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return model.Webhook{}, fmt.Errorf("%w: the URL must be an absolute http or https URL", model.ErrInvalidWebhook)
+	}
+	names := make([]string, 0, len(events))
+	for _, event := range events {
+		if !slices.Contains(model.WebhookEventTypes, event) {
+			return model.Webhook{}, fmt.Errorf("%w: unknown event %q", model.ErrInvalidWebhook, event)
+		}
+		if !slices.Contains(names, string(event)) {
+			names = append(names, string(event))
+		}
+	}
+	if secret == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return model.Webhook{}, fmt.Errorf("error generating the secret: %w", err)
+		}
+		secret = hex.EncodeToString(key)
+	}
+
+	row, err := w.queries.InsertWebhook(ctx, db.InsertWebhookParams{
+		Url:    rawURL,
+		Secret: secret,
+		Events: strings.Join(names, ","),
+	})
+	if err != nil {
+		return model.Webhook{}, fmt.Errorf("error saving the webhook: %w", err)
+	}
+	log.Printf("Added webhook %d for %s", row.ID, rawURL)
+	webhook := model.WebhookFromDB(row)
+	webhook.Secret = row.Secret
+	return webhook, nil
+}
+
+func (w *WebhookDispatcher) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	ctx = context.Background()
+	rows, err := w.queries.ListWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the webhooks: %w", err)
+	}
+	webhooks := make([]model.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, model.WebhookFromDB(row))
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook and its deliveries. Those running
+// make no more attempts.
+func (w *WebhookDispatcher) DeleteWebhook(ctx context.Context, id int64) error {
+	ctx = context.Background()
+	n, err := w.queries.DeleteWebhook(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error deleting the webhook: %w", err)
+	}
+	if n == 0 {
+		return model.ErrWebhookNotFound
+	}
+	log.Printf("Deleted webhook %d", id)
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of the webhook, newest
+// first.
+func (w *WebhookDispatcher) ListDeliveries(ctx context.Context, id int64) ([]model.WebhookDelivery, error) {
+	ctx = context.Background()
+	if _, err := w.getWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := w.queries.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		WebhookID: id,
+		Limit:     webhookDeliveriesLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the deliveries of webhook %d: %w", id, err)
+	}
+	deliveries := make([]model.WebhookDelivery, 0, len(rows))
+	for _, row := range rows {
+		deliveries = append(deliveries, model.WebhookDeliveryFromDB(row))
+	}
+	return deliveries, nil
+}
+
+// SendTest sends a test event to the webhook, whatever its events, and
+// waits for the answer. It is attempted once: the returned delivery tells
+// whether it worked.
+func (w *WebhookDispatcher) SendTest(ctx context.Context, id int64) (model.WebhookDelivery, error) {
+	ctx = context.Background()
+	webhook, err := w.getWebhook(ctx, id)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	event := newWebhookEvent(model.WebhookTest, map[string]string{
+		"message": "This is a test event sent from Sancho",
+	})
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("error encoding the test event: %w", err)
+	}
+	delivery, err := w.queries.InsertWebhookDelivery(ctx, db.InsertWebhookDeliveryParams{
+		WebhookID: id,
+		EventID:   event.ID,
+		EventType: string(event.Type),
+		Payload:   string(payload),
+	})
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("error saving the test delivery: %w", err)
+	}
+
+	responseStatus, sendErr := w.send(webhook, delivery)
+	status := model.DeliverySuccess
+	if sendErr != nil {
+		status = model.DeliveryFailed
+		log.Printf("Test event to webhook %d failed: %v", id, sendErr)
+	}
+	delivery, err = w.saveAttempt(delivery.ID, status, 1, responseStatus, sendErr)
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("error saving the test delivery: %w", err)
+	}
+	return model.WebhookDeliveryFromDB(delivery), nil
+}
+
+func (w *WebhookDispatcher) getWebhook(ctx context.Context, id int64) (db.Webhook, error) {
+	webhook, err := w.queries.GetWebhook(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Webhook{}, model.ErrWebhookNotFound
+	}
+	if err != nil {
+		return db.Webhook{}, fmt.Errorf("error getting webhook %d: %w", id, err)
+	}
+	return webhook, nil
+}
+
+// wait blocks until the running deliveries finish.
+func (w *WebhookDispatcher) wait() {
+	w.wg.Wait()
+}
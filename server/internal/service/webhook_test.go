@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alejandro-bustamante/sancho/server/internal/config"
+	model "github.com/alejandro-bustamante/sancho/server/internal/model"
+)
+
+// webhookReceiver records the events it gets, failing the first request
+// to make the dispatcher retry.
+type webhookReceiver struct {
+	t      *testing.T
+	secret string
+	mu     sync.Mutex
+	calls  int
+	events []model.WebhookEvent
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if got, want := req.Header.Get("X-Sancho-Signature"), signWebhookPayload(r.secret, body); got != want {
+		r.t.Errorf("signature = %q, want %q", got, want)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var event model.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Errorf("decoding the event: %v", err)
+	}
+	r.events = append(r.events, event)
+}
+
+func TestWebhooksGetSignedEventsOfDownloads(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.WebhookRetryDelay, 10*time.Millisecond)
+	env.add(model.SourceQobuz, "q1", FakeRelease{Tracks: []FakeTrack{callMe}})
+	ctx := context.Background()
+
+	receiver := &webhookReceiver{t: t, secret: "s3cret"}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	events := []model.WebhookEventType{model.WebhookDownloadSucceeded, model.WebhookTrackLinked}
+	webhook, err := env.webhooks.CreateWebhook(ctx, server.URL, events, receiver.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.wait(env.ensure("alice", "q1", callMe, model.QualityHiRes).ID)
+	// Bob gets the song linked, which isn't a download
+	env.ensure("bob", "q1", callMe, model.QualityHiRes)
+	env.waitIdle()
+	env.webhooks.wait()
+
+	counts := make(map[model.WebhookEventType]int)
+	for _, event := range receiver.events {
+		counts[event.Type]++
+	}
+	if counts[model.WebhookDownloadSucceeded] != 1 || counts[model.WebhookTrackLinked] != 2 || len(receiver.events) != 3 {
+		t.Errorf("received %v, want 1 download.succeeded and 2 track.linked", counts)
+	}
+
+	deliveries, err := env.webhooks.ListDeliveries(ctx, webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts int64
+	for _, delivery := range deliveries {
+		if delivery.Status != model.DeliverySuccess {
+			t.Errorf("delivery %d of %s is %s", delivery.ID, delivery.EventType, delivery.Status)
+		}
+		attempts += delivery.Attempts
+	}
+	if len(deliveries) != 3 || attempts != 4 {
+		t.Errorf("%d deliveries in %d attempts, want 3 in 4", len(deliveries), attempts)
+	}
+
+	test, err := env.webhooks.SendTest(ctx, webhook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.Status != model.DeliverySuccess || test.EventType != model.WebhookTest {
+		t.Errorf("test delivery = %+v, want a successful test event", test)
+	}
+}
+
+func TestDeletedWebhooksStopRetrying(t *testing.T) {
+	env := newTestEnv(t)
+	setConfig(t, &config.WebhookRetryDelay, 100*time.Millisecond)
+	setConfig(t, &config.WebhookMaxAttempts, 5)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	first := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(first)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	webhook, err := env.webhooks.CreateWebhook(ctx, server.URL, nil, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.webhooks.Emit(model.WebhookTest, map[string]string{"message": "retry me"})
+	<-first
+	// Deleted while the delivery waits for its second attempt
+	if err := env.webhooks.DeleteWebhook(ctx, webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	env.webhooks.wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("the deleted webhook got %d requests, want 1", n)
+	}
+	var deliveries int
+	if err := env.conn.QueryRow(`SELECT COUNT(*) FROM webhook_delivery`).Scan(&deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries != 0 {
+		t.Errorf("%d deliveries outlived their webhook", deliveries)
+	}
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_download_history_status;
+
+ALTER TABLE download_history DROP COLUMN isrc;
+ALTER TABLE download_history DROP COLUMN source_track_id;
@@ -0,0 +1,5 @@
+-- Columnas para poder reanudar un trabajo de descarga tras un reinicio
+ALTER TABLE download_history ADD COLUMN source_track_id TEXT; -- id de la canción en el servicio (qobuz, etc.)
+ALTER TABLE download_history ADD COLUMN isrc TEXT;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
@@ -0,0 +1,30 @@
+CREATE TABLE download_history_old (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER,
+    track_id INTEGER,
+    quality INTEGER CHECK(quality IN (0, 1, 2, 3)),
+    status TEXT CHECK(status IN ('success', 'downloading', 'indexing', 'failed', 'canceled', 'transfered')),
+    service TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    error_message TEXT,
+    source_track_id TEXT,
+    isrc TEXT,
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (track_id) REFERENCES track(id)
+);
+
+INSERT INTO download_history_old (
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+)
+SELECT
+    id, user_id, track_id, quality,
+    CASE status WHEN 'queued' THEN 'canceled' ELSE status END,
+    service, started_at, completed_at, error_message, source_track_id, isrc
+FROM download_history;
+
+DROP TABLE download_history;
+ALTER TABLE download_history_old RENAME TO download_history;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
@@ -0,0 +1,31 @@
+-- Se agrega el estado 'queued' para las descargas que esperan un worker libre.
+-- SQLite no permite modificar un CHECK, así que se reconstruye la tabla.
+CREATE TABLE download_history_new (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER,
+    track_id INTEGER,
+    quality INTEGER CHECK(quality IN (0, 1, 2, 3)), -- quality of the file (determined by bit depth and sample rate)
+    status TEXT CHECK(status IN ('success', 'queued', 'downloading', 'indexing', 'failed', 'canceled', 'transfered')),
+    service TEXT, -- qobuz, tidal, etc.
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    error_message TEXT,
+    source_track_id TEXT, -- id de la canción en el servicio (qobuz, etc.)
+    isrc TEXT,
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (track_id) REFERENCES track(id)
+);
+
+INSERT INTO download_history_new (
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+)
+SELECT
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+FROM download_history;
+
+DROP TABLE download_history;
+ALTER TABLE download_history_new RENAME TO download_history;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
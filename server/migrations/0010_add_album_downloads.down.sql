@@ -0,0 +1,31 @@
+CREATE TABLE download_history_old (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER,
+    track_id INTEGER,
+    quality INTEGER CHECK(quality IN (0, 1, 2, 3)),
+    status TEXT CHECK(status IN ('success', 'queued', 'downloading', 'indexing', 'failed', 'canceled', 'transfered')),
+    service TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    error_message TEXT,
+    source_track_id TEXT,
+    isrc TEXT,
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (track_id) REFERENCES track(id)
+);
+
+-- Las descargas de álbumes no tienen representación en el esquema anterior
+INSERT INTO download_history_old (
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+)
+SELECT
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+FROM download_history
+WHERE media_type = 'track' AND parent_id IS NULL;
+
+DROP TABLE download_history;
+ALTER TABLE download_history_old RENAME TO download_history;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
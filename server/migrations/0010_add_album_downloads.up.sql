@@ -0,0 +1,36 @@
+-- Descargas de álbumes completos. El álbum es una fila con media_type 'album'
+-- y cada canción que produjo es una fila hija (parent_id) con su propio estado.
+-- 'skipped' marca las canciones del álbum que el usuario ya tenía.
+CREATE TABLE download_history_new (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER,
+    track_id INTEGER,
+    quality INTEGER CHECK(quality IN (0, 1, 2, 3)), -- quality of the file (determined by bit depth and sample rate)
+    status TEXT CHECK(status IN ('success', 'queued', 'downloading', 'indexing', 'failed', 'canceled', 'skipped', 'transfered')),
+    service TEXT, -- qobuz, tidal, etc.
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    error_message TEXT,
+    source_track_id TEXT, -- id de la canción (o del álbum) en el servicio
+    isrc TEXT,
+    media_type TEXT NOT NULL DEFAULT 'track' CHECK(media_type IN ('track', 'album')),
+    parent_id TEXT, -- descarga del álbum al que pertenece la canción
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (track_id) REFERENCES track(id),
+    FOREIGN KEY (parent_id) REFERENCES download_history(id) ON DELETE CASCADE
+);
+
+INSERT INTO download_history_new (
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+)
+SELECT
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc
+FROM download_history;
+
+DROP TABLE download_history;
+ALTER TABLE download_history_new RENAME TO download_history;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
+CREATE INDEX idx_download_history_parent_id ON download_history(parent_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS playlist_import_entry;
+DROP TABLE IF EXISTS playlist_import;
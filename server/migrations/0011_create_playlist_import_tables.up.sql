@@ -0,0 +1,31 @@
+-- Importaciones de playlists (M3U, CSV o JSON) y el resultado de buscar
+-- cada una de sus canciones en la biblioteca o en Qobuz
+CREATE TABLE playlist_import (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    format TEXT NOT NULL CHECK(format IN ('m3u', 'csv', 'json')),
+    quality INTEGER NOT NULL, -- calidad de las descargas que genera la importación
+    status TEXT NOT NULL CHECK(status IN ('processing', 'done')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE playlist_import_entry (
+    id INTEGER PRIMARY KEY,
+    import_id TEXT NOT NULL,
+    position INTEGER NOT NULL, -- posición en el archivo original
+    title TEXT NOT NULL,
+    artist TEXT,
+    album TEXT,
+    isrc TEXT,
+    result TEXT NOT NULL CHECK(result IN ('matched', 'queued', 'ambiguous', 'not_found')),
+    track_id INTEGER, -- canción de la biblioteca, si ya estaba
+    download_id TEXT, -- descarga creada para la canción
+    candidates TEXT, -- JSON con las opciones de Qobuz cuando es ambigua
+    FOREIGN KEY (import_id) REFERENCES playlist_import(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES track(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_playlist_import_user_id ON playlist_import(user_id);
+CREATE INDEX idx_playlist_import_entry_import_id ON playlist_import_entry(import_id);
@@ -0,0 +1,3 @@
+-- Servicio al que recurrir cuando el principal no tiene la canción.
+-- Si se usa, service pasa a ser el de respaldo y esta columna guarda el pedido.
+ALTER TABLE download_history ADD COLUMN fallback_service TEXT;
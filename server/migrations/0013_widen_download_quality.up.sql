@@ -0,0 +1,43 @@
+-- La calidad usa la escala común a todos los servicios (ver model.Quality),
+-- de 0 (con pérdida) a 4 (24 bit hasta 192 kHz). Qobuz llega hasta 4, que
+-- el CHECK anterior rechazaba.
+-- quality pasa a ser la calidad real del archivo descargado y
+-- requested_quality la que se pidió.
+CREATE TABLE download_history_new (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER,
+    track_id INTEGER,
+    quality INTEGER CHECK(quality BETWEEN 0 AND 4), -- quality of the file (determined by bit depth and sample rate)
+    status TEXT CHECK(status IN ('success', 'queued', 'downloading', 'indexing', 'failed', 'canceled', 'skipped', 'transfered')),
+    service TEXT, -- qobuz, tidal, etc.
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    error_message TEXT,
+    source_track_id TEXT, -- id de la canción (o del álbum) en el servicio
+    isrc TEXT,
+    media_type TEXT NOT NULL DEFAULT 'track' CHECK(media_type IN ('track', 'album')),
+    parent_id TEXT, -- descarga del álbum al que pertenece la canción
+    fallback_service TEXT,
+    requested_quality INTEGER CHECK(requested_quality BETWEEN 0 AND 4),
+    FOREIGN KEY (user_id) REFERENCES user(id),
+    FOREIGN KEY (track_id) REFERENCES track(id),
+    FOREIGN KEY (parent_id) REFERENCES download_history(id) ON DELETE CASCADE
+);
+
+-- Hasta ahora solo se guardaba la calidad pedida
+INSERT INTO download_history_new (
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc,
+    media_type, parent_id, fallback_service, requested_quality
+)
+SELECT
+    id, user_id, track_id, quality, status, service,
+    started_at, completed_at, error_message, source_track_id, isrc,
+    media_type, parent_id, fallback_service, quality
+FROM download_history;
+
+DROP TABLE download_history;
+ALTER TABLE download_history_new RENAME TO download_history;
+
+CREATE INDEX idx_download_history_status ON download_history(status);
+CREATE INDEX idx_download_history_parent_id ON download_history(parent_id);
@@ -0,0 +1,2 @@
+ALTER TABLE download_history DROP COLUMN next_attempt_at;
+ALTER TABLE download_history DROP COLUMN attempts;
@@ -0,0 +1,4 @@
+-- Reintentos automáticos: attempts es el número del intento en curso, o del
+-- siguiente mientras el trabajo espera en estado 'queued' hasta next_attempt_at.
+ALTER TABLE download_history ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE download_history ADD COLUMN next_attempt_at TIMESTAMP;
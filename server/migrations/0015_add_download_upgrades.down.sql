@@ -0,0 +1 @@
+ALTER TABLE download_history DROP COLUMN upgrade;
@@ -0,0 +1,3 @@
+-- Descargas que reemplazan el archivo de una canción que ya está en la
+-- biblioteca por una copia de mejor calidad.
+ALTER TABLE download_history ADD COLUMN upgrade BOOLEAN NOT NULL DEFAULT 0;
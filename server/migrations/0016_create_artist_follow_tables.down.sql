@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_artist_follow_deezer_artist_id;
+DROP TABLE IF EXISTS release_notification;
+DROP TABLE IF EXISTS artist_follow;
@@ -0,0 +1,33 @@
+-- Artistas que sigue cada usuario, por su id en Deezer, y qué hacer con
+-- sus nuevos lanzamientos
+CREATE TABLE artist_follow (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    deezer_artist_id TEXT NOT NULL,
+    artist_name TEXT NOT NULL,
+    release_types TEXT NOT NULL, -- tipos de lanzamiento que interesan, separados por comas
+    auto_download BOOLEAN NOT NULL DEFAULT 0, -- descargar los nuevos lanzamientos sin preguntar
+    quality INTEGER NOT NULL, -- calidad de esas descargas
+    last_checked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (user_id, deezer_artist_id),
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+-- Avisos de nuevos lanzamientos de los artistas seguidos
+CREATE TABLE release_notification (
+    id INTEGER PRIMARY KEY,
+    follow_id INTEGER NOT NULL,
+    deezer_album_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    release_type TEXT NOT NULL CHECK(release_type IN ('album', 'ep', 'single', 'live', 'compilation')),
+    release_date TEXT, -- día del lanzamiento según Deezer (YYYY-MM-DD)
+    cover_url TEXT,
+    download_id TEXT, -- descarga creada automáticamente, si la hubo
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (follow_id, deezer_album_id),
+    FOREIGN KEY (follow_id) REFERENCES artist_follow(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_artist_follow_deezer_artist_id ON artist_follow(deezer_artist_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_download_request_pending;
+DROP INDEX IF EXISTS idx_download_request_status;
+DROP TABLE IF EXISTS download_request;
@@ -0,0 +1,27 @@
+-- Descargas pedidas por usuarios que no son admins mientras
+-- SANCHO_DOWNLOAD_APPROVAL está activo. Esperan a que un admin las apruebe
+-- o las rechace.
+CREATE TABLE download_request (
+    id TEXT PRIMARY KEY, -- UUID
+    user_id INTEGER NOT NULL,
+    media_type TEXT NOT NULL CHECK(media_type IN ('track', 'album')),
+    source TEXT NOT NULL,
+    fallback TEXT,
+    source_id TEXT NOT NULL, -- id de la canción o del álbum en source
+    isrc TEXT,
+    quality INTEGER NOT NULL CHECK(quality BETWEEN 0 AND 4),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'approved', 'rejected', 'canceled')),
+    reason TEXT, -- motivo del rechazo
+    reviewed_by TEXT, -- admin que la aprobó o rechazó, o el usuario si la canceló
+    reviewed_at TIMESTAMP,
+    download_id TEXT, -- descarga creada al aprobarla
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_download_request_status ON download_request(status, created_at);
+
+-- Un usuario no puede pedir dos veces lo mismo mientras espera
+CREATE UNIQUE INDEX idx_download_request_pending
+    ON download_request(user_id, media_type, source, source_id)
+    WHERE status = 'pending';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_download_history_track_id;
+DROP INDEX IF EXISTS idx_download_history_user_started;
+ALTER TABLE download_history DROP COLUMN linked;
+DROP TABLE IF EXISTS user_quota;
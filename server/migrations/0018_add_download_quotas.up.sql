@@ -0,0 +1,30 @@
+-- Cuotas de descarga de cada usuario. Un NULL toma el valor por defecto
+-- del servidor y un 0 quita el límite.
+CREATE TABLE user_quota (
+    user_id INTEGER PRIMARY KEY,
+    daily_downloads INTEGER CHECK(daily_downloads >= 0), -- descargas en las últimas 24 horas
+    weekly_downloads INTEGER CHECK(weekly_downloads >= 0), -- descargas en los últimos 7 días
+    storage_bytes INTEGER CHECK(storage_bytes >= 0), -- bytes de las canciones que el usuario trajo primero
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+-- Las filas que solo enlazaron una canción que ya estaba en la biblioteca
+-- no cuentan como descargas.
+ALTER TABLE download_history ADD COLUMN linked BOOLEAN NOT NULL DEFAULT 0;
+
+-- Hasta ahora no se distinguían: son las que terminaron bien después de
+-- otra que ya había traído la misma canción
+UPDATE download_history SET linked = 1
+WHERE status = 'success' AND parent_id IS NULL AND upgrade = 0 AND track_id IS NOT NULL
+  AND EXISTS (
+    SELECT 1 FROM download_history AS earlier
+    WHERE earlier.track_id = download_history.track_id
+      AND earlier.status = 'success'
+      AND earlier.started_at < download_history.started_at
+  );
+
+-- Para contar las descargas recientes de un usuario y encontrar quién trajo
+-- primero cada canción
+CREATE INDEX idx_download_history_user_started ON download_history(user_id, started_at);
+CREATE INDEX idx_download_history_track_id ON download_history(track_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webhook_delivery_status;
+DROP INDEX IF EXISTS idx_webhook_delivery_webhook_id;
+DROP TABLE IF EXISTS webhook_delivery;
+DROP TABLE IF EXISTS webhook;
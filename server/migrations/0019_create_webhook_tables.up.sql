@@ -0,0 +1,28 @@
+-- URLs que reciben los eventos de descargas y de la biblioteca, firmados
+-- con su secreto
+CREATE TABLE webhook (
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL, -- clave del HMAC-SHA256 con el que se firma cada envío
+    events TEXT NOT NULL DEFAULT '', -- tipos de evento separados por comas, vacío para todos
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- Cada envío de un evento a un webhook, con sus reintentos
+CREATE TABLE webhook_delivery (
+    id INTEGER PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event_id TEXT NOT NULL, -- UUID del evento, el mismo para todos sus webhooks
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL, -- JSON enviado, para poder reintentarlo tras un reinicio
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'success', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER, -- código HTTP de la última respuesta
+    error_message TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at TIMESTAMP,
+    FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_delivery_webhook_id ON webhook_delivery(webhook_id, created_at);
+CREATE INDEX idx_webhook_delivery_status ON webhook_delivery(status);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_download_batch_item_batch_id;
+DROP INDEX IF EXISTS idx_download_batch_user_id;
+DROP TABLE IF EXISTS download_batch_item;
+DROP TABLE IF EXISTS download_batch;
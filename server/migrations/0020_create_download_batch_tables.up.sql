@@ -0,0 +1,28 @@
+-- Descargas de muchas canciones pedidas de una vez. Cada canción es un
+-- elemento con el resultado de pedirla; su estado es el de la descarga
+-- (o la solicitud de aprobación) que generó.
+CREATE TABLE download_batch (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    source TEXT NOT NULL,
+    fallback TEXT,
+    quality INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE download_batch_item (
+    id INTEGER PRIMARY KEY,
+    batch_id TEXT NOT NULL,
+    position INTEGER NOT NULL, -- posición en la lista pedida
+    source_id TEXT, -- vacío cuando solo se dio el ISRC
+    isrc TEXT NOT NULL,
+    action TEXT, -- lo que se hizo con la canción, NULL si falló al pedirla
+    download_id TEXT, -- descarga creada para la canción
+    request_id TEXT, -- solicitud creada cuando la descarga necesita aprobación
+    error_message TEXT,
+    FOREIGN KEY (batch_id) REFERENCES download_batch(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_download_batch_user_id ON download_batch(user_id);
+CREATE INDEX idx_download_batch_item_batch_id ON download_batch_item(batch_id, position);
@@ -0,0 +1,12 @@
+-- Consultas de la librería guardadas con un nombre por cada usuario, en el
+-- lenguaje de consultas (p. ej. artist:"boards of canada" year:>=1998)
+CREATE TABLE saved_query (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE,
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,30 @@
+-- Las canciones fallidas vuelven a ser ambiguas, como antes
+CREATE TABLE playlist_import_entry_old (
+    id INTEGER PRIMARY KEY,
+    import_id TEXT NOT NULL,
+    position INTEGER NOT NULL, -- posición en el archivo original
+    title TEXT NOT NULL,
+    artist TEXT,
+    album TEXT,
+    isrc TEXT,
+    result TEXT NOT NULL CHECK(result IN ('matched', 'queued', 'ambiguous', 'not_found')),
+    track_id INTEGER, -- canción de la biblioteca, si ya estaba
+    download_id TEXT, -- descarga creada para la canción
+    candidates TEXT, -- JSON con las opciones de Qobuz cuando es ambigua
+    FOREIGN KEY (import_id) REFERENCES playlist_import(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES track(id) ON DELETE SET NULL
+);
+
+INSERT INTO playlist_import_entry_old (
+    id, import_id, position, title, artist, album, isrc,
+    result, track_id, download_id, candidates
+)
+SELECT
+    id, import_id, position, title, artist, album, isrc,
+    CASE WHEN result = 'failed' THEN 'ambiguous' ELSE result END, track_id, download_id, candidates
+FROM playlist_import_entry;
+
+DROP TABLE playlist_import_entry;
+ALTER TABLE playlist_import_entry_old RENAME TO playlist_import_entry;
+
+CREATE INDEX idx_playlist_import_entry_import_id ON playlist_import_entry(import_id);
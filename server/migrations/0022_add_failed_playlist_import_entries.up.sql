@@ -0,0 +1,33 @@
+-- Las canciones que no se pudieron añadir a la biblioteca del usuario (por
+-- ejemplo, por superar su cuota) quedan como 'failed', con el motivo en
+-- error_message. SQLite no permite cambiar el CHECK, así que se rehace la tabla.
+CREATE TABLE playlist_import_entry_new (
+    id INTEGER PRIMARY KEY,
+    import_id TEXT NOT NULL,
+    position INTEGER NOT NULL, -- posición en el archivo original
+    title TEXT NOT NULL,
+    artist TEXT,
+    album TEXT,
+    isrc TEXT,
+    result TEXT NOT NULL CHECK(result IN ('matched', 'queued', 'ambiguous', 'not_found', 'failed')),
+    track_id INTEGER, -- canción de la biblioteca, si ya estaba
+    download_id TEXT, -- descarga creada para la canción
+    candidates TEXT, -- JSON con las opciones de Qobuz cuando es ambigua
+    error_message TEXT, -- motivo por el que falló
+    FOREIGN KEY (import_id) REFERENCES playlist_import(id) ON DELETE CASCADE,
+    FOREIGN KEY (track_id) REFERENCES track(id) ON DELETE SET NULL
+);
+
+INSERT INTO playlist_import_entry_new (
+    id, import_id, position, title, artist, album, isrc,
+    result, track_id, download_id, candidates
+)
+SELECT
+    id, import_id, position, title, artist, album, isrc,
+    result, track_id, download_id, candidates
+FROM playlist_import_entry;
+
+DROP TABLE playlist_import_entry;
+ALTER TABLE playlist_import_entry_new RENAME TO playlist_import_entry;
+
+CREATE INDEX idx_playlist_import_entry_import_id ON playlist_import_entry(import_id);
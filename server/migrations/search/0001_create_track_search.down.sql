@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS track_search_track_insert;
+DROP TRIGGER IF EXISTS track_search_track_update;
+DROP TRIGGER IF EXISTS track_search_track_delete;
+DROP TRIGGER IF EXISTS track_search_artist_insert;
+DROP TRIGGER IF EXISTS track_search_artist_update;
+DROP TRIGGER IF EXISTS track_search_artist_delete;
+DROP TRIGGER IF EXISTS track_search_album_insert;
+DROP TRIGGER IF EXISTS track_search_album_update;
+DROP TRIGGER IF EXISTS track_search_album_delete;
+DROP TABLE IF EXISTS track_search;
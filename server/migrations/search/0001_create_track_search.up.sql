@@ -0,0 +1,124 @@
+-- Índice de texto completo de la búsqueda en la librería. Usa FTS5, que
+-- SQLite solo incluye si el servidor se compila con la etiqueta sqlite_fts5,
+-- por eso estas migraciones van aparte y solo se aplican cuando está.
+
+-- Las versiones anteriores creaban el índice desde el código, se rehace
+DROP TRIGGER IF EXISTS track_search_track_insert;
+DROP TRIGGER IF EXISTS track_search_track_update;
+DROP TRIGGER IF EXISTS track_search_track_delete;
+DROP TRIGGER IF EXISTS track_search_artist_insert;
+DROP TRIGGER IF EXISTS track_search_artist_update;
+DROP TRIGGER IF EXISTS track_search_artist_delete;
+DROP TRIGGER IF EXISTS track_search_album_insert;
+DROP TRIGGER IF EXISTS track_search_album_update;
+DROP TRIGGER IF EXISTS track_search_album_delete;
+DROP TABLE IF EXISTS track_search;
+
+-- remove_diacritics permite que "beyonce" encuentre "Beyoncé".
+-- normalized repite el título, el artista y el álbum normalizados (ver
+-- NormalizeText) para que la puntuación de un nombre no estorbe.
+CREATE VIRTUAL TABLE track_search USING fts5(
+    title, artist, album, composer, normalized,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- Los triggers mantienen el índice al día con las tablas de las que toma
+-- el texto. Cambiar un artista o un álbum reindexa sus canciones.
+CREATE TRIGGER track_search_track_insert AFTER INSERT ON track BEGIN
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.id = new.id;
+END;
+
+CREATE TRIGGER track_search_track_update AFTER UPDATE ON track BEGIN
+    DELETE FROM track_search WHERE rowid = old.id;
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.id = new.id;
+END;
+
+CREATE TRIGGER track_search_track_delete AFTER DELETE ON track BEGIN
+    DELETE FROM track_search WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER track_search_artist_insert AFTER INSERT ON artist BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.artist_id = new.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.artist_id = new.id;
+END;
+
+CREATE TRIGGER track_search_artist_update AFTER UPDATE ON artist BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.artist_id = new.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.artist_id = new.id;
+END;
+
+CREATE TRIGGER track_search_artist_delete AFTER DELETE ON artist BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.artist_id = old.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.artist_id = old.id;
+END;
+
+CREATE TRIGGER track_search_album_insert AFTER INSERT ON album BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.album_id = new.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.album_id = new.id;
+END;
+
+CREATE TRIGGER track_search_album_update AFTER UPDATE ON album BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.album_id = new.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.album_id = new.id;
+END;
+
+CREATE TRIGGER track_search_album_delete AFTER DELETE ON album BEGIN
+    DELETE FROM track_search WHERE rowid IN (SELECT t.id FROM track AS t WHERE t.album_id = old.id);
+    INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+    SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+        t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+    FROM track AS t
+    LEFT JOIN artist AS ar ON ar.id = t.artist_id
+    LEFT JOIN album AS al ON al.id = t.album_id
+    WHERE t.album_id = old.id;
+END;
+
+-- Las canciones que ya estaban en la librería
+INSERT INTO track_search (rowid, title, artist, album, composer, normalized)
+SELECT t.id, t.title, COALESCE(ar.name, ''), COALESCE(al.title, ''), COALESCE(t.composer, ''),
+    t.normalized_title || ' ' || COALESCE(ar.normalized_name, '') || ' ' || COALESCE(al.normalized_title, '')
+FROM track AS t
+LEFT JOIN artist AS ar ON ar.id = t.artist_id
+LEFT JOIN album AS al ON al.id = t.album_id;